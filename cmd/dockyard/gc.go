@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/utils"
+)
+
+// collectGarbage runs a single garbage collection and prints what was removed:
+//
+//	dockyard -config config.yml gc [-dry-run] [-grace-period 24h]
+func collectGarbage(dp *ioc.DependencyProvider, arguments []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Only report what would be removed.")
+	gracePeriod := flags.Duration("grace-period", config.C.Gc.GracePeriod, "Blobs that were uploaded or linked within the grace period are kept.")
+	// invalid arguments exit the application
+	_ = flags.Parse(arguments)
+
+	scope := dp.NewScope()
+	defer utils.PanicOnError(scope.Close, "closing scope")
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+
+	mediator := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.CollectGarbageResponse](ctx, mediator, commands.CollectGarbage{
+		GracePeriod: *gracePeriod,
+		DryRun:      *dryRun,
+	})
+	if err != nil {
+		logging.Logger.Panicf("garbage collection failed: %s", err)
+	}
+
+	if response.Skipped {
+		logging.Logger.Warnf("another garbage collection is running")
+		return
+	}
+
+	if *dryRun {
+		fmt.Println("dry run, nothing was removed")
+	}
+
+	fmt.Printf("repository blob links: %d\n", response.DeletedRepositoryBlobs)
+	fmt.Printf("blobs: %d (%d bytes)\n", response.DeletedBlobs, response.BytesFreed)
+	if response.FailedBlobs > 0 {
+		fmt.Printf("failed blobs: %d, they are retried by the next collection\n", response.FailedBlobs)
+	}
+}
@@ -55,10 +55,23 @@ func main() {
 	setup.Kv(dc, config.C.Kv)
 	setup.Mediator(dc)
 	setup.Blob(dc, config.C.Blob)
+	setup.RegistryClient(dc)
 	setup.Kms(dc, config.C.Kms)
 
 	dp := dc.BuildProvider()
 
+	switch args.Command() {
+	case "":
+		break
+
+	case "gc":
+		collectGarbage(dp, args.CommandArgs())
+		return
+
+	default:
+		panic(fmt.Errorf("unknown command: %s", args.Command()))
+	}
+
 	var hostBlobApi bool
 	switch config.C.Blob.Mode {
 	case config.BlobStorageModeInMemory:
@@ -76,6 +89,10 @@ func main() {
 
 	initApp(dp)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	setup.Jobs(jobsCtx, dp, config.C.Gc)
+
 	server.Serve(dp, config.C.Server, hostBlobApi)
 	waitForExit()
 }
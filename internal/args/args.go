@@ -13,6 +13,20 @@ func IsProduction() bool {
 	return environment == "PRODUCTION"
 }
 
+// Command returns the subcommand the application was started with, the server is started if it is empty.
+func Command() string {
+	return flag.Arg(0)
+}
+
+// CommandArgs returns the arguments following the subcommand.
+func CommandArgs() []string {
+	if flag.NArg() == 0 {
+		return nil
+	}
+
+	return flag.Args()[1:]
+}
+
 func Init() {
 	flag.StringVar(&configFilePath, "config", "", "The path for the config file.")
 	flag.StringVar(&environment, "environment", "PRODUCTION", "The environment that this application is running in (can be PRODUCTION or DEVELOPMENT).")
@@ -51,3 +51,8 @@ func (t *Tracker) Add(entry *Entry) {
 func (t *Tracker) GetChanges() []*Entry {
 	return t.entries
 }
+
+// Clear removes all tracked entries, it is called once the changes have been persisted.
+func (t *Tracker) Clear() {
+	t.entries = []*Entry{}
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+)
+
+// AbortExpiredUploads removes the partially uploaded data of upload sessions that expired before they were completed.
+type AbortExpiredUploads struct {
+	Limit int
+}
+
+type AbortExpiredUploadsResponse struct {
+	Aborted int
+	// Failed counts the sessions that could not be aborted, they are retried by the next run.
+	Failed int
+}
+
+func HandleAbortExpiredUploads(ctx context.Context, command AbortExpiredUploads) (*AbortExpiredUploadsResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+
+	filter := repositories.NewUploadSessionFilter().
+		ByExpiredBefore(clockService.Now()).
+		WithLimit(command.Limit)
+
+	uploadSessions, _, err := dbContext.UploadSessions().List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("listing expired upload sessions: %w", err)
+	}
+
+	response := &AbortExpiredUploadsResponse{}
+	for _, uploadSession := range uploadSessions {
+		aborted, err := blobService.AbortExpiredUploadSession(ctx, uploadSession.GetSessionId())
+		if err != nil {
+			logging.Logger.Errorf("aborting expired upload session %s: %v", uploadSession.GetSessionId(), err)
+			response.Failed++
+			continue
+		}
+
+		if aborted {
+			response.Aborted++
+		}
+	}
+
+	return response, nil
+}
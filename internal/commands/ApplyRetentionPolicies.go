@@ -0,0 +1,418 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// untaggedGracePeriod protects manifests that were pushed by digest only moments ago, e.g. the children of an image
+// index whose push has not finished yet.
+const untaggedGracePeriod = time.Hour
+
+// ApplyRetentionPolicies applies the enabled retention policies to a repository, or to every repository of the project
+// if RepositorySlug is not set. With DryRun nothing is deleted and the response reports what would have been deleted.
+type ApplyRetentionPolicies struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+	DryRun         bool
+}
+
+type ApplyRetentionPoliciesResponse struct {
+	DryRun bool
+	// Items contains the repositories something was deleted from.
+	Items []ApplyRetentionPoliciesResponseItem
+	// ReclaimableBytes is the sum of the ReclaimableBytes of the items.
+	ReclaimableBytes int64
+}
+
+type ApplyRetentionPoliciesResponseItem struct {
+	RepositorySlug   string
+	DeletedTags      []string
+	DeletedManifests []string
+	// ReclaimableBytes is the size of the manifests and blobs no remaining manifest of the repository refers to anymore
+	// and no other repository is linked to. Their data is removed by the next garbage collection, blobs that are still
+	// linked to other repositories are kept.
+	ReclaimableBytes int64
+}
+
+func HandleApplyRetentionPolicies(ctx context.Context, command ApplyRetentionPolicies) (*ApplyRetentionPoliciesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	projectId, repositoryId, err := getOwner(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	repositoryFilter := repositories.NewRepositoryFilter().ByProjectId(projectId)
+	if repositoryId != nil {
+		repositoryFilter = repositoryFilter.ById(*repositoryId)
+	}
+
+	repositoryList, _, err := dbContext.Repositories().List(ctx, repositoryFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	now := clockService.Now()
+	response := &ApplyRetentionPoliciesResponse{
+		DryRun: command.DryRun,
+		Items:  make([]ApplyRetentionPoliciesResponseItem, 0),
+	}
+
+	for _, repository := range repositoryList {
+		plan, err := applyRetention(ctx, dbContext, repository, now, command.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		if plan.isEmpty() {
+			continue
+		}
+
+		item := ApplyRetentionPoliciesResponseItem{
+			RepositorySlug:   repository.GetSlug(),
+			DeletedTags:      make([]string, len(plan.tags)),
+			DeletedManifests: make([]string, len(plan.manifests)),
+			ReclaimableBytes: plan.reclaimableBytes,
+		}
+
+		for i, tag := range plan.tags {
+			item.DeletedTags[i] = tag.GetName()
+		}
+
+		for i, manifest := range plan.manifests {
+			item.DeletedManifests[i] = manifest.GetDigest()
+		}
+
+		response.Items = append(response.Items, item)
+		response.ReclaimableBytes += plan.reclaimableBytes
+	}
+
+	return response, nil
+}
+
+// applyRetention plans the retention of the repository and applies the plan unless dryRun is set. The repository is
+// locked like the scheduled retention does, so that both never delete from it at the same time.
+func applyRetention(ctx context.Context, dbContext db.Context, repository *repositories.Repository, now time.Time, dryRun bool) (*retentionPlan, error) {
+	if dryRun {
+		return planRetention(ctx, dbContext, repository.GetId(), now)
+	}
+
+	kvStore := ioc.GetDependency[kv.Store](middlewares.GetScope(ctx))
+	unlock, locked, err := tryLock(ctx, kvStore, buildRetentionLockKey(repository.GetId()), retentionLockExpiration)
+	if err != nil {
+		return nil, fmt.Errorf("locking repository retention: %w", err)
+	}
+	if !locked {
+		return nil, fmt.Errorf("retention policies are already being applied to repository '%s': %w", repository.GetSlug(), apiError.ErrApiConflict)
+	}
+	defer unlock()
+
+	plan, err := planRetention(ctx, dbContext, repository.GetId(), now)
+	if err != nil {
+		return nil, err
+	}
+	if plan.isEmpty() {
+		return plan, nil
+	}
+
+	err = applyRetentionPlan(ctx, dbContext, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// retentionPlan is the outcome of the retention policies of a repository.
+type retentionPlan struct {
+	// tags contains every deleted tag, including the ones of deleted manifests.
+	tags      []*repositories.Tag
+	manifests []*repositories.Manifest
+
+	reclaimableBytes int64
+}
+
+func (p *retentionPlan) isEmpty() bool {
+	return len(p.tags) == 0 && len(p.manifests) == 0
+}
+
+// planRetention determines what the enabled retention policies of the repository and its project delete. A tag is
+// deleted if any policy selects it, unless it is protected by an immutable tag rule. Untagged manifests are only deleted
+// if a policy asks for it and no remaining manifest refers to them, either as a child or as the subject of a referrer.
+func planRetention(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, now time.Time) (*retentionPlan, error) {
+	policies, err := getRetentionPolicies(ctx, dbContext, repositoryId)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return &retentionPlan{}, nil
+	}
+
+	tags, _, err := dbContext.Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	tagRules, err := getTagRules(ctx, dbContext, repositoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	expiredTags := make(map[uuid.UUID]bool)
+	deleteUntagged := false
+	for _, policy := range policies {
+		deleteUntagged = deleteUntagged || policy.GetDeleteUntagged()
+
+		for _, tag := range selectExpiredTags(policy, tags, now) {
+			if !isImmutableTag(tagRules, tag.GetName()) {
+				expiredTags[tag.GetId()] = true
+			}
+		}
+	}
+
+	plan := &retentionPlan{}
+	keptManifests := make(map[uuid.UUID]bool)
+	for _, tag := range tags {
+		if expiredTags[tag.GetId()] {
+			plan.tags = append(plan.tags, tag)
+		} else {
+			keptManifests[tag.GetRepositoryManifestId()] = true
+		}
+	}
+
+	if !deleteUntagged {
+		return plan, nil
+	}
+
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	manifestReferences, _, err := dbContext.ManifestReferences().List(ctx, repositories.NewManifestReferenceFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing manifest references: %w", err)
+	}
+
+	referrers, _, err := dbContext.Referrers().List(ctx, repositories.NewReferrerFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	referencedDigests := make(map[uuid.UUID][]string)
+	for _, manifestReference := range manifestReferences {
+		referencedDigests[manifestReference.GetManifestId()] = append(referencedDigests[manifestReference.GetManifestId()], manifestReference.GetDigest())
+	}
+
+	subjectDigests := make(map[uuid.UUID]string)
+	for _, referrer := range referrers {
+		subjectDigests[referrer.GetManifestId()] = referrer.GetSubjectDigest()
+	}
+
+	for _, manifest := range manifests {
+		if now.Sub(manifest.GetCreatedAt()) < untaggedGracePeriod {
+			keptManifests[manifest.GetId()] = true
+		}
+	}
+
+	// children of kept manifests and referrers of kept subjects are kept as well, until nothing changes anymore
+	for changed := true; changed; {
+		changed = false
+
+		keptDigests := make(map[string]bool)
+		for _, manifest := range manifests {
+			if !keptManifests[manifest.GetId()] {
+				continue
+			}
+
+			keptDigests[manifest.GetDigest()] = true
+			for _, referencedDigest := range referencedDigests[manifest.GetId()] {
+				keptDigests[referencedDigest] = true
+			}
+		}
+
+		for _, manifest := range manifests {
+			if keptManifests[manifest.GetId()] {
+				continue
+			}
+
+			subjectDigest, isReferrer := subjectDigests[manifest.GetId()]
+			if keptDigests[manifest.GetDigest()] || (isReferrer && keptDigests[subjectDigest]) {
+				keptManifests[manifest.GetId()] = true
+				changed = true
+			}
+		}
+	}
+
+	remainingDigests := make(map[string]bool)
+	for _, manifest := range manifests {
+		if !keptManifests[manifest.GetId()] {
+			plan.manifests = append(plan.manifests, manifest)
+			continue
+		}
+
+		remainingDigests[manifest.GetDigest()] = true
+		for _, referencedDigest := range referencedDigests[manifest.GetId()] {
+			remainingDigests[referencedDigest] = true
+		}
+	}
+
+	unusedDigests := make(map[string]bool)
+	for _, manifest := range plan.manifests {
+		for _, unusedDigest := range append([]string{manifest.GetDigest()}, referencedDigests[manifest.GetId()]...) {
+			if remainingDigests[unusedDigest] || unusedDigests[unusedDigest] {
+				continue
+			}
+			unusedDigests[unusedDigest] = true
+
+			reclaimableBytes, err := getReclaimableBytes(ctx, dbContext, repositoryId, unusedDigest)
+			if err != nil {
+				return nil, err
+			}
+			plan.reclaimableBytes += reclaimableBytes
+		}
+	}
+
+	return plan, nil
+}
+
+// getReclaimableBytes returns the size of the blob if the garbage collection can remove it once the repository does not
+// use it anymore, i.e. if no other repository is linked to it.
+func getReclaimableBytes(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, digest string) (int64, error) {
+	blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ByDigest(digest))
+	if err != nil {
+		return 0, fmt.Errorf("getting blob: %w", err)
+	}
+	if blob == nil {
+		return 0, nil
+	}
+
+	repositoryBlobs, _, err := dbContext.RepositoryBlobs().List(ctx, repositories.NewRepositoryBlobFilter().ByBlobId(blob.GetId()))
+	if err != nil {
+		return 0, fmt.Errorf("listing repository blobs: %w", err)
+	}
+
+	for _, repositoryBlob := range repositoryBlobs {
+		if repositoryBlob.GetRepositoryId() != repositoryId {
+			return 0, nil
+		}
+	}
+
+	return blob.GetSize(), nil
+}
+
+// applyRetentionPlan deletes the tags and manifests of the plan. The blobs that are not used by any repository anymore
+// are removed by the garbage collection.
+func applyRetentionPlan(ctx context.Context, dbContext db.Context, plan *retentionPlan) error {
+	manifestTags := make(map[uuid.UUID][]*repositories.Tag)
+	for _, tag := range plan.tags {
+		manifestTags[tag.GetRepositoryManifestId()] = append(manifestTags[tag.GetRepositoryManifestId()], tag)
+	}
+
+	for _, manifest := range plan.manifests {
+		err := deleteManifest(ctx, dbContext, manifest, manifestTags[manifest.GetId()])
+		if err != nil {
+			return err
+		}
+
+		delete(manifestTags, manifest.GetId())
+	}
+
+	for _, tags := range manifestTags {
+		for _, tag := range tags {
+			dbContext.Tags().Delete(tag)
+		}
+	}
+
+	err := dbContext.SaveChanges(ctx)
+	if err != nil {
+		return fmt.Errorf("saving changes: %w", err)
+	}
+
+	return nil
+}
+
+// getRetentionPolicies returns the enabled retention policies of the repository together with the ones of its project.
+func getRetentionPolicies(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID) ([]*repositories.RetentionPolicy, error) {
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("getting repository: %w", err)
+	}
+
+	projectPolicies, _, err := dbContext.RetentionPolicies().List(ctx, repositories.NewRetentionPolicyFilter().ByProjectId(repository.GetProjectId()).ByEnabled(true))
+	if err != nil {
+		return nil, fmt.Errorf("listing project retention policies: %w", err)
+	}
+
+	repositoryPolicies, _, err := dbContext.RetentionPolicies().List(ctx, repositories.NewRetentionPolicyFilter().ByRepositoryId(repositoryId).ByEnabled(true))
+	if err != nil {
+		return nil, fmt.Errorf("listing repository retention policies: %w", err)
+	}
+
+	return append(projectPolicies, repositoryPolicies...), nil
+}
+
+// selectExpiredTags returns the tags the policy deletes: the ones matching the tag filter that are not among the
+// keepLast most recently pushed matching tags and fulfill every age criterion of the policy.
+func selectExpiredTags(policy *repositories.RetentionPolicy, tags []*repositories.Tag, now time.Time) []*repositories.Tag {
+	if !policy.HasTagCriteria() {
+		return nil
+	}
+
+	var matchingTags []*repositories.Tag
+	for _, tag := range tags {
+		if policy.MatchesTag(tag.GetName()) {
+			matchingTags = append(matchingTags, tag)
+		}
+	}
+
+	slices.SortFunc(matchingTags, func(a, b *repositories.Tag) int {
+		return b.GetUpdatedAt().Compare(a.GetUpdatedAt())
+	})
+
+	if keepLast := policy.GetKeepLast(); keepLast != nil {
+		if *keepLast >= len(matchingTags) {
+			return nil
+		}
+		matchingTags = matchingTags[*keepLast:]
+	}
+
+	var expiredTags []*repositories.Tag
+	for _, tag := range matchingTags {
+		if olderThanDays := policy.GetOlderThanDays(); olderThanDays != nil && tag.GetUpdatedAt().After(now.AddDate(0, 0, -*olderThanDays)) {
+			continue
+		}
+
+		if notPulledInDays := policy.GetNotPulledInDays(); notPulledInDays != nil && tag.GetLastUsedAt().After(now.AddDate(0, 0, -*notPulledInDays)) {
+			continue
+		}
+
+		expiredTags = append(expiredTags, tag)
+	}
+
+	return expiredTags
+}
+
+func isImmutableTag(tagRules []*repositories.TagRule, tagName string) bool {
+	for _, tagRule := range tagRules {
+		if tagRule.GetKind() == repositories.TagRuleKindImmutable && tagRule.Matches(tagName) {
+			return true
+		}
+	}
+
+	return false
+}
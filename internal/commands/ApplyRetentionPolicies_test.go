@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/database/inmemory"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+const (
+	retentionManifestSize  = 100
+	retentionLayerSize     = 1000
+	retentionBaseLayerSize = 10000
+)
+
+type PlanRetentionTestSuite struct {
+	suite.Suite
+}
+
+func TestPlanRetentionTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(PlanRetentionTestSuite))
+}
+
+// arrangeRepository creates a repository with the images v1 to v4, v1 was pushed 40 days before now and every
+// following one 10 days later. Every image has a layer of its own and shares a base layer with the others, the layer
+// of v1 is linked to another repository as well. It returns the db context and the id of the repository.
+func (s *PlanRetentionTestSuite) arrangeRepository(ctx context.Context, now time.Time, policy func(repositoryId uuid.UUID) *repositories.RetentionPolicy) (db.Context, uuid.UUID) {
+	database, err := inmemory.NewInMemoryDatabase()
+	s.Require().NoError(err)
+
+	dbContext, err := database.NewContext(ctx)
+	s.Require().NoError(err)
+
+	tenant := repositories.NewTenant("t", "t", repositories.NewTenantOidcConfig("client", "issuer", "roles", "array", nil))
+	dbContext.Tenants().Insert(tenant)
+
+	project := repositories.NewProject(tenant.GetId(), "p", "p")
+	dbContext.Projects().Insert(project)
+
+	repository := repositories.NewRepository(project.GetId(), "app", "app")
+	dbContext.Repositories().Insert(repository)
+
+	otherRepository := repositories.NewRepository(project.GetId(), "other", "other")
+	dbContext.Repositories().Insert(otherRepository)
+
+	dbContext.RetentionPolicies().Insert(policy(repository.GetId()))
+
+	insertBlob := func(blobDigest string, size int64) *repositories.Blob {
+		blob := repositories.NewBlob(blobDigest, size)
+		dbContext.Blobs().Insert(blob)
+		dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(repository.GetId(), blob.GetId()))
+		return blob
+	}
+
+	baseLayerDigest := digest.SHA256.FromBytes([]byte("base layer"))
+	insertBlob(baseLayerDigest, retentionBaseLayerSize)
+
+	for i := 1; i <= 4; i++ {
+		pushedAt := now.AddDate(0, 0, -50+i*10)
+
+		layerDigest := digest.SHA256.FromBytes([]byte(fmt.Sprintf("layer v%d", i)))
+		layer := insertBlob(layerDigest, retentionLayerSize)
+		if i == 1 {
+			dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(otherRepository.GetId(), layer.GetId()))
+		}
+
+		manifestDigest := digest.SHA256.FromBytes([]byte(fmt.Sprintf("manifest v%d", i)))
+		manifestBlob := insertBlob(manifestDigest, retentionManifestSize)
+
+		manifest := repositories.NewManifestFromDB(repository.GetId(), manifestBlob.GetId(), manifestDigest, "application/vnd.oci.image.manifest.v1+json", repositories.NewBaseModelFromDB(uuid.New(), pushedAt, pushedAt, nil))
+		dbContext.Manifests().Insert(manifest)
+		dbContext.ManifestReferences().Insert(repositories.NewManifestReference(repository.GetId(), manifest.GetId(), baseLayerDigest, "application/vnd.oci.image.layer.v1.tar"))
+		dbContext.ManifestReferences().Insert(repositories.NewManifestReference(repository.GetId(), manifest.GetId(), layerDigest, "application/vnd.oci.image.layer.v1.tar"))
+
+		dbContext.Tags().Insert(repositories.NewTagFromDB(repository.GetId(), manifest.GetId(), fmt.Sprintf("v%d", i), nil, nil, repositories.NewBaseModelFromDB(uuid.New(), pushedAt, pushedAt, nil)))
+	}
+
+	s.Require().NoError(dbContext.SaveChanges(ctx))
+
+	return dbContext, repository.GetId()
+}
+
+func (s *PlanRetentionTestSuite) TestPlanRetention() {
+	testCases := []struct {
+		name             string
+		keepLast         *int
+		olderThanDays    *int
+		deleteUntagged   bool
+		tags             []string
+		manifests        []string
+		reclaimableBytes int64
+	}{
+		{
+			name:     "keep last",
+			keepLast: pointer.To(3),
+			tags:     []string{"v1"},
+		},
+		{
+			name:           "keep last with untagged manifests",
+			keepLast:       pointer.To(2),
+			deleteUntagged: true,
+			tags:           []string{"v1", "v2"},
+			manifests:      []string{"v1", "v2"},
+			// the layer of v1 is linked to another repository and the base layer is used by v3 and v4
+			reclaimableBytes: 2*retentionManifestSize + retentionLayerSize,
+		},
+		{
+			name:     "keep last covers every tag",
+			keepLast: pointer.To(4),
+		},
+		{
+			name:          "max age",
+			olderThanDays: pointer.To(25),
+			tags:          []string{"v1", "v2"},
+		},
+		{
+			name:          "max age and keep last",
+			keepLast:      pointer.To(3),
+			olderThanDays: pointer.To(25),
+			tags:          []string{"v1"},
+		},
+		{
+			name:           "max age with untagged manifests",
+			olderThanDays:  pointer.To(5),
+			deleteUntagged: true,
+			tags:           []string{"v1", "v2", "v3", "v4"},
+			manifests:      []string{"v1", "v2", "v3", "v4"},
+			// the layer of v1 is linked to another repository
+			reclaimableBytes: 4*retentionManifestSize + 3*retentionLayerSize + retentionBaseLayerSize,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			ctx := context.Background()
+			now := time.Now()
+			dbContext, repositoryId := s.arrangeRepository(ctx, now, func(repositoryId uuid.UUID) *repositories.RetentionPolicy {
+				policy := repositories.NewRepositoryRetentionPolicy(repositoryId)
+				policy.SetCriteria(testCase.keepLast, testCase.olderThanDays, nil)
+				policy.SetDeleteUntagged(testCase.deleteUntagged)
+				return policy
+			})
+
+			// act
+			plan, err := planRetention(ctx, dbContext, repositoryId, now)
+
+			// assert
+			s.Require().NoError(err)
+
+			tags := make([]string, len(plan.tags))
+			for i, tag := range plan.tags {
+				tags[i] = tag.GetName()
+			}
+			s.ElementsMatch(testCase.tags, tags)
+
+			manifests := make([]string, len(plan.manifests))
+			for i, manifest := range plan.manifests {
+				manifests[i] = manifest.GetDigest()
+			}
+			expectedManifests := make([]string, len(testCase.manifests))
+			for i, tag := range testCase.manifests {
+				expectedManifests[i] = digest.SHA256.FromBytes([]byte("manifest " + tag))
+			}
+			s.ElementsMatch(expectedManifests, manifests)
+
+			s.Equal(testCase.reclaimableBytes, plan.reclaimableBytes)
+		})
+	}
+}
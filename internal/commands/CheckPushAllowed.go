@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+// CheckPushAllowed rejects the start of an upload into a repository that cannot be pushed to, e.g. a pull-through
+// cache. Manifests are checked when they are uploaded.
+type CheckPushAllowed struct {
+	RepositoryId uuid.UUID
+}
+
+type CheckPushAllowedResponse struct{}
+
+func HandleCheckPushAllowed(ctx context.Context, command CheckPushAllowed) (*CheckPushAllowedResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := checkNotProxied(ctx, dbContext, command.RepositoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CheckPushAllowedResponse{}, nil
+}
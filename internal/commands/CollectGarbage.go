@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+const (
+	// garbageCollectionLockExpiration must be longer than a garbage collection takes, so that two instances never
+	// collect at once.
+	garbageCollectionLockExpiration = 6 * time.Hour
+
+	// blobCollectionLockExpiration must be longer than deleting the row and the data of a single blob takes.
+	blobCollectionLockExpiration = 15 * time.Minute
+)
+
+// CollectGarbage removes blobs that are not used anymore. A blob is used by a repository if a manifest of the
+// repository is stored in it or refers to it, e.g. as config, layer or child of an index. Links of repositories to
+// blobs they do not use are removed first, afterward blobs without any link are deleted together with their data.
+//
+// Links and blobs that were created or refreshed within the grace period are kept, they belong to pushes whose
+// manifest has not been uploaded yet.
+type CollectGarbage struct {
+	GracePeriod time.Duration
+	// DryRun only reports what would be removed.
+	DryRun bool
+}
+
+type CollectGarbageResponse struct {
+	// Skipped is set if another garbage collection is running.
+	Skipped bool
+
+	DeletedRepositoryBlobs int
+	DeletedBlobs           int
+	// BytesFreed is the size of the deleted blobs.
+	BytesFreed int64
+	// FailedBlobs counts the blobs that could not be deleted, they are retried by the next collection.
+	FailedBlobs int
+}
+
+func HandleCollectGarbage(ctx context.Context, command CollectGarbage) (*CollectGarbageResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbFactory := ioc.GetDependency[db.Factory](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	locked, err := kvStore.SetIfNotExists(ctx, garbageCollectionLockKey, "locked", kv.WithExpiration(garbageCollectionLockExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("locking garbage collection: %w", err)
+	}
+	if !locked {
+		return &CollectGarbageResponse{Skipped: true}, nil
+	}
+	defer func() {
+		err := kvStore.Delete(context.WithoutCancel(ctx), garbageCollectionLockKey)
+		if err != nil {
+			logging.Logger.Errorf("unlocking garbage collection: %v", err)
+		}
+	}()
+
+	// the collection deletes in several steps, a separate context keeps a failed step from being saved again later
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating db context: %w", err)
+	}
+
+	cutoff := clockService.Now().Add(-command.GracePeriod)
+
+	usedDigests, err := markUsedDigests(ctx, dbContext)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, _, err := dbContext.Blobs().List(ctx, repositories.NewBlobFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing blobs: %w", err)
+	}
+
+	blobsById := make(map[uuid.UUID]*repositories.Blob, len(blobs))
+	for _, blob := range blobs {
+		blobsById[blob.GetId()] = blob
+	}
+
+	repositoryBlobs, _, err := dbContext.RepositoryBlobs().List(ctx, repositories.NewRepositoryBlobFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing repository blobs: %w", err)
+	}
+
+	response := &CollectGarbageResponse{}
+	linkedBlobs := make(map[uuid.UUID]bool)
+	for _, repositoryBlob := range repositoryBlobs {
+		blob, ok := blobsById[repositoryBlob.GetBlobId()]
+		if !ok || usedDigests[repositoryBlob.GetRepositoryId()][blob.GetDigest()] || repositoryBlob.GetUpdatedAt().After(cutoff) {
+			linkedBlobs[repositoryBlob.GetBlobId()] = true
+			continue
+		}
+
+		dbContext.RepositoryBlobs().Delete(repositoryBlob)
+		response.DeletedRepositoryBlobs++
+	}
+
+	if !command.DryRun {
+		err = dbContext.SaveChanges(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("saving changes: %w", err)
+		}
+	}
+
+	for _, blob := range blobs {
+		if linkedBlobs[blob.GetId()] || blob.GetCreatedAt().After(cutoff) {
+			continue
+		}
+
+		if !command.DryRun {
+			deleted, err := sweepBlob(ctx, dbFactory, kvStore, blobService, blob)
+			if err != nil {
+				logging.Logger.Errorf("deleting unused blob %s: %v", blob.GetDigest(), err)
+				response.FailedBlobs++
+				continue
+			}
+			if !deleted {
+				continue
+			}
+		}
+
+		response.DeletedBlobs++
+		response.BytesFreed += blob.GetSize()
+	}
+
+	return response, nil
+}
+
+// markUsedDigests returns the digests every repository uses: the ones of its manifests and everything they refer to.
+func markUsedDigests(ctx context.Context, dbContext db.Context) (map[uuid.UUID]map[string]bool, error) {
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	manifestReferences, _, err := dbContext.ManifestReferences().List(ctx, repositories.NewManifestReferenceFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing manifest references: %w", err)
+	}
+
+	usedDigests := make(map[uuid.UUID]map[string]bool)
+	markUsed := func(repositoryId uuid.UUID, digest string) {
+		if usedDigests[repositoryId] == nil {
+			usedDigests[repositoryId] = make(map[string]bool)
+		}
+		usedDigests[repositoryId][digest] = true
+	}
+
+	for _, manifest := range manifests {
+		markUsed(manifest.GetRepositoryId(), manifest.GetDigest())
+	}
+
+	for _, manifestReference := range manifestReferences {
+		markUsed(manifestReference.GetRepositoryId(), manifestReference.GetDigest())
+	}
+
+	return usedDigests, nil
+}
+
+// sweepBlob deletes an unused blob and its data. While it does so, pushes of a blob with the same digest are told to
+// retry, see checkBlobDataAvailable. A blob that was linked again in the meantime is kept, the database refuses to
+// delete it if the link is created concurrently.
+func sweepBlob(ctx context.Context, dbFactory db.Factory, kvStore kv.Store, blobService blobStorage.Service, blob *repositories.Blob) (bool, error) {
+	lockKey := buildBlobCollectionLockKey(blob.GetDigest())
+	locked, err := kvStore.SetIfNotExists(ctx, lockKey, "locked", kv.WithExpiration(blobCollectionLockExpiration))
+	if err != nil {
+		return false, fmt.Errorf("locking blob: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		err := kvStore.Delete(context.WithoutCancel(ctx), lockKey)
+		if err != nil {
+			logging.Logger.Errorf("unlocking blob %s: %v", blob.GetDigest(), err)
+		}
+	}()
+
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("creating db context: %w", err)
+	}
+
+	_, linkCount, err := dbContext.RepositoryBlobs().List(ctx, repositories.NewRepositoryBlobFilter().ByBlobId(blob.GetId()))
+	if err != nil {
+		return false, fmt.Errorf("listing repository blobs: %w", err)
+	}
+	if linkCount > 0 {
+		return false, nil
+	}
+
+	dbContext.Blobs().Delete(blob)
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return false, fmt.Errorf("saving changes: %w", err)
+	}
+
+	err = blobService.DeleteBlob(ctx, blob.GetDigest())
+	if err != nil {
+		return false, fmt.Errorf("deleting blob data: %w", err)
+	}
+
+	return true, nil
+}
+
+const garbageCollectionLockKey = "garbage_collection_lock"
+
+func buildBlobCollectionLockKey(digest string) string {
+	return fmt.Sprintf("blob_collection_lock:%s", digest)
+}
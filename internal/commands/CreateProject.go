@@ -18,6 +18,8 @@ type CreateProject struct {
 	DisplayName string
 
 	Description *string
+	// StorageQuota limits the bytes stored by the repositories of the project, nil means unlimited.
+	StorageQuota *int64
 }
 
 type CreateProjectResponse struct {
@@ -28,6 +30,11 @@ func HandleCreateProject(ctx context.Context, command CreateProject) (*CreatePro
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[db.Context](scope)
 
+	err := validateStorageQuota(command.StorageQuota)
+	if err != nil {
+		return nil, err
+	}
+
 	tenantRepository := dbContext.Tenants()
 	tenantFilter := repositories.NewTenantFilter().
 		BySlug(command.TenantSlug)
@@ -38,6 +45,7 @@ func HandleCreateProject(ctx context.Context, command CreateProject) (*CreatePro
 
 	project := repositories.NewProject(tenant.GetId(), command.Slug, command.DisplayName)
 	project.SetDescription(command.Description)
+	project.SetStorageQuota(command.StorageQuota)
 
 	projectRepository := dbContext.Projects()
 	projectRepository.Insert(project)
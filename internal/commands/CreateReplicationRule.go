@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+type CreateReplicationRule struct {
+	TenantSlug  string
+	ProjectSlug string
+
+	Url string
+	// Namespace is an optional prefix of the remote repository names.
+	Namespace string
+	// TagFilter is a regular expression that has to match the whole tag, all tags are replicated if it is nil.
+	TagFilter *string
+	Username  *string
+	Password  *string
+	Enabled   bool
+}
+
+type CreateReplicationRuleResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateReplicationRule(ctx context.Context, command CreateReplicationRule) (*CreateReplicationRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateTagFilter(command.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := getProject(ctx, dbContext, command.TenantSlug, command.ProjectSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := repositories.NewReplicationRule(project.GetId(), command.Url, command.Namespace, command.TagFilter)
+	encryptedPassword, err := encryptPassword(ctx, command.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.SetCredentials(command.Username, encryptedPassword)
+	rule.SetEnabled(command.Enabled)
+
+	dbContext.ReplicationRules().Insert(rule)
+
+	return &CreateReplicationRuleResponse{
+		Id: rule.GetId(),
+	}, nil
+}
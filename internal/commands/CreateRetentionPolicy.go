@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// CreateRetentionPolicy adds a retention policy to a project, or to a repository if RepositorySlug is set.
+type CreateRetentionPolicy struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+
+	TagFilter       *string
+	KeepLast        *int
+	OlderThanDays   *int
+	NotPulledInDays *int
+	DeleteUntagged  bool
+	Enabled         bool
+}
+
+type CreateRetentionPolicyResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateRetentionPolicy(ctx context.Context, command CreateRetentionPolicy) (*CreateRetentionPolicyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateRetentionPolicy(command.TagFilter, command.KeepLast, command.OlderThanDays, command.NotPulledInDays)
+	if err != nil {
+		return nil, err
+	}
+
+	projectId, repositoryId, err := getOwner(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy *repositories.RetentionPolicy
+	if repositoryId != nil {
+		policy = repositories.NewRepositoryRetentionPolicy(*repositoryId)
+	} else {
+		policy = repositories.NewProjectRetentionPolicy(projectId)
+	}
+
+	policy.SetTagFilter(command.TagFilter)
+	policy.SetCriteria(command.KeepLast, command.OlderThanDays, command.NotPulledInDays)
+	policy.SetDeleteUntagged(command.DeleteUntagged)
+	policy.SetEnabled(command.Enabled)
+
+	dbContext.RetentionPolicies().Insert(policy)
+
+	return &CreateRetentionPolicyResponse{
+		Id: policy.GetId(),
+	}, nil
+}
+
+func validateRetentionPolicy(tagFilter *string, keepLast *int, olderThanDays *int, notPulledInDays *int) error {
+	err := validateTagFilter(tagFilter)
+	if err != nil {
+		return err
+	}
+
+	if keepLast != nil && *keepLast < 0 {
+		return fmt.Errorf("keepLast must not be negative: %w", apiError.ErrApiBadRequest)
+	}
+
+	if olderThanDays != nil && *olderThanDays < 0 {
+		return fmt.Errorf("olderThanDays must not be negative: %w", apiError.ErrApiBadRequest)
+	}
+
+	if notPulledInDays != nil && *notPulledInDays < 0 {
+		return fmt.Errorf("notPulledInDays must not be negative: %w", apiError.ErrApiBadRequest)
+	}
+
+	return nil
+}
+
+// getRetentionPolicy returns the retention policy with the id that belongs to the project, or to the repository if the
+// repository slug is set. Policies of other owners are reported as not found.
+func getRetentionPolicy(ctx context.Context, dbContext db.Context, tenantSlug string, projectSlug string, repositorySlug *string, policyId uuid.UUID) (*repositories.RetentionPolicy, error) {
+	projectId, repositoryId, err := getOwner(ctx, dbContext, tenantSlug, projectSlug, repositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repositories.NewRetentionPolicyFilter().ById(policyId).ByProjectId(projectId)
+	if repositoryId != nil {
+		filter = repositories.NewRetentionPolicyFilter().ById(policyId).ByRepositoryId(*repositoryId)
+	}
+
+	policy, err := dbContext.RetentionPolicies().Single(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("getting retention policy: %w", err)
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// CreateTagRule protects tags of a project, or of a repository if RepositorySlug is set.
+type CreateTagRule struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+
+	Pattern string
+	// Kind is one of the repositories.TagRuleKind values.
+	Kind string
+}
+
+type CreateTagRuleResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateTagRule(ctx context.Context, command CreateTagRule) (*CreateTagRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateTagRule(command.Pattern, repositories.TagRuleKind(command.Kind))
+	if err != nil {
+		return nil, err
+	}
+
+	projectId, repositoryId, err := getOwner(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagRule *repositories.TagRule
+	if repositoryId != nil {
+		tagRule = repositories.NewRepositoryTagRule(*repositoryId, command.Pattern, repositories.TagRuleKind(command.Kind))
+	} else {
+		tagRule = repositories.NewProjectTagRule(projectId, command.Pattern, repositories.TagRuleKind(command.Kind))
+	}
+
+	dbContext.TagRules().Insert(tagRule)
+
+	return &CreateTagRuleResponse{
+		Id: tagRule.GetId(),
+	}, nil
+}
+
+func validateTagRule(pattern string, kind repositories.TagRuleKind) error {
+	if pattern == "" {
+		return fmt.Errorf("tag rule pattern must not be empty: %w", apiError.ErrApiBadRequest)
+	}
+
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return fmt.Errorf("invalid tag rule pattern: %s: %w", err, apiError.ErrApiBadRequest)
+	}
+
+	if !kind.IsValid() {
+		return fmt.Errorf("unknown tag rule kind '%s': %w", kind, apiError.ErrApiBadRequest)
+	}
+
+	return nil
+}
+
+// getTagRule returns the tag rule with the id that belongs to the project, or to the repository if the repository slug
+// is set. Rules of other owners are reported as not found.
+func getTagRule(ctx context.Context, dbContext db.Context, tenantSlug string, projectSlug string, repositorySlug *string, ruleId uuid.UUID) (*repositories.TagRule, error) {
+	projectId, repositoryId, err := getOwner(ctx, dbContext, tenantSlug, projectSlug, repositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repositories.NewTagRuleFilter().ById(ruleId).ByProjectId(projectId)
+	if repositoryId != nil {
+		filter = repositories.NewTagRuleFilter().ById(ruleId).ByRepositoryId(*repositoryId)
+	}
+
+	tagRule, err := dbContext.TagRules().Single(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("getting tag rule: %w", err)
+	}
+
+	return tagRule, nil
+}
@@ -19,6 +19,9 @@ type CreateTenant struct {
 	OidcRoleClaim   string
 	OidcRoleFormat  string
 	OidcRoleMapping map[string]string
+
+	// StorageQuota limits the bytes stored by all repositories of the tenant, nil means unlimited.
+	StorageQuota *int64
 }
 
 type CreateTenantResponse struct {
@@ -29,6 +32,11 @@ func HandleCreateTenant(ctx context.Context, command CreateTenant) (*CreateTenan
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[db.Context](scope)
 
+	err := validateStorageQuota(command.StorageQuota)
+	if err != nil {
+		return nil, err
+	}
+
 	tenant := repositories.NewTenant(
 		command.Slug,
 		command.DisplayName,
@@ -40,6 +48,7 @@ func HandleCreateTenant(ctx context.Context, command CreateTenant) (*CreateTenan
 			command.OidcRoleMapping,
 		),
 	)
+	tenant.SetStorageQuota(command.StorageQuota)
 	dbContext.Tenants().Insert(tenant)
 
 	return &CreateTenantResponse{
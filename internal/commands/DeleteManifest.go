@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+// DeleteManifest removes a tag if the reference is a tag name. If the reference is a digest the manifest is deleted
+// together with all tags pointing to it. Tags protected by an immutable tag rule are never deleted.
+type DeleteManifest struct {
+	RepositoryId uuid.UUID
+	Reference    string
+}
+
+type DeleteManifestResponse struct{}
+
+func HandleDeleteManifest(ctx context.Context, command DeleteManifest) (*DeleteManifestResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	if !digest.IsDigest(command.Reference) {
+		tag, err := dbContext.Tags().First(ctx, repositories.NewTagFilter().ByRepositoryId(command.RepositoryId).ByName(command.Reference))
+		if err != nil {
+			return nil, fmt.Errorf("getting tag: %w", err)
+		}
+		if tag == nil {
+			return nil, ociError.NewOciError(ociError.ManifestUnknown).
+				WithMessage(fmt.Sprintf("tag '%s' does not exist", command.Reference)).
+				WithHttpCode(http.StatusNotFound)
+		}
+
+		err = checkTagDelete(ctx, dbContext, command.RepositoryId, tag.GetName())
+		if err != nil {
+			return nil, err
+		}
+
+		dbContext.Tags().Delete(tag)
+
+		err = dbContext.SaveChanges(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("saving changes: %w", err)
+		}
+
+		return &DeleteManifestResponse{}, nil
+	}
+
+	manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(command.RepositoryId).ByDigest(command.Reference))
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+	if manifest == nil {
+		return nil, ociError.NewOciError(ociError.ManifestUnknown).
+			WithMessage(fmt.Sprintf("manifest '%s' does not exist", command.Reference)).
+			WithHttpCode(http.StatusNotFound)
+	}
+
+	tags, _, err := dbContext.Tags().List(ctx, repositories.NewTagFilter().ByRepositoryManifestId(manifest.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	tagNames := make([]string, len(tags))
+	for i, tag := range tags {
+		tagNames[i] = tag.GetName()
+	}
+
+	err = checkTagDelete(ctx, dbContext, command.RepositoryId, tagNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = deleteManifest(ctx, dbContext, manifest, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("saving changes: %w", err)
+	}
+
+	return &DeleteManifestResponse{}, nil
+}
+
+// deleteManifest deletes the manifest together with the given tags pointing to it and everything that was recorded
+// about its content. The blobs of the manifest and its content are removed by the garbage collection.
+func deleteManifest(ctx context.Context, dbContext db.Context, manifest *repositories.Manifest, tags []*repositories.Tag) error {
+	for _, tag := range tags {
+		dbContext.Tags().Delete(tag)
+	}
+
+	referrers, _, err := dbContext.Referrers().List(ctx, repositories.NewReferrerFilter().ByManifestId(manifest.GetId()))
+	if err != nil {
+		return fmt.Errorf("listing referrers: %w", err)
+	}
+
+	for _, referrer := range referrers {
+		dbContext.Referrers().Delete(referrer)
+	}
+
+	manifestReferences, _, err := dbContext.ManifestReferences().List(ctx, repositories.NewManifestReferenceFilter().ByManifestId(manifest.GetId()))
+	if err != nil {
+		return fmt.Errorf("listing manifest references: %w", err)
+	}
+
+	for _, manifestReference := range manifestReferences {
+		dbContext.ManifestReferences().Delete(manifestReference)
+	}
+
+	dbContext.Manifests().Delete(manifest)
+
+	return unlinkRepositoryBlob(ctx, dbContext, manifest.GetRepositoryId(), manifest.GetBlobId())
+}
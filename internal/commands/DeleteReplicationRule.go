@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// DeleteReplicationRule deletes the rule together with its queued tasks, content that has already been replicated is
+// kept in the remote registry.
+type DeleteReplicationRule struct {
+	TenantSlug  string
+	ProjectSlug string
+	RuleId      uuid.UUID
+}
+
+type DeleteReplicationRuleResponse struct{}
+
+func HandleDeleteReplicationRule(ctx context.Context, command DeleteReplicationRule) (*DeleteReplicationRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	rule, err := getReplicationRule(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RuleId)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, _, err := dbContext.ReplicationTasks().List(ctx, repositories.NewReplicationTaskFilter().ByReplicationRuleId(rule.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing replication tasks: %w", err)
+	}
+
+	for _, task := range tasks {
+		dbContext.ReplicationTasks().Delete(task)
+	}
+
+	dbContext.ReplicationRules().Delete(rule)
+
+	return &DeleteReplicationRuleResponse{}, nil
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+// DeleteRepositoryBlob unlinks a blob from a repository. The blob data is removed by the garbage collection once no
+// repository references the blob anymore.
+type DeleteRepositoryBlob struct {
+	RepositoryId uuid.UUID
+	Digest       string
+}
+
+type DeleteRepositoryBlobResponse struct{}
+
+func HandleDeleteRepositoryBlob(ctx context.Context, command DeleteRepositoryBlob) (*DeleteRepositoryBlobResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ByDigest(command.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("getting blob: %w", err)
+	}
+	if blob == nil {
+		return nil, ociError.NewOciError(ociError.BlobUnknown).
+			WithMessage(fmt.Sprintf("blob '%s' does not exist", command.Digest)).
+			WithHttpCode(http.StatusNotFound)
+	}
+
+	repositoryBlob, err := dbContext.RepositoryBlobs().First(ctx, repositories.NewRepositoryBlobFilter().ByRepositoryId(command.RepositoryId).ByBlobId(blob.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting repository blob: %w", err)
+	}
+	if repositoryBlob == nil {
+		return nil, ociError.NewOciError(ociError.BlobUnknown).
+			WithMessage(fmt.Sprintf("blob '%s' does not exist", command.Digest)).
+			WithHttpCode(http.StatusNotFound)
+	}
+
+	manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(command.RepositoryId).ByBlobId(blob.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+	if manifest != nil {
+		return nil, ociError.NewOciError(ociError.Unsupported).
+			WithMessage(fmt.Sprintf("blob '%s' is a manifest, delete the manifest instead", command.Digest)).
+			WithHttpCode(http.StatusMethodNotAllowed)
+	}
+
+	err = unlinkRepositoryBlob(ctx, dbContext, command.RepositoryId, blob.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("saving changes: %w", err)
+	}
+
+	return &DeleteRepositoryBlobResponse{}, nil
+}
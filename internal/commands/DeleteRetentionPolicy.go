@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+type DeleteRetentionPolicy struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+	PolicyId       uuid.UUID
+}
+
+type DeleteRetentionPolicyResponse struct{}
+
+func HandleDeleteRetentionPolicy(ctx context.Context, command DeleteRetentionPolicy) (*DeleteRetentionPolicyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	policy, err := getRetentionPolicy(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug, command.PolicyId)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext.RetentionPolicies().Delete(policy)
+
+	return &DeleteRetentionPolicyResponse{}, nil
+}
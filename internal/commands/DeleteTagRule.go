@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+type DeleteTagRule struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+	RuleId         uuid.UUID
+}
+
+type DeleteTagRuleResponse struct{}
+
+func HandleDeleteTagRule(ctx context.Context, command DeleteTagRule) (*DeleteTagRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tagRule, err := getTagRule(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug, command.RuleId)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext.TagRules().Delete(tagRule)
+
+	return &DeleteTagRuleResponse{}, nil
+}
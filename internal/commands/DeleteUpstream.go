@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+// DeleteUpstream turns a pull-through cache back into a regular project or repository, cached content is kept.
+type DeleteUpstream struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+}
+
+type DeleteUpstreamResponse struct{}
+
+func HandleDeleteUpstream(ctx context.Context, command DeleteUpstream) (*DeleteUpstreamResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	projectId, repositoryId, err := getOwner(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := dbContext.Upstreams().Single(ctx, upstreamOwnerFilter(projectId, repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("getting upstream: %w", err)
+	}
+
+	dbContext.Upstreams().Delete(upstream)
+
+	return &DeleteUpstreamResponse{}, nil
+}
@@ -9,6 +9,7 @@ import (
 	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/storageUsage"
 )
 
 type FinishUpload struct {
@@ -28,6 +29,17 @@ func HandleFinishUpload(ctx context.Context, command FinishUpload) (*FinishUploa
 		return nil, err
 	}
 
+	err = storageUsage.CheckQuota(ctx, dbContext, command.RepositoryId, blob.GetDigest(), blob.GetSize())
+	if err != nil {
+		// the blob is saved without a link, so that the garbage collection removes the uploaded data
+		saveErr := dbContext.SaveChanges(ctx)
+		if saveErr != nil {
+			return nil, fmt.Errorf("saving changes: %w", saveErr)
+		}
+
+		return nil, err
+	}
+
 	dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(command.RepositoryId, blob.GetId()))
 
 	err = dbContext.SaveChanges(ctx)
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/storageUsage"
+)
+
+// MountBlob links a blob that already exists in the source repository into the target repository, so clients do not
+// have to upload it again.
+type MountBlob struct {
+	SourceRepositoryId uuid.UUID
+	TargetRepositoryId uuid.UUID
+	Digest             string
+}
+
+type MountBlobResponse struct {
+	// Mounted is false if the source repository does not contain the blob.
+	Mounted bool
+}
+
+func HandleMountBlob(ctx context.Context, command MountBlob) (*MountBlobResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ByDigest(command.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("getting blob: %w", err)
+	}
+	if blob == nil {
+		return &MountBlobResponse{Mounted: false}, nil
+	}
+
+	sourceRepositoryBlob, err := dbContext.RepositoryBlobs().First(ctx, repositories.NewRepositoryBlobFilter().ByRepositoryId(command.SourceRepositoryId).ByBlobId(blob.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting repository blob: %w", err)
+	}
+	if sourceRepositoryBlob == nil {
+		return &MountBlobResponse{Mounted: false}, nil
+	}
+
+	err = storageUsage.CheckQuota(ctx, dbContext, command.TargetRepositoryId, blob.GetDigest(), blob.GetSize())
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(command.TargetRepositoryId, blob.GetId()))
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("saving changes: %w", err)
+	}
+
+	return &MountBlobResponse{Mounted: true}, nil
+}
@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/services/registryClient"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+const (
+	// replicationMaxAttempts is the number of failed attempts after which a replication task is given up.
+	replicationMaxAttempts = 10
+
+	// replicationBaseBackoff is the delay after the first failed attempt, it doubles with every further attempt up to
+	// replicationMaxBackoff.
+	replicationBaseBackoff = 30 * time.Second
+	replicationMaxBackoff  = time.Hour
+
+	// replicationTaskLockExpiration must be longer than the longest replication of a single tag, so that a task is
+	// never processed by two instances at once.
+	replicationTaskLockExpiration = time.Hour
+)
+
+// ProcessReplicationTasks pushes the tags of due replication tasks to the remote registries of their rules.
+type ProcessReplicationTasks struct {
+	// Limit is the maximum number of tasks processed.
+	Limit int
+}
+
+type ProcessReplicationTasksResponse struct {
+	Succeeded int
+	Failed    int
+}
+
+func HandleProcessReplicationTasks(ctx context.Context, command ProcessReplicationTasks) (*ProcessReplicationTasksResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	tasks, _, err := dbContext.ReplicationTasks().List(ctx, repositories.NewReplicationTaskFilter().
+		ByFailed(false).
+		ByDueBefore(clockService.Now()).
+		WithLimit(command.Limit))
+	if err != nil {
+		return nil, fmt.Errorf("listing replication tasks: %w", err)
+	}
+
+	response := &ProcessReplicationTasksResponse{}
+	for _, listedTask := range tasks {
+		unlock, locked, err := tryLock(ctx, kvStore, buildReplicationTaskLockKey(listedTask.GetId()), replicationTaskLockExpiration)
+		if err != nil {
+			return nil, fmt.Errorf("locking replication task: %w", err)
+		}
+		if !locked {
+			continue
+		}
+
+		// the task was listed before it was locked, another instance may have processed it in the meantime
+		task, err := dbContext.ReplicationTasks().First(ctx, repositories.NewReplicationTaskFilter().
+			ById(listedTask.GetId()).
+			ByFailed(false).
+			ByDueBefore(clockService.Now()))
+
+		succeeded := false
+		switch {
+		case err != nil:
+			err = fmt.Errorf("getting replication task: %w", err)
+
+		case task != nil:
+			succeeded, err = processReplicationTask(ctx, dbContext, clockService, task)
+		}
+
+		unlock()
+
+		// a task that cannot be processed must not keep the remaining ones from being replicated
+		switch {
+		case err != nil:
+			logging.Logger.Errorf("processing replication task %s: %v", listedTask.GetId(), err)
+			response.Failed++
+
+		case task == nil:
+			continue
+
+		case succeeded:
+			response.Succeeded++
+
+		default:
+			response.Failed++
+		}
+	}
+
+	return response, nil
+}
+
+// processReplicationTask replicates the tag of the task and records the outcome on the task and its rule. Tasks of
+// disabled rules stay queued until the rule is enabled again.
+func processReplicationTask(ctx context.Context, dbContext db.Context, clockService clock.Service, task *repositories.ReplicationTask) (bool, error) {
+	rule, err := dbContext.ReplicationRules().Single(ctx, repositories.NewReplicationRuleFilter().ById(task.GetReplicationRuleId()))
+	if err != nil {
+		return false, fmt.Errorf("getting replication rule: %w", err)
+	}
+	if !rule.GetEnabled() {
+		return false, nil
+	}
+
+	replicationErr := replicateTag(ctx, dbContext, rule, task.GetRepositoryId(), task.GetTag())
+	now := clockService.Now()
+
+	if replicationErr == nil {
+		dbContext.ReplicationTasks().Delete(task)
+		rule.RecordSuccess(now)
+	} else {
+		logging.Logger.Warnf("replicating tag '%s' of repository %s failed: %v", task.GetTag(), task.GetRepositoryId(), replicationErr)
+
+		var nextAttemptAt *time.Time
+		if task.GetAttempts()+1 < replicationMaxAttempts {
+			nextAttemptAt = pointer.To(now.Add(replicationBackoff(task.GetAttempts() + 1)))
+		}
+
+		task.RecordFailure(replicationErr.Error(), nextAttemptAt)
+		dbContext.ReplicationTasks().Update(task)
+		rule.RecordFailure(now, replicationErr.Error())
+	}
+
+	dbContext.ReplicationRules().Update(rule)
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return false, fmt.Errorf("saving changes: %w", err)
+	}
+
+	return replicationErr == nil, nil
+}
+
+// replicationBackoff returns the delay before the next attempt after the given number of failed attempts.
+func replicationBackoff(attempts int) time.Duration {
+	backoff := replicationBaseBackoff
+	for i := 1; i < attempts && backoff < replicationMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, replicationMaxBackoff)
+}
+
+// replicateTag pushes the manifest the tag currently points to, together with everything it references, to the
+// remote registry of the rule. A tag that has been deleted in the meantime has nothing left to replicate.
+func replicateTag(ctx context.Context, dbContext db.Context, rule *repositories.ReplicationRule, repositoryId uuid.UUID, tagName string) error {
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(repositoryId))
+	if err != nil {
+		return fmt.Errorf("getting repository: %w", err)
+	}
+
+	tag, err := dbContext.Tags().First(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId).ByName(tagName))
+	if err != nil {
+		return fmt.Errorf("getting tag: %w", err)
+	}
+	if tag == nil {
+		return nil
+	}
+
+	manifest, err := dbContext.Manifests().Single(ctx, repositories.NewManifestFilter().ById(tag.GetRepositoryManifestId()))
+	if err != nil {
+		return fmt.Errorf("getting manifest: %w", err)
+	}
+
+	password, err := decryptPassword(ctx, rule.GetEncryptedPassword())
+	if err != nil {
+		return fmt.Errorf("decrypting password of replication rule %s: %w", rule.GetId(), err)
+	}
+
+	registry := registryClient.Registry{
+		Url:        rule.GetUrl(),
+		Repository: rule.ResolveRepository(repository.GetSlug()),
+		Username:   rule.GetUsername(),
+		Password:   password,
+	}
+
+	return pushManifest(ctx, dbContext, registry, manifest, tagName)
+}
+
+// pushManifest pushes the blobs and child manifests of the manifest before the manifest itself, so the remote
+// registry accepts it.
+func pushManifest(ctx context.Context, dbContext db.Context, registry registryClient.Registry, manifest *repositories.Manifest, reference string) error {
+	scope := middlewares.GetScope(ctx)
+	client := ioc.GetDependency[registryClient.Client](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+
+	references, _, err := dbContext.ManifestReferences().List(ctx, repositories.NewManifestReferenceFilter().ByManifestId(manifest.GetId()))
+	if err != nil {
+		return fmt.Errorf("listing manifest references: %w", err)
+	}
+
+	for _, manifestReference := range references {
+		childManifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(manifest.GetRepositoryId()).ByDigest(manifestReference.GetDigest()))
+		if err != nil {
+			return fmt.Errorf("getting manifest: %w", err)
+		}
+		if childManifest != nil {
+			err = pushManifest(ctx, dbContext, registry, childManifest, childManifest.GetDigest())
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err = pushBlob(ctx, dbContext, client, blobService, registry, manifestReference.GetDigest())
+		if err != nil {
+			return err
+		}
+	}
+
+	manifestBlob, err := dbContext.Blobs().Single(ctx, repositories.NewBlobFilter().ById(manifest.GetBlobId()))
+	if err != nil {
+		return fmt.Errorf("getting manifest blob: %w", err)
+	}
+
+	reader, err := blobService.OpenBlob(ctx, manifestBlob.GetDigest())
+	if err != nil {
+		return fmt.Errorf("opening manifest: %w", err)
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %w", err)
+	}
+
+	err = client.PushManifest(ctx, registry, reference, manifest.GetMediaType(), body)
+	if err != nil {
+		return fmt.Errorf("pushing manifest '%s': %w", manifest.GetDigest(), err)
+	}
+
+	return nil
+}
+
+func pushBlob(ctx context.Context, dbContext db.Context, client registryClient.Client, blobService blobStorage.Service, registry registryClient.Registry, digest string) error {
+	exists, err := client.BlobExists(ctx, registry, digest)
+	if err != nil {
+		return fmt.Errorf("checking blob '%s': %w", digest, err)
+	}
+	if exists {
+		return nil
+	}
+
+	blob, err := dbContext.Blobs().Single(ctx, repositories.NewBlobFilter().ByDigest(digest))
+	if err != nil {
+		return fmt.Errorf("getting blob '%s': %w", digest, err)
+	}
+
+	reader, err := blobService.OpenBlob(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("opening blob '%s': %w", digest, err)
+	}
+	defer reader.Close()
+
+	err = client.PushBlob(ctx, registry, digest, blob.GetSize(), reader)
+	if err != nil {
+		return fmt.Errorf("pushing blob '%s': %w", digest, err)
+	}
+
+	return nil
+}
+
+func buildReplicationTaskLockKey(taskId uuid.UUID) string {
+	return fmt.Sprintf("replication_task_lock:%s", taskId)
+}
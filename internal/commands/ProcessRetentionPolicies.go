@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// retentionLockExpiration must be longer than applying the retention policies to a single repository takes, so that a
+// repository is never cleaned up by two instances at once.
+const retentionLockExpiration = time.Hour
+
+// ProcessRetentionPolicies applies the enabled retention policies to every repository they cover.
+type ProcessRetentionPolicies struct{}
+
+type ProcessRetentionPoliciesResponse struct {
+	DeletedTags      int
+	DeletedManifests int
+	ReclaimableBytes int64
+}
+
+func HandleProcessRetentionPolicies(ctx context.Context, _ ProcessRetentionPolicies) (*ProcessRetentionPoliciesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repositoryIds, err := getRetentionRepositoryIds(ctx, dbContext)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ProcessRetentionPoliciesResponse{}
+	for _, repositoryId := range repositoryIds {
+		unlock, locked, err := tryLock(ctx, kvStore, buildRetentionLockKey(repositoryId), retentionLockExpiration)
+		if err != nil {
+			return nil, fmt.Errorf("locking repository retention: %w", err)
+		}
+		if !locked {
+			continue
+		}
+
+		plan, err := planRetention(ctx, dbContext, repositoryId, clockService.Now())
+		if err == nil && !plan.isEmpty() {
+			err = applyRetentionPlan(ctx, dbContext, plan)
+		}
+
+		unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		response.DeletedTags += len(plan.tags)
+		response.DeletedManifests += len(plan.manifests)
+		response.ReclaimableBytes += plan.reclaimableBytes
+	}
+
+	return response, nil
+}
+
+// getRetentionRepositoryIds returns the repositories covered by at least one enabled retention policy.
+func getRetentionRepositoryIds(ctx context.Context, dbContext db.Context) ([]uuid.UUID, error) {
+	policies, _, err := dbContext.RetentionPolicies().List(ctx, repositories.NewRetentionPolicyFilter().ByEnabled(true))
+	if err != nil {
+		return nil, fmt.Errorf("listing retention policies: %w", err)
+	}
+
+	var repositoryIds []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, policy := range policies {
+		if policy.GetRepositoryId() != nil {
+			if !seen[*policy.GetRepositoryId()] {
+				seen[*policy.GetRepositoryId()] = true
+				repositoryIds = append(repositoryIds, *policy.GetRepositoryId())
+			}
+			continue
+		}
+
+		projectRepositories, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter().ByProjectId(*policy.GetProjectId()))
+		if err != nil {
+			return nil, fmt.Errorf("listing repositories: %w", err)
+		}
+
+		for _, repository := range projectRepositories {
+			if !seen[repository.GetId()] {
+				seen[repository.GetId()] = true
+				repositoryIds = append(repositoryIds, repository.GetId())
+			}
+		}
+	}
+
+	return repositoryIds, nil
+}
+
+func buildRetentionLockKey(repositoryId uuid.UUID) string {
+	return fmt.Sprintf("retention_lock:%s", repositoryId)
+}
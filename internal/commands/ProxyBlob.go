@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/registryClient"
+)
+
+// ProxyBlob fetches a blob that a pull-through cache repository does not contain yet from the upstream. Blobs are
+// content addressed, so once cached they are never fetched again. It does nothing for regular repositories.
+type ProxyBlob struct {
+	RepositoryId uuid.UUID
+	Digest       string
+	// HeadOnly only asks the upstream for the size of a blob that is not cached yet, so that checking for a blob does
+	// not download it.
+	HeadOnly bool
+}
+
+type ProxyBlobResponse struct {
+	// UpstreamSize is the size reported by the upstream, it is only set for HeadOnly requests of blobs that are not
+	// cached.
+	UpstreamSize *int64
+}
+
+func HandleProxyBlob(ctx context.Context, command ProxyBlob) (*ProxyBlobResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	registry, _, err := getProxyRegistry(ctx, dbContext, command.RepositoryId)
+	if err != nil {
+		return nil, err
+	}
+	if registry == nil {
+		return &ProxyBlobResponse{}, nil
+	}
+
+	blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ByDigest(command.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("getting blob: %w", err)
+	}
+	if blob != nil {
+		repositoryBlob, err := dbContext.RepositoryBlobs().First(ctx, repositories.NewRepositoryBlobFilter().ByRepositoryId(command.RepositoryId).ByBlobId(blob.GetId()))
+		if err != nil {
+			return nil, fmt.Errorf("getting repository blob: %w", err)
+		}
+		if repositoryBlob != nil {
+			return &ProxyBlobResponse{}, nil
+		}
+
+		// the content is already stored for another repository, possibly of another tenant. It is only linked if this
+		// repository may see it, otherwise knowing a digest would be enough to read a private blob.
+		allowed, err := isProxiedBlob(ctx, dbContext, ioc.GetDependency[registryClient.Client](scope), *registry, command.RepositoryId, command.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			// the query reports the blob as unknown
+			return &ProxyBlobResponse{}, nil
+		}
+
+		dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(command.RepositoryId, blob.GetId()))
+
+		err = dbContext.SaveChanges(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("saving changes: %w", err)
+		}
+
+		return &ProxyBlobResponse{}, nil
+	}
+
+	client := ioc.GetDependency[registryClient.Client](scope)
+
+	if command.HeadOnly {
+		size, err := client.HeadBlob(ctx, *registry, command.Digest)
+		if errors.Is(err, registryClient.ErrNotFound) {
+			return &ProxyBlobResponse{}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking blob in upstream: %w", err)
+		}
+
+		return &ProxyBlobResponse{
+			UpstreamSize: &size,
+		}, nil
+	}
+
+	reader, err := client.GetBlob(ctx, *registry, command.Digest)
+	if errors.Is(err, registryClient.ErrNotFound) {
+		// the query reports the blob as unknown
+		return &ProxyBlobResponse{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetching blob from upstream: %w", err)
+	}
+	defer reader.Close()
+
+	// the blob service verifies that the content matches the digest
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	uploadResponse, err := blobService.UploadCompleteBlob(ctx, command.Digest, reader, blobStorage.BlobContentTypeOctetStream)
+	if err != nil {
+		return nil, fmt.Errorf("storing upstream blob: %w", err)
+	}
+
+	_, err = HandleFinishUpload(ctx, FinishUpload{
+		RepositoryId:   command.RepositoryId,
+		ComputedDigest: uploadResponse.Digest,
+		Size:           uploadResponse.Size,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProxyBlobResponse{}, nil
+}
+
+// isProxiedBlob reports whether the blob belongs to the upstream of a pull-through cache repository, either because a
+// manifest proxied into the repository references it or because the upstream has it.
+func isProxiedBlob(ctx context.Context, dbContext db.Context, client registryClient.Client, registry registryClient.Registry, repositoryId uuid.UUID, digest string) (bool, error) {
+	reference, err := dbContext.ManifestReferences().First(ctx, repositories.NewManifestReferenceFilter().ByRepositoryId(repositoryId).ByDigest(digest))
+	if err != nil {
+		return false, fmt.Errorf("getting manifest reference: %w", err)
+	}
+	if reference != nil {
+		return true, nil
+	}
+
+	_, err = client.HeadBlob(ctx, registry, digest)
+	if errors.Is(err, registryClient.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking blob in upstream: %w", err)
+	}
+
+	return true, nil
+}
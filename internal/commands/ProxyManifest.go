@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/services/registryClient"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+// ProxyManifest makes sure that a pull-through cache repository contains an up-to-date copy of the manifest before it
+// is read. Manifests pulled by digest never change and are only fetched once, tags are revalidated against the upstream
+// after the tag ttl of the upstream has passed. It does nothing for regular repositories.
+type ProxyManifest struct {
+	RepositoryId uuid.UUID
+	Reference    string
+}
+
+type ProxyManifestResponse struct{}
+
+func HandleProxyManifest(ctx context.Context, command ProxyManifest) (*ProxyManifestResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	registry, upstream, err := getProxyRegistry(ctx, dbContext, command.RepositoryId)
+	if err != nil {
+		return nil, err
+	}
+	if registry == nil {
+		return &ProxyManifestResponse{}, nil
+	}
+
+	cachedDigest, err := getCachedManifestDigest(ctx, dbContext, command.RepositoryId, command.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	isTag := !digest.IsDigest(command.Reference)
+	if cachedDigest != nil && !isTag {
+		return &ProxyManifestResponse{}, nil
+	}
+
+	kvStore := ioc.GetDependency[kv.Store](scope)
+	tagKey := buildProxyTagCacheKey(command.RepositoryId, command.Reference)
+
+	if cachedDigest != nil {
+		_, fresh, err := kvStore.Get(ctx, tagKey)
+		if err != nil {
+			return nil, fmt.Errorf("getting tag freshness: %w", err)
+		}
+		if fresh {
+			return &ProxyManifestResponse{}, nil
+		}
+	}
+
+	client := ioc.GetDependency[registryClient.Client](scope)
+
+	if cachedDigest != nil {
+		upstreamDigest, err := client.HeadManifest(ctx, *registry, command.Reference)
+		if err != nil {
+			// a cache keeps serving what it has while the upstream is unreachable
+			logging.Logger.Warnf("revalidating tag '%s' of repository %s failed, serving cached manifest: %v", command.Reference, command.RepositoryId, err)
+			return &ProxyManifestResponse{}, nil
+		}
+
+		if upstreamDigest == *cachedDigest {
+			err = markProxyTagFresh(ctx, kvStore, tagKey, upstream)
+			if err != nil {
+				return nil, err
+			}
+
+			return &ProxyManifestResponse{}, nil
+		}
+	}
+
+	manifest, err := client.GetManifest(ctx, *registry, command.Reference)
+	switch {
+	case errors.Is(err, registryClient.ErrNotFound) && cachedDigest == nil:
+		// the query reports the manifest as unknown
+		return &ProxyManifestResponse{}, nil
+
+	case err != nil && cachedDigest != nil:
+		logging.Logger.Warnf("fetching tag '%s' of repository %s failed, serving cached manifest: %v", command.Reference, command.RepositoryId, err)
+		return &ProxyManifestResponse{}, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("fetching manifest from upstream: %w", err)
+	}
+
+	_, err = HandleUploadManifest(ctx, UploadManifest{
+		RepositoryId: command.RepositoryId,
+		Reference:    command.Reference,
+		Digest:       manifest.Digest,
+		MediaType:    manifest.MediaType,
+		Body:         manifest.Body,
+		Blobs:        mapProxyDescriptors(manifest.Blobs),
+		Manifests:    mapProxyDescriptors(manifest.Manifests),
+		Proxied:      true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storing upstream manifest: %w", err)
+	}
+
+	if isTag {
+		err = markProxyTagFresh(ctx, kvStore, tagKey, upstream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ProxyManifestResponse{}, nil
+}
+
+// getCachedManifestDigest returns the digest of the manifest the reference points to in the repository, or nil.
+func getCachedManifestDigest(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, reference string) (*string, error) {
+	if digest.IsDigest(reference) {
+		manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId).ByDigest(reference))
+		if err != nil {
+			return nil, fmt.Errorf("getting manifest: %w", err)
+		}
+		if manifest == nil {
+			return nil, nil
+		}
+
+		manifestDigest := manifest.GetDigest()
+		return &manifestDigest, nil
+	}
+
+	tag, err := dbContext.Tags().First(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId).ByName(reference))
+	if err != nil {
+		return nil, fmt.Errorf("getting tag: %w", err)
+	}
+	if tag == nil {
+		return nil, nil
+	}
+
+	manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ById(tag.GetRepositoryManifestId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+	if manifest == nil {
+		return nil, nil
+	}
+
+	manifestDigest := manifest.GetDigest()
+	return &manifestDigest, nil
+}
+
+func mapProxyDescriptors(descriptors []registryClient.Descriptor) []ManifestDescriptor {
+	result := make([]ManifestDescriptor, len(descriptors))
+	for i, descriptor := range descriptors {
+		result[i] = ManifestDescriptor{
+			MediaType: descriptor.MediaType,
+			Digest:    descriptor.Digest,
+			Size:      descriptor.Size,
+		}
+	}
+	return result
+}
+
+// markProxyTagFresh remembers that the tag matches the upstream until the tag ttl has passed. A ttl of zero revalidates
+// the tag on every pull.
+func markProxyTagFresh(ctx context.Context, kvStore kv.Store, tagKey string, upstream *repositories.Upstream) error {
+	if upstream.GetTagTtl() <= 0 {
+		return nil
+	}
+
+	err := kvStore.Set(ctx, tagKey, "fresh", kv.WithExpiration(upstream.GetTagTtl()))
+	if err != nil {
+		return fmt.Errorf("setting tag freshness: %w", err)
+	}
+
+	return nil
+}
+
+func buildProxyTagCacheKey(repositoryId uuid.UUID, tag string) string {
+	return fmt.Sprintf("proxy-tag:%s:%s", repositoryId, tag)
+}
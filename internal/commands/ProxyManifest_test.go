@@ -0,0 +1,363 @@
+package commands_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/config"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/registryClient"
+	"github.com/the127/dockyard/internal/setup"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
+	"go.uber.org/zap"
+)
+
+const (
+	proxyTestConfig = `{"architecture":"amd64","os":"linux"}`
+	proxyTestLayer  = "hello"
+)
+
+var proxyTestManifest = fmt.Sprintf(
+	`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"%s","size":%d}]}`,
+	digest.SHA256.FromBytes([]byte(proxyTestConfig)), len(proxyTestConfig),
+	digest.SHA256.FromBytes([]byte(proxyTestLayer)), len(proxyTestLayer),
+)
+
+type ProxyTestSuite struct {
+	suite.Suite
+	upstream *httptest.Server
+	dp       *ioc.DependencyProvider
+
+	repositoryId uuid.UUID
+	// requests counts the authorized requests the upstream received by method and path.
+	requests      map[string]int
+	requestsMutex sync.Mutex
+}
+
+func TestProxyTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ProxyTestSuite))
+}
+
+// SetupTest starts an upstream that serves one image with basic auth and a registry whose repository p/app is a
+// pull-through cache of it.
+func (s *ProxyTestSuite) SetupTest() {
+	if logging.Logger == nil {
+		logging.Logger = zap.NewNop().Sugar()
+	}
+
+	s.requests = make(map[string]int)
+	s.upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		s.requestsMutex.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		s.requestsMutex.Unlock()
+
+		switch r.URL.Path {
+		case "/v2/library/app/manifests/latest":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", digest.SHA256.FromBytes([]byte(proxyTestManifest)))
+			_, _ = io.WriteString(w, proxyTestManifest)
+
+		case "/v2/library/app/blobs/" + digest.SHA256.FromBytes([]byte(proxyTestLayer)):
+			_, _ = io.WriteString(w, proxyTestLayer)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	dc := ioc.NewDependencyCollection()
+	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) clock.Service {
+		return clock.NewSystemClock()
+	})
+	setup.Database(dc, config.DatabaseConfig{Mode: config.DatabaseModeInMemory})
+	setup.Kv(dc, config.KvConfig{Mode: config.KvModeInMemory})
+	setup.Blob(dc, config.BlobStorageConfig{Mode: config.BlobStorageModeInMemory, UploadSessionTtl: time.Minute})
+	setup.Kms(dc, config.KmsConfig{Mode: config.KmsModeMemory, EncryptionKey: base64.StdEncoding.EncodeToString(make([]byte, 32))})
+	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) registryClient.Client {
+		return registryClient.NewClient(s.upstream.Client())
+	})
+	s.dp = dc.BuildProvider()
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		tenant := repositories.NewTenant("t", "t", repositories.NewTenantOidcConfig("client", "issuer", "roles", "array", nil))
+		dbContext.Tenants().Insert(tenant)
+
+		project := repositories.NewProject(tenant.GetId(), "p", "p")
+		dbContext.Projects().Insert(project)
+
+		repository := repositories.NewRepository(project.GetId(), "app", "app")
+		dbContext.Repositories().Insert(repository)
+		s.repositoryId = repository.GetId()
+	})
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		username := "user"
+		password := "secret"
+		_, err := commands.HandleSetUpstream(ctx, commands.SetUpstream{
+			TenantSlug:  "t",
+			ProjectSlug: "p",
+			Url:         s.upstream.URL,
+			Repository:  "library",
+			Username:    &username,
+			Password:    &password,
+			TagTtl:      time.Hour,
+		})
+		s.Require().NoError(err)
+	})
+}
+
+func (s *ProxyTestSuite) TearDownTest() {
+	s.upstream.Close()
+}
+
+// inScope runs f in a scope of its own like a request, the changes are saved when the scope is closed.
+func (s *ProxyTestSuite) inScope(f func(ctx context.Context, dbContext db.Context)) {
+	scope := s.dp.NewScope()
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+
+	f(ctx, ioc.GetDependency[db.Context](scope))
+
+	s.Require().NoError(scope.Close())
+}
+
+func (s *ProxyTestSuite) upstreamRequests(method string, path string) int {
+	s.requestsMutex.Lock()
+	defer s.requestsMutex.Unlock()
+
+	return s.requests[method+" /v2/library/app/"+path]
+}
+
+func (s *ProxyTestSuite) proxyManifest(reference string) {
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		_, err := commands.HandleProxyManifest(ctx, commands.ProxyManifest{
+			RepositoryId: s.repositoryId,
+			Reference:    reference,
+		})
+		s.Require().NoError(err)
+	})
+}
+
+func (s *ProxyTestSuite) TestProxyManifest_ByTag() {
+	// act
+	s.proxyManifest("latest")
+
+	// assert
+	s.Equal(1, s.upstreamRequests(http.MethodGet, "manifests/latest"))
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		tag, err := dbContext.Tags().First(ctx, repositories.NewTagFilter().ByRepositoryId(s.repositoryId).ByName("latest"))
+		s.Require().NoError(err)
+		s.Require().NotNil(tag)
+
+		manifest, err := dbContext.Manifests().Single(ctx, repositories.NewManifestFilter().ById(tag.GetRepositoryManifestId()))
+		s.Require().NoError(err)
+		s.Equal(digest.SHA256.FromBytes([]byte(proxyTestManifest)), manifest.GetDigest())
+	})
+}
+
+func (s *ProxyTestSuite) TestProxyManifest_FreshTagIsNotRevalidated() {
+	// arrange
+	s.proxyManifest("latest")
+
+	// act
+	s.proxyManifest("latest")
+
+	// assert
+	s.Equal(1, s.upstreamRequests(http.MethodGet, "manifests/latest"))
+	s.Equal(0, s.upstreamRequests(http.MethodHead, "manifests/latest"))
+}
+
+func (s *ProxyTestSuite) TestProxyBlob_FetchesOnceAndServesFromCache() {
+	// arrange
+	s.proxyManifest("latest")
+	layerDigest := digest.SHA256.FromBytes([]byte(proxyTestLayer))
+
+	// act
+	for range 2 {
+		s.inScope(func(ctx context.Context, dbContext db.Context) {
+			_, err := commands.HandleProxyBlob(ctx, commands.ProxyBlob{
+				RepositoryId: s.repositoryId,
+				Digest:       layerDigest,
+			})
+			s.Require().NoError(err)
+		})
+	}
+
+	// assert
+	s.Equal(1, s.upstreamRequests(http.MethodGet, "blobs/"+layerDigest))
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		blobService := ioc.GetDependency[blobStorage.Service](middlewares.GetScope(ctx))
+		reader, err := blobService.OpenBlob(ctx, layerDigest)
+		s.Require().NoError(err)
+		defer reader.Close()
+
+		content, err := io.ReadAll(reader)
+		s.Require().NoError(err)
+		s.Equal(proxyTestLayer, string(content))
+	})
+}
+
+func (s *ProxyTestSuite) TestProxyBlob_HeadOnlyDoesNotDownload() {
+	// arrange
+	layerDigest := digest.SHA256.FromBytes([]byte(proxyTestLayer))
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		// act
+		response, err := commands.HandleProxyBlob(ctx, commands.ProxyBlob{
+			RepositoryId: s.repositoryId,
+			Digest:       layerDigest,
+			HeadOnly:     true,
+		})
+
+		// assert
+		s.Require().NoError(err)
+		s.Require().NotNil(response.UpstreamSize)
+		s.Equal(int64(len(proxyTestLayer)), *response.UpstreamSize)
+	})
+
+	s.Equal(0, s.upstreamRequests(http.MethodGet, "blobs/"+layerDigest))
+}
+
+func (s *ProxyTestSuite) TestUploadManifest_PushIsDenied() {
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		// act
+		_, err := commands.HandleUploadManifest(ctx, commands.UploadManifest{
+			RepositoryId: s.repositoryId,
+			Reference:    "pushed",
+			Digest:       digest.SHA256.FromBytes([]byte(proxyTestManifest)),
+			MediaType:    "application/vnd.oci.image.manifest.v1+json",
+			Body:         []byte(proxyTestManifest),
+		})
+
+		// assert
+		var ociErr *ociError.OciError
+		s.Require().True(errors.As(err, &ociErr))
+		s.Equal(ociError.Denied, ociErr.Code)
+	})
+}
+
+// storeInOtherRepository stores the content as a blob of another repository and returns its digest.
+func (s *ProxyTestSuite) storeInOtherRepository(content string) string {
+	blobDigest := digest.SHA256.FromBytes([]byte(content))
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(s.repositoryId))
+		s.Require().NoError(err)
+
+		other := repositories.NewRepository(repository.GetProjectId(), "other", "other")
+		dbContext.Repositories().Insert(other)
+
+		blobService := ioc.GetDependency[blobStorage.Service](middlewares.GetScope(ctx))
+		_, err = blobService.UploadCompleteBlob(ctx, blobDigest, strings.NewReader(content), blobStorage.BlobContentTypeOctetStream)
+		s.Require().NoError(err)
+
+		blob := repositories.NewBlob(blobDigest, int64(len(content)))
+		dbContext.Blobs().Insert(blob)
+		dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(other.GetId(), blob.GetId()))
+	})
+
+	return blobDigest
+}
+
+func (s *ProxyTestSuite) isLinked(blobDigest string) bool {
+	linked := false
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ByRepositoryId(s.repositoryId).ByDigest(blobDigest))
+		s.Require().NoError(err)
+		linked = blob != nil
+	})
+
+	return linked
+}
+
+func (s *ProxyTestSuite) TestProxyBlob_LinksStoredBlobOfTheUpstream() {
+	// arrange
+	layerDigest := s.storeInOtherRepository(proxyTestLayer)
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		// act
+		_, err := commands.HandleProxyBlob(ctx, commands.ProxyBlob{
+			RepositoryId: s.repositoryId,
+			Digest:       layerDigest,
+		})
+
+		// assert
+		s.Require().NoError(err)
+	})
+
+	s.True(s.isLinked(layerDigest))
+	s.Equal(1, s.upstreamRequests(http.MethodHead, "blobs/"+layerDigest))
+	s.Equal(0, s.upstreamRequests(http.MethodGet, "blobs/"+layerDigest))
+}
+
+func (s *ProxyTestSuite) TestProxyBlob_LinksStoredBlobOfProxiedManifest() {
+	// arrange
+	layerDigest := s.storeInOtherRepository(proxyTestLayer)
+	s.proxyManifest("latest")
+
+	s.inScope(func(ctx context.Context, dbContext db.Context) {
+		// act
+		_, err := commands.HandleProxyBlob(ctx, commands.ProxyBlob{
+			RepositoryId: s.repositoryId,
+			Digest:       layerDigest,
+		})
+
+		// assert
+		s.Require().NoError(err)
+	})
+
+	s.True(s.isLinked(layerDigest))
+	s.Equal(0, s.upstreamRequests(http.MethodHead, "blobs/"+layerDigest))
+}
+
+func (s *ProxyTestSuite) TestProxyBlob_DoesNotLinkBlobsUnknownToTheUpstream() {
+	for _, headOnly := range []bool{false, true} {
+		s.Run(fmt.Sprintf("head only %t", headOnly), func() {
+			// arrange
+			privateDigest := s.storeInOtherRepository(fmt.Sprintf("private %t", headOnly))
+
+			s.inScope(func(ctx context.Context, dbContext db.Context) {
+				// act
+				response, err := commands.HandleProxyBlob(ctx, commands.ProxyBlob{
+					RepositoryId: s.repositoryId,
+					Digest:       privateDigest,
+					HeadOnly:     headOnly,
+				})
+
+				// assert
+				s.Require().NoError(err)
+				s.Nil(response.UpstreamSize)
+			})
+
+			s.False(s.isLinked(privateDigest))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+// pullRecordInterval limits how often the pull time of a tag is written, retention policies count in days so a
+// coarse pull time is precise enough.
+const pullRecordInterval = time.Hour
+
+// RecordManifestPull remembers when the tags of a manifest were last pulled, so that retention policies can remove
+// tags nobody uses anymore. A pull by digest counts for every tag pointing to the manifest.
+type RecordManifestPull struct {
+	RepositoryId uuid.UUID
+	Reference    string
+}
+
+type RecordManifestPullResponse struct{}
+
+func HandleRecordManifestPull(ctx context.Context, command RecordManifestPull) (*RecordManifestPullResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	tagFilter := repositories.NewTagFilter().ByRepositoryId(command.RepositoryId).ByName(command.Reference)
+	if digest.IsDigest(command.Reference) {
+		manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(command.RepositoryId).ByDigest(command.Reference))
+		if err != nil {
+			return nil, fmt.Errorf("getting manifest: %w", err)
+		}
+		if manifest == nil {
+			return &RecordManifestPullResponse{}, nil
+		}
+
+		tagFilter = repositories.NewTagFilter().ByRepositoryManifestId(manifest.GetId())
+	}
+
+	tags, _, err := dbContext.Tags().List(ctx, tagFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	now := clockService.Now()
+	for _, tag := range tags {
+		if tag.GetLastPulledAt() != nil && now.Sub(*tag.GetLastPulledAt()) < pullRecordInterval {
+			continue
+		}
+
+		tag.RecordPull(now)
+		dbContext.Tags().Update(tag)
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("saving changes: %w", err)
+	}
+
+	return &RecordManifestPullResponse{}, nil
+}
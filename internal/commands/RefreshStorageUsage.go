@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/storageUsage"
+)
+
+// storageUsageLockExpiration must be longer than a refresh takes, so that two instances never refresh at once.
+const storageUsageLockExpiration = time.Hour
+
+// RefreshStorageUsage recomputes the storage counters of every tenant, project and repository, see
+// repositories.StorageUsage. Counters of deleted owners are removed.
+type RefreshStorageUsage struct{}
+
+type RefreshStorageUsageResponse struct {
+	// Skipped is set if another refresh is running.
+	Skipped bool
+}
+
+func HandleRefreshStorageUsage(ctx context.Context, _ RefreshStorageUsage) (*RefreshStorageUsageResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbFactory := ioc.GetDependency[db.Factory](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	locked, err := kvStore.SetIfNotExists(ctx, storageUsageLockKey, "locked", kv.WithExpiration(storageUsageLockExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("locking storage usage refresh: %w", err)
+	}
+	if !locked {
+		return &RefreshStorageUsageResponse{Skipped: true}, nil
+	}
+	defer func() {
+		err := kvStore.Delete(context.WithoutCancel(ctx), storageUsageLockKey)
+		if err != nil {
+			logging.Logger.Errorf("unlocking storage usage refresh: %v", err)
+		}
+	}()
+
+	// the counters are saved right away, so that they do not wait for the scope of the job to close
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating db context: %w", err)
+	}
+
+	computedAt := clockService.Now()
+
+	snapshot, err := storageUsage.ComputeSnapshot(ctx, dbContext)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	projects, _, err := dbContext.Projects().List(ctx, repositories.NewProjectFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+
+	existing, _, err := dbContext.StorageUsages().List(ctx, repositories.NewStorageUsageFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing storage usages: %w", err)
+	}
+
+	existingByOwner := make(map[uuid.UUID]*repositories.StorageUsage, len(existing))
+	for _, usage := range existing {
+		existingByOwner[usage.GetOwnerId()] = usage
+	}
+
+	upsert := func(kind repositories.StorageUsageKind, ownerId uuid.UUID, tenantId uuid.UUID, projectId *uuid.UUID, sizes storageUsage.Sizes) {
+		usage, ok := existingByOwner[ownerId]
+		delete(existingByOwner, ownerId)
+
+		if !ok {
+			usage = repositories.NewStorageUsage(kind, ownerId, tenantId, projectId)
+			usage.SetSizes(sizes.LogicalSize, sizes.StoredSize, sizes.UniqueSize)
+			dbContext.StorageUsages().Insert(usage)
+			return
+		}
+
+		usage.SetSizes(sizes.LogicalSize, sizes.StoredSize, sizes.UniqueSize)
+		if usage.HasChanges() {
+			dbContext.StorageUsages().Update(usage)
+		}
+	}
+
+	tenantOfProject := make(map[uuid.UUID]uuid.UUID, len(projects))
+	for _, project := range projects {
+		tenantOfProject[project.GetId()] = project.GetTenantId()
+	}
+
+	for tenantId, sizes := range snapshot.Tenants {
+		upsert(repositories.StorageUsageKindTenant, tenantId, tenantId, nil, sizes)
+	}
+
+	for _, project := range projects {
+		projectId := project.GetId()
+		upsert(repositories.StorageUsageKindProject, projectId, project.GetTenantId(), &projectId, snapshot.Projects[projectId])
+	}
+
+	for _, repository := range repos {
+		projectId := repository.GetProjectId()
+		upsert(repositories.StorageUsageKindRepository, repository.GetId(), tenantOfProject[projectId], &projectId, snapshot.Repositories[repository.GetId()])
+	}
+
+	for _, usage := range existingByOwner {
+		dbContext.StorageUsages().Delete(usage)
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("saving changes: %w", err)
+	}
+
+	err = storageUsage.SetComputedAt(ctx, kvStore, computedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshStorageUsageResponse{}, nil
+}
+
+const storageUsageLockKey = "storage_usage_lock"
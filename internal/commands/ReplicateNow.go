@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// ReplicateNow queues the replication of every tag of the project that matches the rule, including tags that were
+// pushed before the rule existed. Tasks that have been given up are retried as well.
+type ReplicateNow struct {
+	TenantSlug  string
+	ProjectSlug string
+	RuleId      uuid.UUID
+}
+
+type ReplicateNowResponse struct {
+	QueuedTasks int
+}
+
+func HandleReplicateNow(ctx context.Context, command ReplicateNow) (*ReplicateNowResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	rule, err := getReplicationRule(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RuleId)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.GetEnabled() {
+		return nil, fmt.Errorf("replication rule is disabled: %w", apiError.ErrApiBadRequest)
+	}
+
+	repos, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter().ByProjectId(rule.GetProjectId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	queuedTasks := 0
+	for _, repository := range repos {
+		tags, _, err := dbContext.Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(repository.GetId()))
+		if err != nil {
+			return nil, fmt.Errorf("listing tags: %w", err)
+		}
+
+		for _, tag := range tags {
+			if !rule.MatchesTag(tag.GetName()) {
+				continue
+			}
+
+			err = enqueueReplicationTask(ctx, dbContext, rule, repository.GetId(), tag.GetName(), clockService.Now())
+			if err != nil {
+				return nil, err
+			}
+
+			queuedTasks++
+		}
+	}
+
+	return &ReplicateNowResponse{
+		QueuedTasks: queuedTasks,
+	}, nil
+}
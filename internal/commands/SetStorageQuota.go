@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// SetStorageQuota limits the storage of a tenant, or of a project if ProjectSlug is set. A nil quota removes the
+// limit. Lowering the quota below the current usage does not remove anything, it only rejects further pushes.
+type SetStorageQuota struct {
+	TenantSlug  string
+	ProjectSlug *string
+
+	StorageQuota *int64
+}
+
+type SetStorageQuotaResponse struct{}
+
+func HandleSetStorageQuota(ctx context.Context, command SetStorageQuota) (*SetStorageQuotaResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateStorageQuota(command.StorageQuota)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(command.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	if command.ProjectSlug == nil {
+		tenant.SetStorageQuota(command.StorageQuota)
+		dbContext.Tenants().Update(tenant)
+
+		return &SetStorageQuotaResponse{}, nil
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(*command.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	project.SetStorageQuota(command.StorageQuota)
+	dbContext.Projects().Update(project)
+
+	return &SetStorageQuotaResponse{}, nil
+}
+
+func validateStorageQuota(storageQuota *int64) error {
+	if storageQuota != nil && *storageQuota < 0 {
+		return fmt.Errorf("storage quota must not be negative: %w", apiError.ErrApiBadRequest)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// SetUpstream creates or updates the upstream of a project, or of a repository if RepositorySlug is set.
+type SetUpstream struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+
+	Url string
+	// Repository is the full upstream repository name for repository upstreams and an optional namespace for project
+	// upstreams.
+	Repository string
+	Username   *string
+	Password   *string
+	TagTtl     time.Duration
+}
+
+type SetUpstreamResponse struct {
+	Id uuid.UUID
+}
+
+func HandleSetUpstream(ctx context.Context, command SetUpstream) (*SetUpstreamResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	if command.RepositorySlug != nil && command.Repository == "" {
+		return nil, fmt.Errorf("repository upstreams need an upstream repository: %w", apiError.ErrApiBadRequest)
+	}
+
+	projectId, repositoryId, err := getOwner(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedPassword, err := encryptPassword(ctx, command.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := dbContext.Upstreams().First(ctx, upstreamOwnerFilter(projectId, repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("getting upstream: %w", err)
+	}
+
+	if upstream == nil {
+		if repositoryId != nil {
+			upstream = repositories.NewRepositoryUpstream(*repositoryId, command.Url, command.Repository, command.TagTtl)
+		} else {
+			upstream = repositories.NewProjectUpstream(projectId, command.Url, command.Repository, command.TagTtl)
+		}
+		upstream.SetCredentials(command.Username, encryptedPassword)
+
+		dbContext.Upstreams().Insert(upstream)
+
+		return &SetUpstreamResponse{
+			Id: upstream.GetId(),
+		}, nil
+	}
+
+	upstream.SetUrl(command.Url)
+	upstream.SetRepository(command.Repository)
+	upstream.SetCredentials(command.Username, encryptedPassword)
+	upstream.SetTagTtl(command.TagTtl)
+
+	dbContext.Upstreams().Update(upstream)
+
+	return &SetUpstreamResponse{
+		Id: upstream.GetId(),
+	}, nil
+}
+
+func upstreamOwnerFilter(projectId uuid.UUID, repositoryId *uuid.UUID) *repositories.UpstreamFilter {
+	if repositoryId != nil {
+		return repositories.NewUpstreamFilter().ByRepositoryId(*repositoryId)
+	}
+
+	return repositories.NewUpstreamFilter().ByProjectId(projectId)
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+type UpdateReplicationRule struct {
+	TenantSlug  string
+	ProjectSlug string
+	RuleId      uuid.UUID
+
+	Url       string
+	Namespace string
+	TagFilter *string
+	Username  *string
+	Password  *string
+	Enabled   bool
+}
+
+type UpdateReplicationRuleResponse struct{}
+
+func HandleUpdateReplicationRule(ctx context.Context, command UpdateReplicationRule) (*UpdateReplicationRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateTagFilter(command.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := getReplicationRule(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RuleId)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.SetUrl(command.Url)
+	rule.SetNamespace(command.Namespace)
+	rule.SetTagFilter(command.TagFilter)
+	encryptedPassword, err := encryptPassword(ctx, command.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.SetCredentials(command.Username, encryptedPassword)
+	rule.SetEnabled(command.Enabled)
+
+	dbContext.ReplicationRules().Update(rule)
+
+	return &UpdateReplicationRuleResponse{}, nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+type UpdateRetentionPolicy struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+	PolicyId       uuid.UUID
+
+	TagFilter       *string
+	KeepLast        *int
+	OlderThanDays   *int
+	NotPulledInDays *int
+	DeleteUntagged  bool
+	Enabled         bool
+}
+
+type UpdateRetentionPolicyResponse struct{}
+
+func HandleUpdateRetentionPolicy(ctx context.Context, command UpdateRetentionPolicy) (*UpdateRetentionPolicyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateRetentionPolicy(command.TagFilter, command.KeepLast, command.OlderThanDays, command.NotPulledInDays)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := getRetentionPolicy(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug, command.PolicyId)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.SetTagFilter(command.TagFilter)
+	policy.SetCriteria(command.KeepLast, command.OlderThanDays, command.NotPulledInDays)
+	policy.SetDeleteUntagged(command.DeleteUntagged)
+	policy.SetEnabled(command.Enabled)
+
+	dbContext.RetentionPolicies().Update(policy)
+
+	return &UpdateRetentionPolicyResponse{}, nil
+}
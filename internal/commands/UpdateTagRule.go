@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+type UpdateTagRule struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+	RuleId         uuid.UUID
+
+	Pattern string
+	// Kind is one of the repositories.TagRuleKind values.
+	Kind string
+}
+
+type UpdateTagRuleResponse struct{}
+
+func HandleUpdateTagRule(ctx context.Context, command UpdateTagRule) (*UpdateTagRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	err := validateTagRule(command.Pattern, repositories.TagRuleKind(command.Kind))
+	if err != nil {
+		return nil, err
+	}
+
+	tagRule, err := getTagRule(ctx, dbContext, command.TenantSlug, command.ProjectSlug, command.RepositorySlug, command.RuleId)
+	if err != nil {
+		return nil, err
+	}
+
+	tagRule.SetPattern(command.Pattern)
+	tagRule.SetKind(repositories.TagRuleKind(command.Kind))
+
+	dbContext.TagRules().Update(tagRule)
+
+	return &UpdateTagRuleResponse{}, nil
+}
@@ -4,23 +4,48 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"strings"
+	"slices"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 	"github.com/google/uuid"
 	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
 	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/storageUsage"
+	"github.com/the127/dockyard/internal/utils/digest"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
 type UploadManifest struct {
 	RepositoryId uuid.UUID
-	Reference    string
-	Digest       string
-	MediaType    string
-	Body         []byte
+	// UserId is the user pushing the manifest, it is checked against the tag rules of the repository.
+	UserId    uuid.UUID
+	Reference string
+	Digest    string
+	MediaType string
+	Body      []byte
+
+	// SubjectDigest is set when the manifest refers to another manifest through its subject field.
+	SubjectDigest *string
+	ArtifactType  string
+	Annotations   map[string]string
+
+	// Blobs are the config and layer descriptors of an image manifest, they must have been pushed to the repository.
+	Blobs []ManifestDescriptor
+	// Manifests are the child manifest descriptors of an image index, they must exist in the repository.
+	Manifests []ManifestDescriptor
+
+	// Proxied is set for manifests fetched into a pull-through cache, their blobs and child manifests are fetched
+	// lazily when they are pulled and are not checked. Tag rules do not apply, the cache follows the upstream.
+	Proxied bool
+}
+
+type ManifestDescriptor struct {
+	MediaType string
+	Digest    string
+	Size      int64
 }
 
 type UploadManifestResponse struct {
@@ -31,6 +56,43 @@ func HandleUploadManifest(ctx context.Context, command UploadManifest) (*UploadM
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[db.Context](scope)
 
+	if digest.IsDigest(command.Reference) && command.Reference != command.Digest {
+		return nil, ociError.NewOciError(ociError.DigestInvalid)
+	}
+
+	if !command.Proxied {
+		err := checkNotProxied(ctx, dbContext, command.RepositoryId)
+		if err != nil {
+			return nil, err
+		}
+
+		if !digest.IsDigest(command.Reference) {
+			err := checkTagPush(ctx, dbContext, command.RepositoryId, command.UserId, command.Reference, command.Digest)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, descriptor := range command.Blobs {
+			err := checkBlobDescriptor(ctx, dbContext, command.RepositoryId, descriptor)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, descriptor := range command.Manifests {
+			err := checkManifestDescriptor(ctx, dbContext, command.RepositoryId, descriptor)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = storageUsage.CheckQuota(ctx, dbContext, command.RepositoryId, command.Digest, int64(len(command.Body)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	blobService := ioc.GetDependency[blobStorage.Service](scope)
 	uploadResponse, err := blobService.UploadCompleteBlob(ctx, command.Digest, bytes.NewReader(command.Body), blobStorage.BlobContentType(command.MediaType))
 	if err != nil {
@@ -44,10 +106,6 @@ func HandleUploadManifest(ctx context.Context, command UploadManifest) (*UploadM
 
 	dbContext.RepositoryBlobs().Insert(repositories.NewRepositoryBlob(command.RepositoryId, blob.GetId()))
 
-	if strings.HasPrefix(command.Reference, "sha256:") && command.Reference != command.Digest {
-		return nil, ociError.NewOciError(ociError.DigestInvalid)
-	}
-
 	manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(command.RepositoryId).ByDigest(uploadResponse.Digest))
 	if err != nil {
 		return nil, fmt.Errorf("getting manifest: %w", err)
@@ -55,10 +113,38 @@ func HandleUploadManifest(ctx context.Context, command UploadManifest) (*UploadM
 	if manifest == nil {
 		manifest = repositories.NewManifest(command.RepositoryId, blob.GetId(), uploadResponse.Digest, command.MediaType)
 		dbContext.Manifests().Insert(manifest)
+
+		if command.SubjectDigest != nil {
+			dbContext.Referrers().Insert(repositories.NewReferrer(command.RepositoryId, manifest.GetId(), *command.SubjectDigest, command.ArtifactType, command.Annotations))
+		}
+
+		insertedDigests := make(map[string]bool)
+		for _, descriptor := range slices.Concat(command.Blobs, command.Manifests) {
+			if insertedDigests[descriptor.Digest] {
+				continue
+			}
+			insertedDigests[descriptor.Digest] = true
+
+			dbContext.ManifestReferences().Insert(repositories.NewManifestReference(command.RepositoryId, manifest.GetId(), descriptor.Digest, descriptor.MediaType))
+		}
 	}
 
-	if !strings.HasPrefix(command.Reference, "sha256:") {
+	if !digest.IsDigest(command.Reference) {
 		dbContext.Tags().Insert(repositories.NewTag(command.RepositoryId, manifest.GetId(), command.Reference))
+
+		// pull-through caches only mirror the upstream, replicating them would push the upstream content around
+		if !command.Proxied {
+			repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(command.RepositoryId))
+			if err != nil {
+				return nil, fmt.Errorf("getting repository: %w", err)
+			}
+
+			clockService := ioc.GetDependency[clock.Service](scope)
+			err = enqueueReplication(ctx, dbContext, repository.GetProjectId(), command.RepositoryId, command.Reference, clockService.Now())
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	err = dbContext.SaveChanges(ctx)
@@ -70,3 +156,55 @@ func HandleUploadManifest(ctx context.Context, command UploadManifest) (*UploadM
 		Digest: uploadResponse.Digest,
 	}, nil
 }
+
+// checkBlobDescriptor makes sure that a blob referenced by a manifest has been pushed to the repository.
+func checkBlobDescriptor(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, descriptor ManifestDescriptor) error {
+	blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ByDigest(descriptor.Digest))
+	if err != nil {
+		return fmt.Errorf("getting blob: %w", err)
+	}
+	if blob == nil {
+		return newManifestBlobUnknownError("blob", descriptor, "is unknown to the repository")
+	}
+
+	repositoryBlob, err := dbContext.RepositoryBlobs().First(ctx, repositories.NewRepositoryBlobFilter().ByRepositoryId(repositoryId).ByBlobId(blob.GetId()))
+	if err != nil {
+		return fmt.Errorf("getting repository blob: %w", err)
+	}
+	if repositoryBlob == nil {
+		return newManifestBlobUnknownError("blob", descriptor, "is unknown to the repository")
+	}
+
+	if blob.GetSize() != descriptor.Size {
+		return newManifestBlobUnknownError("blob", descriptor, fmt.Sprintf("has size %d, not %d", blob.GetSize(), descriptor.Size))
+	}
+
+	return nil
+}
+
+// checkManifestDescriptor makes sure that a manifest referenced by an image index has been pushed to the repository.
+func checkManifestDescriptor(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, descriptor ManifestDescriptor) error {
+	manifest, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId).ByDigest(descriptor.Digest))
+	if err != nil {
+		return fmt.Errorf("getting manifest: %w", err)
+	}
+	if manifest == nil {
+		return newManifestBlobUnknownError("manifest", descriptor, "is unknown to the repository")
+	}
+
+	blob, err := dbContext.Blobs().Single(ctx, repositories.NewBlobFilter().ById(manifest.GetBlobId()))
+	if err != nil {
+		return fmt.Errorf("getting manifest blob: %w", err)
+	}
+
+	if blob.GetSize() != descriptor.Size {
+		return newManifestBlobUnknownError("manifest", descriptor, fmt.Sprintf("has size %d, not %d", blob.GetSize(), descriptor.Size))
+	}
+
+	return nil
+}
+
+func newManifestBlobUnknownError(kind string, descriptor ManifestDescriptor, reason string) error {
+	return ociError.NewOciError(ociError.ManifestBlobUnknown).
+		WithMessage(fmt.Sprintf("referenced %s '%s' %s", kind, descriptor.Digest, reason))
+}
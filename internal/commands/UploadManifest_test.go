@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/database/inmemory"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/ociError"
+	"go.uber.org/zap"
+)
+
+const (
+	pushedDigest = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	taggedDigest = "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+)
+
+type CheckTagPushTestSuite struct {
+	suite.Suite
+}
+
+func TestCheckTagPushTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(CheckTagPushTestSuite))
+}
+
+func (s *CheckTagPushTestSuite) SetupSuite() {
+	if logging.Logger == nil {
+		logging.Logger = zap.NewNop().Sugar()
+	}
+}
+
+type tagRuleCase struct {
+	pattern string
+	kind    repositories.TagRuleKind
+	// onProject adds the rule to the project instead of the repository.
+	onProject bool
+}
+
+type userCase int
+
+const (
+	anonymous userCase = iota
+	repositoryAdmin
+	repositoryUser
+)
+
+// arrangeRepository creates a repository with the tag rules and the tag v1 pointing to taggedDigest. It returns the
+// db context, the id of the repository and the id of the user of the user case, which is uuid.Nil for anonymous.
+func (s *CheckTagPushTestSuite) arrangeRepository(ctx context.Context, rules []tagRuleCase, user userCase) (db.Context, uuid.UUID, uuid.UUID) {
+	database, err := inmemory.NewInMemoryDatabase()
+	s.Require().NoError(err)
+
+	dbContext, err := database.NewContext(ctx)
+	s.Require().NoError(err)
+
+	tenant := repositories.NewTenant("t", "t", repositories.NewTenantOidcConfig("client", "issuer", "roles", "array", nil))
+	dbContext.Tenants().Insert(tenant)
+
+	project := repositories.NewProject(tenant.GetId(), "p", "p")
+	dbContext.Projects().Insert(project)
+
+	repository := repositories.NewRepository(project.GetId(), "app", "app")
+	dbContext.Repositories().Insert(repository)
+
+	for _, rule := range rules {
+		if rule.onProject {
+			dbContext.TagRules().Insert(repositories.NewProjectTagRule(project.GetId(), rule.pattern, rule.kind))
+		} else {
+			dbContext.TagRules().Insert(repositories.NewRepositoryTagRule(repository.GetId(), rule.pattern, rule.kind))
+		}
+	}
+
+	manifest := repositories.NewManifest(repository.GetId(), uuid.New(), taggedDigest, "application/vnd.oci.image.manifest.v1+json")
+	dbContext.Manifests().Insert(manifest)
+	dbContext.Tags().Insert(repositories.NewTag(repository.GetId(), manifest.GetId(), "v1"))
+
+	userId := uuid.Nil
+	if user != anonymous {
+		account := repositories.NewUser(tenant.GetId(), "subject")
+		dbContext.Users().Insert(account)
+		userId = account.GetId()
+
+		role := repositories.RepositoryAccessRoleUser
+		if user == repositoryAdmin {
+			role = repositories.RepositoryAccessRoleAdmin
+		}
+		dbContext.RepositoryAccess().Insert(repositories.NewRepositoryAccess(repository.GetId(), userId, role))
+	}
+
+	s.Require().NoError(dbContext.SaveChanges(ctx))
+
+	return dbContext, repository.GetId(), userId
+}
+
+func (s *CheckTagPushTestSuite) TestCheckTagPush() {
+	testCases := []struct {
+		name   string
+		rules  []tagRuleCase
+		user   userCase
+		tag    string
+		digest string
+		denied bool
+	}{
+		{
+			name: "no rules",
+			tag:  "v1",
+			user: repositoryUser,
+		},
+		{
+			name:   "immutable tag is overwritten",
+			rules:  []tagRuleCase{{pattern: "v*", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryUser,
+			tag:    "v1",
+			digest: pushedDigest,
+			denied: true,
+		},
+		{
+			name:   "immutable tag is pushed again with the same manifest",
+			rules:  []tagRuleCase{{pattern: "v*", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryUser,
+			tag:    "v1",
+			digest: taggedDigest,
+		},
+		{
+			name:   "new tag matching an immutable pattern",
+			rules:  []tagRuleCase{{pattern: "v*", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryUser,
+			tag:    "v2",
+			digest: pushedDigest,
+		},
+		{
+			name:   "immutable tag is overwritten by an admin",
+			rules:  []tagRuleCase{{pattern: "v*", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryAdmin,
+			tag:    "v1",
+			digest: pushedDigest,
+			denied: true,
+		},
+		{
+			name:   "immutable project rule",
+			rules:  []tagRuleCase{{pattern: "v1", kind: repositories.TagRuleKindImmutable, onProject: true}},
+			user:   repositoryUser,
+			tag:    "v1",
+			digest: pushedDigest,
+			denied: true,
+		},
+		{
+			name:   "pattern does not match",
+			rules:  []tagRuleCase{{pattern: "release-*", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryUser,
+			tag:    "v1",
+			digest: pushedDigest,
+		},
+		{
+			name:   "pattern has to match the whole tag",
+			rules:  []tagRuleCase{{pattern: "v", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryUser,
+			tag:    "v1",
+			digest: pushedDigest,
+		},
+		{
+			name:   "malformed pattern matches no tag",
+			rules:  []tagRuleCase{{pattern: "v[", kind: repositories.TagRuleKindImmutable}},
+			user:   repositoryUser,
+			tag:    "v1",
+			digest: pushedDigest,
+		},
+		{
+			name:   "admin only tag pushed by an admin",
+			rules:  []tagRuleCase{{pattern: "latest", kind: repositories.TagRuleKindAdminOnly}},
+			user:   repositoryAdmin,
+			tag:    "latest",
+			digest: pushedDigest,
+		},
+		{
+			name:   "admin only tag pushed by a user",
+			rules:  []tagRuleCase{{pattern: "latest", kind: repositories.TagRuleKindAdminOnly}},
+			user:   repositoryUser,
+			tag:    "latest",
+			digest: pushedDigest,
+			denied: true,
+		},
+		{
+			name:   "admin only tag pushed anonymously",
+			rules:  []tagRuleCase{{pattern: "latest", kind: repositories.TagRuleKindAdminOnly, onProject: true}},
+			user:   anonymous,
+			tag:    "latest",
+			digest: pushedDigest,
+			denied: true,
+		},
+		{
+			name: "user is denied by one of several matching rules",
+			rules: []tagRuleCase{
+				{pattern: "v*", kind: repositories.TagRuleKindImmutable, onProject: true},
+				{pattern: "v2", kind: repositories.TagRuleKindAdminOnly},
+			},
+			user:   repositoryUser,
+			tag:    "v2",
+			digest: pushedDigest,
+			denied: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			ctx := context.Background()
+			dbContext, repositoryId, userId := s.arrangeRepository(ctx, testCase.rules, testCase.user)
+
+			// act
+			err := checkTagPush(ctx, dbContext, repositoryId, userId, testCase.tag, testCase.digest)
+
+			// assert
+			if !testCase.denied {
+				s.NoError(err)
+				return
+			}
+
+			var ociErr *ociError.OciError
+			s.Require().True(errors.As(err, &ociErr))
+			s.Equal(ociError.Denied, ociErr.Code)
+		})
+	}
+}
@@ -2,10 +2,24 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"time"
 
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
 	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/services/registryClient"
+	"github.com/the127/dockyard/internal/services/secrets"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
 func getOrCreateBlob(ctx context.Context, dbContext database.Context, digest string, size int64) (*repositories.Blob, error) {
@@ -14,12 +28,50 @@ func getOrCreateBlob(ctx context.Context, dbContext database.Context, digest str
 		return nil, fmt.Errorf("getting blob: %w", err)
 	}
 	if blob == nil {
+		err = checkBlobDataAvailable(ctx, digest)
+		if err != nil {
+			return nil, err
+		}
+
 		blob = repositories.NewBlob(digest, size)
 		dbContext.Blobs().Insert(blob)
 	}
 	return blob, nil
 }
 
+// checkBlobDataAvailable makes sure that the data of a new blob was not removed while it was uploaded. Blob data is
+// stored by digest, so the garbage collection of an unused blob with the same digest may delete it.
+func checkBlobDataAvailable(ctx context.Context, digest string) error {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+
+	_, collecting, err := kvStore.Get(ctx, buildBlobCollectionLockKey(digest))
+	if err != nil {
+		return fmt.Errorf("getting blob collection lock: %w", err)
+	}
+	if collecting {
+		return newBlobCollectedError(digest)
+	}
+
+	reader, err := blobService.OpenBlob(ctx, digest)
+	var ociErr *ociError.OciError
+	if errors.As(err, &ociErr) && ociErr.Code == ociError.BlobUnknown {
+		return newBlobCollectedError(digest)
+	}
+	if err != nil {
+		return fmt.Errorf("opening blob: %w", err)
+	}
+
+	return reader.Close()
+}
+
+func newBlobCollectedError(digest string) error {
+	return ociError.NewOciError(ociError.BlobUploadInvalid).
+		WithMessage(fmt.Sprintf("blob '%s' was garbage collected during the upload, please retry", digest)).
+		WithHttpCode(http.StatusConflict)
+}
+
 func getRepository(ctx context.Context, dbContext database.Context, tenantSlug, projectSlug, repositorySlug string) (*repositories.Tenant, *repositories.Project, *repositories.Repository, error) {
 	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(tenantSlug))
 	if err != nil {
@@ -38,3 +90,346 @@ func getRepository(ctx context.Context, dbContext database.Context, tenantSlug,
 
 	return tenant, project, repository, nil
 }
+
+// unlinkRepositoryBlob removes the link between a repository and a blob. The blob itself and its data are left to the
+// garbage collection, which removes them under the blob collection lock once no repository is linked to the blob
+// anymore, so that a concurrent push of the same blob never loses its data.
+func unlinkRepositoryBlob(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID, blobId uuid.UUID) error {
+	repositoryBlob, err := dbContext.RepositoryBlobs().First(ctx, repositories.NewRepositoryBlobFilter().ByRepositoryId(repositoryId).ByBlobId(blobId))
+	if err != nil {
+		return fmt.Errorf("getting repository blob: %w", err)
+	}
+	if repositoryBlob != nil {
+		dbContext.RepositoryBlobs().Delete(repositoryBlob)
+	}
+
+	return nil
+}
+
+// getProxyRegistry returns the upstream registry the repository is a pull-through cache of, or nil for regular
+// repositories.
+func getProxyRegistry(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) (*registryClient.Registry, *repositories.Upstream, error) {
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(repositoryId))
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting repository: %w", err)
+	}
+
+	upstream, err := getUpstream(ctx, dbContext, repository)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upstream == nil {
+		return nil, nil, nil
+	}
+
+	password, err := decryptPassword(ctx, upstream.GetEncryptedPassword())
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypting password of upstream %s: %w", upstream.GetId(), err)
+	}
+
+	return &registryClient.Registry{
+		Url:        upstream.GetUrl(),
+		Repository: upstream.ResolveRepository(repository.GetSlug()),
+		Username:   upstream.GetUsername(),
+		Password:   password,
+	}, upstream, nil
+}
+
+// encryptPassword encrypts the password of a remote registry for storing it, nil stays nil.
+func encryptPassword(ctx context.Context, password *string) ([]byte, error) {
+	if password == nil {
+		return nil, nil
+	}
+
+	secretsService := ioc.GetDependency[secrets.Service](middlewares.GetScope(ctx))
+	encrypted, err := secretsService.Encrypt(*password)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting password: %w", err)
+	}
+
+	return encrypted, nil
+}
+
+func decryptPassword(ctx context.Context, encryptedPassword []byte) (*string, error) {
+	if encryptedPassword == nil {
+		return nil, nil
+	}
+
+	secretsService := ioc.GetDependency[secrets.Service](middlewares.GetScope(ctx))
+	password, err := secretsService.Decrypt(encryptedPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return &password, nil
+}
+
+// checkNotProxied rejects pushes into pull-through cache repositories, their content only ever comes from the
+// upstream.
+func checkNotProxied(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) error {
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(repositoryId))
+	if err != nil {
+		return fmt.Errorf("getting repository: %w", err)
+	}
+
+	upstream, err := getUpstream(ctx, dbContext, repository)
+	if err != nil {
+		return err
+	}
+	if upstream != nil {
+		return ociError.NewOciError(ociError.Denied).
+			WithMessage("the repository is a pull-through cache, it cannot be pushed to").
+			WithHttpCode(http.StatusForbidden)
+	}
+
+	return nil
+}
+
+// getUpstream returns the upstream the repository is a pull-through cache of, or nil for regular repositories. An
+// upstream configured on the repository takes precedence over the one of its project.
+func getUpstream(ctx context.Context, dbContext database.Context, repository *repositories.Repository) (*repositories.Upstream, error) {
+	upstream, err := dbContext.Upstreams().First(ctx, repositories.NewUpstreamFilter().ByRepositoryId(repository.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting repository upstream: %w", err)
+	}
+	if upstream != nil {
+		return upstream, nil
+	}
+
+	upstream, err = dbContext.Upstreams().First(ctx, repositories.NewUpstreamFilter().ByProjectId(repository.GetProjectId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting project upstream: %w", err)
+	}
+
+	return upstream, nil
+}
+
+// enqueueReplication queues the replication of the tag for every enabled replication rule of the project whose tag
+// filter matches. A task that is already queued for the tag is rescheduled instead, so pushing a tag repeatedly does
+// not pile up tasks.
+func enqueueReplication(ctx context.Context, dbContext database.Context, projectId uuid.UUID, repositoryId uuid.UUID, tag string, now time.Time) error {
+	rules, _, err := dbContext.ReplicationRules().List(ctx, repositories.NewReplicationRuleFilter().ByProjectId(projectId).ByEnabled(true))
+	if err != nil {
+		return fmt.Errorf("listing replication rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		if !rule.MatchesTag(tag) {
+			continue
+		}
+
+		err = enqueueReplicationTask(ctx, dbContext, rule, repositoryId, tag, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func enqueueReplicationTask(ctx context.Context, dbContext database.Context, rule *repositories.ReplicationRule, repositoryId uuid.UUID, tag string, now time.Time) error {
+	task, err := dbContext.ReplicationTasks().First(ctx, repositories.NewReplicationTaskFilter().ByReplicationRuleId(rule.GetId()).ByRepositoryId(repositoryId).ByTag(tag))
+	if err != nil {
+		return fmt.Errorf("getting replication task: %w", err)
+	}
+
+	if task != nil {
+		task.Reset(now)
+		dbContext.ReplicationTasks().Update(task)
+		return nil
+	}
+
+	dbContext.ReplicationTasks().Insert(repositories.NewReplicationTask(rule.GetId(), repositoryId, tag, now))
+	return nil
+}
+
+func getProject(ctx context.Context, dbContext database.Context, tenantSlug string, projectSlug string) (*repositories.Project, error) {
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(tenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(projectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	return project, nil
+}
+
+// getReplicationRule returns the replication rule of the project, rules of other projects are reported as not found.
+func getReplicationRule(ctx context.Context, dbContext database.Context, tenantSlug string, projectSlug string, ruleId uuid.UUID) (*repositories.ReplicationRule, error) {
+	project, err := getProject(ctx, dbContext, tenantSlug, projectSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := dbContext.ReplicationRules().Single(ctx, repositories.NewReplicationRuleFilter().ByProjectId(project.GetId()).ById(ruleId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replication rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func validateTagFilter(tagFilter *string) error {
+	if tagFilter == nil {
+		return nil
+	}
+
+	_, err := regexp.Compile(*tagFilter)
+	if err != nil {
+		return fmt.Errorf("invalid tag filter: %s: %w", err, apiError.ErrApiBadRequest)
+	}
+
+	return nil
+}
+
+// getTagRules returns the tag rules of the repository together with the ones of its project.
+func getTagRules(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) ([]*repositories.TagRule, error) {
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("getting repository: %w", err)
+	}
+
+	projectRules, _, err := dbContext.TagRules().List(ctx, repositories.NewTagRuleFilter().ByProjectId(repository.GetProjectId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing project tag rules: %w", err)
+	}
+
+	repositoryRules, _, err := dbContext.TagRules().List(ctx, repositories.NewTagRuleFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing repository tag rules: %w", err)
+	}
+
+	return append(projectRules, repositoryRules...), nil
+}
+
+// checkTagPush makes sure that the tag rules of the repository allow the user to point the tag at the manifest.
+// Pushing the manifest an immutable tag already points to again is allowed, so that retried pushes succeed.
+func checkTagPush(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID, userId uuid.UUID, tagName string, manifestDigest string) error {
+	rules, err := getTagRules(ctx, dbContext, repositoryId)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if !rule.Matches(tagName) {
+			continue
+		}
+
+		switch rule.GetKind() {
+		case repositories.TagRuleKindImmutable:
+			currentDigest, err := getCachedManifestDigest(ctx, dbContext, repositoryId, tagName)
+			if err != nil {
+				return err
+			}
+			if currentDigest != nil && *currentDigest != manifestDigest {
+				return newTagDeniedError(tagName, rule, "is immutable and cannot be overwritten")
+			}
+
+		case repositories.TagRuleKindAdminOnly:
+			isAdmin, err := isRepositoryAdmin(ctx, dbContext, repositoryId, userId)
+			if err != nil {
+				return err
+			}
+			if !isAdmin {
+				return newTagDeniedError(tagName, rule, "can only be pushed by repository admins")
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkTagDelete makes sure that none of the tags is protected by an immutable tag rule of the repository.
+func checkTagDelete(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID, tagNames ...string) error {
+	rules, err := getTagRules(ctx, dbContext, repositoryId)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if rule.GetKind() != repositories.TagRuleKindImmutable {
+			continue
+		}
+
+		for _, tagName := range tagNames {
+			if rule.Matches(tagName) {
+				return newTagDeniedError(tagName, rule, "is immutable and cannot be deleted")
+			}
+		}
+	}
+
+	return nil
+}
+
+func isRepositoryAdmin(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID, userId uuid.UUID) (bool, error) {
+	if userId == uuid.Nil {
+		return false, nil
+	}
+
+	repositoryAccess, err := dbContext.RepositoryAccess().First(ctx, repositories.NewRepositoryAccessFilter().ByRepositoryId(repositoryId).ByUserId(userId))
+	if err != nil {
+		return false, fmt.Errorf("getting repository access: %w", err)
+	}
+
+	return repositoryAccess != nil && repositoryAccess.GetRole() == repositories.RepositoryAccessRoleAdmin, nil
+}
+
+func newTagDeniedError(tagName string, rule *repositories.TagRule, reason string) error {
+	return ociError.NewOciError(ociError.Denied).
+		WithMessage(fmt.Sprintf("tag '%s' %s, it matches the tag rule '%s'", tagName, reason, rule.GetPattern())).
+		WithHttpCode(http.StatusForbidden)
+}
+
+// getOwner resolves the project and, if the repository slug is set, the repository that owns an upstream or a tag rule.
+func getOwner(ctx context.Context, dbContext database.Context, tenantSlug string, projectSlug string, repositorySlug *string) (uuid.UUID, *uuid.UUID, error) {
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(tenantSlug))
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(projectSlug))
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	if repositorySlug == nil {
+		return project.GetId(), nil, nil
+	}
+
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()).BySlug(*repositorySlug))
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	repositoryId := repository.GetId()
+	return project.GetId(), &repositoryId, nil
+}
+
+// tryLock takes the lock with the given key, false is returned if someone else holds it. The returned function releases
+// the lock again.
+func tryLock(ctx context.Context, kvStore kv.Store, lockKey string, expiration time.Duration) (func(), bool, error) {
+	// the token identifies this holder, so that it does not release a lock someone else took after this one expired
+	token := uuid.New().String()
+
+	locked, err := kvStore.SetIfNotExists(ctx, lockKey, token, kv.WithExpiration(expiration))
+	if err != nil {
+		return nil, false, err
+	}
+	if !locked {
+		return nil, false, nil
+	}
+
+	return func() {
+		released, err := kvStore.DeleteIfEquals(context.WithoutCancel(ctx), lockKey, token)
+		if err != nil {
+			logging.Logger.Errorf("releasing lock %s: %v", lockKey, err)
+			return
+		}
+		if !released {
+			logging.Logger.Warnf("lock %s expired before it was released", lockKey)
+		}
+	}, true, nil
+}
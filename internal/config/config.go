@@ -1,11 +1,15 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"net/netip"
 	"net/url"
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 
 	"github.com/the127/dockyard/internal/args"
@@ -23,6 +27,7 @@ type Config struct {
 	Kv            KvConfig
 	Blob          BlobStorageConfig
 	Kms           KmsConfig
+	Gc            GcConfig
 }
 
 type KmsMode string
@@ -33,6 +38,10 @@ const (
 
 type KmsConfig struct {
 	Mode KmsMode
+	// EncryptionKey is the base64 encoded 32 byte key that secrets stored in the database are encrypted with, e.g. the
+	// passwords of upstream registries and replication targets. Outside of production a random key is generated if it
+	// is not set, stored secrets then cannot be read after a restart.
+	EncryptionKey string
 }
 
 type InitialTenantConfig struct {
@@ -113,9 +122,21 @@ const (
 )
 
 type BlobStorageConfig struct {
-	Mode      BlobStorageMode
-	Directory DirectoryBlobStorageConfig
-	S3        S3BlobStorageConfig
+	Mode BlobStorageMode
+	// UploadSessionTtl is how long an upload session is kept without receiving data, every chunk extends it.
+	UploadSessionTtl time.Duration
+	// ExternalUrl is the URL under which clients reach the blob API, e.g. a CDN in front of it. It defaults to
+	// Server.ExternalUrl.
+	ExternalUrl string
+	// LinkExpiry is how long the signed download links of the blob API are valid.
+	LinkExpiry time.Duration
+	// BindLinksToClientIp restricts signed download links to the IP address of the client they were issued to.
+	BindLinksToClientIp bool
+	// TrustedProxies are the networks of reverse proxies in front of dockyard, e.g. 10.0.0.0/8. The client IP of
+	// requests they forward is taken from the X-Forwarded-For header, otherwise every client behind a proxy has its IP.
+	TrustedProxies []string
+	Directory      DirectoryBlobStorageConfig
+	S3             S3BlobStorageConfig
 }
 
 type DirectoryBlobStorageConfig struct {
@@ -124,6 +145,31 @@ type DirectoryBlobStorageConfig struct {
 }
 
 type S3BlobStorageConfig struct {
+	// Endpoint is the URL of the S3 API, e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000.
+	Endpoint string
+	Bucket   string
+	Region   string
+	// PathStyle addresses the bucket in the path instead of the host name, most S3-compatible stores require it.
+	PathStyle bool
+	// AccessKeyId and SecretAccessKey are taken from the AWS environment variables or the instance role if not set.
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	// Prefix is prepended to the keys of all objects, it allows to share a bucket.
+	Prefix string
+	// PresignExpiry is how long the presigned download links handed to clients are valid.
+	PresignExpiry time.Duration
+	// TempPath is where chunks are buffered before they are uploaded as parts, it defaults to the temp directory of the
+	// system.
+	TempPath string
+}
+
+type GcConfig struct {
+	// Disabled turns off the scheduled garbage collection, it can still be run with the gc subcommand.
+	Disabled bool
+	Interval time.Duration
+	// GracePeriod protects blobs that were uploaded or linked recently, it has to be longer than the longest push.
+	GracePeriod time.Duration
 }
 
 var C Config
@@ -174,6 +220,8 @@ func setDefaultsOrPanic() {
 	setDatabaseDefaultsOrPanic()
 	setKvDefaultsOrPanic()
 	setBlobDefaultsOrPanic()
+	setKmsDefaultsOrPanic()
+	setGcDefaults()
 }
 
 func setServerDefaultsOrPanic() {
@@ -335,6 +383,25 @@ func setBlobDefaultsOrPanic() {
 		C.Blob.Mode = BlobStorageModeInMemory
 	}
 
+	if C.Blob.UploadSessionTtl == 0 {
+		C.Blob.UploadSessionTtl = 5 * time.Minute
+	}
+
+	if C.Blob.ExternalUrl == "" {
+		C.Blob.ExternalUrl = C.Server.ExternalUrl
+	}
+
+	if C.Blob.LinkExpiry == 0 {
+		C.Blob.LinkExpiry = 20 * time.Minute
+	}
+
+	for _, trustedProxy := range C.Blob.TrustedProxies {
+		_, err := netip.ParsePrefix(trustedProxy)
+		if err != nil {
+			panic(fmt.Errorf("Blob.TrustedProxies must be networks in CIDR notation: %w", err))
+		}
+	}
+
 	switch C.Blob.Mode {
 	case BlobStorageModeInMemory:
 		return
@@ -342,6 +409,9 @@ func setBlobDefaultsOrPanic() {
 	case BlobStorageModeDirectory:
 		setBlobStorageDirectoryDefaultsOrPanic()
 
+	case BlobStorageModeS3:
+		setBlobStorageS3DefaultsOrPanic()
+
 	default:
 		panic(fmt.Errorf("unsupported blob storage mode: %s", C.Blob.Mode))
 	}
@@ -356,3 +426,60 @@ func setBlobStorageDirectoryDefaultsOrPanic() {
 		C.Blob.Directory.TempPath = C.Blob.Directory.Path + "/temp"
 	}
 }
+
+func setBlobStorageS3DefaultsOrPanic() {
+	if C.Blob.S3.Bucket == "" {
+		panic("Blob.S3.Bucket must be set.")
+	}
+
+	if C.Blob.S3.Region == "" {
+		C.Blob.S3.Region = "us-east-1"
+	}
+
+	if C.Blob.S3.Endpoint == "" {
+		C.Blob.S3.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", C.Blob.S3.Region)
+	}
+
+	if C.Blob.S3.PresignExpiry == 0 {
+		C.Blob.S3.PresignExpiry = 20 * time.Minute
+	}
+
+	if C.Blob.S3.TempPath == "" {
+		C.Blob.S3.TempPath = os.TempDir() + "/dockyard"
+	}
+}
+
+func setKmsDefaultsOrPanic() {
+	if C.Kms.EncryptionKey == "" {
+		if args.IsProduction() {
+			panic("Kms.EncryptionKey must be set in production.")
+		}
+
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			panic(fmt.Errorf("failed to generate encryption key: %w", err))
+		}
+
+		C.Kms.EncryptionKey = base64.StdEncoding.EncodeToString(key)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(C.Kms.EncryptionKey)
+	if err != nil {
+		panic(fmt.Errorf("failed to decode Kms.EncryptionKey: %w", err))
+	}
+
+	if len(key) != 32 {
+		panic("Kms.EncryptionKey must be 32 bytes long.")
+	}
+}
+
+func setGcDefaults() {
+	if C.Gc.Interval == 0 {
+		C.Gc.Interval = 24 * time.Hour
+	}
+
+	if C.Gc.GracePeriod == 0 {
+		C.Gc.GracePeriod = 24 * time.Hour
+	}
+}
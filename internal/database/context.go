@@ -19,6 +19,15 @@ const (
 	BlobType
 	RepositoryBlobType
 	FileType
+	ReferrerType
+	ManifestReferenceType
+	UpstreamType
+	ReplicationRuleType
+	ReplicationTaskType
+	TagRuleType
+	RetentionPolicyType
+	UploadSessionType
+	StorageUsageType
 )
 
 type Context interface {
@@ -34,6 +43,15 @@ type Context interface {
 	Blobs() repositories.BlobRepository
 	RepositoryBlobs() repositories.RepositoryBlobRepository
 	Files() repositories.FileRepository
+	Referrers() repositories.ReferrerRepository
+	ManifestReferences() repositories.ManifestReferenceRepository
+	Upstreams() repositories.UpstreamRepository
+	ReplicationRules() repositories.ReplicationRuleRepository
+	ReplicationTasks() repositories.ReplicationTaskRepository
+	TagRules() repositories.TagRuleRepository
+	RetentionPolicies() repositories.RetentionPolicyRepository
+	UploadSessions() repositories.UploadSessionRepository
+	StorageUsages() repositories.StorageUsageRepository
 
 	SaveChanges(ctx context.Context) error
 }
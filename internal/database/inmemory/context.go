@@ -16,18 +16,27 @@ type Context struct {
 	txn           *memdb.Txn
 	changeTracker *change.Tracker
 
-	tenants          *inmemory.TenantRepository
-	projects         *inmemory.ProjectRepository
-	projectAccess    *inmemory.ProjectAccessRepository
-	users            *inmemory.UserRepository
-	pats             *inmemory.PatRepository
-	repos            *inmemory.RepositoryRepository
-	repositoryAccess *inmemory.RepositoryAccessRepository
-	manifest         *inmemory.ManifestRepository
-	tags             *inmemory.TagRepository
-	blobs            *inmemory.BlobRepository
-	repositoryBlobs  *inmemory.RepositoryBlobRepository
-	files            *inmemory.FileRepository
+	tenants            *inmemory.TenantRepository
+	projects           *inmemory.ProjectRepository
+	projectAccess      *inmemory.ProjectAccessRepository
+	users              *inmemory.UserRepository
+	pats               *inmemory.PatRepository
+	repos              *inmemory.RepositoryRepository
+	repositoryAccess   *inmemory.RepositoryAccessRepository
+	manifest           *inmemory.ManifestRepository
+	tags               *inmemory.TagRepository
+	blobs              *inmemory.BlobRepository
+	repositoryBlobs    *inmemory.RepositoryBlobRepository
+	files              *inmemory.FileRepository
+	referrers          *inmemory.ReferrerRepository
+	manifestReferences *inmemory.ManifestReferenceRepository
+	upstreams          *inmemory.UpstreamRepository
+	replicationRules   *inmemory.ReplicationRuleRepository
+	replicationTasks   *inmemory.ReplicationTaskRepository
+	tagRules           *inmemory.TagRuleRepository
+	retentionPolicies  *inmemory.RetentionPolicyRepository
+	uploadSessions     *inmemory.UploadSessionRepository
+	storageUsages      *inmemory.StorageUsageRepository
 }
 
 func newContext(db *memdb.MemDB) *Context {
@@ -122,8 +131,72 @@ func (c *Context) Files() repositories.FileRepository {
 	return c.files
 }
 
+func (c *Context) Referrers() repositories.ReferrerRepository {
+	if c.referrers == nil {
+		c.referrers = inmemory.NewInMemoryReferrerRepository(c.txn, c.changeTracker, db.ReferrerType)
+	}
+	return c.referrers
+}
+
+func (c *Context) ManifestReferences() repositories.ManifestReferenceRepository {
+	if c.manifestReferences == nil {
+		c.manifestReferences = inmemory.NewInMemoryManifestReferenceRepository(c.txn, c.changeTracker, db.ManifestReferenceType)
+	}
+	return c.manifestReferences
+}
+
+func (c *Context) Upstreams() repositories.UpstreamRepository {
+	if c.upstreams == nil {
+		c.upstreams = inmemory.NewInMemoryUpstreamRepository(c.txn, c.changeTracker, db.UpstreamType)
+	}
+	return c.upstreams
+}
+
+func (c *Context) ReplicationRules() repositories.ReplicationRuleRepository {
+	if c.replicationRules == nil {
+		c.replicationRules = inmemory.NewInMemoryReplicationRuleRepository(c.txn, c.changeTracker, db.ReplicationRuleType)
+	}
+	return c.replicationRules
+}
+
+func (c *Context) ReplicationTasks() repositories.ReplicationTaskRepository {
+	if c.replicationTasks == nil {
+		c.replicationTasks = inmemory.NewInMemoryReplicationTaskRepository(c.txn, c.changeTracker, db.ReplicationTaskType)
+	}
+	return c.replicationTasks
+}
+
+func (c *Context) TagRules() repositories.TagRuleRepository {
+	if c.tagRules == nil {
+		c.tagRules = inmemory.NewInMemoryTagRuleRepository(c.txn, c.changeTracker, db.TagRuleType)
+	}
+	return c.tagRules
+}
+
+func (c *Context) RetentionPolicies() repositories.RetentionPolicyRepository {
+	if c.retentionPolicies == nil {
+		c.retentionPolicies = inmemory.NewInMemoryRetentionPolicyRepository(c.txn, c.changeTracker, db.RetentionPolicyType)
+	}
+	return c.retentionPolicies
+}
+
+func (c *Context) UploadSessions() repositories.UploadSessionRepository {
+	if c.uploadSessions == nil {
+		c.uploadSessions = inmemory.NewInMemoryUploadSessionRepository(c.txn, c.changeTracker, db.UploadSessionType)
+	}
+	return c.uploadSessions
+}
+
+func (c *Context) StorageUsages() repositories.StorageUsageRepository {
+	if c.storageUsages == nil {
+		c.storageUsages = inmemory.NewInMemoryStorageUsageRepository(c.txn, c.changeTracker, db.StorageUsageType)
+	}
+	return c.storageUsages
+}
+
 func (c *Context) SaveChanges(ctx context.Context) error {
 	tx := c.db.Txn(true)
+	defer tx.Abort()
 
 	changes := c.changeTracker.GetChanges()
 	for _, changeEntry := range changes {
@@ -134,6 +207,9 @@ func (c *Context) SaveChanges(ctx context.Context) error {
 	}
 
 	tx.Commit()
+
+	// start over with a new snapshot, so reads after saving see the committed changes like they do with postgres
+	*c = *newContext(c.db)
 	return nil
 }
 
@@ -175,6 +251,33 @@ func (c *Context) applyChange(tx *memdb.Txn, entry *change.Entry) error {
 	case db.FileType:
 		return c.applyFileChange(tx, entry)
 
+	case db.ReferrerType:
+		return c.applyReferrerChange(tx, entry)
+
+	case db.ManifestReferenceType:
+		return c.applyManifestReferenceChange(tx, entry)
+
+	case db.UpstreamType:
+		return c.applyUpstreamChange(tx, entry)
+
+	case db.ReplicationRuleType:
+		return c.applyReplicationRuleChange(tx, entry)
+
+	case db.ReplicationTaskType:
+		return c.applyReplicationTaskChange(tx, entry)
+
+	case db.TagRuleType:
+		return c.applyTagRuleChange(tx, entry)
+
+	case db.RetentionPolicyType:
+		return c.applyRetentionPolicyChange(tx, entry)
+
+	case db.UploadSessionType:
+		return c.applyUploadSessionChange(tx, entry)
+
+	case db.StorageUsageType:
+		return c.applyStorageUsageChange(tx, entry)
+
 	default:
 		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
 	}
@@ -313,6 +416,9 @@ func (c *Context) applyTagChange(tx *memdb.Txn, entry *change.Entry) error {
 	case change.Added:
 		return c.tags.ExecuteInsert(tx, entry.GetItem().(*repositories.Tag))
 
+	case change.Updated:
+		return c.tags.ExecuteUpdate(tx, entry.GetItem().(*repositories.Tag))
+
 	case change.Deleted:
 		return c.tags.ExecuteDelete(tx, entry.GetItem().(*repositories.Tag))
 
@@ -359,3 +465,141 @@ func (c *Context) applyFileChange(tx *memdb.Txn, entry *change.Entry) error {
 		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
 	}
 }
+
+func (c *Context) applyReferrerChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.referrers.ExecuteInsert(tx, entry.GetItem().(*repositories.Referrer))
+
+	case change.Deleted:
+		return c.referrers.ExecuteDelete(tx, entry.GetItem().(*repositories.Referrer))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyManifestReferenceChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.manifestReferences.ExecuteInsert(tx, entry.GetItem().(*repositories.ManifestReference))
+
+	case change.Deleted:
+		return c.manifestReferences.ExecuteDelete(tx, entry.GetItem().(*repositories.ManifestReference))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyUpstreamChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.upstreams.ExecuteInsert(tx, entry.GetItem().(*repositories.Upstream))
+
+	case change.Updated:
+		return c.upstreams.ExecuteUpdate(tx, entry.GetItem().(*repositories.Upstream))
+
+	case change.Deleted:
+		return c.upstreams.ExecuteDelete(tx, entry.GetItem().(*repositories.Upstream))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyReplicationRuleChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.replicationRules.ExecuteInsert(tx, entry.GetItem().(*repositories.ReplicationRule))
+
+	case change.Updated:
+		return c.replicationRules.ExecuteUpdate(tx, entry.GetItem().(*repositories.ReplicationRule))
+
+	case change.Deleted:
+		return c.replicationRules.ExecuteDelete(tx, entry.GetItem().(*repositories.ReplicationRule))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyReplicationTaskChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.replicationTasks.ExecuteInsert(tx, entry.GetItem().(*repositories.ReplicationTask))
+
+	case change.Updated:
+		return c.replicationTasks.ExecuteUpdate(tx, entry.GetItem().(*repositories.ReplicationTask))
+
+	case change.Deleted:
+		return c.replicationTasks.ExecuteDelete(tx, entry.GetItem().(*repositories.ReplicationTask))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyTagRuleChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.tagRules.ExecuteInsert(tx, entry.GetItem().(*repositories.TagRule))
+
+	case change.Updated:
+		return c.tagRules.ExecuteUpdate(tx, entry.GetItem().(*repositories.TagRule))
+
+	case change.Deleted:
+		return c.tagRules.ExecuteDelete(tx, entry.GetItem().(*repositories.TagRule))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyRetentionPolicyChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.retentionPolicies.ExecuteInsert(tx, entry.GetItem().(*repositories.RetentionPolicy))
+
+	case change.Updated:
+		return c.retentionPolicies.ExecuteUpdate(tx, entry.GetItem().(*repositories.RetentionPolicy))
+
+	case change.Deleted:
+		return c.retentionPolicies.ExecuteDelete(tx, entry.GetItem().(*repositories.RetentionPolicy))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyUploadSessionChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.uploadSessions.ExecuteInsert(tx, entry.GetItem().(*repositories.UploadSession))
+
+	case change.Updated:
+		return c.uploadSessions.ExecuteUpdate(tx, entry.GetItem().(*repositories.UploadSession))
+
+	case change.Deleted:
+		return c.uploadSessions.ExecuteDelete(tx, entry.GetItem().(*repositories.UploadSession))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyStorageUsageChange(tx *memdb.Txn, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.storageUsages.ExecuteInsert(tx, entry.GetItem().(*repositories.StorageUsage))
+
+	case change.Updated:
+		return c.storageUsages.ExecuteUpdate(tx, entry.GetItem().(*repositories.StorageUsage))
+
+	case change.Deleted:
+		return c.storageUsages.ExecuteDelete(tx, entry.GetItem().(*repositories.StorageUsage))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
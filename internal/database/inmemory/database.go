@@ -54,9 +54,12 @@ func NewInMemoryDatabase() (db.Database, error) {
 				Name: "projects",
 				Indexes: map[string]*memdb.IndexSchema{
 					"id": {
-						Name:    "id",
-						Unique:  true,
-						Indexer: &memdb.StringFieldIndex{Field: "slug"},
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							project := obj.(repositories.Project)
+							return project.GetId()
+						}},
 					},
 				},
 			},
@@ -77,9 +80,12 @@ func NewInMemoryDatabase() (db.Database, error) {
 				Name: "repositories",
 				Indexes: map[string]*memdb.IndexSchema{
 					"id": {
-						Name:    "id",
-						Unique:  true,
-						Indexer: &memdb.StringFieldIndex{Field: "slug"},
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							repository := obj.(repositories.Repository)
+							return repository.GetId()
+						}},
 					},
 				},
 			},
@@ -113,9 +119,28 @@ func NewInMemoryDatabase() (db.Database, error) {
 				Name: "tags",
 				Indexes: map[string]*memdb.IndexSchema{
 					"id": {
-						Name:    "id",
-						Unique:  true,
-						Indexer: &memdb.StringFieldIndex{Field: "name"},
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							tag := obj.(repositories.Tag)
+							return tag.GetId()
+						}},
+					},
+					"repository_name": {
+						Name:   "repository_name",
+						Unique: true,
+						Indexer: &memdb.CompoundIndex{
+							Indexes: []memdb.Indexer{
+								&StringValueIndexer{Getter: func(obj interface{}) string {
+									tag := obj.(repositories.Tag)
+									return tag.GetRepositoryId().String()
+								}},
+								&StringValueIndexer{Getter: func(obj interface{}) string {
+									tag := obj.(repositories.Tag)
+									return tag.GetName()
+								}},
+							},
+						},
 					},
 				},
 			},
@@ -140,6 +165,22 @@ func NewInMemoryDatabase() (db.Database, error) {
 							return repositoryBlob.GetId()
 						}},
 					},
+					"repository_blob": {
+						Name:   "repository_blob",
+						Unique: true,
+						Indexer: &memdb.CompoundIndex{
+							Indexes: []memdb.Indexer{
+								&StringValueIndexer{Getter: func(obj interface{}) string {
+									repositoryBlob := obj.(repositories.RepositoryBlob)
+									return repositoryBlob.GetRepositoryId().String()
+								}},
+								&StringValueIndexer{Getter: func(obj interface{}) string {
+									repositoryBlob := obj.(repositories.RepositoryBlob)
+									return repositoryBlob.GetBlobId().String()
+								}},
+							},
+						},
+					},
 				},
 			},
 			"files": {
@@ -155,6 +196,123 @@ func NewInMemoryDatabase() (db.Database, error) {
 					},
 				},
 			},
+			"referrers": {
+				Name: "referrers",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							referrer := obj.(repositories.Referrer)
+							return referrer.GetId()
+						}},
+					},
+				},
+			},
+			"manifest_references": {
+				Name: "manifest_references",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							manifestReference := obj.(repositories.ManifestReference)
+							return manifestReference.GetId()
+						}},
+					},
+				},
+			},
+			"upstreams": {
+				Name: "upstreams",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							upstream := obj.(repositories.Upstream)
+							return upstream.GetId()
+						}},
+					},
+				},
+			},
+			"replication_rules": {
+				Name: "replication_rules",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							replicationRule := obj.(repositories.ReplicationRule)
+							return replicationRule.GetId()
+						}},
+					},
+				},
+			},
+			"replication_tasks": {
+				Name: "replication_tasks",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							replicationTask := obj.(repositories.ReplicationTask)
+							return replicationTask.GetId()
+						}},
+					},
+				},
+			},
+			"tag_rules": {
+				Name: "tag_rules",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							tagRule := obj.(repositories.TagRule)
+							return tagRule.GetId()
+						}},
+					},
+				},
+			},
+			"retention_policies": {
+				Name: "retention_policies",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							retentionPolicy := obj.(repositories.RetentionPolicy)
+							return retentionPolicy.GetId()
+						}},
+					},
+				},
+			},
+			"upload_sessions": {
+				Name: "upload_sessions",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							uploadSession := obj.(repositories.UploadSession)
+							return uploadSession.GetId()
+						}},
+					},
+				},
+			},
+			"storage_usages": {
+				Name: "storage_usages",
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:   "id",
+						Unique: true,
+						Indexer: &UUIDValueIndexer{Getter: func(obj interface{}) uuid.UUID {
+							storageUsage := obj.(repositories.StorageUsage)
+							return storageUsage.GetId()
+						}},
+					},
+				},
+			},
 		},
 	}
 
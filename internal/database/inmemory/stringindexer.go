@@ -0,0 +1,32 @@
+package inmemory
+
+import (
+	"fmt"
+)
+
+type StringValueIndexer struct {
+	Getter func(obj interface{}) string
+}
+
+func (s *StringValueIndexer) FromObject(obj interface{}) (bool, []byte, error) {
+	val := s.Getter(obj)
+	if val == "" {
+		return false, nil, nil
+	}
+
+	// null terminate like memdb.StringFieldIndex so that compound indexes do not match on prefixes
+	return true, []byte(val + "\x00"), nil
+}
+
+func (s *StringValueIndexer) FromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("StringValueIndexer takes exactly one argument")
+	}
+
+	val, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument is not string")
+	}
+
+	return []byte(val + "\x00"), nil
+}
@@ -16,18 +16,27 @@ type Context struct {
 	db            *sql.DB
 	changeTracker *change.Tracker
 
-	tenants          *postgres.TenantRepository
-	projects         *postgres.ProjectRepository
-	projectAccess    *postgres.ProjectAccessRepository
-	users            *postgres.UserRepository
-	pats             *postgres.PatRepository
-	repos            *postgres.RepositoryRepository
-	repositoryAccess *postgres.RepositoryAccessRepository
-	manifest         *postgres.ManifestRepository
-	tags             *postgres.TagRepository
-	blobs            *postgres.BlobRepository
-	repositoryBlobs  *postgres.RepositoryBlobRepository
-	files            *postgres.FileRepository
+	tenants            *postgres.TenantRepository
+	projects           *postgres.ProjectRepository
+	projectAccess      *postgres.ProjectAccessRepository
+	users              *postgres.UserRepository
+	pats               *postgres.PatRepository
+	repos              *postgres.RepositoryRepository
+	repositoryAccess   *postgres.RepositoryAccessRepository
+	manifest           *postgres.ManifestRepository
+	tags               *postgres.TagRepository
+	blobs              *postgres.BlobRepository
+	repositoryBlobs    *postgres.RepositoryBlobRepository
+	files              *postgres.FileRepository
+	referrers          *postgres.ReferrerRepository
+	manifestReferences *postgres.ManifestReferenceRepository
+	upstreams          *postgres.UpstreamRepository
+	replicationRules   *postgres.ReplicationRuleRepository
+	replicationTasks   *postgres.ReplicationTaskRepository
+	tagRules           *postgres.TagRuleRepository
+	retentionPolicies  *postgres.RetentionPolicyRepository
+	uploadSessions     *postgres.UploadSessionRepository
+	storageUsages      *postgres.StorageUsageRepository
 }
 
 func newContext(db *sql.DB) *Context {
@@ -133,6 +142,78 @@ func (c *Context) Files() repositories.FileRepository {
 	return c.files
 }
 
+func (c *Context) Referrers() repositories.ReferrerRepository {
+	if c.referrers == nil {
+		c.referrers = postgres.NewPostgresReferrerRepository(c.db, c.changeTracker, db.ReferrerType)
+	}
+
+	return c.referrers
+}
+
+func (c *Context) ManifestReferences() repositories.ManifestReferenceRepository {
+	if c.manifestReferences == nil {
+		c.manifestReferences = postgres.NewPostgresManifestReferenceRepository(c.db, c.changeTracker, db.ManifestReferenceType)
+	}
+
+	return c.manifestReferences
+}
+
+func (c *Context) Upstreams() repositories.UpstreamRepository {
+	if c.upstreams == nil {
+		c.upstreams = postgres.NewPostgresUpstreamRepository(c.db, c.changeTracker, db.UpstreamType)
+	}
+
+	return c.upstreams
+}
+
+func (c *Context) ReplicationRules() repositories.ReplicationRuleRepository {
+	if c.replicationRules == nil {
+		c.replicationRules = postgres.NewPostgresReplicationRuleRepository(c.db, c.changeTracker, db.ReplicationRuleType)
+	}
+
+	return c.replicationRules
+}
+
+func (c *Context) ReplicationTasks() repositories.ReplicationTaskRepository {
+	if c.replicationTasks == nil {
+		c.replicationTasks = postgres.NewPostgresReplicationTaskRepository(c.db, c.changeTracker, db.ReplicationTaskType)
+	}
+
+	return c.replicationTasks
+}
+
+func (c *Context) TagRules() repositories.TagRuleRepository {
+	if c.tagRules == nil {
+		c.tagRules = postgres.NewPostgresTagRuleRepository(c.db, c.changeTracker, db.TagRuleType)
+	}
+
+	return c.tagRules
+}
+
+func (c *Context) RetentionPolicies() repositories.RetentionPolicyRepository {
+	if c.retentionPolicies == nil {
+		c.retentionPolicies = postgres.NewPostgresRetentionPolicyRepository(c.db, c.changeTracker, db.RetentionPolicyType)
+	}
+
+	return c.retentionPolicies
+}
+
+func (c *Context) UploadSessions() repositories.UploadSessionRepository {
+	if c.uploadSessions == nil {
+		c.uploadSessions = postgres.NewPostgresUploadSessionRepository(c.db, c.changeTracker, db.UploadSessionType)
+	}
+
+	return c.uploadSessions
+}
+
+func (c *Context) StorageUsages() repositories.StorageUsageRepository {
+	if c.storageUsages == nil {
+		c.storageUsages = postgres.NewPostgresStorageUsageRepository(c.db, c.changeTracker, db.StorageUsageType)
+	}
+
+	return c.storageUsages
+}
+
 func (c *Context) SaveChanges(ctx context.Context) error {
 	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: 0,
@@ -156,6 +237,7 @@ func (c *Context) SaveChanges(ctx context.Context) error {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	c.changeTracker.Clear()
 	return nil
 }
 
@@ -197,6 +279,33 @@ func (c *Context) applyChange(ctx context.Context, tx *sql.Tx, entry *change.Ent
 	case db.FileType:
 		return c.applyFileChange(ctx, tx, entry)
 
+	case db.ReferrerType:
+		return c.applyReferrerChange(ctx, tx, entry)
+
+	case db.ManifestReferenceType:
+		return c.applyManifestReferenceChange(ctx, tx, entry)
+
+	case db.UpstreamType:
+		return c.applyUpstreamChange(ctx, tx, entry)
+
+	case db.ReplicationRuleType:
+		return c.applyReplicationRuleChange(ctx, tx, entry)
+
+	case db.ReplicationTaskType:
+		return c.applyReplicationTaskChange(ctx, tx, entry)
+
+	case db.TagRuleType:
+		return c.applyTagRuleChange(ctx, tx, entry)
+
+	case db.RetentionPolicyType:
+		return c.applyRetentionPolicyChange(ctx, tx, entry)
+
+	case db.UploadSessionType:
+		return c.applyUploadSessionChange(ctx, tx, entry)
+
+	case db.StorageUsageType:
+		return c.applyStorageUsageChange(ctx, tx, entry)
+
 	default:
 		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
 	}
@@ -332,6 +441,9 @@ func (c *Context) applyTagChange(ctx context.Context, tx *sql.Tx, entry *change.
 	case change.Added:
 		return c.tags.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.Tag))
 
+	case change.Updated:
+		return c.tags.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.Tag))
+
 	case change.Deleted:
 		return c.tags.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.Tag))
 
@@ -378,3 +490,141 @@ func (c *Context) applyFileChange(ctx context.Context, tx *sql.Tx, entry *change
 		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
 	}
 }
+
+func (c *Context) applyReferrerChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.referrers.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.Referrer))
+
+	case change.Deleted:
+		return c.referrers.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.Referrer))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyManifestReferenceChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.manifestReferences.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.ManifestReference))
+
+	case change.Deleted:
+		return c.manifestReferences.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.ManifestReference))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyUpstreamChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.upstreams.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.Upstream))
+
+	case change.Updated:
+		return c.upstreams.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.Upstream))
+
+	case change.Deleted:
+		return c.upstreams.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.Upstream))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyReplicationRuleChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.replicationRules.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.ReplicationRule))
+
+	case change.Updated:
+		return c.replicationRules.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.ReplicationRule))
+
+	case change.Deleted:
+		return c.replicationRules.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.ReplicationRule))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyReplicationTaskChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.replicationTasks.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.ReplicationTask))
+
+	case change.Updated:
+		return c.replicationTasks.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.ReplicationTask))
+
+	case change.Deleted:
+		return c.replicationTasks.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.ReplicationTask))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyTagRuleChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.tagRules.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.TagRule))
+
+	case change.Updated:
+		return c.tagRules.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.TagRule))
+
+	case change.Deleted:
+		return c.tagRules.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.TagRule))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyRetentionPolicyChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.retentionPolicies.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.RetentionPolicy))
+
+	case change.Updated:
+		return c.retentionPolicies.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.RetentionPolicy))
+
+	case change.Deleted:
+		return c.retentionPolicies.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.RetentionPolicy))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyUploadSessionChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.uploadSessions.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.UploadSession))
+
+	case change.Updated:
+		return c.uploadSessions.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.UploadSession))
+
+	case change.Deleted:
+		return c.uploadSessions.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.UploadSession))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
+
+func (c *Context) applyStorageUsageChange(ctx context.Context, tx *sql.Tx, entry *change.Entry) error {
+	switch entry.GetChangeType() {
+	case change.Added:
+		return c.storageUsages.ExecuteInsert(ctx, tx, entry.GetItem().(*repositories.StorageUsage))
+
+	case change.Updated:
+		return c.storageUsages.ExecuteUpdate(ctx, tx, entry.GetItem().(*repositories.StorageUsage))
+
+	case change.Deleted:
+		return c.storageUsages.ExecuteDelete(ctx, tx, entry.GetItem().(*repositories.StorageUsage))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", entry.GetChangeType())
+	}
+}
@@ -0,0 +1,24 @@
+-- +migrate Up
+create table referrers
+(
+    id             uuid        not null,
+    created_at     timestamptz not null,
+    updated_at     timestamptz not null,
+
+    repository_id  uuid        not null,
+    manifest_id    uuid        not null,
+
+    subject_digest text        not null,
+    artifact_type  text        not null,
+    annotations    hstore      not null,
+
+    primary key (id),
+    foreign key (repository_id) references repositories (id),
+    foreign key (manifest_id) references manifests (id),
+    unique (manifest_id)
+);
+
+create index referrers_subject_idx on referrers (repository_id, subject_digest);
+
+-- +migrate Down
+drop table referrers;
@@ -0,0 +1,23 @@
+-- +migrate Up
+create table manifest_references
+(
+    id            uuid        not null,
+    created_at    timestamptz not null,
+    updated_at    timestamptz not null,
+
+    repository_id uuid        not null,
+    manifest_id   uuid        not null,
+
+    digest        text        not null,
+    media_type    text        not null,
+
+    primary key (id),
+    foreign key (repository_id) references repositories (id),
+    foreign key (manifest_id) references manifests (id)
+);
+
+create index manifest_references_manifest_idx on manifest_references (manifest_id);
+create index manifest_references_digest_idx on manifest_references (repository_id, digest);
+
+-- +migrate Down
+drop table manifest_references;
@@ -0,0 +1,27 @@
+-- +migrate Up
+create table upstreams
+(
+    id                 uuid        not null,
+    created_at         timestamptz not null,
+    updated_at         timestamptz not null,
+
+    project_id         uuid,
+    repository_id      uuid,
+
+    url                text        not null,
+    repository         text        not null,
+    username           text,
+    -- encrypted with Kms.EncryptionKey
+    encrypted_password bytea,
+    tag_ttl_seconds    bigint      not null,
+
+    primary key (id),
+    foreign key (project_id) references projects (id),
+    foreign key (repository_id) references repositories (id),
+    unique (project_id),
+    unique (repository_id),
+    check ((project_id is null) != (repository_id is null))
+);
+
+-- +migrate Down
+drop table upstreams;
@@ -0,0 +1,51 @@
+-- +migrate Up
+create table replication_rules
+(
+    id                 uuid        not null,
+    created_at         timestamptz not null,
+    updated_at         timestamptz not null,
+
+    project_id         uuid        not null,
+
+    url                text        not null,
+    namespace          text        not null,
+    tag_filter         text,
+    username           text,
+    -- encrypted with Kms.EncryptionKey
+    encrypted_password bytea,
+    enabled            boolean     not null,
+
+    last_success_at    timestamptz,
+    last_failure_at    timestamptz,
+    last_error         text,
+
+    primary key (id),
+    foreign key (project_id) references projects (id)
+);
+
+create table replication_tasks
+(
+    id                  uuid        not null,
+    created_at          timestamptz not null,
+    updated_at          timestamptz not null,
+
+    replication_rule_id uuid        not null,
+    repository_id       uuid        not null,
+    tag                 text        not null,
+
+    attempts            integer     not null,
+    next_attempt_at     timestamptz not null,
+    last_error          text,
+    failed              boolean     not null,
+
+    primary key (id),
+    foreign key (replication_rule_id) references replication_rules (id),
+    foreign key (repository_id) references repositories (id),
+    unique (replication_rule_id, repository_id, tag)
+);
+
+create index replication_tasks_next_attempt_at_idx on replication_tasks (next_attempt_at) where not failed;
+
+-- +migrate Down
+drop table replication_tasks;
+drop table replication_rules;
@@ -0,0 +1,24 @@
+-- +migrate Up
+create table tag_rules
+(
+    id            uuid        not null,
+    created_at    timestamptz not null,
+    updated_at    timestamptz not null,
+
+    project_id    uuid,
+    repository_id uuid,
+
+    pattern       text        not null,
+    kind          text        not null,
+
+    primary key (id),
+    foreign key (project_id) references projects (id),
+    foreign key (repository_id) references repositories (id),
+    check ((project_id is null) != (repository_id is null))
+);
+
+create index tag_rules_project_idx on tag_rules (project_id);
+create index tag_rules_repository_idx on tag_rules (repository_id);
+
+-- +migrate Down
+drop table tag_rules;
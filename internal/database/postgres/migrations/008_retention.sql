@@ -0,0 +1,34 @@
+-- +migrate Up
+alter table tags
+    add column last_pulled_at timestamptz;
+
+create table retention_policies
+(
+    id                 uuid        not null,
+    created_at         timestamptz not null,
+    updated_at         timestamptz not null,
+
+    project_id         uuid,
+    repository_id      uuid,
+
+    tag_filter         text,
+    keep_last          integer,
+    older_than_days    integer,
+    not_pulled_in_days integer,
+    delete_untagged    boolean     not null,
+    enabled            boolean     not null,
+
+    primary key (id),
+    foreign key (project_id) references projects (id),
+    foreign key (repository_id) references repositories (id),
+    check ((project_id is null) != (repository_id is null))
+);
+
+create index retention_policies_project_idx on retention_policies (project_id);
+create index retention_policies_repository_idx on retention_policies (repository_id);
+
+-- +migrate Down
+drop table retention_policies;
+
+alter table tags
+    drop column last_pulled_at;
@@ -0,0 +1,20 @@
+-- +migrate Up
+create table upload_sessions
+(
+    id            uuid        not null,
+    created_at    timestamptz not null,
+    updated_at    timestamptz not null,
+
+    session_id    uuid        not null,
+    repository_id uuid        not null,
+    backend_state hstore      not null,
+    expires_at    timestamptz not null,
+
+    primary key (id),
+    unique (session_id)
+);
+
+create index upload_sessions_expires_at_idx on upload_sessions (expires_at);
+
+-- +migrate Down
+drop table upload_sessions;
@@ -0,0 +1,7 @@
+-- +migrate Up
+alter table tenants add column storage_quota bigint;
+alter table projects add column storage_quota bigint;
+
+-- +migrate Down
+alter table projects drop column storage_quota;
+alter table tenants drop column storage_quota;
@@ -0,0 +1,25 @@
+-- +migrate Up
+create table storage_usages
+(
+    id           uuid        not null,
+    created_at   timestamptz not null,
+    updated_at   timestamptz not null,
+
+    kind         text        not null,
+    owner_id     uuid        not null,
+    tenant_id    uuid        not null,
+    project_id   uuid,
+
+    logical_size bigint      not null,
+    stored_size  bigint      not null,
+    unique_size  bigint      not null,
+
+    primary key (id),
+    unique (kind, owner_id)
+);
+
+create index storage_usages_tenant_idx on storage_usages (tenant_id);
+create index storage_usages_project_idx on storage_usages (project_id);
+
+-- +migrate Down
+drop table storage_usages;
@@ -22,6 +22,8 @@ type CreateTenantRequest struct {
 	DisplayName string `json:"displayName"`
 	OidcClient  string `json:"oidcClient" validate:"required"`
 	OidcIssuer  string `json:"oidcIssuer" validate:"required"`
+	// StorageQuota limits the bytes stored by all repositories of the tenant, it is unlimited if omitted.
+	StorageQuota *int64 `json:"storageQuota" validate:"omitempty,min=0"`
 }
 
 func CreateTenant(w http.ResponseWriter, r *http.Request) {
@@ -46,6 +48,8 @@ func CreateTenant(w http.ResponseWriter, r *http.Request) {
 		DisplayName: dto.DisplayName,
 		OidcClient:  dto.OidcClient,
 		OidcIssuer:  dto.OidcIssuer,
+
+		StorageQuota: dto.StorageQuota,
 	})
 	if err != nil {
 		apiError.HandleHttpError(w, err)
@@ -92,11 +96,13 @@ func ListTenants(w http.ResponseWriter, r *http.Request) {
 }
 
 type GetTenantResponse struct {
-	Id          uuid.UUID `json:"id"`
-	Slug        string    `json:"slug"`
-	DisplayName string    `json:"displayName"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	Id           uuid.UUID `json:"id"`
+	Slug         string    `json:"slug"`
+	DisplayName  string    `json:"displayName"`
+	StorageUsed  int64     `json:"storageUsed"`
+	StorageQuota *int64    `json:"storageQuota"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 func GetTenant(w http.ResponseWriter, r *http.Request) {
@@ -115,11 +121,13 @@ func GetTenant(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := GetTenantResponse{
-		Id:          tenant.Id,
-		Slug:        tenant.Slug,
-		DisplayName: tenant.DisplayName,
-		CreatedAt:   tenant.CreatedAt,
-		UpdatedAt:   tenant.UpdatedAt,
+		Id:           tenant.Id,
+		Slug:         tenant.Slug,
+		DisplayName:  tenant.DisplayName,
+		StorageUsed:  tenant.StorageUsed,
+		StorageQuota: tenant.StorageQuota,
+		CreatedAt:    tenant.CreatedAt,
+		UpdatedAt:    tenant.UpdatedAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -129,3 +137,40 @@ func GetTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type SetStorageQuotaRequest struct {
+	// StorageQuota is the number of bytes that may be stored, null removes the limit.
+	StorageQuota *int64 `json:"storageQuota" validate:"omitempty,min=0"`
+}
+
+func SetTenantStorageQuota(w http.ResponseWriter, r *http.Request) {
+	var dto SetStorageQuotaRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.SetStorageQuotaResponse](ctx, mediator, commands.SetStorageQuota{
+		TenantSlug:   tenantSlug,
+		StorageQuota: dto.StorageQuota,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,196 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/mediatr"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type CompareImagesResponse struct {
+	Base   ComparedImageResponse `json:"base"`
+	Target ComparedImageResponse `json:"target"`
+
+	SizeDelta int64 `json:"sizeDelta"`
+
+	Layers LayerComparisonResponse  `json:"layers"`
+	Config ConfigComparisonResponse `json:"config"`
+
+	// Files is only set if the files were asked for.
+	Files []FileChangeResponse `json:"files,omitempty"`
+}
+
+type ComparedImageResponse struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+type LayerComparisonResponse struct {
+	Added   []ImageLayerResponse `json:"added"`
+	Removed []ImageLayerResponse `json:"removed"`
+	Shared  []ImageLayerResponse `json:"shared"`
+}
+
+type ConfigComparisonResponse struct {
+	Env          MapComparisonResponse `json:"env"`
+	Labels       MapComparisonResponse `json:"labels"`
+	ExposedPorts SetComparisonResponse `json:"exposedPorts"`
+
+	// The changes below are null if the value is the same in both images.
+	Entrypoint *ChangeResponse[[]string] `json:"entrypoint"`
+	Cmd        *ChangeResponse[[]string] `json:"cmd"`
+	User       *ChangeResponse[string]   `json:"user"`
+	WorkingDir *ChangeResponse[string]   `json:"workingDir"`
+}
+
+type MapComparisonResponse struct {
+	Added   map[string]string                 `json:"added"`
+	Removed map[string]string                 `json:"removed"`
+	Changed map[string]ChangeResponse[string] `json:"changed"`
+}
+
+type SetComparisonResponse struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+type ChangeResponse[T any] struct {
+	Base   T `json:"base"`
+	Target T `json:"target"`
+}
+
+type FileChangeResponse struct {
+	Path   string        `json:"path"`
+	Kind   string        `json:"kind"`
+	Base   *FileResponse `json:"base,omitempty"`
+	Target *FileResponse `json:"target,omitempty"`
+}
+
+func CompareImages(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	base := r.URL.Query().Get("base")
+	target := r.URL.Query().Get("target")
+	if base == "" || target == "" {
+		apiError.HandleHttpError(w, fmt.Errorf("base and target have to be set: %w", apiError.ErrApiBadRequest))
+		return
+	}
+
+	result, err := mediatr.Send[*queries.CompareImagesResponse](ctx, mediator, queries.CompareImages{
+		TenantSlug:      vars["tenant"],
+		ProjectSlug:     vars["project"],
+		RepositorySlug:  vars["repository"],
+		BaseReference:   base,
+		TargetReference: target,
+		WithFiles:       r.URL.Query().Get("files") == "true",
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	comparison := result.Comparison
+	response := CompareImagesResponse{
+		Base: ComparedImageResponse{
+			Digest: result.BaseDigest,
+			Size:   comparison.Base.Size,
+		},
+		Target: ComparedImageResponse{
+			Digest: result.TargetDigest,
+			Size:   comparison.Target.Size,
+		},
+		SizeDelta: comparison.SizeDelta,
+		Layers: LayerComparisonResponse{
+			Added:   mapLayers(comparison.AddedLayers),
+			Removed: mapLayers(comparison.RemovedLayers),
+			Shared:  mapLayers(comparison.SharedLayers),
+		},
+		Config: ConfigComparisonResponse{
+			Env:    mapMapComparison(comparison.Config.Env),
+			Labels: mapMapComparison(comparison.Config.Labels),
+			ExposedPorts: SetComparisonResponse{
+				Added:   nonNil(comparison.Config.ExposedPorts.Added),
+				Removed: nonNil(comparison.Config.ExposedPorts.Removed),
+			},
+			Entrypoint: mapChange(comparison.Config.Entrypoint),
+			Cmd:        mapChange(comparison.Config.Cmd),
+			User:       mapChange(comparison.Config.User),
+			WorkingDir: mapChange(comparison.Config.WorkingDir),
+		},
+	}
+
+	for _, change := range comparison.Files {
+		changeResponse := FileChangeResponse{
+			Path: change.Path,
+			Kind: string(change.Kind),
+		}
+
+		if change.Base != nil {
+			file := mapFile(change.Base.LayerFile, change.Base.LayerDigest)
+			changeResponse.Base = &file
+		}
+
+		if change.Target != nil {
+			file := mapFile(change.Target.LayerFile, change.Target.LayerDigest)
+			changeResponse.Target = &file
+		}
+
+		response.Files = append(response.Files, changeResponse)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func mapLayers(layers []images.Layer) []ImageLayerResponse {
+	response := make([]ImageLayerResponse, len(layers))
+	for i, layer := range layers {
+		response[i] = ImageLayerResponse{
+			Digest:      layer.Digest,
+			MediaType:   layer.MediaType,
+			Size:        layer.Size,
+			DiffId:      layer.DiffId,
+			Annotations: layer.Annotations,
+		}
+	}
+
+	return response
+}
+
+func mapMapComparison(comparison images.MapComparison) MapComparisonResponse {
+	response := MapComparisonResponse{
+		Added:   comparison.Added,
+		Removed: comparison.Removed,
+		Changed: make(map[string]ChangeResponse[string], len(comparison.Changed)),
+	}
+
+	for key, change := range comparison.Changed {
+		response.Changed[key] = ChangeResponse[string]{Base: change.Base, Target: change.Target}
+	}
+
+	return response
+}
+
+func mapChange[T any](change *images.Change[T]) *ChangeResponse[T] {
+	if change == nil {
+		return nil
+	}
+
+	return &ChangeResponse[T]{
+		Base:   change.Base,
+		Target: change.Target,
+	}
+}
@@ -0,0 +1,181 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/The127/mediatr"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type ListFilesResponse struct {
+	Files []FileResponse `json:"files"`
+}
+
+type FileResponse struct {
+	Path       string    `json:"path"`
+	Type       string    `json:"type"`
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	Uid        int       `json:"uid"`
+	Gid        int       `json:"gid"`
+	ModTime    time.Time `json:"modTime"`
+	LinkTarget string    `json:"linkTarget,omitempty"`
+	Whiteout   bool      `json:"whiteout,omitempty"`
+	Opaque     bool      `json:"opaque,omitempty"`
+	// LayerDigest is the layer that last wrote the file, it is only set for the files of an image.
+	LayerDigest string `json:"layerDigest,omitempty"`
+}
+
+func mapFile(file images.LayerFile, layerDigest string) FileResponse {
+	return FileResponse{
+		Path:        file.Path,
+		Type:        string(file.Type),
+		Size:        file.Size,
+		Mode:        fmt.Sprintf("%04o", file.Mode),
+		Uid:         file.Uid,
+		Gid:         file.Gid,
+		ModTime:     file.ModTime,
+		LinkTarget:  file.LinkTarget,
+		Whiteout:    file.Whiteout,
+		Opaque:      file.Opaque,
+		LayerDigest: layerDigest,
+	}
+}
+
+func ListLayerFiles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	files, err := mediatr.Send[*queries.ListLayerFilesResponse](ctx, mediator, queries.ListLayerFiles{
+		TenantSlug:     vars["tenant"],
+		ProjectSlug:    vars["project"],
+		RepositorySlug: vars["repository"],
+		LayerDigest:    vars["digest"],
+		Path:           r.URL.Query().Get("path"),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListFilesResponse{
+		Files: make([]FileResponse, len(files.Files)),
+	}
+
+	for i, file := range files.Files {
+		response.Files[i] = mapFile(file, "")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func DownloadLayerFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	file, err := mediatr.Send[*queries.GetLayerFileResponse](ctx, mediator, queries.GetLayerFile{
+		TenantSlug:     vars["tenant"],
+		ProjectSlug:    vars["project"],
+		RepositorySlug: vars["repository"],
+		LayerDigest:    vars["digest"],
+		Path:           r.URL.Query().Get("path"),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	writeFile(w, file.File, file.Content)
+}
+
+func ListImageFiles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	files, err := mediatr.Send[*queries.ListImageFilesResponse](ctx, mediator, queries.ListImageFiles{
+		TenantSlug:     vars["tenant"],
+		ProjectSlug:    vars["project"],
+		RepositorySlug: vars["repository"],
+		Reference:      vars["reference"],
+		Path:           r.URL.Query().Get("path"),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListFilesResponse{
+		Files: make([]FileResponse, len(files.Files)),
+	}
+
+	for i, file := range files.Files {
+		response.Files[i] = mapFile(file.LayerFile, file.LayerDigest)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func DownloadImageFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	file, err := mediatr.Send[*queries.GetImageFileResponse](ctx, mediator, queries.GetImageFile{
+		TenantSlug:     vars["tenant"],
+		ProjectSlug:    vars["project"],
+		RepositorySlug: vars["repository"],
+		Reference:      vars["reference"],
+		Path:           r.URL.Query().Get("path"),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("X-Layer-Digest", file.File.LayerDigest)
+	writeFile(w, file.File.LayerFile, file.Content)
+}
+
+// writeFile streams the content of a file as attachment and closes it. Errors while streaming can only be logged, the
+// status has been sent by then.
+func writeFile(w http.ResponseWriter, file images.LayerFile, content io.ReadCloser) {
+	defer content.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(file.Path)}))
+
+	_, err := io.Copy(w, content)
+	if err != nil {
+		logging.Logger.Errorf("streaming file %s: %v", file.Path, err)
+	}
+}
@@ -0,0 +1,204 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/The127/mediatr"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type GetManifestResponse struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"mediaType"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+
+	// Image is set for image manifests, Platforms for image indexes.
+	Image     *ImageResponse          `json:"image,omitempty"`
+	Platforms []PlatformImageResponse `json:"platforms,omitempty"`
+}
+
+type ImageResponse struct {
+	ConfigDigest    string `json:"configDigest,omitempty"`
+	ConfigMediaType string `json:"configMediaType,omitempty"`
+
+	Os           string     `json:"os,omitempty"`
+	Architecture string     `json:"architecture,omitempty"`
+	Variant      string     `json:"variant,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	Author       string     `json:"author,omitempty"`
+
+	User         string            `json:"user,omitempty"`
+	Entrypoint   []string          `json:"entrypoint"`
+	Cmd          []string          `json:"cmd"`
+	Env          []string          `json:"env"`
+	WorkingDir   string            `json:"workingDir,omitempty"`
+	ExposedPorts []string          `json:"exposedPorts"`
+	Volumes      []string          `json:"volumes"`
+	StopSignal   string            `json:"stopSignal,omitempty"`
+	Labels       map[string]string `json:"labels"`
+
+	Size    int64                  `json:"size"`
+	Layers  []ImageLayerResponse   `json:"layers"`
+	History []ImageHistoryResponse `json:"history"`
+}
+
+type ImageLayerResponse struct {
+	Digest      string            `json:"digest"`
+	MediaType   string            `json:"mediaType"`
+	Size        int64             `json:"size"`
+	DiffId      string            `json:"diffId,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ImageHistoryResponse struct {
+	Created     *time.Time `json:"created,omitempty"`
+	CreatedBy   string     `json:"createdBy,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+	EmptyLayer  bool       `json:"emptyLayer"`
+	LayerDigest string     `json:"layerDigest,omitempty"`
+}
+
+type PlatformImageResponse struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"mediaType"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Os           string            `json:"os,omitempty"`
+	Architecture string            `json:"architecture,omitempty"`
+	Variant      string            `json:"variant,omitempty"`
+	OsVersion    string            `json:"osVersion,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+
+	// Image is null if the manifest of the platform is not stored in the repository.
+	Image *ImageResponse `json:"image"`
+}
+
+func GetManifest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+	repositorySlug := vars["repository"]
+	reference := vars["reference"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	details, err := mediatr.Send[*queries.GetImageDetailsResponse](ctx, mediator, queries.GetImageDetails{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: repositorySlug,
+		Reference:      reference,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := GetManifestResponse{
+		Digest:       details.Digest,
+		MediaType:    details.MediaType,
+		Size:         details.Size,
+		ArtifactType: details.ArtifactType,
+		Annotations:  details.Annotations,
+		Image:        mapImage(details.Image),
+	}
+
+	for _, platform := range details.Platforms {
+		platformResponse := PlatformImageResponse{
+			Digest:       platform.Digest,
+			MediaType:    platform.MediaType,
+			Size:         platform.Size,
+			ArtifactType: platform.ArtifactType,
+			Annotations:  platform.Annotations,
+			Image:        mapImage(platform.Image),
+		}
+
+		if platform.Platform != nil {
+			platformResponse.Os = platform.Platform.OS
+			platformResponse.Architecture = platform.Platform.Architecture
+			platformResponse.Variant = platform.Platform.Variant
+			platformResponse.OsVersion = platform.Platform.OSVersion
+		}
+
+		response.Platforms = append(response.Platforms, platformResponse)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func mapImage(image *images.Image) *ImageResponse {
+	if image == nil {
+		return nil
+	}
+
+	response := &ImageResponse{
+		ConfigDigest:    image.ConfigDigest,
+		ConfigMediaType: image.ConfigMediaType,
+		Os:              image.Platform.OS,
+		Architecture:    image.Platform.Architecture,
+		Variant:         image.Platform.Variant,
+		Created:         image.Created,
+		Author:          image.Author,
+		User:            image.User,
+		Entrypoint:      nonNil(image.Entrypoint),
+		Cmd:             nonNil(image.Cmd),
+		Env:             nonNil(image.Env),
+		WorkingDir:      image.WorkingDir,
+		ExposedPorts:    nonNil(image.ExposedPorts),
+		Volumes:         nonNil(image.Volumes),
+		StopSignal:      image.StopSignal,
+		Labels:          image.Labels,
+		Size:            image.Size,
+		Layers:          make([]ImageLayerResponse, len(image.Layers)),
+		History:         make([]ImageHistoryResponse, len(image.History)),
+	}
+
+	if response.Labels == nil {
+		response.Labels = map[string]string{}
+	}
+
+	for i, layer := range image.Layers {
+		response.Layers[i] = ImageLayerResponse{
+			Digest:      layer.Digest,
+			MediaType:   layer.MediaType,
+			Size:        layer.Size,
+			DiffId:      layer.DiffId,
+			Annotations: layer.Annotations,
+		}
+	}
+
+	for i, history := range image.History {
+		response.History[i] = ImageHistoryResponse{
+			Created:     history.Created,
+			CreatedBy:   history.CreatedBy,
+			Author:      history.Author,
+			Comment:     history.Comment,
+			EmptyLayer:  history.EmptyLayer,
+			LayerDigest: history.LayerDigest,
+		}
+	}
+
+	return response
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
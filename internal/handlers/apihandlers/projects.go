@@ -22,6 +22,8 @@ type CreateProjectRequest struct {
 	Slug        string  `json:"slug" validate:"required"`
 	DisplayName *string `json:"displayName"`
 	Description *string `json:"description"`
+	// StorageQuota limits the bytes stored by the repositories of the project, it is unlimited if omitted.
+	StorageQuota *int64 `json:"storageQuota" validate:"omitempty,min=0"`
 }
 
 func CreateProject(w http.ResponseWriter, r *http.Request) {
@@ -57,6 +59,8 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		Slug:        dto.Slug,
 		DisplayName: displayName,
 		Description: dto.Description,
+
+		StorageQuota: dto.StorageQuota,
 	})
 	if err != nil {
 		apiError.HandleHttpError(w, err)
@@ -112,12 +116,14 @@ func ListProjects(w http.ResponseWriter, r *http.Request) {
 }
 
 type GetProjectResponse struct {
-	Id          uuid.UUID `json:"id"`
-	Slug        string    `json:"slug"`
-	DisplayName string    `json:"displayName"`
-	Description *string   `json:"description"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	Id           uuid.UUID `json:"id"`
+	Slug         string    `json:"slug"`
+	DisplayName  string    `json:"displayName"`
+	Description  *string   `json:"description"`
+	StorageUsed  int64     `json:"storageUsed"`
+	StorageQuota *int64    `json:"storageQuota"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 func GetProject(w http.ResponseWriter, r *http.Request) {
@@ -138,12 +144,14 @@ func GetProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := GetProjectResponse{
-		Id:          project.Id,
-		Slug:        project.Slug,
-		DisplayName: project.DisplayName,
-		Description: project.Description,
-		CreatedAt:   project.CreatedAt,
-		UpdatedAt:   project.UpdatedAt,
+		Id:           project.Id,
+		Slug:         project.Slug,
+		DisplayName:  project.DisplayName,
+		Description:  project.Description,
+		StorageUsed:  project.StorageUsed,
+		StorageQuota: project.StorageQuota,
+		CreatedAt:    project.CreatedAt,
+		UpdatedAt:    project.UpdatedAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -153,3 +161,42 @@ func GetProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type SetStorageQuotaRequest struct {
+	// StorageQuota is the number of bytes that may be stored, null removes the limit.
+	StorageQuota *int64 `json:"storageQuota" validate:"omitempty,min=0"`
+}
+
+func SetProjectStorageQuota(w http.ResponseWriter, r *http.Request) {
+	var dto SetStorageQuotaRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.SetStorageQuotaResponse](ctx, mediator, commands.SetStorageQuota{
+		TenantSlug:   tenantSlug,
+		ProjectSlug:  &projectSlug,
+		StorageQuota: dto.StorageQuota,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,396 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/handlers"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/decoding"
+	"github.com/the127/dockyard/internal/utils/validate"
+)
+
+type ReplicationRuleRequest struct {
+	Url string `json:"url" validate:"required,url"`
+	// Namespace is prefixed to the repository slugs to get the repository names in the remote registry.
+	Namespace string `json:"namespace"`
+	// TagFilter is a regular expression that has to match the whole tag, all tags are replicated if it is empty.
+	TagFilter *string `json:"tagFilter"`
+	Username  *string `json:"username"`
+	Password  *string `json:"password"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
+
+func (r ReplicationRuleRequest) isEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+func (r ReplicationRuleRequest) tagFilter() *string {
+	if r.TagFilter == nil || *r.TagFilter == "" {
+		return nil
+	}
+
+	return r.TagFilter
+}
+
+type CreateReplicationRuleResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+// replicationRuleId parses the rule of the route, malformed ids are reported as bad requests.
+func replicationRuleId(vars map[string]string) (uuid.UUID, error) {
+	ruleId, err := uuid.Parse(vars["rule"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid replication rule id: %w", apiError.ErrApiBadRequest)
+	}
+
+	return ruleId, nil
+}
+
+func CreateReplicationRule(w http.ResponseWriter, r *http.Request) {
+	var dto ReplicationRuleRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	rule, err := mediatr.Send[*commands.CreateReplicationRuleResponse](ctx, mediator, commands.CreateReplicationRule{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+		Url:         dto.Url,
+		Namespace:   dto.Namespace,
+		TagFilter:   dto.tagFilter(),
+		Username:    dto.Username,
+		Password:    dto.Password,
+		Enabled:     dto.isEnabled(),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := CreateReplicationRuleResponse{
+		Id: rule.Id,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type ListReplicationRulesResponse handlers.PagedResponse[ListReplicationRulesResponseItem]
+
+type ListReplicationRulesResponseItem struct {
+	Id            uuid.UUID  `json:"id"`
+	Url           string     `json:"url"`
+	Namespace     string     `json:"namespace"`
+	TagFilter     *string    `json:"tagFilter"`
+	Enabled       bool       `json:"enabled"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	LastFailureAt *time.Time `json:"lastFailureAt"`
+}
+
+func ListReplicationRules(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	rules, err := mediatr.Send[*queries.ListReplicationRulesResponse](ctx, mediator, queries.ListReplicationRules{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListReplicationRulesResponse{
+		Items: make([]ListReplicationRulesResponseItem, len(rules.Items)),
+	}
+
+	for i, item := range rules.Items {
+		response.Items[i] = ListReplicationRulesResponseItem{
+			Id:            item.Id,
+			Url:           item.Url,
+			Namespace:     item.Namespace,
+			TagFilter:     item.TagFilter,
+			Enabled:       item.Enabled,
+			LastSuccessAt: item.LastSuccessAt,
+			LastFailureAt: item.LastFailureAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type GetReplicationRuleResponse struct {
+	Id            uuid.UUID  `json:"id"`
+	Url           string     `json:"url"`
+	Namespace     string     `json:"namespace"`
+	TagFilter     *string    `json:"tagFilter"`
+	Username      *string    `json:"username"`
+	HasPassword   bool       `json:"hasPassword"`
+	Enabled       bool       `json:"enabled"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	LastFailureAt *time.Time `json:"lastFailureAt"`
+	LastError     *string    `json:"lastError"`
+	PendingTasks  int        `json:"pendingTasks"`
+	FailedTasks   int        `json:"failedTasks"`
+}
+
+func GetReplicationRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := replicationRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	rule, err := mediatr.Send[*queries.GetReplicationRuleResponse](ctx, mediator, queries.GetReplicationRule{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+		RuleId:      ruleId,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := GetReplicationRuleResponse{
+		Id:            rule.Id,
+		Url:           rule.Url,
+		Namespace:     rule.Namespace,
+		TagFilter:     rule.TagFilter,
+		Username:      rule.Username,
+		HasPassword:   rule.HasPassword,
+		Enabled:       rule.Enabled,
+		LastSuccessAt: rule.LastSuccessAt,
+		LastFailureAt: rule.LastFailureAt,
+		LastError:     rule.LastError,
+		PendingTasks:  rule.PendingTasks,
+		FailedTasks:   rule.FailedTasks,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func UpdateReplicationRule(w http.ResponseWriter, r *http.Request) {
+	var dto ReplicationRuleRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := replicationRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.UpdateReplicationRuleResponse](ctx, mediator, commands.UpdateReplicationRule{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+		RuleId:      ruleId,
+		Url:         dto.Url,
+		Namespace:   dto.Namespace,
+		TagFilter:   dto.tagFilter(),
+		Username:    dto.Username,
+		Password:    dto.Password,
+		Enabled:     dto.isEnabled(),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func DeleteReplicationRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := replicationRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.DeleteReplicationRuleResponse](ctx, mediator, commands.DeleteReplicationRule{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+		RuleId:      ruleId,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ReplicateNowResponse struct {
+	QueuedTasks int `json:"queuedTasks"`
+}
+
+// ReplicateNow queues all matching tags of the project for replication, the tasks are processed in the background.
+func ReplicateNow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := replicationRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	result, err := mediatr.Send[*commands.ReplicateNowResponse](ctx, mediator, commands.ReplicateNow{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+		RuleId:      ruleId,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ReplicateNowResponse{
+		QueuedTasks: result.QueuedTasks,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type ListReplicationTasksResponse handlers.PagedResponse[ListReplicationTasksResponseItem]
+
+type ListReplicationTasksResponseItem struct {
+	Id            uuid.UUID `json:"id"`
+	Repository    string    `json:"repository"`
+	Tag           string    `json:"tag"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	LastError     *string   `json:"lastError"`
+	Failed        bool      `json:"failed"`
+}
+
+func ListReplicationTasks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := replicationRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	tasks, err := mediatr.Send[*queries.ListReplicationTasksResponse](ctx, mediator, queries.ListReplicationTasks{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+		RuleId:      ruleId,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListReplicationTasksResponse{
+		Items: make([]ListReplicationTasksResponseItem, len(tasks.Items)),
+	}
+
+	for i, item := range tasks.Items {
+		response.Items[i] = ListReplicationTasksResponseItem{
+			Id:            item.Id,
+			Repository:    item.RepositorySlug,
+			Tag:           item.Tag,
+			Attempts:      item.Attempts,
+			NextAttemptAt: item.NextAttemptAt,
+			LastError:     item.LastError,
+			Failed:        item.Failed,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
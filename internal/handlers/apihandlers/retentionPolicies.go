@@ -0,0 +1,306 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/handlers"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/decoding"
+	"github.com/the127/dockyard/internal/utils/validate"
+)
+
+type RetentionPolicyRequest struct {
+	// TagFilter is a regular expression that has to match the whole tag, the policy applies to all tags if it is empty.
+	TagFilter *string `json:"tagFilter"`
+	// KeepLast keeps the most recently pushed matching tags.
+	KeepLast *int `json:"keepLast" validate:"omitempty,min=0"`
+	// OlderThanDays only deletes tags that were pushed before.
+	OlderThanDays *int `json:"olderThanDays" validate:"omitempty,min=0"`
+	// NotPulledInDays only deletes tags that were neither pushed nor pulled since.
+	NotPulledInDays *int `json:"notPulledInDays" validate:"omitempty,min=0"`
+	DeleteUntagged  bool `json:"deleteUntagged"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
+
+func (r RetentionPolicyRequest) isEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+func (r RetentionPolicyRequest) tagFilter() *string {
+	if r.TagFilter == nil || *r.TagFilter == "" {
+		return nil
+	}
+
+	return r.TagFilter
+}
+
+type CreateRetentionPolicyResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+// retentionPolicyId parses the policy of the route, malformed ids are reported as bad requests.
+func retentionPolicyId(vars map[string]string) (uuid.UUID, error) {
+	policyId, err := uuid.Parse(vars["policy"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid retention policy id: %w", apiError.ErrApiBadRequest)
+	}
+
+	return policyId, nil
+}
+
+func CreateRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	var dto RetentionPolicyRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	policy, err := mediatr.Send[*commands.CreateRetentionPolicyResponse](ctx, mediator, commands.CreateRetentionPolicy{
+		TenantSlug:      tenantSlug,
+		ProjectSlug:     projectSlug,
+		RepositorySlug:  optionalRepositorySlug(vars),
+		TagFilter:       dto.tagFilter(),
+		KeepLast:        dto.KeepLast,
+		OlderThanDays:   dto.OlderThanDays,
+		NotPulledInDays: dto.NotPulledInDays,
+		DeleteUntagged:  dto.DeleteUntagged,
+		Enabled:         dto.isEnabled(),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := CreateRetentionPolicyResponse{
+		Id: policy.Id,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type ListRetentionPoliciesResponse handlers.PagedResponse[ListRetentionPoliciesResponseItem]
+
+type ListRetentionPoliciesResponseItem struct {
+	Id              uuid.UUID `json:"id"`
+	TagFilter       *string   `json:"tagFilter"`
+	KeepLast        *int      `json:"keepLast"`
+	OlderThanDays   *int      `json:"olderThanDays"`
+	NotPulledInDays *int      `json:"notPulledInDays"`
+	DeleteUntagged  bool      `json:"deleteUntagged"`
+	Enabled         bool      `json:"enabled"`
+	Inherited       bool      `json:"inherited"`
+}
+
+func ListRetentionPolicies(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	policies, err := mediatr.Send[*queries.ListRetentionPoliciesResponse](ctx, mediator, queries.ListRetentionPolicies{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListRetentionPoliciesResponse{
+		Items: make([]ListRetentionPoliciesResponseItem, len(policies.Items)),
+	}
+
+	for i, item := range policies.Items {
+		response.Items[i] = ListRetentionPoliciesResponseItem{
+			Id:              item.Id,
+			TagFilter:       item.TagFilter,
+			KeepLast:        item.KeepLast,
+			OlderThanDays:   item.OlderThanDays,
+			NotPulledInDays: item.NotPulledInDays,
+			DeleteUntagged:  item.DeleteUntagged,
+			Enabled:         item.Enabled,
+			Inherited:       item.Inherited,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func UpdateRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	var dto RetentionPolicyRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	policyId, err := retentionPolicyId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.UpdateRetentionPolicyResponse](ctx, mediator, commands.UpdateRetentionPolicy{
+		TenantSlug:      tenantSlug,
+		ProjectSlug:     projectSlug,
+		RepositorySlug:  optionalRepositorySlug(vars),
+		PolicyId:        policyId,
+		TagFilter:       dto.tagFilter(),
+		KeepLast:        dto.KeepLast,
+		OlderThanDays:   dto.OlderThanDays,
+		NotPulledInDays: dto.NotPulledInDays,
+		DeleteUntagged:  dto.DeleteUntagged,
+		Enabled:         dto.isEnabled(),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	policyId, err := retentionPolicyId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.DeleteRetentionPolicyResponse](ctx, mediator, commands.DeleteRetentionPolicy{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+		PolicyId:       policyId,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ApplyRetentionPoliciesRequest struct {
+	// DryRun only reports what would be deleted.
+	DryRun bool `json:"dryRun"`
+}
+
+type ApplyRetentionPoliciesResponse struct {
+	DryRun           bool                                 `json:"dryRun"`
+	Items            []ApplyRetentionPoliciesResponseItem `json:"items"`
+	ReclaimableBytes int64                                `json:"reclaimableBytes"`
+}
+
+type ApplyRetentionPoliciesResponseItem struct {
+	RepositorySlug   string   `json:"repositorySlug"`
+	DeletedTags      []string `json:"deletedTags"`
+	DeletedManifests []string `json:"deletedManifests"`
+	ReclaimableBytes int64    `json:"reclaimableBytes"`
+}
+
+func ApplyRetentionPolicies(w http.ResponseWriter, r *http.Request) {
+	var dto ApplyRetentionPoliciesRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	result, err := mediatr.Send[*commands.ApplyRetentionPoliciesResponse](ctx, mediator, commands.ApplyRetentionPolicies{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+		DryRun:         dto.DryRun,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ApplyRetentionPoliciesResponse{
+		DryRun:           result.DryRun,
+		Items:            make([]ApplyRetentionPoliciesResponseItem, len(result.Items)),
+		ReclaimableBytes: result.ReclaimableBytes,
+	}
+
+	for i, item := range result.Items {
+		response.Items[i] = ApplyRetentionPoliciesResponseItem{
+			RepositorySlug:   item.RepositorySlug,
+			DeletedTags:      item.DeletedTags,
+			DeletedManifests: item.DeletedManifests,
+			ReclaimableBytes: item.ReclaimableBytes,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
@@ -0,0 +1,212 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/storageUsage"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// StorageSizes are in bytes: logicalSize counts blobs once per manifest referring to them, storedSize once and
+// uniqueSize only if nothing else holds on to them, which is what deleting the content would free.
+type StorageSizes struct {
+	LogicalSize int64 `json:"logicalSize"`
+	StoredSize  int64 `json:"storedSize"`
+	UniqueSize  int64 `json:"uniqueSize"`
+}
+
+func mapStorageSizes(sizes storageUsage.Sizes) StorageSizes {
+	return StorageSizes{
+		LogicalSize: sizes.LogicalSize,
+		StoredSize:  sizes.StoredSize,
+		UniqueSize:  sizes.UniqueSize,
+	}
+}
+
+type GetTenantStorageUsageResponse struct {
+	StorageSizes
+	// ComputedAt is null until the counters are refreshed for the first time.
+	ComputedAt *time.Time                             `json:"computedAt"`
+	Projects   []GetTenantStorageUsageResponseProject `json:"projects"`
+}
+
+type GetTenantStorageUsageResponseProject struct {
+	StorageSizes
+	Id   uuid.UUID `json:"id"`
+	Slug string    `json:"slug"`
+}
+
+func GetTenantStorageUsage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	usage, err := mediatr.Send[*queries.GetTenantStorageUsageResponse](ctx, mediator, queries.GetTenantStorageUsage{
+		TenantSlug: tenantSlug,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := GetTenantStorageUsageResponse{
+		StorageSizes: mapStorageSizes(usage.Sizes),
+		ComputedAt:   usage.ComputedAt,
+		Projects:     make([]GetTenantStorageUsageResponseProject, len(usage.Projects)),
+	}
+
+	for i, project := range usage.Projects {
+		response.Projects[i] = GetTenantStorageUsageResponseProject{
+			StorageSizes: mapStorageSizes(project.Sizes),
+			Id:           project.Id,
+			Slug:         project.Slug,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type GetProjectStorageUsageResponse struct {
+	StorageSizes
+	// ComputedAt is null until the counters are refreshed for the first time.
+	ComputedAt   *time.Time                                 `json:"computedAt"`
+	Repositories []GetProjectStorageUsageResponseRepository `json:"repositories"`
+}
+
+type GetProjectStorageUsageResponseRepository struct {
+	StorageSizes
+	Id   uuid.UUID `json:"id"`
+	Slug string    `json:"slug"`
+}
+
+func GetProjectStorageUsage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	usage, err := mediatr.Send[*queries.GetProjectStorageUsageResponse](ctx, mediator, queries.GetProjectStorageUsage{
+		TenantSlug:  tenantSlug,
+		ProjectSlug: projectSlug,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := GetProjectStorageUsageResponse{
+		StorageSizes: mapStorageSizes(usage.Sizes),
+		ComputedAt:   usage.ComputedAt,
+		Repositories: make([]GetProjectStorageUsageResponseRepository, len(usage.Repositories)),
+	}
+
+	for i, repository := range usage.Repositories {
+		response.Repositories[i] = GetProjectStorageUsageResponseRepository{
+			StorageSizes: mapStorageSizes(repository.Sizes),
+			Id:           repository.Id,
+			Slug:         repository.Slug,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type GetRepositoryStorageUsageResponse struct {
+	StorageSizes
+	Tags         []GetRepositoryStorageUsageResponseTag         `json:"tags"`
+	SharedLayers []GetRepositoryStorageUsageResponseSharedLayer `json:"sharedLayers"`
+}
+
+type GetRepositoryStorageUsageResponseTag struct {
+	Name        string `json:"name"`
+	Digest      string `json:"digest"`
+	LogicalSize int64  `json:"logicalSize"`
+	UniqueSize  int64  `json:"uniqueSize"`
+}
+
+type GetRepositoryStorageUsageResponseSharedLayer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	// SharedWith is the number of other repositories storing the layer, they may belong to other tenants.
+	SharedWith int      `json:"sharedWith"`
+	Tags       []string `json:"tags"`
+}
+
+func GetRepositoryStorageUsage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+	repositorySlug := vars["repository"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	usage, err := mediatr.Send[*queries.GetRepositoryStorageUsageResponse](ctx, mediator, queries.GetRepositoryStorageUsage{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: repositorySlug,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := GetRepositoryStorageUsageResponse{
+		StorageSizes: mapStorageSizes(usage.Sizes),
+		Tags:         make([]GetRepositoryStorageUsageResponseTag, len(usage.Tags)),
+		SharedLayers: make([]GetRepositoryStorageUsageResponseSharedLayer, len(usage.SharedBlobs)),
+	}
+
+	for i, tag := range usage.Tags {
+		response.Tags[i] = GetRepositoryStorageUsageResponseTag{
+			Name:        tag.Name,
+			Digest:      tag.Digest,
+			LogicalSize: tag.LogicalSize,
+			UniqueSize:  tag.UniqueSize,
+		}
+	}
+
+	for i, blob := range usage.SharedBlobs {
+		tags := blob.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+
+		response.SharedLayers[i] = GetRepositoryStorageUsageResponseSharedLayer{
+			Digest:     blob.Digest,
+			MediaType:  blob.MediaType,
+			Size:       blob.Size,
+			SharedWith: blob.SharedWith,
+			Tags:       tags,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
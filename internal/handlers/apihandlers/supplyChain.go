@@ -0,0 +1,286 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/The127/mediatr"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/supplyChain"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// AttachmentResponse names the image a document describes and where the document is stored.
+type AttachmentResponse struct {
+	SubjectDigest string `json:"subjectDigest"`
+	// Os, Architecture and Variant are only set if the subject is a platform of the requested index.
+	Os             string `json:"os,omitempty"`
+	Architecture   string `json:"architecture,omitempty"`
+	Variant        string `json:"variant,omitempty"`
+	ArtifactDigest string `json:"artifactDigest"`
+	DocumentDigest string `json:"documentDigest"`
+	MediaType      string `json:"mediaType"`
+}
+
+func mapAttachment(attachment supplyChain.Attachment) AttachmentResponse {
+	response := AttachmentResponse{
+		SubjectDigest:  attachment.SubjectDigest,
+		ArtifactDigest: attachment.ArtifactDigest,
+		DocumentDigest: attachment.DocumentDigest,
+		MediaType:      attachment.MediaType,
+	}
+
+	if attachment.Platform != nil {
+		response.Os = attachment.Platform.OS
+		response.Architecture = attachment.Platform.Architecture
+		response.Variant = attachment.Platform.Variant
+	}
+
+	return response
+}
+
+type PackageResponse struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version,omitempty"`
+	Purl     string   `json:"purl,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Licenses []string `json:"licenses"`
+}
+
+func mapPackage(documentPackage supplyChain.Package) PackageResponse {
+	return PackageResponse{
+		Name:     documentPackage.Name,
+		Version:  documentPackage.Version,
+		Purl:     documentPackage.Purl,
+		Type:     documentPackage.Type,
+		Licenses: nonNil(documentPackage.Licenses),
+	}
+}
+
+type ListImageSbomsResponse struct {
+	Sboms []SbomResponse `json:"sboms"`
+}
+
+type SbomResponse struct {
+	AttachmentResponse
+	Format   string            `json:"format"`
+	Packages []PackageResponse `json:"packages"`
+}
+
+func ListImageSboms(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	sboms, err := mediatr.Send[*queries.ListImageSbomsResponse](ctx, mediator, queries.ListImageSboms{
+		TenantSlug:     vars["tenant"],
+		ProjectSlug:    vars["project"],
+		RepositorySlug: vars["repository"],
+		Reference:      vars["reference"],
+		Search:         r.URL.Query().Get("search"),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListImageSbomsResponse{
+		Sboms: make([]SbomResponse, len(sboms.Sboms)),
+	}
+
+	for i, sbom := range sboms.Sboms {
+		response.Sboms[i] = SbomResponse{
+			AttachmentResponse: mapAttachment(sbom.Attachment),
+			Format:             string(sbom.Format),
+			Packages:           make([]PackageResponse, len(sbom.Packages)),
+		}
+
+		for j, documentPackage := range sbom.Packages {
+			response.Sboms[i].Packages[j] = mapPackage(documentPackage)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type ListImageProvenanceResponse struct {
+	Provenance []ProvenanceResponse `json:"provenance"`
+}
+
+type ProvenanceResponse struct {
+	AttachmentResponse
+	PredicateType    string             `json:"predicateType"`
+	BuilderId        string             `json:"builderId,omitempty"`
+	BuildType        string             `json:"buildType,omitempty"`
+	SourceRepository string             `json:"sourceRepository,omitempty"`
+	SourceRef        string             `json:"sourceRef,omitempty"`
+	SourceRevision   string             `json:"sourceRevision,omitempty"`
+	EntryPoint       string             `json:"entryPoint,omitempty"`
+	StartedOn        *time.Time         `json:"startedOn,omitempty"`
+	FinishedOn       *time.Time         `json:"finishedOn,omitempty"`
+	Materials        []MaterialResponse `json:"materials"`
+}
+
+type MaterialResponse struct {
+	Uri    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+func ListImageProvenance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	provenance, err := mediatr.Send[*queries.ListImageProvenanceResponse](ctx, mediator, queries.ListImageProvenance{
+		TenantSlug:     vars["tenant"],
+		ProjectSlug:    vars["project"],
+		RepositorySlug: vars["repository"],
+		Reference:      vars["reference"],
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListImageProvenanceResponse{
+		Provenance: make([]ProvenanceResponse, len(provenance.Provenance)),
+	}
+
+	for i, document := range provenance.Provenance {
+		response.Provenance[i] = ProvenanceResponse{
+			AttachmentResponse: mapAttachment(document.Attachment),
+			PredicateType:      document.Provenance.PredicateType,
+			BuilderId:          document.Provenance.BuilderId,
+			BuildType:          document.Provenance.BuildType,
+			SourceRepository:   document.Provenance.SourceRepository,
+			SourceRef:          document.Provenance.SourceRef,
+			SourceRevision:     document.Provenance.SourceRevision,
+			EntryPoint:         document.Provenance.EntryPoint,
+			StartedOn:          document.Provenance.StartedOn,
+			FinishedOn:         document.Provenance.FinishedOn,
+			Materials:          make([]MaterialResponse, len(document.Provenance.Materials)),
+		}
+
+		for j, material := range document.Provenance.Materials {
+			response.Provenance[i].Materials[j] = MaterialResponse{
+				Uri:    material.Uri,
+				Digest: material.Digest,
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+const (
+	defaultPackagesPageSize = 100
+	maxPackagesPageSize     = 1000
+)
+
+// SearchPackagesResponse is a page of the matches, TotalCount counts the matches on all pages.
+type SearchPackagesResponse struct {
+	Items      []SearchPackagesResponseItem `json:"items"`
+	TotalCount int                          `json:"totalCount"`
+}
+
+type SearchPackagesResponseItem struct {
+	Project        string          `json:"project"`
+	Repository     string          `json:"repository"`
+	ManifestDigest string          `json:"manifestDigest"`
+	Tags           []string        `json:"tags"`
+	Package        PackageResponse `json:"package"`
+}
+
+func SearchPackages(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		apiError.HandleHttpError(w, fmt.Errorf("name has to be set: %w", apiError.ErrApiBadRequest))
+		return
+	}
+
+	page, err := parseQueryInt(r, "page", 1)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	pageSize, err := parseQueryInt(r, "pageSize", defaultPackagesPageSize)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+	if pageSize > maxPackagesPageSize {
+		apiError.HandleHttpError(w, fmt.Errorf("pageSize must not exceed %d: %w", maxPackagesPageSize, apiError.ErrApiBadRequest))
+		return
+	}
+
+	matches, err := mediatr.Send[*queries.SearchPackagesResponse](ctx, mediator, queries.SearchPackages{
+		TenantSlug: vars["tenant"],
+		Name:       name,
+		Version:    r.URL.Query().Get("version"),
+		Page:       page,
+		PageSize:   pageSize,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := SearchPackagesResponse{
+		Items:      make([]SearchPackagesResponseItem, len(matches.Items)),
+		TotalCount: matches.TotalCount,
+	}
+
+	for i, match := range matches.Items {
+		response.Items[i] = SearchPackagesResponseItem{
+			Project:        match.ProjectSlug,
+			Repository:     match.RepositorySlug,
+			ManifestDigest: match.ManifestDigest,
+			Tags:           nonNil(match.Tags),
+			Package:        mapPackage(match.Package),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+// parseQueryInt reads a positive integer query parameter, fallback is used if the parameter is not set.
+func parseQueryInt(r *http.Request, name string, fallback int) (int, error) {
+	if !r.URL.Query().Has(name) {
+		return fallback, nil
+	}
+
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer: %w", name, apiError.ErrApiBadRequest)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,204 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/handlers"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/decoding"
+	"github.com/the127/dockyard/internal/utils/validate"
+)
+
+type TagRuleRequest struct {
+	// Pattern is a shell pattern the tags are matched against, e.g. v* or release-*.
+	Pattern string `json:"pattern" validate:"required"`
+	// Kind is either immutable or admin_only.
+	Kind string `json:"kind" validate:"required,oneof=immutable admin_only"`
+}
+
+type CreateTagRuleResponse struct {
+	Id uuid.UUID `json:"id"`
+}
+
+// tagRuleId parses the rule of the route, malformed ids are reported as bad requests.
+func tagRuleId(vars map[string]string) (uuid.UUID, error) {
+	ruleId, err := uuid.Parse(vars["rule"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid tag rule id: %w", apiError.ErrApiBadRequest)
+	}
+
+	return ruleId, nil
+}
+
+func CreateTagRule(w http.ResponseWriter, r *http.Request) {
+	var dto TagRuleRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	tagRule, err := mediatr.Send[*commands.CreateTagRuleResponse](ctx, mediator, commands.CreateTagRule{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+		Pattern:        dto.Pattern,
+		Kind:           dto.Kind,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := CreateTagRuleResponse{
+		Id: tagRule.Id,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+type ListTagRulesResponse handlers.PagedResponse[ListTagRulesResponseItem]
+
+type ListTagRulesResponseItem struct {
+	Id        uuid.UUID `json:"id"`
+	Pattern   string    `json:"pattern"`
+	Kind      string    `json:"kind"`
+	Inherited bool      `json:"inherited"`
+}
+
+func ListTagRules(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	tagRules, err := mediatr.Send[*queries.ListTagRulesResponse](ctx, mediator, queries.ListTagRules{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := ListTagRulesResponse{
+		Items: make([]ListTagRulesResponseItem, len(tagRules.Items)),
+	}
+
+	for i, item := range tagRules.Items {
+		response.Items[i] = ListTagRulesResponseItem{
+			Id:        item.Id,
+			Pattern:   item.Pattern,
+			Kind:      item.Kind,
+			Inherited: item.Inherited,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func UpdateTagRule(w http.ResponseWriter, r *http.Request) {
+	var dto TagRuleRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := tagRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.UpdateTagRuleResponse](ctx, mediator, commands.UpdateTagRule{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+		RuleId:         ruleId,
+		Pattern:        dto.Pattern,
+		Kind:           dto.Kind,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func DeleteTagRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ruleId, err := tagRuleId(vars)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.DeleteTagRuleResponse](ctx, mediator, commands.DeleteTagRule{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+		RuleId:         ruleId,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
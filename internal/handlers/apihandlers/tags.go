@@ -33,6 +33,7 @@ func ListTags(w http.ResponseWriter, r *http.Request) {
 		TenantSlug:     tenantSlug,
 		ProjectSlug:    projectSlug,
 		RepositorySlug: repositorySlug,
+		WithSizes:      true,
 	})
 	if err != nil {
 		apiError.HandleHttpError(w, err)
@@ -0,0 +1,149 @@
+package apihandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/decoding"
+	"github.com/the127/dockyard/internal/utils/validate"
+)
+
+// defaultUpstreamTagTtl is used when an upstream is set without a tag ttl.
+const defaultUpstreamTagTtl = 5 * time.Minute
+
+type SetUpstreamRequest struct {
+	Url string `json:"url" validate:"required,url"`
+	// Repository is the upstream repository of a repository upstream, e.g. library/nginx, or the namespace the
+	// repositories of a project upstream are resolved in.
+	Repository    string  `json:"repository"`
+	Username      *string `json:"username"`
+	Password      *string `json:"password"`
+	TagTtlSeconds *int64  `json:"tagTtlSeconds" validate:"omitempty,min=0"`
+}
+
+type GetUpstreamResponse struct {
+	Id            uuid.UUID `json:"id"`
+	Url           string    `json:"url"`
+	Repository    string    `json:"repository"`
+	Username      *string   `json:"username"`
+	HasPassword   bool      `json:"hasPassword"`
+	TagTtlSeconds int64     `json:"tagTtlSeconds"`
+}
+
+// optionalRepositorySlug returns the repository of repository scoped routes and nil for project scoped routes.
+func optionalRepositorySlug(vars map[string]string) *string {
+	repositorySlug, ok := vars["repository"]
+	if !ok {
+		return nil
+	}
+
+	return &repositorySlug
+}
+
+func SetUpstream(w http.ResponseWriter, r *http.Request) {
+	var dto SetUpstreamRequest
+	err := decoding.HttpBodyAsJson(w, r, &dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	err = validate.Validate(dto)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	tagTtl := defaultUpstreamTagTtl
+	if dto.TagTtlSeconds != nil {
+		tagTtl = time.Duration(*dto.TagTtlSeconds) * time.Second
+	}
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err = mediatr.Send[*commands.SetUpstreamResponse](ctx, mediator, commands.SetUpstream{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+		Url:            dto.Url,
+		Repository:     dto.Repository,
+		Username:       dto.Username,
+		Password:       dto.Password,
+		TagTtl:         tagTtl,
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetUpstream(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	upstream, err := mediatr.Send[*queries.GetUpstreamResponse](ctx, mediator, queries.GetUpstream{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	response := GetUpstreamResponse{
+		Id:            upstream.Id,
+		Url:           upstream.Url,
+		Repository:    upstream.Repository,
+		Username:      upstream.Username,
+		HasPassword:   upstream.HasPassword,
+		TagTtlSeconds: int64(upstream.TagTtl / time.Second),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+}
+
+func DeleteUpstream(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenantSlug := vars["tenant"]
+	projectSlug := vars["project"]
+
+	ctx := r.Context()
+	mediator := middlewares.GetMediator(ctx)
+
+	_, err := mediatr.Send[*commands.DeleteUpstreamResponse](ctx, mediator, commands.DeleteUpstream{
+		TenantSlug:     tenantSlug,
+		ProjectSlug:    projectSlug,
+		RepositorySlug: optionalRepositorySlug(vars),
+	})
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
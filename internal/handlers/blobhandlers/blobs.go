@@ -1,8 +1,10 @@
 package blobhandlers
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 	"github.com/gorilla/mux"
 	"github.com/the127/dockyard/internal/middlewares"
@@ -18,7 +20,19 @@ func DownloadBlob(w http.ResponseWriter, r *http.Request) {
 	digest := vars["digest"]
 
 	blobService := ioc.GetDependency[blobStorage.Service](scope)
-	err := blobService.DownloadBlob(ctx, w, digest)
+	expiresAt, err := blobService.VerifyBlobDownloadLink(ctx, r, digest)
+	if err != nil {
+		apiError.HandleHttpError(w, err)
+		return
+	}
+
+	// blobs are addressed by their digest, so their content can never change. Caches must not serve the response after
+	// the link expired though.
+	clockService := ioc.GetDependency[clock.Service](scope)
+	maxAge := int(expiresAt.Sub(clockService.Now()).Seconds())
+	cacheControl := fmt.Sprintf("public, max-age=%d, immutable", maxAge)
+
+	_, err = blobService.DownloadBlob(ctx, w, r, digest, cacheControl)
 	if err != nil {
 		apiError.HandleHttpError(w, err)
 		return
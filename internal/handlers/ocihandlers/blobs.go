@@ -1,9 +1,12 @@
 package ocihandlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/The127/ioc"
 	"github.com/The127/mediatr"
@@ -15,7 +18,9 @@ import (
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
 	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/repositories"
 	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/storageUsage"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
@@ -48,6 +53,15 @@ func BlobsDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	med := middlewares.GetMediator(ctx)
+	_, err = mediatr.Send[*commands.ProxyBlobResponse](ctx, med, commands.ProxyBlob{
+		RepositoryId: repository.GetId(),
+		Digest:       digest,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
 	result, err := mediatr.Send[*queries.GetRepositoryBlobResponse](ctx, med, queries.GetRepositoryBlob{
 		RepositoryId: repository.GetId(),
 		Digest:       digest,
@@ -58,7 +72,11 @@ func BlobsDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	blobService := ioc.GetDependency[blobStorage.Service](scope)
-	redirectUri, err := blobService.GetBlobDownloadLink(ctx, result.Blob.GetDigest())
+	redirectUri, err := blobService.GetBlobDownloadLink(ctx, blobStorage.BlobDownloadLinkParams{
+		Digest:     result.Blob.GetDigest(),
+		TenantSlug: repoIdentifier.TenantSlug,
+		ClientIp:   blobService.ClientIp(r),
+	})
 	if err != nil {
 		ociError.HandleHttpError(w, r, err)
 		return
@@ -96,6 +114,23 @@ func BlobExists(w http.ResponseWriter, r *http.Request) {
 	}
 
 	med := middlewares.GetMediator(ctx)
+	proxied, err := mediatr.Send[*commands.ProxyBlobResponse](ctx, med, commands.ProxyBlob{
+		RepositoryId: repository.GetId(),
+		Digest:       digest,
+		HeadOnly:     true,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	if proxied.UpstreamSize != nil {
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", strconv.FormatInt(*proxied.UpstreamSize, 10))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	result, err := mediatr.Send[*queries.GetRepositoryBlobResponse](ctx, med, queries.GetRepositoryBlob{
 		RepositoryId: repository.GetId(),
 		Digest:       digest,
@@ -135,8 +170,23 @@ func BlobsUploadStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	med := middlewares.GetMediator(ctx)
+	_, err = mediatr.Send[*commands.CheckPushAllowedResponse](ctx, med, commands.CheckPushAllowed{
+		RepositoryId: repository.GetId(),
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
 	digest := r.URL.Query().Get("digest")
 	if digest != "" {
+		err = storageUsage.CheckQuota(ctx, tx, repository.GetId(), digest, max(r.ContentLength, 0))
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
+
 		blobService := ioc.GetDependency[blobStorage.Service](scope)
 		r.Body = http.MaxBytesReader(w, r.Body, 1024*1024*512)
 		uploadResponse, err := blobService.UploadCompleteBlob(ctx, digest, r.Body, blobStorage.BlobContentTypeOctetStream)
@@ -145,7 +195,6 @@ func BlobsUploadStart(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		med := middlewares.GetMediator(ctx)
 		_, err = mediatr.Send[*commands.FinishUploadResponse](ctx, med, commands.FinishUpload{
 			RepositoryId:   repository.GetId(),
 			ComputedDigest: uploadResponse.Digest,
@@ -163,6 +212,25 @@ func BlobsUploadStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mountDigest := r.URL.Query().Get("mount")
+	if mountDigest != "" {
+		mounted, err := mountBlob(ctx, tx, repository, mountDigest, r.URL.Query().Get("from"))
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
+
+		if mounted {
+			location := fmt.Sprintf("/v2/%s/%s/blobs/%s", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, mountDigest)
+			w.Header().Set("Location", location)
+			w.Header().Set("Docker-Content-Digest", mountDigest)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		// the blob cannot be mounted, the client has to upload it using the session started below
+	}
+
 	var uploadMode jsontypes.BlobUploadMode
 	contentLength := r.Header.Get("Content-Length")
 	switch contentLength {
@@ -179,6 +247,13 @@ func BlobsUploadStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the size of the blob is unknown yet, only uploads into a project or tenant that is over its quota are rejected
+	err = storageUsage.CheckQuota(ctx, tx, repository.GetId(), "", 0)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
 	blobService := ioc.GetDependency[blobStorage.Service](scope)
 	uploadSession, err := blobService.StartUploadSession(ctx, blobStorage.StartUploadSessionParams{
 		BlobUploadMode: uploadMode,
@@ -192,90 +267,174 @@ func BlobsUploadStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	location := fmt.Sprintf("/v2/%s/%s/blobs/uploads/%s", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, uploadSession.SessionId.String())
-
-	w.Header().Set("Location", location)
+	w.Header().Set("Location", buildUploadLocation(repoIdentifier, uploadSession.SessionId))
+	w.Header().Set("Docker-Upload-UUID", uploadSession.SessionId.String())
+	w.Header().Set("OCI-Chunk-Min-Length", strconv.FormatInt(blobService.GetChunkMinLength(), 10))
 	w.WriteHeader(http.StatusAccepted)
 }
 
-func UploadChunk(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("Content-Type") != "application/octet-stream" {
-		err := ociError.NewOciError(ociError.Unsupported).
-			WithMessage("unsupported content type")
+// mountBlob links the blob from the source repository given by from into the target repository. It reports false if
+// the source repository does not exist, is not readable by the current user or does not contain the blob.
+func mountBlob(ctx context.Context, dbContext database.Context, repository *repositories.Repository, digest string, from string) (bool, error) {
+	fromParts := strings.Split(from, "/")
+	if len(fromParts) != 2 {
+		return false, nil
+	}
+
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+	sourceIdentifier := middlewares.OciRepositoryIdentifier{
+		TenantSlug:     repoIdentifier.TenantSlug,
+		ProjectSlug:    fromParts[0],
+		RepositorySlug: fromParts[1],
+	}
+
+	_, _, sourceRepository, err := getRepositoryByIdentifier(ctx, dbContext, sourceIdentifier)
+	if err != nil {
+		var ociErr *ociError.OciError
+		if errors.As(err, &ociErr) && ociErr.Code == ociError.NameUnknown {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	currentUser := ociAuthentication.GetCurrentUser(ctx)
+	allowed, err := checkAccessForUserAndRepository(ctx, dbContext, currentUser.UserId, sourceRepository, ociAuthentication.PullAccess)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		return false, nil
+	}
+
+	med := middlewares.GetMediator(ctx)
+	result, err := mediatr.Send[*commands.MountBlobResponse](ctx, med, commands.MountBlob{
+		SourceRepositoryId: sourceRepository.GetId(),
+		TargetRepositoryId: repository.GetId(),
+		Digest:             digest,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.Mounted, nil
+}
+
+func UploadStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.PushAccess)
+	if err != nil {
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	/*lengthHeader := r.Header.Get("Content-Length")
-	if lengthHeader == "" {
-		ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-			WithMessage("missing content length"))
+	sessionId, err := parseUploadSessionId(r)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	length, err := strconv.Atoi(lengthHeader)
+	scope := middlewares.GetScope(ctx)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+
+	rangeEnd, err := blobService.GetUploadRangeEnd(ctx, sessionId)
 	if err != nil {
-		ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-			WithMessage("invalid content length"))
+		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	rangeHeader := r.Header.Get("Content-Range")
-	if rangeHeader == "" {
-		ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-			WithMessage("missing content range"))
+	w.Header().Set("Location", buildUploadLocation(repoIdentifier, sessionId))
+	w.Header().Set("Range", blobStorage.FormatRange(rangeEnd))
+	w.Header().Set("Docker-Upload-UUID", sessionId.String())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UploadChunk(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.PushAccess)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	rangeParts := strings.SplitN(rangeHeader, "-", 2)
+	if r.Header.Get("Content-Type") != "application/octet-stream" {
+		err := ociError.NewOciError(ociError.Unsupported).
+			WithMessage("unsupported content type")
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
 
-	rangeStart, err := strconv.Atoi(rangeParts[0])
+	sessionId, err := parseUploadSessionId(r)
 	if err != nil {
-		ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-			WithMessage("invalid content range"))
+		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	rangeEnd, err := strconv.Atoi(rangeParts[1])
+	location := buildUploadLocation(repoIdentifier, sessionId)
+
+	rangeStart, err := parseContentRange(r)
 	if err != nil {
-		ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-			WithMessage("invalid content range"))
+		ociError.HandleHttpError(w, r, withUploadLocation(err, location))
 		return
 	}
 
-	if length != rangeEnd-rangeStart+1 {
-		ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-			WithMessage("content range differs from content length"))
+	scope := middlewares.GetScope(ctx)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+
+	rangeEnd, err := blobService.GetUploadRangeEnd(ctx, sessionId)
+	if err != nil {
+		ociError.HandleHttpError(w, r, withUploadLocation(err, location))
 		return
-	}*/
+	}
 
-	vars := mux.Vars(r)
-	sessionIdString := vars["reference"]
-	sessionId, err := uuid.Parse(sessionIdString)
+	dbFactory := ioc.GetDependency[database.Factory](scope)
+	dbContext, err := dbFactory.NewDbContext(ctx)
 	if err != nil {
-		err := ociError.NewOciError(ociError.BlobUploadInvalid).
-			WithMessage("session id must be a valid uuid")
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	ctx := r.Context()
-	scope := middlewares.GetScope(ctx)
-	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	_, _, repository, err := getRepositoryByIdentifier(ctx, dbContext, repoIdentifier)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	// the digest of the blob is unknown until the upload is finished, so the upload counts as new data even if the
+	// blob turns out to be stored already
+	err = storageUsage.CheckQuota(ctx, dbContext, repository.GetId(), "", rangeEnd+max(r.ContentLength, 0))
+	if err != nil {
+		ociError.HandleHttpError(w, r, withUploadLocation(err, location))
+		return
+	}
 
 	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024*512) // max 512 MB
-	uploadResponse, err := blobService.UploadWriteChunk(ctx, sessionId, r.Body)
+	uploadResponse, err := blobService.UploadWriteChunk(ctx, sessionId, rangeStart, r.Body)
 	if err != nil {
-		ociError.HandleHttpError(w, r, err)
+		ociError.HandleHttpError(w, r, withUploadLocation(err, location))
 		return
 	}
 
-	w.Header().Set("Location", r.URL.String())
-	w.Header().Set("Range", "0-"+strconv.FormatInt(uploadResponse.Size, 10))
+	w.Header().Set("Location", location)
+	w.Header().Set("Range", blobStorage.FormatRange(uploadResponse.Size))
+	w.Header().Set("Docker-Upload-UUID", sessionId.String())
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func FinishUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.PushAccess)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
 	digest := r.URL.Query().Get("digest")
 	if digest == "" {
 		err := ociError.NewOciError(ociError.DigestInvalid).
@@ -284,46 +443,28 @@ func FinishUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	sessionIdString := vars["reference"]
-	sessionId, err := uuid.Parse(sessionIdString)
+	sessionId, err := parseUploadSessionId(r)
 	if err != nil {
-		err := ociError.NewOciError(ociError.BlobUploadInvalid).
-			WithMessage("session id must be a valid uuid")
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	ctx := r.Context()
+	uploadLocation := buildUploadLocation(repoIdentifier, sessionId)
+
+	rangeStart, err := parseContentRange(r)
+	if err != nil {
+		ociError.HandleHttpError(w, r, withUploadLocation(err, uploadLocation))
+		return
+	}
+
 	scope := middlewares.GetScope(ctx)
 	blobService := ioc.GetDependency[blobStorage.Service](scope)
 
+	// the final chunk is optional and may be smaller than the minimum chunk length
 	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024*512) // max 512 MB
-	/*lengthHeader := r.Header.Get("Content-Length")
-	if lengthHeader != "" {
-		if r.Header.Get("Content-Type") != "application/octet-stream" {
-			ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-				WithMessage("unsupported content type"))
-			return
-		}
-
-		length, err := strconv.Atoi(lengthHeader)
-		if err != nil {
-			ociError.HandleHttpError(w, ociError.NewOciError(ociError.Unsupported).
-				WithMessage("invalid content length"))
-			return
-		}
-
-		err := blobService.UploadWriteChunk(ctx, sessionId, r.Body)
-		if err != nil {
-			ociError.HandleHttpError(w, r, err)
-			return
-		}
-	}*/
-
-	_, err = blobService.UploadWriteChunk(ctx, sessionId, r.Body)
+	_, err = blobService.UploadWriteChunk(ctx, sessionId, rangeStart, r.Body)
 	if err != nil {
-		ociError.HandleHttpError(w, r, err)
+		ociError.HandleHttpError(w, r, withUploadLocation(err, uploadLocation))
 		return
 	}
 
@@ -351,10 +492,111 @@ func FinishUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
-
-	location := fmt.Sprintf("/v2/%s/%s/%s/blobs/%s", repoIdentifier.TenantSlug, repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, digest)
+	location := fmt.Sprintf("/v2/%s/%s/blobs/%s", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, digest)
 
 	w.Header().Set("Location", location)
+	w.Header().Set("Docker-Content-Digest", digest)
 	w.WriteHeader(http.StatusCreated)
 }
+
+func parseUploadSessionId(r *http.Request) (uuid.UUID, error) {
+	vars := mux.Vars(r)
+	sessionId, err := uuid.Parse(vars["reference"])
+	if err != nil {
+		return uuid.Nil, ociError.NewOciError(ociError.BlobUploadInvalid).
+			WithMessage("session id must be a valid uuid")
+	}
+
+	return sessionId, nil
+}
+
+func buildUploadLocation(repoIdentifier middlewares.OciRepositoryIdentifier, sessionId uuid.UUID) string {
+	return fmt.Sprintf("/v2/%s/%s/blobs/uploads/%s", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, sessionId)
+}
+
+// withUploadLocation adds the upload session location to oci errors, clients need it to resume an upload after a
+// rejected chunk.
+func withUploadLocation(err error, location string) error {
+	var ociErr *ociError.OciError
+	if errors.As(err, &ociErr) {
+		ociErr.WithHeader("Location", location)
+	}
+
+	return err
+}
+
+// parseContentRange returns the start offset of the chunk given by the Content-Range header, or nil if the client
+// did not send one. The range is inclusive and has the form <start>-<end>.
+func parseContentRange(r *http.Request) (*int64, error) {
+	rangeHeader := r.Header.Get("Content-Range")
+	if rangeHeader == "" {
+		return nil, nil
+	}
+
+	invalidRangeErr := ociError.NewOciError(ociError.BlobUploadInvalid).
+		WithMessage("invalid content range").
+		WithHttpCode(http.StatusRequestedRangeNotSatisfiable)
+
+	rangeParts := strings.SplitN(rangeHeader, "-", 2)
+	if len(rangeParts) != 2 {
+		return nil, invalidRangeErr
+	}
+
+	rangeStart, err := strconv.ParseInt(rangeParts[0], 10, 64)
+	if err != nil || rangeStart < 0 {
+		return nil, invalidRangeErr
+	}
+
+	rangeEnd, err := strconv.ParseInt(rangeParts[1], 10, 64)
+	if err != nil || rangeEnd < rangeStart {
+		return nil, invalidRangeErr
+	}
+
+	if r.ContentLength >= 0 && r.ContentLength != rangeEnd-rangeStart+1 {
+		return nil, ociError.NewOciError(ociError.SizeInvalid).
+			WithMessage("content range differs from content length")
+	}
+
+	return &rangeStart, nil
+}
+
+func BlobsDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.DeleteAccess)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	digest := vars["digest"]
+
+	dbFactory := ioc.GetDependency[database.Factory](scope)
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	_, _, repository, err := getRepositoryByIdentifier(ctx, dbContext, repoIdentifier)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	med := middlewares.GetMediator(ctx)
+	_, err = mediatr.Send[*commands.DeleteRepositoryBlobResponse](ctx, med, commands.DeleteRepositoryBlob{
+		RepositoryId: repository.GetId(),
+		Digest:       digest,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
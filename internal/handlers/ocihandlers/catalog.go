@@ -0,0 +1,67 @@
+package ocihandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/The127/mediatr"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+type CatalogResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+func Catalog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantSlug := strings.Split(r.Host, ".")[0]
+
+	currentUser := ociAuthentication.GetCurrentUser(ctx)
+	if !currentUser.CatalogAccess {
+		err := ociAuthentication.NewUnauthorizedError(tenantSlug, catalogScope, "user is not authenticated")
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	page, err := parsePagination(r)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	med := middlewares.GetMediator(ctx)
+	result, err := mediatr.Send[*queries.ListCatalogResponse](ctx, med, queries.ListCatalog{
+		TenantSlug: tenantSlug,
+		UserId:     currentUser.UserId,
+		Last:       page.last,
+		Limit:      page.limit,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	names := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		names[i] = item.Name
+	}
+
+	if page.limit != nil && len(names) > 0 && len(names) < result.TotalCount {
+		setNextPageLink(w, "/v2/_catalog", *page.limit, names[len(names)-1])
+	}
+
+	response := CatalogResponse{
+		Repositories: names,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+}
@@ -1,7 +1,6 @@
 package ocihandlers
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,10 +12,12 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/the127/dockyard/internal/commands"
 	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/logging"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
 	"github.com/the127/dockyard/internal/queries"
 	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/utils/digest"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
@@ -27,6 +28,49 @@ var validManifestMediaTypes = map[string]bool{
 	"application/vnd.docker.distribution.manifest.list.v2+json": true,
 }
 
+var indexManifestMediaTypes = map[string]bool{
+	"application/vnd.oci.image.index.v1+json":                   true,
+	"application/vnd.docker.distribution.manifest.list.v2+json": true,
+}
+
+// externalLayerMediaTypes are layers that are hosted outside the registry, they are never pushed and cannot be checked.
+var externalLayerMediaTypes = map[string]bool{
+	"application/vnd.oci.image.layer.nondistributable.v1.tar":      true,
+	"application/vnd.oci.image.layer.nondistributable.v1.tar+gzip": true,
+	"application/vnd.oci.image.layer.nondistributable.v1.tar+zstd": true,
+	"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip":    true,
+}
+
+type manifestDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// mapManifestDescriptors checks that the descriptors are well-formed and skips the ones pointing to external content.
+func mapManifestDescriptors(descriptors ...manifestDescriptor) ([]commands.ManifestDescriptor, error) {
+	result := make([]commands.ManifestDescriptor, 0, len(descriptors))
+
+	for _, descriptor := range descriptors {
+		if digest.Validate(descriptor.Digest) != nil || descriptor.Size < 0 {
+			return nil, ociError.NewOciError(ociError.ManifestInvalid).
+				WithMessage(fmt.Sprintf("descriptor '%s' is invalid", descriptor.Digest))
+		}
+
+		if externalLayerMediaTypes[descriptor.MediaType] {
+			continue
+		}
+
+		result = append(result, commands.ManifestDescriptor{
+			MediaType: descriptor.MediaType,
+			Digest:    descriptor.Digest,
+			Size:      descriptor.Size,
+		})
+	}
+
+	return result, nil
+}
+
 func ManifestsDownload(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
@@ -56,6 +100,15 @@ func ManifestsDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	med := middlewares.GetMediator(ctx)
+	_, err = mediatr.Send[*commands.ProxyManifestResponse](ctx, med, commands.ProxyManifest{
+		RepositoryId: repository.GetId(),
+		Reference:    reference,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
 	result, err := mediatr.Send[*queries.GetManifestByReferenceResponse](ctx, med, queries.GetManifestByReference{
 		RepositoryId: repository.GetId(),
 		Reference:    reference,
@@ -71,10 +124,31 @@ func ManifestsDownload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Docker-Content-Digest", result.Blob.GetDigest())
 	w.Header().Set("Content-Length", strconv.FormatInt(result.Blob.GetSize(), 10))
 
-	if err := blobService.DownloadBlob(ctx, w, result.Blob.GetDigest()); err != nil {
+	// tags can be moved, but a manifest fetched by digest never changes
+	cacheControl := ""
+	if digest.IsDigest(reference) {
+		cacheControl = "max-age=31536000, immutable"
+	}
+
+	download, err := blobService.DownloadBlob(ctx, w, r, result.Blob.GetDigest(), cacheControl)
+	if err != nil {
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
+
+	// partial and not modified responses are resumed or cached downloads, only complete ones count as pulls
+	if download.StatusCode != http.StatusOK {
+		return
+	}
+
+	// the manifest has already been served, failing to record the pull must not fail the request
+	_, err = mediatr.Send[*commands.RecordManifestPullResponse](ctx, med, commands.RecordManifestPull{
+		RepositoryId: repository.GetId(),
+		Reference:    reference,
+	})
+	if err != nil {
+		logging.Logger.Warnf("recording pull of '%s' in repository %s failed: %v", reference, repository.GetId(), err)
+	}
 }
 
 func ManifestsExists(w http.ResponseWriter, r *http.Request) {
@@ -106,6 +180,15 @@ func ManifestsExists(w http.ResponseWriter, r *http.Request) {
 	}
 
 	med := middlewares.GetMediator(ctx)
+	_, err = mediatr.Send[*commands.ProxyManifestResponse](ctx, med, commands.ProxyManifest{
+		RepositoryId: repository.GetId(),
+		Reference:    reference,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
 	result, err := mediatr.Send[*queries.GetManifestByReferenceResponse](ctx, med, queries.GetManifestByReference{
 		RepositoryId: repository.GetId(),
 		Reference:    reference,
@@ -118,6 +201,7 @@ func ManifestsExists(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", result.Manifest.GetMediaType())
 	w.Header().Set("Docker-Content-Digest", result.Blob.GetDigest())
 	w.Header().Set("Content-Length", strconv.FormatInt(result.Blob.GetSize(), 10))
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", result.Blob.GetDigest()))
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -156,8 +240,14 @@ func UploadManifest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var manifest struct {
-		MediaType     string `json:"mediaType"`
-		SchemaVersion int    `json:"schemaVersion"`
+		MediaType     string               `json:"mediaType"`
+		SchemaVersion int                  `json:"schemaVersion"`
+		ArtifactType  string               `json:"artifactType"`
+		Config        *manifestDescriptor  `json:"config"`
+		Layers        []manifestDescriptor `json:"layers"`
+		Manifests     []manifestDescriptor `json:"manifests"`
+		Subject       *manifestDescriptor  `json:"subject"`
+		Annotations   map[string]string    `json:"annotations"`
 	}
 	if jsonErr := json.Unmarshal(bodyBytes, &manifest); jsonErr != nil {
 		ociError.HandleHttpError(w, r, ociError.NewOciError(ociError.ManifestInvalid).WithMessage("manifest is not valid JSON"))
@@ -176,19 +266,74 @@ func UploadManifest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sum256 := sha256.Sum256(bodyBytes)
-	digest := "sha256:" + fmt.Sprintf("%x", sum256)
+	var blobDescriptors []commands.ManifestDescriptor
+	var manifestDescriptors []commands.ManifestDescriptor
+	if indexManifestMediaTypes[mediaType] {
+		if manifest.Manifests == nil {
+			ociError.HandleHttpError(w, r, ociError.NewOciError(ociError.ManifestInvalid).WithMessage("index must contain a manifests list"))
+			return
+		}
+
+		manifestDescriptors, err = mapManifestDescriptors(manifest.Manifests...)
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
+	} else {
+		if manifest.Config == nil || manifest.Layers == nil {
+			ociError.HandleHttpError(w, r, ociError.NewOciError(ociError.ManifestInvalid).WithMessage("manifest must contain a config and a layers list"))
+			return
+		}
+
+		blobDescriptors, err = mapManifestDescriptors(append([]manifestDescriptor{*manifest.Config}, manifest.Layers...)...)
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
+	}
+
+	var subjectDigest *string
+	if manifest.Subject != nil {
+		if digest.Validate(manifest.Subject.Digest) != nil {
+			ociError.HandleHttpError(w, r, ociError.NewOciError(ociError.ManifestInvalid).WithMessage("manifest subject digest is invalid"))
+			return
+		}
+		subjectDigest = &manifest.Subject.Digest
+	}
+
+	// images without an explicit artifact type are identified by their config media type
+	artifactType := manifest.ArtifactType
+	if artifactType == "" && manifest.Config != nil {
+		artifactType = manifest.Config.MediaType
+	}
 
 	vars := mux.Vars(r)
 	reference := vars["reference"]
 
+	// a manifest pushed by digest is hashed with the algorithm chosen by the client
+	algorithm := digest.Canonical
+	if digest.IsDigest(reference) {
+		algorithm, _, err = digest.Parse(reference)
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
+	}
+	manifestDigest := algorithm.FromBytes(bodyBytes)
+
 	med := middlewares.GetMediator(ctx)
 	result, err := mediatr.Send[*commands.UploadManifestResponse](ctx, med, commands.UploadManifest{
-		RepositoryId: repository.GetId(),
-		Reference:    reference,
-		Digest:       digest,
-		MediaType:    mediaType,
-		Body:         bodyBytes,
+		RepositoryId:  repository.GetId(),
+		UserId:        ociAuthentication.GetCurrentUser(ctx).UserId,
+		Reference:     reference,
+		Digest:        manifestDigest,
+		MediaType:     mediaType,
+		Body:          bodyBytes,
+		SubjectDigest: subjectDigest,
+		ArtifactType:  artifactType,
+		Annotations:   manifest.Annotations,
+		Blobs:         blobDescriptors,
+		Manifests:     manifestDescriptors,
 	})
 	if err != nil {
 		ociError.HandleHttpError(w, r, err)
@@ -199,5 +344,50 @@ func UploadManifest(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Location", location)
 	w.Header().Set("Docker-Content-Digest", result.Digest)
+	if subjectDigest != nil {
+		// tells clients that the subject was processed so they can skip the referrers tag fallback
+		w.Header().Set("OCI-Subject", *subjectDigest)
+	}
 	w.WriteHeader(http.StatusCreated)
 }
+
+func ManifestsDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.DeleteAccess)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	reference := vars["reference"]
+
+	dbFactory := ioc.GetDependency[database.Factory](scope)
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	_, _, repository, err := getRepositoryByIdentifier(ctx, dbContext, repoIdentifier)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	med := middlewares.GetMediator(ctx)
+	_, err = mediatr.Send[*commands.DeleteManifestResponse](ctx, med, commands.DeleteManifest{
+		RepositoryId: repository.GetId(),
+		Reference:    reference,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
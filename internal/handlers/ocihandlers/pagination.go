@@ -0,0 +1,46 @@
+package ocihandlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+type pagination struct {
+	limit *int
+	last  *string
+}
+
+// parsePagination reads the n and last query parameters used by the tag and catalog listing endpoints.
+func parsePagination(r *http.Request) (pagination, error) {
+	var result pagination
+	query := r.URL.Query()
+
+	if query.Has("n") {
+		n, err := strconv.Atoi(query.Get("n"))
+		if err != nil || n < 0 {
+			return pagination{}, ociError.NewOciError(ociError.Unsupported).
+				WithMessage("n must be a non-negative integer")
+		}
+		result.limit = &n
+	}
+
+	if query.Has("last") {
+		last := query.Get("last")
+		result.last = &last
+	}
+
+	return result, nil
+}
+
+// setNextPageLink sets a RFC 5988 Link header pointing to the page after last.
+func setNextPageLink(w http.ResponseWriter, path string, limit int, last string) {
+	query := url.Values{}
+	query.Set("n", strconv.Itoa(limit))
+	query.Set("last", last)
+
+	w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", path, query.Encode()))
+}
@@ -0,0 +1,71 @@
+package ocihandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/utils/ociError"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type PaginationTestSuite struct {
+	suite.Suite
+}
+
+func TestPaginationTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(PaginationTestSuite))
+}
+
+func (s *PaginationTestSuite) TestParsePagination() {
+	testCases := []struct {
+		name    string
+		query   string
+		limit   *int
+		last    *string
+		invalid bool
+	}{
+		{name: "no parameters"},
+		{name: "limit", query: "n=10", limit: pointer.To(10)},
+		{name: "empty page", query: "n=0", limit: pointer.To(0)},
+		{name: "limit and last", query: "n=2&last=v1.0", limit: pointer.To(2), last: pointer.To("v1.0")},
+		{name: "escaped last", query: "last=a%2Bb", last: pointer.To("a+b")},
+		{name: "limit is not a number", query: "n=ten", invalid: true},
+		{name: "negative limit", query: "n=-1", invalid: true},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			r := httptest.NewRequest(http.MethodGet, "/v2/p/r/tags/list?"+testCase.query, nil)
+
+			// act
+			page, err := parsePagination(r)
+
+			// assert
+			if testCase.invalid {
+				var ociErr *ociError.OciError
+				s.Require().ErrorAs(err, &ociErr)
+				s.Equal(ociError.Unsupported, ociErr.Code)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(testCase.limit, page.limit)
+			s.Equal(testCase.last, page.last)
+		})
+	}
+}
+
+func (s *PaginationTestSuite) TestSetNextPageLink() {
+	// arrange
+	w := httptest.NewRecorder()
+
+	// act
+	setNextPageLink(w, "/v2/p/r/tags/list", 2, "v1.0+build")
+
+	// assert
+	s.Equal(`</v2/p/r/tags/list?last=v1.0%2Bbuild&n=2>; rel="next"`, w.Header().Get("Link"))
+}
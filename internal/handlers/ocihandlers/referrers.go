@@ -0,0 +1,102 @@
+package ocihandlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/The127/mediatr"
+	"github.com/gorilla/mux"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+const imageIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+type ReferrersDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type ReferrersResponse struct {
+	SchemaVersion int                   `json:"schemaVersion"`
+	MediaType     string                `json:"mediaType"`
+	Manifests     []ReferrersDescriptor `json:"manifests"`
+}
+
+// ReferrersList serves the referrers of a manifest as an image index. Clients that do not support the referrers api
+// push the index to a sha256-<digest> tag instead, which is served like any other tag.
+func ReferrersList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.PullAccess)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	subjectDigest := vars["digest"]
+	err = digest.Validate(subjectDigest)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	repository, err := getRepository(ctx, repoIdentifier)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	var artifactType *string
+	if r.URL.Query().Has("artifactType") {
+		value := r.URL.Query().Get("artifactType")
+		artifactType = &value
+	}
+
+	med := middlewares.GetMediator(ctx)
+	result, err := mediatr.Send[*queries.ListReferrersResponse](ctx, med, queries.ListReferrers{
+		RepositoryId:  repository.GetId(),
+		SubjectDigest: subjectDigest,
+		ArtifactType:  artifactType,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	manifests := make([]ReferrersDescriptor, len(result.Items))
+	for i, item := range result.Items {
+		manifests[i] = ReferrersDescriptor{
+			MediaType:    item.MediaType,
+			Digest:       item.Digest,
+			Size:         item.Size,
+			ArtifactType: item.ArtifactType,
+			Annotations:  item.Annotations,
+		}
+	}
+
+	response := ReferrersResponse{
+		SchemaVersion: 2,
+		MediaType:     imageIndexMediaType,
+		Manifests:     manifests,
+	}
+
+	if artifactType != nil {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+
+	w.Header().Set("Content-Type", imageIndexMediaType)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+}
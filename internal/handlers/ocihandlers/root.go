@@ -1,11 +1,9 @@
 package ocihandlers
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
-	"github.com/the127/dockyard/internal/config"
 	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
@@ -20,15 +18,6 @@ func Root(w http.ResponseWriter, r *http.Request) {
 
 	tenant := strings.Split(r.Host, ".")[0]
 
-	realm := fmt.Sprintf("%s/v2/token", config.C.Server.ExternalUrl)
-	service := fmt.Sprintf("%s:%s", config.C.Server.ExternalDomain, tenant)
-
-	wwwAuthenticateHeaderValue := fmt.Sprintf("Bearer realm=\"%s\",service=\"%s\"", realm, service)
-
-	err := ociError.NewOciError(ociError.Unauthorized).
-		WithMessage("user is not authenticated").
-		WithHttpCode(401).
-		WithHeader("WWW-Authenticate", wwwAuthenticateHeaderValue)
-
+	err := ociAuthentication.NewUnauthorizedError(tenant, "", "user is not authenticated")
 	ociError.HandleHttpError(w, r, err)
 }
@@ -0,0 +1,71 @@
+package ocihandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/mediatr"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
+	"github.com/the127/dockyard/internal/queries"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+type TagsListResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func TagsList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	repoIdentifier := middlewares.GetRepoIdentifier(ctx)
+
+	err := checkAccess(ctx, repoIdentifier, ociAuthentication.PullAccess)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	page, err := parsePagination(r)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+
+	med := middlewares.GetMediator(ctx)
+	result, err := mediatr.Send[*queries.ListTagsResponse](ctx, med, queries.ListTags{
+		TenantSlug:     repoIdentifier.TenantSlug,
+		ProjectSlug:    repoIdentifier.ProjectSlug,
+		RepositorySlug: repoIdentifier.RepositorySlug,
+		Last:           page.last,
+		Limit:          page.limit,
+	})
+	if err != nil {
+		ociError.HandleHttpError(w, r, mapNotFoundToNameUnknown(err, repoIdentifier))
+		return
+	}
+
+	name := fmt.Sprintf("%s/%s", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug)
+
+	tags := make([]string, len(result.Items))
+	for i, tag := range result.Items {
+		tags[i] = tag.Name
+	}
+
+	if page.limit != nil && len(tags) > 0 && len(tags) < result.TotalCount {
+		setNextPageLink(w, fmt.Sprintf("/v2/%s/tags/list", name), *page.limit, tags[len(tags)-1])
+	}
+
+	response := TagsListResponse{
+		Name: name,
+		Tags: tags,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
+}
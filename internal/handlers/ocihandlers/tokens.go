@@ -25,10 +25,41 @@ import (
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
+const (
+	accessTokenExpiration  = 10 * time.Minute
+	refreshTokenExpiration = 30 * 24 * time.Hour
+
+	passwordGrantType     = "password"
+	refreshTokenGrantType = "refresh_token"
+)
+
 type TokensResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int    `json:"expiresAt"`
-	IssuedAt  string `json:"issuedAt"`
+	Token        string `json:"token"`
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	IssuedAt     string `json:"issued_at"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+
+	// ExpiresAt and LegacyIssuedAt are kept for clients relying on the previous response, they hold the same values as
+	// ExpiresIn and IssuedAt.
+	ExpiresAt      int    `json:"expiresAt"`
+	LegacyIssuedAt string `json:"issuedAt"`
+}
+
+// refreshTokenClaims are the claims of the refresh tokens. Their audience is the token service instead of the tenant
+// id, so they cannot be used as access tokens. They are bound to the pat they were issued for and stop working as soon
+// as it is gone.
+type refreshTokenClaims struct {
+	jwt.RegisteredClaims
+	PatId uuid.UUID `json:"pat"`
+}
+
+// tokenGrant is the identity a token request was authenticated as.
+type tokenGrant struct {
+	userId uuid.UUID
+	// patId is uuid.Nil for anonymous requests, refresh tokens can only be issued if it is set.
+	patId uuid.UUID
 }
 
 func Tokens(w http.ResponseWriter, r *http.Request) {
@@ -39,8 +70,14 @@ func Tokens(w http.ResponseWriter, r *http.Request) {
 	}
 
 	service := r.Form.Get("service")
-	splitN := strings.SplitN(service, ":", 2)
-	tenantSlug := splitN[1]
+	_, tenantSlug, ok := strings.Cut(service, ":")
+	if !ok {
+		err := ociError.NewOciError(ociError.Unauthorized).
+			WithMessage("invalid service").
+			WithHttpCode(http.StatusUnauthorized)
+		ociError.HandleHttpError(w, r, err)
+		return
+	}
 
 	ctx := r.Context()
 	scope := middlewares.GetScope(ctx)
@@ -65,58 +102,92 @@ func Tokens(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	requestedScope := parseScopeFromRequest(r, tenantSlug)
-
-	userId, err := getUserId(r, tenant)
+	keyManager := ioc.GetDependency[signr.KeyManager](scope)
+	signingKey, err := keyManager.
+		GetGroup(fmt.Sprintf("jwt-signing-key:%s", tenantSlug)).
+		GetKey("EdDSA")
 	if err != nil {
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	restrictedScope, err := restrictScope(ctx, dbContext, userId, requestedScope)
+	grant, issueRefreshToken, err := authenticateTokenRequest(r, dbContext, tenant, service, signingKey)
 	if err != nil {
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
-	keyManager := ioc.GetDependency[signr.KeyManager](scope)
-	signingKey, err := keyManager.
-		GetGroup(fmt.Sprintf("jwt-signing-key:%s", tenantSlug)).
-		GetKey("EdDSA")
-	if err != nil {
-		ociError.HandleHttpError(w, r, err)
-		return
+	var accessClaims []ociAuthentication.AccessClaim
+	for _, requestedScope := range parseScopesFromRequest(r, tenantSlug) {
+		restrictedScope, err := restrictScope(ctx, dbContext, grant.userId, requestedScope)
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
+
+		if restrictedScope != nil {
+			accessClaims = addAccessClaim(accessClaims, restrictedScope.toAccessClaim())
+		}
+	}
+
+	// the catalog is filtered by the access of the user when it is listed, so the scope can be granted to everyone
+	if slices.Contains(requestedScopeStrings(r), catalogScope) {
+		accessClaims = append(accessClaims, ociAuthentication.AccessClaim{
+			Type:    ociAuthentication.RegistryResourceType,
+			Name:    ociAuthentication.CatalogResourceName,
+			Actions: []string{"*"},
+		})
 	}
 
 	clockService := ioc.GetDependency[clock.Service](scope)
 	now := clockService.Now()
 
-	claims := map[string]any{
-		"iss": config.C.Server.ExternalDomain,
-		"sub": userId.String(),
-		"aud": tenant.GetId().String(),
-		"exp": jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
-		"iat": jwt.NewNumericDate(now),
-	}
-
-	if restrictedScope != nil {
-		claims["repository"] = restrictedScope.repository
-		claims["access"] = restrictedScope.access
+	claims := ociAuthentication.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.C.Server.ExternalDomain,
+			Subject:   grant.userId.String(),
+			Audience:  jwt.ClaimStrings{tenant.GetId().String()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenExpiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Access: accessClaims,
 	}
 
-	mapClaims := jwt.MapClaims(claims)
-
 	s := NewJwtSigningMethod(signingKey)
-	j, err := jwt.NewWithClaims(s, mapClaims).SignedString(s)
+	j, err := jwt.NewWithClaims(s, claims).SignedString(s)
 	if err != nil {
 		ociError.HandleHttpError(w, r, err)
 		return
 	}
 
+	expiresIn := int(accessTokenExpiration.Seconds())
 	response := TokensResponse{
-		Token:     j,
-		ExpiresAt: 10 * 60,
-		IssuedAt:  now.Format(time.RFC3339),
+		Token:          j,
+		AccessToken:    j,
+		ExpiresIn:      expiresIn,
+		IssuedAt:       now.Format(time.RFC3339),
+		Scope:          formatAccessClaims(accessClaims),
+		ExpiresAt:      expiresIn,
+		LegacyIssuedAt: now.Format(time.RFC3339),
+	}
+
+	if issueRefreshToken && grant.patId != uuid.Nil {
+		refreshClaims := refreshTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    config.C.Server.ExternalDomain,
+				Subject:   grant.userId.String(),
+				Audience:  jwt.ClaimStrings{service},
+				ExpiresAt: jwt.NewNumericDate(now.Add(refreshTokenExpiration)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+			PatId: grant.patId,
+		}
+
+		response.RefreshToken, err = jwt.NewWithClaims(s, refreshClaims).SignedString(s)
+		if err != nil {
+			ociError.HandleHttpError(w, r, err)
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -127,6 +198,87 @@ func Tokens(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authenticateTokenRequest authenticates GET requests by their basic auth credentials and POST requests by the oauth2
+// grant. Requests without credentials are anonymous. It also reports whether the client asked for a refresh token.
+func authenticateTokenRequest(
+	r *http.Request,
+	dbContext database.Context,
+	tenant *repositories.Tenant,
+	service string,
+	signingKey signr.SigningKey,
+) (tokenGrant, bool, error) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		_, password, ok := r.BasicAuth()
+		if !ok {
+			return tokenGrant{}, false, nil
+		}
+
+		grant, err := authenticatePat(ctx, dbContext, tenant, password)
+		return grant, r.Form.Get("offline_token") == "true", err
+	}
+
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case passwordGrantType:
+		grant, err := authenticatePat(ctx, dbContext, tenant, r.PostForm.Get("password"))
+		return grant, r.PostForm.Get("access_type") == "offline", err
+
+	case refreshTokenGrantType:
+		grant, err := authenticateRefreshToken(ctx, dbContext, tenant, service, signingKey, r.PostForm.Get("refresh_token"))
+		return grant, false, err
+
+	default:
+		return tokenGrant{}, false, ociError.NewOciError(ociError.Unsupported).
+			WithMessage(fmt.Sprintf("grant type '%s' is not supported", grantType))
+	}
+}
+
+func authenticateRefreshToken(
+	ctx context.Context,
+	dbContext database.Context,
+	tenant *repositories.Tenant,
+	service string,
+	signingKey signr.SigningKey,
+	refreshToken string,
+) (tokenGrant, error) {
+	claims := &refreshTokenClaims{}
+	token, err := jwt.ParseWithClaims(
+		refreshToken,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return signingKey.PublicKey()
+		},
+		jwt.WithAudience(service),
+		jwt.WithIssuer(config.C.Server.ExternalDomain),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return tokenGrant{}, ociError.NewOciError(ociError.Unauthorized).
+			WithMessage("invalid refresh token").
+			WithHttpCode(http.StatusUnauthorized)
+	}
+
+	pat, err := getPat(ctx, dbContext, claims.PatId)
+	if err != nil {
+		return tokenGrant{}, err
+	}
+
+	grant, err := getPatGrant(ctx, dbContext, tenant, pat)
+	if err != nil {
+		return tokenGrant{}, err
+	}
+
+	if grant.userId.String() != claims.Subject {
+		return tokenGrant{}, ociError.NewOciError(ociError.Unauthorized).
+			WithMessage("invalid refresh token").
+			WithHttpCode(http.StatusUnauthorized)
+	}
+
+	return grant, nil
+}
+
 func checkAccessForUserAndRepository(
 	ctx context.Context,
 	dbContext database.Context,
@@ -166,6 +318,10 @@ func checkAccessForUserAndRepository(
 		return true, nil
 	}
 
+	if accessType == ociAuthentication.DeleteAccess && repositoryAccess.GetRole().AllowDelete() {
+		return true, nil
+	}
+
 	return false, nil
 }
 
@@ -208,30 +364,52 @@ func restrictScope(ctx context.Context, dbContext database.Context, userId uuid.
 	}, nil
 }
 
-func parseScopeFromRequest(r *http.Request, tenantSlug string) *ociScope {
-	scopeStr := r.Form.Get("scope")
+// requestedScopeStrings returns all requested scopes. Clients either repeat the scope parameter or, following oauth2,
+// separate multiple scopes by spaces.
+func requestedScopeStrings(r *http.Request) []string {
+	var scopes []string
+	for _, scopeParam := range r.Form["scope"] {
+		scopes = append(scopes, strings.Fields(scopeParam)...)
+	}
+
+	return scopes
+}
+
+func parseScopesFromRequest(r *http.Request, tenantSlug string) []*ociScope {
+	var scopes []*ociScope
+	for _, scopeStr := range requestedScopeStrings(r) {
+		scope := parseScope(scopeStr, tenantSlug)
+		if scope != nil {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
 
+func parseScope(scopeStr string, tenantSlug string) *ociScope {
 	// repository:<reponame>:<accesslist>
 	splitN := strings.SplitN(scopeStr, ":", 3)
-	if len(splitN) != 3 || splitN[0] != "repository" {
+	if len(splitN) != 3 || splitN[0] != ociAuthentication.RepositoryResourceType {
 		return nil
 	}
 
 	repository := splitN[1]
 
 	accessStrs := strings.Split(splitN[2], ",")
-	if len(accessStrs) == 0 {
-		return nil
-	}
-
-	accesses := make([]ociAuthentication.Access, len(accessStrs))
+	accesses := make([]ociAuthentication.Access, 0, len(accessStrs))
 
 	for _, accessStr := range accessStrs {
-		if accessStr != string(ociAuthentication.PushAccess) && accessStr != string(ociAuthentication.PullAccess) {
+		access := ociAuthentication.Access(accessStr)
+		if access != ociAuthentication.PushAccess && access != ociAuthentication.PullAccess && access != ociAuthentication.DeleteAccess {
 			continue
 		}
 
-		accesses = append(accesses, ociAuthentication.Access(accessStr))
+		accesses = append(accesses, access)
+	}
+
+	if len(accesses) == 0 {
+		return nil
 	}
 
 	repositoryParts := strings.Split(repository, "/")
@@ -249,27 +427,69 @@ func parseScopeFromRequest(r *http.Request, tenantSlug string) *ociScope {
 	}
 }
 
+const catalogScope = "registry:catalog:*"
+
 type ociScope struct {
 	repository middlewares.OciRepositoryIdentifier
 	access     []ociAuthentication.Access
 }
 
-func getUserId(r *http.Request, tenant *repositories.Tenant) (uuid.UUID, error) {
-	_, password, ok := r.BasicAuth()
-	if !ok {
-		return uuid.Nil, nil
+func (s *ociScope) toAccessClaim() ociAuthentication.AccessClaim {
+	actions := make([]string, len(s.access))
+	for i, access := range s.access {
+		actions[i] = string(access)
+	}
+
+	return ociAuthentication.AccessClaim{
+		Type:    ociAuthentication.RepositoryResourceType,
+		Name:    fmt.Sprintf("%s/%s", s.repository.ProjectSlug, s.repository.RepositorySlug),
+		Actions: actions,
 	}
+}
+
+// addAccessClaim adds the claim, merging its actions into an existing claim for the same resource.
+func addAccessClaim(accessClaims []ociAuthentication.AccessClaim, accessClaim ociAuthentication.AccessClaim) []ociAuthentication.AccessClaim {
+	for i := range accessClaims {
+		if accessClaims[i].Type != accessClaim.Type || accessClaims[i].Name != accessClaim.Name {
+			continue
+		}
+
+		for _, action := range accessClaim.Actions {
+			if !slices.Contains(accessClaims[i].Actions, action) {
+				accessClaims[i].Actions = append(accessClaims[i].Actions, action)
+			}
+		}
 
+		return accessClaims
+	}
+
+	return append(accessClaims, accessClaim)
+}
+
+// formatAccessClaims formats the granted access as space separated scopes for the oauth2 scope response field.
+func formatAccessClaims(accessClaims []ociAuthentication.AccessClaim) string {
+	scopes := make([]string, len(accessClaims))
+	for i, accessClaim := range accessClaims {
+		scopes[i] = fmt.Sprintf("%s:%s:%s", accessClaim.Type, accessClaim.Name, strings.Join(accessClaim.Actions, ","))
+	}
+
+	return strings.Join(scopes, " ")
+}
+
+func authenticatePat(ctx context.Context, dbContext database.Context, tenant *repositories.Tenant, password string) (tokenGrant, error) {
 	if !strings.HasPrefix(password, "pat_") {
 		err := ociError.NewOciError(ociError.Unauthorized).
 			WithMessage("invalid token, must start with 'pat_'").
 			WithHttpCode(http.StatusUnauthorized)
-		return uuid.Nil, err
+		return tokenGrant{}, err
 	}
 
 	patBytes, err := base64.RawURLEncoding.DecodeString(password[4:])
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("decoding pat: %w", err)
+	if err != nil || len(patBytes) < 16 {
+		err := ociError.NewOciError(ociError.Unauthorized).
+			WithMessage("invalid token").
+			WithHttpCode(http.StatusUnauthorized)
+		return tokenGrant{}, err
 	}
 
 	uuidBytes := patBytes[:16]
@@ -277,56 +497,64 @@ func getUserId(r *http.Request, tenant *repositories.Tenant) (uuid.UUID, error)
 
 	patId, err := uuid.FromBytes(uuidBytes)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("parsing pat id: %w", err)
+		return tokenGrant{}, fmt.Errorf("parsing pat id: %w", err)
 	}
 
-	ctx := r.Context()
-	scope := middlewares.GetScope(ctx)
-
-	dbFactory := ioc.GetDependency[database.Factory](scope)
-	tx, err := dbFactory.NewDbContext(ctx)
+	pat, err := getPat(ctx, dbContext, patId)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("getting transaction: %w", err)
+		return tokenGrant{}, err
 	}
 
-	pat, err := tx.Pats().First(ctx, repositories.NewPatFilter().ById(patId))
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("getting pat: %w", err)
-	}
-	if pat == nil {
+	hashedSecret := sha256.New().Sum(secretBytes)
+	if !slices.Equal(hashedSecret, pat.GetHashedSecret()) {
 		err := ociError.NewOciError(ociError.Unauthorized).
 			WithMessage("invalid token").
 			WithHttpCode(http.StatusUnauthorized)
-		return uuid.Nil, err
+		return tokenGrant{}, err
 	}
 
-	hashedSecret := sha256.New().Sum(secretBytes)
-	if !slices.Equal(hashedSecret, pat.GetHashedSecret()) {
+	return getPatGrant(ctx, dbContext, tenant, pat)
+}
+
+func getPat(ctx context.Context, dbContext database.Context, patId uuid.UUID) (*repositories.Pat, error) {
+	pat, err := dbContext.Pats().First(ctx, repositories.NewPatFilter().ById(patId))
+	if err != nil {
+		return nil, fmt.Errorf("getting pat: %w", err)
+	}
+	if pat == nil {
 		err := ociError.NewOciError(ociError.Unauthorized).
 			WithMessage("invalid token").
 			WithHttpCode(http.StatusUnauthorized)
-		return uuid.Nil, err
+		return nil, err
 	}
 
-	user, err := tx.Users().First(ctx, repositories.NewUserFilter().ById(pat.GetUserId()))
+	return pat, nil
+}
+
+// getPatGrant resolves the user of the pat and makes sure it still exists and belongs to the tenant.
+func getPatGrant(ctx context.Context, dbContext database.Context, tenant *repositories.Tenant, pat *repositories.Pat) (tokenGrant, error) {
+	user, err := dbContext.Users().First(ctx, repositories.NewUserFilter().ById(pat.GetUserId()))
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("getting user: %w", err)
+		return tokenGrant{}, fmt.Errorf("getting user: %w", err)
 	}
 	if user == nil {
 		err := ociError.NewOciError(ociError.Unauthorized).
 			WithMessage("invalid token").
 			WithHttpCode(http.StatusUnauthorized)
-		return uuid.Nil, err
+		return tokenGrant{}, err
 	}
 
 	if tenant.GetId() != user.GetTenantId() {
 		err := ociError.NewOciError(ociError.Unauthorized).
 			WithMessage("invalid token").
 			WithHttpCode(http.StatusUnauthorized)
-		return uuid.Nil, err
+		return tokenGrant{}, err
 	}
 
-	return user.GetId(), nil
+	return tokenGrant{
+		userId: user.GetId(),
+		patId:  pat.GetId(),
+	}, nil
 }
 
 type jwtSigningMethod struct {
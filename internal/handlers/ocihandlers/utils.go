@@ -2,15 +2,16 @@ package ocihandlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"slices"
 
-	"github.com/the127/dockyard/internal/config"
+	"github.com/The127/ioc"
 	"github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/middlewares/ociAuthentication"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
@@ -20,24 +21,35 @@ func checkAccess(
 	accessType ociAuthentication.Access,
 ) error {
 	currentUser := ociAuthentication.GetCurrentUser(ctx)
-	if currentUser.Repository != nil {
-		if currentUser.Repository.Equals(repoIdentifier) {
-			if slices.Contains(currentUser.Access, accessType) {
-				return nil
-			}
-		}
+	if currentUser.HasAccess(repoIdentifier, accessType) {
+		return nil
 	}
 
-	realm := fmt.Sprintf("%s/v2/token", config.C.Server.ExternalUrl)
-	service := fmt.Sprintf("%s:%s", config.C.Server.ExternalDomain, repoIdentifier.TenantSlug)
-	scope := fmt.Sprintf("repository:%s/%s/%s:%s", repoIdentifier.TenantSlug, repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, accessType)
+	scope := ociAuthentication.RepositoryScope(repoIdentifier, accessType)
+	if currentUser.IsAuthenticated {
+		return ociAuthentication.NewInsufficientScopeError(repoIdentifier.TenantSlug, scope)
+	}
+
+	return ociAuthentication.NewUnauthorizedError(repoIdentifier.TenantSlug, scope, "user is not authenticated")
+}
 
-	wwwAuthenticateHeaderValue := fmt.Sprintf("Bearer realm=\"%s\",service=\"%s\",scope=\"%s\"", realm, service, scope)
+// getRepository resolves the repository addressed by the request. It opens a db context of its own, so that handlers
+// that only need the repository do not have to create one.
+func getRepository(ctx context.Context, repoIdentifier middlewares.OciRepositoryIdentifier) (*repositories.Repository, error) {
+	scope := middlewares.GetScope(ctx)
 
-	return ociError.NewOciError(ociError.Unauthorized).
-		WithMessage("user is not authenticated").
-		WithHttpCode(401).
-		WithHeader("WWW-Authenticate", wwwAuthenticateHeaderValue)
+	dbFactory := ioc.GetDependency[database.Factory](scope)
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, repository, err := getRepositoryByIdentifier(ctx, dbContext, repoIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository, nil
 }
 
 func getRepositoryByIdentifier(ctx context.Context, dbContext database.Context, repoIdentifier middlewares.OciRepositoryIdentifier) (*repositories.Tenant, *repositories.Project, *repositories.Repository, error) {
@@ -73,3 +85,15 @@ func getRepositoryByIdentifier(ctx context.Context, dbContext database.Context,
 
 	return tenant, project, repository, nil
 }
+
+// mapNotFoundToNameUnknown converts the not found errors of queries that resolve a repository by its slugs into the
+// NAME_UNKNOWN error oci clients expect.
+func mapNotFoundToNameUnknown(err error, repoIdentifier middlewares.OciRepositoryIdentifier) error {
+	if !errors.Is(err, apiError.ErrApiNotFound) {
+		return err
+	}
+
+	return ociError.NewOciError(ociError.NameUnknown).
+		WithMessage(fmt.Sprintf("repository '%s/%s' does not exist", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug)).
+		WithHttpCode(http.StatusNotFound)
+}
@@ -0,0 +1,366 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// Comparison describes how the target image differs from the base image.
+type Comparison struct {
+	Base   *Image
+	Target *Image
+
+	// SizeDelta is the size of the target minus the size of the base, both counting the config and all layers.
+	SizeDelta int64
+
+	AddedLayers   []Layer
+	RemovedLayers []Layer
+	SharedLayers  []Layer
+
+	Config ConfigComparison
+
+	// Files is only set if the comparison was asked to compare the files.
+	Files []FileChange
+}
+
+// Change holds a value of the base and of the target image.
+type Change[T any] struct {
+	Base   T
+	Target T
+}
+
+// ConfigComparison lists the differences between two image configs, the pointers are nil if the value did not change.
+type ConfigComparison struct {
+	Env          MapComparison
+	Labels       MapComparison
+	ExposedPorts SetComparison
+
+	Entrypoint *Change[[]string]
+	Cmd        *Change[[]string]
+	User       *Change[string]
+	WorkingDir *Change[string]
+}
+
+type MapComparison struct {
+	Added   map[string]string
+	Removed map[string]string
+	Changed map[string]Change[string]
+}
+
+type SetComparison struct {
+	Added   []string
+	Removed []string
+}
+
+type FileChangeKind string
+
+const (
+	FileChangeAdded    FileChangeKind = "added"
+	FileChangeRemoved  FileChangeKind = "removed"
+	FileChangeModified FileChangeKind = "modified"
+)
+
+// FileChange is a file that differs between the filesystems of two images. Base is nil for added files, Target for
+// removed ones.
+type FileChange struct {
+	Path   string
+	Kind   FileChangeKind
+	Base   *MergedFile
+	Target *MergedFile
+}
+
+// Compare compares two image manifests. Files are only compared if withFiles is set, as it requires listing every
+// layer of both images that is not cached yet. Only the files the added and removed layers write or delete are
+// compared, the others are the same in both images.
+func Compare(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, base *Manifest, target *Manifest, withFiles bool) (*Comparison, error) {
+	if base.Config == nil || target.Config == nil || len(base.Manifests) > 0 || len(target.Manifests) > 0 {
+		return nil, fmt.Errorf("only image manifests can be compared, pick a platform of an index by its digest: %w", apiError.ErrApiBadRequest)
+	}
+
+	baseImage, err := InspectImage(ctx, blobService, base)
+	if err != nil {
+		return nil, err
+	}
+
+	targetImage, err := InspectImage(ctx, blobService, target)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &Comparison{
+		Base:      baseImage,
+		Target:    targetImage,
+		SizeDelta: targetImage.Size - baseImage.Size,
+		Config:    compareConfigs(baseImage, targetImage),
+	}
+
+	comparison.AddedLayers, comparison.SharedLayers = partitionLayers(targetImage.Layers, baseImage.Layers)
+	comparison.RemovedLayers, _ = partitionLayers(baseImage.Layers, targetImage.Layers)
+
+	if !withFiles {
+		return comparison, nil
+	}
+
+	comparison.Files, err = compareImageFiles(ctx, blobService, kvStore, comparison)
+	if err != nil {
+		return nil, err
+	}
+
+	return comparison, nil
+}
+
+func compareImageFiles(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, comparison *Comparison) ([]FileChange, error) {
+	if len(comparison.AddedLayers) == 0 && len(comparison.RemovedLayers) == 0 && sameLayerOrder(comparison.Base.Layers, comparison.Target.Layers) {
+		return nil, nil
+	}
+
+	// shared layers are listed once for both images
+	listings := make(map[string][]LayerFile)
+	for _, layer := range slices.Concat(comparison.Base.Layers, comparison.Target.Layers) {
+		if _, ok := listings[layer.Digest]; ok {
+			continue
+		}
+
+		files, err := ListLayerFiles(ctx, blobService, kvStore, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		listings[layer.Digest] = files
+	}
+
+	baseFiles := mergeListings(comparison.Base.Layers, listings)
+	targetFiles := mergeListings(comparison.Target.Layers, listings)
+
+	// a path no added or removed layer touches can only differ if the shared layers are stacked in another order
+	_, sharedBase := partitionLayers(comparison.Base.Layers, comparison.Target.Layers)
+	if sameLayerOrder(comparison.SharedLayers, sharedBase) {
+		changed := newChangedPaths(slices.Concat(comparison.AddedLayers, comparison.RemovedLayers), listings)
+		baseFiles = slices.DeleteFunc(baseFiles, func(file MergedFile) bool {
+			return !changed.contains(file.Path)
+		})
+		targetFiles = slices.DeleteFunc(targetFiles, func(file MergedFile) bool {
+			return !changed.contains(file.Path)
+		})
+	}
+
+	return compareFiles(baseFiles, targetFiles), nil
+}
+
+func mergeListings(layers []Layer, listings map[string][]LayerFile) []MergedFile {
+	layerDigests := make([]string, len(layers))
+	layerListings := make([][]LayerFile, len(layers))
+	for i, layer := range layers {
+		layerDigests[i] = layer.Digest
+		layerListings[i] = listings[layer.Digest]
+	}
+
+	return MergeLayers(layerDigests, layerListings)
+}
+
+func sameLayerOrder(layers []Layer, other []Layer) bool {
+	return slices.EqualFunc(layers, other, func(a, b Layer) bool {
+		return a.Digest == b.Digest
+	})
+}
+
+// changedPaths are the paths some layers write or delete. Deleted paths and opaque directories are trees, as
+// everything below them may have been removed.
+type changedPaths struct {
+	paths map[string]bool
+	trees map[string]bool
+}
+
+func newChangedPaths(layers []Layer, listings map[string][]LayerFile) changedPaths {
+	changed := changedPaths{
+		paths: make(map[string]bool),
+		trees: make(map[string]bool),
+	}
+
+	for _, layer := range layers {
+		for _, file := range listings[layer.Digest] {
+			changed.paths[file.Path] = true
+			if file.Whiteout || file.Opaque {
+				changed.trees[file.Path] = true
+			}
+		}
+	}
+
+	return changed
+}
+
+func (c changedPaths) contains(filePath string) bool {
+	if c.paths[filePath] || c.trees[filePath] {
+		return true
+	}
+
+	for dir := filePath; dir != "/"; {
+		dir = path.Dir(dir)
+		if c.trees[dir] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// partitionLayers splits the layers into the ones that are missing from the other image and the ones that are not.
+func partitionLayers(layers []Layer, other []Layer) ([]Layer, []Layer) {
+	otherDigests := make(map[string]bool, len(other))
+	for _, layer := range other {
+		otherDigests[layer.Digest] = true
+	}
+
+	var missing []Layer
+	var present []Layer
+	for _, layer := range layers {
+		if otherDigests[layer.Digest] {
+			present = append(present, layer)
+		} else {
+			missing = append(missing, layer)
+		}
+	}
+
+	return missing, present
+}
+
+func compareConfigs(base *Image, target *Image) ConfigComparison {
+	comparison := ConfigComparison{
+		Env:          compareMaps(parseEnv(base.Env), parseEnv(target.Env)),
+		Labels:       compareMaps(base.Labels, target.Labels),
+		ExposedPorts: compareSets(base.ExposedPorts, target.ExposedPorts),
+	}
+
+	if !slices.Equal(base.Entrypoint, target.Entrypoint) {
+		comparison.Entrypoint = &Change[[]string]{Base: base.Entrypoint, Target: target.Entrypoint}
+	}
+
+	if !slices.Equal(base.Cmd, target.Cmd) {
+		comparison.Cmd = &Change[[]string]{Base: base.Cmd, Target: target.Cmd}
+	}
+
+	if base.User != target.User {
+		comparison.User = &Change[string]{Base: base.User, Target: target.User}
+	}
+
+	if base.WorkingDir != target.WorkingDir {
+		comparison.WorkingDir = &Change[string]{Base: base.WorkingDir, Target: target.WorkingDir}
+	}
+
+	return comparison
+}
+
+// parseEnv turns KEY=value entries into a map, later entries win like they do when a container is started.
+func parseEnv(env []string) map[string]string {
+	result := make(map[string]string, len(env))
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		result[key] = value
+	}
+
+	return result
+}
+
+func compareMaps(base map[string]string, target map[string]string) MapComparison {
+	comparison := MapComparison{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]Change[string]{},
+	}
+
+	for key, targetValue := range target {
+		baseValue, ok := base[key]
+		switch {
+		case !ok:
+			comparison.Added[key] = targetValue
+		case baseValue != targetValue:
+			comparison.Changed[key] = Change[string]{Base: baseValue, Target: targetValue}
+		}
+	}
+
+	for key, baseValue := range base {
+		if _, ok := target[key]; !ok {
+			comparison.Removed[key] = baseValue
+		}
+	}
+
+	return comparison
+}
+
+func compareSets(base []string, target []string) SetComparison {
+	comparison := SetComparison{}
+
+	for _, value := range target {
+		if !slices.Contains(base, value) {
+			comparison.Added = append(comparison.Added, value)
+		}
+	}
+
+	for _, value := range base {
+		if !slices.Contains(target, value) {
+			comparison.Removed = append(comparison.Removed, value)
+		}
+	}
+
+	return comparison
+}
+
+// compareFiles compares two merged filesystems. A file that the same layer wrote in both images is unchanged, a file
+// written by different layers counts as modified if its metadata differs, the contents are not compared. Directories
+// are only reported if they were added or removed, as their metadata changes with every file below them.
+func compareFiles(base []MergedFile, target []MergedFile) []FileChange {
+	baseByPath := make(map[string]MergedFile, len(base))
+	for _, file := range base {
+		baseByPath[file.Path] = file
+	}
+
+	var changes []FileChange
+	for _, targetFile := range target {
+		baseFile, ok := baseByPath[targetFile.Path]
+		delete(baseByPath, targetFile.Path)
+
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: targetFile.Path, Kind: FileChangeAdded, Target: &targetFile})
+		case isModified(baseFile, targetFile):
+			changes = append(changes, FileChange{Path: targetFile.Path, Kind: FileChangeModified, Base: &baseFile, Target: &targetFile})
+		}
+	}
+
+	for _, filePath := range slices.Sorted(maps.Keys(baseByPath)) {
+		baseFile := baseByPath[filePath]
+		changes = append(changes, FileChange{Path: filePath, Kind: FileChangeRemoved, Base: &baseFile})
+	}
+
+	slices.SortStableFunc(changes, func(a, b FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return changes
+}
+
+func isModified(base MergedFile, target MergedFile) bool {
+	if base.LayerDigest == target.LayerDigest {
+		return false
+	}
+
+	if base.Type == FileTypeDir && target.Type == FileTypeDir {
+		return false
+	}
+
+	return base.Type != target.Type ||
+		base.Size != target.Size ||
+		base.Mode != target.Mode ||
+		base.Uid != target.Uid ||
+		base.Gid != target.Gid ||
+		base.LinkTarget != target.LinkTarget ||
+		!base.ModTime.Equal(target.ModTime)
+}
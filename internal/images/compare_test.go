@@ -0,0 +1,52 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CompareTestSuite struct {
+	suite.Suite
+}
+
+func TestCompareTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(CompareTestSuite))
+}
+
+func (s *CompareTestSuite) TestChangedPaths() {
+	listings := map[string][]LayerFile{
+		"added":   {directory("/app"), regularFile("/app/main"), whiteout("/tmp")},
+		"removed": {opaque("/var/cache"), regularFile("/var/cache/index")},
+		"shared":  {regularFile("/etc/hosts")},
+	}
+	changed := newChangedPaths([]Layer{{Digest: "added"}, {Digest: "removed"}}, listings)
+
+	testCases := []struct {
+		filePath string
+		contains bool
+	}{
+		{filePath: "/app", contains: true},
+		{filePath: "/app/main", contains: true},
+		{filePath: "/app/other"},
+		{filePath: "/tmp", contains: true},
+		{filePath: "/tmp/file", contains: true},
+		{filePath: "/tmp2"},
+		{filePath: "/var"},
+		{filePath: "/var/cache", contains: true},
+		{filePath: "/var/cache/deep/file", contains: true},
+		{filePath: "/etc/hosts"},
+		{filePath: "/"},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.filePath, func() {
+			// act
+			contains := changed.contains(testCase.filePath)
+
+			// assert
+			s.Equal(testCase.contains, contains)
+		})
+	}
+}
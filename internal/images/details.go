@@ -0,0 +1,282 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+)
+
+// maxJsonBlobSize limits how much of a manifest or config is read into memory. Manifests are limited to 4 MB when
+// pushed, configs are not checked and may grow large with a long build history.
+const maxJsonBlobSize = 16 * 1024 * 1024
+
+// Details describes a stored manifest. Image is set for image manifests, Platforms for image indexes.
+type Details struct {
+	Digest       string
+	MediaType    string
+	Size         int64
+	ArtifactType string
+	Annotations  map[string]string
+
+	Image     *Image
+	Platforms []PlatformImage
+}
+
+// Image describes an image manifest together with its config. The config fields are empty for artifacts, whose config
+// is not an image config.
+type Image struct {
+	ConfigDigest    string
+	ConfigMediaType string
+
+	Platform Platform
+	Created  *time.Time
+	Author   string
+
+	User         string
+	Entrypoint   []string
+	Cmd          []string
+	Env          []string
+	WorkingDir   string
+	ExposedPorts []string
+	Volumes      []string
+	StopSignal   string
+	Labels       map[string]string
+
+	Layers  []Layer
+	History []HistoryEntry
+
+	// Size is the size of the config and all layers, without the manifest itself.
+	Size int64
+}
+
+type Layer struct {
+	Digest    string
+	MediaType string
+	Size      int64
+	// DiffId is the digest of the uncompressed layer, it is empty if the config does not list it.
+	DiffId      string
+	Annotations map[string]string
+}
+
+type HistoryEntry struct {
+	Created    *time.Time
+	CreatedBy  string
+	Author     string
+	Comment    string
+	EmptyLayer bool
+	// LayerDigest is the layer the build step created, it is empty for steps that did not change the filesystem.
+	LayerDigest string
+}
+
+// PlatformImage is an entry of an image index.
+type PlatformImage struct {
+	Digest       string
+	MediaType    string
+	Size         int64
+	ArtifactType string
+	// Platform is nil for entries that are not bound to a platform, e.g. attestations.
+	Platform    *Platform
+	Annotations map[string]string
+
+	// Image is nil if the entry is not an image manifest or is not stored in the repository, which happens for
+	// partially proxied indexes.
+	Image *Image
+}
+
+// GetDetails parses the manifest and, for images, their config. The entries of an index are inspected if they are
+// stored in the same repository.
+func GetDetails(ctx context.Context, dbContext database.Context, blobService blobStorage.Service, manifest *repositories.Manifest) (*Details, error) {
+	parsed, size, err := ReadManifest(ctx, blobService, manifest.GetDigest())
+	if err != nil {
+		return nil, err
+	}
+
+	details := &Details{
+		Digest:       manifest.GetDigest(),
+		MediaType:    manifest.GetMediaType(),
+		Size:         size,
+		ArtifactType: parsed.ArtifactType,
+		Annotations:  parsed.Annotations,
+	}
+
+	if !IsIndex(manifest.GetMediaType()) {
+		details.Image, err = InspectImage(ctx, blobService, parsed)
+		if err != nil {
+			return nil, err
+		}
+
+		if details.ArtifactType == "" {
+			details.ArtifactType = details.Image.ConfigMediaType
+		}
+
+		return details, nil
+	}
+
+	details.Platforms = make([]PlatformImage, len(parsed.Manifests))
+	for i, descriptor := range parsed.Manifests {
+		platformImage := PlatformImage{
+			Digest:       descriptor.Digest,
+			MediaType:    descriptor.MediaType,
+			Size:         descriptor.Size,
+			ArtifactType: descriptor.ArtifactType,
+			Platform:     descriptor.Platform,
+			Annotations:  descriptor.Annotations,
+		}
+
+		child, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(manifest.GetRepositoryId()).ByDigest(descriptor.Digest))
+		if err != nil {
+			return nil, fmt.Errorf("getting manifest: %w", err)
+		}
+
+		if child != nil && !IsIndex(child.GetMediaType()) {
+			parsedChild, _, err := ReadManifest(ctx, blobService, child.GetDigest())
+			if err != nil {
+				return nil, err
+			}
+
+			platformImage.Image, err = InspectImage(ctx, blobService, parsedChild)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		details.Platforms[i] = platformImage
+	}
+
+	return details, nil
+}
+
+// InspectImage reads the config of an image manifest. Build steps are matched to the layers they created in order, as
+// the config lists one history entry per layer plus the empty ones.
+func InspectImage(ctx context.Context, blobService blobStorage.Service, manifest *Manifest) (*Image, error) {
+	image := &Image{
+		Layers: make([]Layer, len(manifest.Layers)),
+	}
+
+	var config *Config
+	if manifest.Config != nil {
+		image.ConfigDigest = manifest.Config.Digest
+		image.ConfigMediaType = manifest.Config.MediaType
+		image.Size += manifest.Config.Size
+
+		if ConfigMediaTypes[manifest.Config.MediaType] {
+			body, err := readBlob(ctx, blobService, manifest.Config.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("reading image config: %w", err)
+			}
+
+			config, err = ParseConfig(body)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, layer := range manifest.Layers {
+		image.Layers[i] = Layer{
+			Digest:      layer.Digest,
+			MediaType:   layer.MediaType,
+			Size:        layer.Size,
+			Annotations: layer.Annotations,
+		}
+		image.Size += layer.Size
+	}
+
+	if config == nil {
+		return image, nil
+	}
+
+	image.Platform = Platform{
+		Architecture: config.Architecture,
+		OS:           config.OS,
+		OSVersion:    config.OSVersion,
+		Variant:      config.Variant,
+	}
+	image.Created = config.Created
+	image.Author = config.Author
+	image.User = config.Config.User
+	image.Entrypoint = config.Config.Entrypoint
+	image.Cmd = config.Config.Cmd
+	image.Env = config.Config.Env
+	image.WorkingDir = config.Config.WorkingDir
+	image.ExposedPorts = sortedKeys(config.Config.ExposedPorts)
+	image.Volumes = sortedKeys(config.Config.Volumes)
+	image.StopSignal = config.Config.StopSignal
+	image.Labels = config.Config.Labels
+
+	for i, diffId := range config.RootFS.DiffIds {
+		if i < len(image.Layers) {
+			image.Layers[i].DiffId = diffId
+		}
+	}
+
+	layerIndex := 0
+	image.History = make([]HistoryEntry, len(config.History))
+	for i, history := range config.History {
+		entry := HistoryEntry{
+			Created:    history.Created,
+			CreatedBy:  history.CreatedBy,
+			Author:     history.Author,
+			Comment:    history.Comment,
+			EmptyLayer: history.EmptyLayer,
+		}
+
+		if !history.EmptyLayer && layerIndex < len(image.Layers) {
+			entry.LayerDigest = image.Layers[layerIndex].Digest
+			layerIndex++
+		}
+
+		image.History[i] = entry
+	}
+
+	return image, nil
+}
+
+// ReadManifest reads and parses a stored manifest, it also returns its size in bytes.
+func ReadManifest(ctx context.Context, blobService blobStorage.Service, manifestDigest string) (*Manifest, int64, error) {
+	body, err := readBlob(ctx, blobService, manifestDigest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	manifest, err := ParseManifest(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return manifest, int64(len(body)), nil
+}
+
+func readBlob(ctx context.Context, blobService blobStorage.Service, blobDigest string) ([]byte, error) {
+	reader, err := blobService.OpenBlob(ctx, blobDigest)
+	if err != nil {
+		return nil, fmt.Errorf("opening blob %s: %w", blobDigest, err)
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxJsonBlobSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", blobDigest, err)
+	}
+	if len(body) > maxJsonBlobSize {
+		return nil, fmt.Errorf("blob %s is larger than %d bytes", blobDigest, maxJsonBlobSize)
+	}
+
+	return body, nil
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,471 @@
+package images
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// layerFilesCacheExpiration bounds how long a layer listing stays in the kv store. Layers never change, the
+// expiration only keeps listings of layers nobody browses anymore from piling up.
+const layerFilesCacheExpiration = 24 * time.Hour
+
+const (
+	// maxLayerEntries bounds the size of a layer listing, which is kept in memory and in the kv store as a whole.
+	maxLayerEntries = 100_000
+	// maxLayerSize bounds the decompressed size of a layer, so a small compressed blob cannot expand without limit.
+	maxLayerSize = 16 << 30
+	// maxHardlinkDepth bounds the hard links followed to reach a regular file, links may point to other links.
+	maxHardlinkDepth = 16
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+type FileType string
+
+const (
+	FileTypeRegular  FileType = "file"
+	FileTypeDir      FileType = "dir"
+	FileTypeSymlink  FileType = "symlink"
+	FileTypeHardlink FileType = "hardlink"
+	FileTypeOther    FileType = "other"
+)
+
+// LayerFile is an entry of a layer tar archive. Paths are absolute and cleaned, e.g. /etc/os-release.
+type LayerFile struct {
+	Path       string    `json:"path"`
+	Type       FileType  `json:"type"`
+	Size       int64     `json:"size"`
+	Mode       int64     `json:"mode"`
+	Uid        int       `json:"uid"`
+	Gid        int       `json:"gid"`
+	ModTime    time.Time `json:"modTime"`
+	LinkTarget string    `json:"linkTarget,omitempty"`
+
+	// Whiteout marks a path deleted by the layer, Path is the deleted path and not the name of the marker file.
+	Whiteout bool `json:"whiteout,omitempty"`
+	// Opaque marks a directory whose content in lower layers is hidden, Path is the directory.
+	Opaque bool `json:"opaque,omitempty"`
+}
+
+// MergedFile is a file of the filesystem an image's layers add up to.
+type MergedFile struct {
+	LayerFile
+	// LayerDigest is the layer the file was last written by.
+	LayerDigest string
+}
+
+// ListLayerFiles lists the entries of a layer. Listings are cached in the kv store by the digest of the layer, so a
+// layer is only read and decompressed once. Layers with more than maxLayerEntries entries or more than maxLayerSize
+// decompressed bytes are rejected.
+func ListLayerFiles(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, layerDigest string) ([]LayerFile, error) {
+	cacheKey := buildLayerFilesCacheKey(layerDigest)
+
+	cached, ok, err := kvStore.Get(ctx, cacheKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting cached layer files: %w", err)
+	}
+	if ok {
+		var files []LayerFile
+		err = json.Unmarshal([]byte(cached), &files)
+		if err == nil {
+			return files, nil
+		}
+
+		logging.Logger.Warnf("ignoring invalid cached files of layer %s: %v", layerDigest, err)
+	}
+
+	layer, err := openLayer(ctx, blobService, layerDigest)
+	if err != nil {
+		return nil, err
+	}
+	defer layer.Close()
+
+	var files []LayerFile
+	for {
+		file, err := layer.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(files) >= maxLayerEntries {
+			return nil, fmt.Errorf("layer %s has more than %d entries: %w", layerDigest, maxLayerEntries, apiError.ErrApiBadRequest)
+		}
+
+		files = append(files, *file)
+	}
+
+	encoded, err := json.Marshal(files)
+	if err != nil {
+		return nil, fmt.Errorf("encoding layer files: %w", err)
+	}
+
+	err = kvStore.Set(ctx, cacheKey, string(encoded), kv.WithExpiration(layerFilesCacheExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("caching layer files: %w", err)
+	}
+
+	return files, nil
+}
+
+// MergeLayers stacks the listings of the layers of an image, from the lowest to the topmost layer, and applies their
+// whiteouts. The result is sorted by path.
+func MergeLayers(layerDigests []string, listings [][]LayerFile) []MergedFile {
+	merged := make(map[string]MergedFile)
+
+	for i, files := range listings {
+		// whiteouts only hide the content of lower layers, so they are applied before the layer's own files are added
+		for _, file := range files {
+			switch {
+			case file.Opaque:
+				removeTree(merged, file.Path, false)
+			case file.Whiteout:
+				removeTree(merged, file.Path, true)
+			}
+		}
+
+		for _, file := range files {
+			if file.Whiteout || file.Opaque {
+				continue
+			}
+
+			merged[file.Path] = MergedFile{
+				LayerFile:   file,
+				LayerDigest: layerDigests[i],
+			}
+		}
+	}
+
+	result := make([]MergedFile, 0, len(merged))
+	for _, file := range merged {
+		result = append(result, file)
+	}
+
+	slices.SortFunc(result, func(a, b MergedFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return result
+}
+
+func removeTree(files map[string]MergedFile, root string, includeRoot bool) {
+	if includeRoot {
+		delete(files, root)
+	}
+
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	for filePath := range files {
+		if strings.HasPrefix(filePath, prefix) {
+			delete(files, filePath)
+		}
+	}
+}
+
+// FilterFiles returns the files at or below the directory, all files if it is empty or /.
+func FilterFiles[T interface{ GetPath() string }](files []T, dir string) []T {
+	dir = CleanPath(dir)
+	if dir == "/" {
+		return files
+	}
+
+	var result []T
+	for _, file := range files {
+		filePath := file.GetPath()
+		if filePath == dir || strings.HasPrefix(filePath, dir+"/") {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+func (f LayerFile) GetPath() string {
+	return f.Path
+}
+
+// OpenLayerFile opens a regular file of a layer for reading, hard links are followed within the layer up to
+// maxHardlinkDepth links. The caller has to close the returned reader.
+func OpenLayerFile(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, layerDigest string, filePath string) (*LayerFile, io.ReadCloser, error) {
+	filePath = CleanPath(filePath)
+
+	// the cached listing answers for files that do not exist without reading the layer
+	files, err := ListLayerFiles(ctx, blobService, kvStore, layerDigest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := resolveHardlinks(files, filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' in layer %s: %w", filePath, layerDigest, err)
+	}
+
+	layer, err := openLayer(ctx, blobService, layerDigest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		entry, err := layer.Next()
+		if errors.Is(err, io.EOF) {
+			_ = layer.Close()
+			return nil, nil, fmt.Errorf("'%s' in layer %s: %w", filePath, layerDigest, apiError.ErrApiFileNotFound)
+		}
+		if err != nil {
+			_ = layer.Close()
+			return nil, nil, err
+		}
+
+		if entry.Path == file.Path && entry.Type == FileTypeRegular {
+			entry.Path = filePath
+			return entry, layer, nil
+		}
+	}
+}
+
+// resolveHardlinks returns the regular file at the path of a listing, following hard links to the file they point to.
+func resolveHardlinks(files []LayerFile, filePath string) (LayerFile, error) {
+	for depth := 0; ; depth++ {
+		index := slices.IndexFunc(files, func(file LayerFile) bool {
+			return file.Path == filePath && !file.Whiteout && !file.Opaque
+		})
+		if index < 0 {
+			return LayerFile{}, apiError.ErrApiFileNotFound
+		}
+
+		file := files[index]
+		switch {
+		case file.Type == FileTypeRegular:
+			return file, nil
+
+		case file.Type != FileTypeHardlink:
+			return LayerFile{}, fmt.Errorf("'%s' is a %s and not a regular file: %w", filePath, file.Type, apiError.ErrApiBadRequest)
+
+		case depth >= maxHardlinkDepth:
+			return LayerFile{}, fmt.Errorf("more than %d hard links: %w", maxHardlinkDepth, apiError.ErrApiBadRequest)
+		}
+
+		filePath = CleanPath(file.LinkTarget)
+	}
+}
+
+// layerReader reads the entries of a layer one by one, reading from it returns the content of the current entry.
+type layerReader struct {
+	blob         io.ReadCloser
+	decompressed io.ReadCloser
+	tarReader    *tar.Reader
+	digest       string
+}
+
+// openLayer opens a layer for reading. Gzip and zstd compressed layers are detected by their content, so the media
+// type of the layer does not matter.
+func openLayer(ctx context.Context, blobService blobStorage.Service, layerDigest string) (*layerReader, error) {
+	blob, err := blobService.OpenBlob(ctx, layerDigest)
+	if err != nil {
+		return nil, fmt.Errorf("opening layer %s: %w", layerDigest, err)
+	}
+
+	decompressed, err := decompress(bufio.NewReader(blob))
+	if err != nil {
+		_ = blob.Close()
+		return nil, fmt.Errorf("decompressing layer %s: %w: %w", layerDigest, apiError.ErrApiBadRequest, err)
+	}
+
+	return &layerReader{
+		blob:         blob,
+		decompressed: decompressed,
+		tarReader:    tar.NewReader(&sizeLimitedReader{reader: decompressed, remaining: maxLayerSize}),
+		digest:       layerDigest,
+	}, nil
+}
+
+// Next advances to the next entry of the layer, it returns io.EOF after the last one.
+func (l *layerReader) Next() (*LayerFile, error) {
+	header, err := l.tarReader.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if errors.Is(err, errLayerTooLarge) {
+		return nil, fmt.Errorf("layer %s is larger than %d bytes decompressed: %w", l.digest, maxLayerSize, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("layer %s is not a valid tar archive: %w: %w", l.digest, apiError.ErrApiBadRequest, err)
+	}
+
+	file := mapTarHeader(header)
+	return &file, nil
+}
+
+func (l *layerReader) Read(p []byte) (int, error) {
+	return l.tarReader.Read(p)
+}
+
+func (l *layerReader) Close() error {
+	return errors.Join(l.decompressed.Close(), l.blob.Close())
+}
+
+var errLayerTooLarge = fmt.Errorf("layer too large: %w", apiError.ErrApiBadRequest)
+
+// sizeLimitedReader fails with errLayerTooLarge once more than remaining bytes are read. Unlike io.LimitReader it does
+// not end with io.EOF, which would make a truncated layer look complete.
+type sizeLimitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, errLayerTooLarge
+	}
+
+	return n, err
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func decompress(reader *bufio.Reader) (io.ReadCloser, error) {
+	magic, err := reader.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(reader)
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+
+	default:
+		return io.NopCloser(reader), nil
+	}
+}
+
+func mapTarHeader(header *tar.Header) LayerFile {
+	file := LayerFile{
+		Path:    CleanPath(header.Name),
+		Size:    header.Size,
+		Mode:    header.Mode & 0o7777,
+		Uid:     header.Uid,
+		Gid:     header.Gid,
+		ModTime: header.ModTime.UTC(),
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+		file.Type = FileTypeRegular
+	case tar.TypeDir:
+		file.Type = FileTypeDir
+	case tar.TypeSymlink:
+		file.Type = FileTypeSymlink
+		file.LinkTarget = header.Linkname
+	case tar.TypeLink:
+		file.Type = FileTypeHardlink
+		file.LinkTarget = CleanPath(header.Linkname)
+	default:
+		file.Type = FileTypeOther
+	}
+
+	dir, name := path.Split(file.Path)
+	switch {
+	case name == opaqueWhiteout:
+		file.Path = CleanPath(dir)
+		file.Opaque = true
+	case strings.HasPrefix(name, whiteoutPrefix):
+		file.Path = CleanPath(dir + strings.TrimPrefix(name, whiteoutPrefix))
+		file.Whiteout = true
+	}
+
+	return file
+}
+
+// CleanPath turns a path of a tar entry or of a request into an absolute path without trailing slash.
+func CleanPath(filePath string) string {
+	return path.Clean("/" + filePath)
+}
+
+func buildLayerFilesCacheKey(layerDigest string) string {
+	return fmt.Sprintf("layer_files:%s", layerDigest)
+}
+
+// ListImageFiles lists the files of the filesystem the layers of an image manifest add up to.
+func ListImageFiles(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, manifest *Manifest) ([]MergedFile, error) {
+	if manifest.Config == nil || len(manifest.Manifests) > 0 {
+		return nil, fmt.Errorf("only the files of image manifests can be listed, pick a platform of an index by its digest: %w", apiError.ErrApiBadRequest)
+	}
+
+	layerDigests := make([]string, len(manifest.Layers))
+	listings := make([][]LayerFile, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		files, err := ListLayerFiles(ctx, blobService, kvStore, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		layerDigests[i] = layer.Digest
+		listings[i] = files
+	}
+
+	return MergeLayers(layerDigests, listings), nil
+}
+
+// OpenImageFile opens a regular file of the filesystem of an image manifest, it is read from the topmost layer that
+// wrote it. The caller has to close the returned reader.
+func OpenImageFile(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, manifest *Manifest, filePath string) (*MergedFile, io.ReadCloser, error) {
+	files, err := ListImageFiles(ctx, blobService, kvStore, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePath = CleanPath(filePath)
+	index := slices.IndexFunc(files, func(file MergedFile) bool {
+		return file.Path == filePath
+	})
+	if index < 0 {
+		return nil, nil, fmt.Errorf("'%s': %w", filePath, apiError.ErrApiFileNotFound)
+	}
+
+	layerFile, reader, err := OpenLayerFile(ctx, blobService, kvStore, files[index].LayerDigest, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &MergedFile{
+		LayerFile:   *layerFile,
+		LayerDigest: files[index].LayerDigest,
+	}, reader, nil
+}
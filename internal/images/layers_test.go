@@ -0,0 +1,360 @@
+package images
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type LayersTestSuite struct {
+	suite.Suite
+}
+
+func TestLayersTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(LayersTestSuite))
+}
+
+func regularFile(filePath string) LayerFile {
+	return LayerFile{Path: filePath, Type: FileTypeRegular}
+}
+
+func directory(filePath string) LayerFile {
+	return LayerFile{Path: filePath, Type: FileTypeDir}
+}
+
+func hardlink(filePath string, target string) LayerFile {
+	return LayerFile{Path: filePath, Type: FileTypeHardlink, LinkTarget: target}
+}
+
+func whiteout(filePath string) LayerFile {
+	return LayerFile{Path: filePath, Type: FileTypeRegular, Whiteout: true}
+}
+
+func opaque(filePath string) LayerFile {
+	return LayerFile{Path: filePath, Type: FileTypeRegular, Opaque: true}
+}
+
+func (s *LayersTestSuite) TestMergeLayers() {
+	testCases := []struct {
+		name     string
+		listings [][]LayerFile
+		// expected maps the merged paths to the index of the layer they are taken from.
+		expected map[string]int
+	}{
+		{
+			name: "layers add up",
+			listings: [][]LayerFile{
+				{directory("/etc"), regularFile("/etc/hosts")},
+				{regularFile("/etc/passwd")},
+			},
+			expected: map[string]int{"/etc": 0, "/etc/hosts": 0, "/etc/passwd": 1},
+		},
+		{
+			name: "upper layer overwrites a file",
+			listings: [][]LayerFile{
+				{regularFile("/app")},
+				{regularFile("/app")},
+			},
+			expected: map[string]int{"/app": 1},
+		},
+		{
+			name: "whiteout removes a file",
+			listings: [][]LayerFile{
+				{regularFile("/a"), regularFile("/b")},
+				{whiteout("/a")},
+			},
+			expected: map[string]int{"/b": 0},
+		},
+		{
+			name: "whiteout removes a directory with its content",
+			listings: [][]LayerFile{
+				{directory("/var"), directory("/var/cache"), regularFile("/var/cache/data"), regularFile("/var/cached")},
+				{whiteout("/var/cache")},
+			},
+			expected: map[string]int{"/var": 0, "/var/cached": 0},
+		},
+		{
+			name: "whiteout does not hide a file added by the same layer",
+			listings: [][]LayerFile{
+				{directory("/cache"), regularFile("/cache/old")},
+				{whiteout("/cache"), directory("/cache"), regularFile("/cache/new")},
+			},
+			expected: map[string]int{"/cache": 1, "/cache/new": 1},
+		},
+		{
+			name: "whiteout of a missing file",
+			listings: [][]LayerFile{
+				{regularFile("/a")},
+				{whiteout("/b")},
+			},
+			expected: map[string]int{"/a": 0},
+		},
+		{
+			name: "opaque directory hides the content of lower layers",
+			listings: [][]LayerFile{
+				{directory("/etc"), regularFile("/etc/hosts"), directory("/etc/ssl"), regularFile("/etc/ssl/cert.pem")},
+				{opaque("/etc"), regularFile("/etc/passwd")},
+			},
+			expected: map[string]int{"/etc": 0, "/etc/passwd": 1},
+		},
+		{
+			name: "opaque root directory",
+			listings: [][]LayerFile{
+				{regularFile("/a"), directory("/b")},
+				{opaque("/"), regularFile("/c")},
+			},
+			expected: map[string]int{"/c": 1},
+		},
+		{
+			name: "whiteout in a middle layer",
+			listings: [][]LayerFile{
+				{regularFile("/a")},
+				{whiteout("/a")},
+				{regularFile("/a")},
+			},
+			expected: map[string]int{"/a": 2},
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			layerDigests := make([]string, len(testCase.listings))
+			for i := range testCase.listings {
+				layerDigests[i] = string(rune('a' + i))
+			}
+
+			// act
+			merged := MergeLayers(layerDigests, testCase.listings)
+
+			// assert
+			actual := make(map[string]int, len(merged))
+			for i, file := range merged {
+				if i > 0 {
+					s.Less(merged[i-1].Path, file.Path, "sorted by path")
+				}
+				actual[file.Path] = int(file.LayerDigest[0] - 'a')
+			}
+			s.Equal(testCase.expected, actual)
+		})
+	}
+}
+
+func (s *LayersTestSuite) TestMapTarHeader() {
+	modTime := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	testCases := []struct {
+		name     string
+		header   tar.Header
+		expected LayerFile
+	}{
+		{
+			name: "regular file",
+			header: tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     "./etc/os-release",
+				Size:     42,
+				Mode:     0o100644,
+				Uid:      1000,
+				Gid:      100,
+				ModTime:  modTime,
+			},
+			expected: LayerFile{
+				Path:    "/etc/os-release",
+				Type:    FileTypeRegular,
+				Size:    42,
+				Mode:    0o644,
+				Uid:     1000,
+				Gid:     100,
+				ModTime: modTime.UTC(),
+			},
+		},
+		{
+			name:     "directory",
+			header:   tar.Header{Typeflag: tar.TypeDir, Name: "usr/bin/", Mode: 0o40755},
+			expected: LayerFile{Path: "/usr/bin", Type: FileTypeDir, Mode: 0o755, ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "setuid bit is kept",
+			header:   tar.Header{Typeflag: tar.TypeReg, Name: "usr/bin/su", Mode: 0o104755},
+			expected: LayerFile{Path: "/usr/bin/su", Type: FileTypeRegular, Mode: 0o4755, ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "symlink keeps its relative target",
+			header:   tar.Header{Typeflag: tar.TypeSymlink, Name: "bin", Linkname: "usr/bin"},
+			expected: LayerFile{Path: "/bin", Type: FileTypeSymlink, LinkTarget: "usr/bin", ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "hard link target is absolute",
+			header:   tar.Header{Typeflag: tar.TypeLink, Name: "usr/bin/perl5", Linkname: "./usr/bin/perl"},
+			expected: LayerFile{Path: "/usr/bin/perl5", Type: FileTypeHardlink, LinkTarget: "/usr/bin/perl", ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "other type",
+			header:   tar.Header{Typeflag: tar.TypeFifo, Name: "run/fifo"},
+			expected: LayerFile{Path: "/run/fifo", Type: FileTypeOther, ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "whiteout",
+			header:   tar.Header{Typeflag: tar.TypeReg, Name: "etc/.wh.hosts"},
+			expected: LayerFile{Path: "/etc/hosts", Type: FileTypeRegular, Whiteout: true, ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "whiteout in the root directory",
+			header:   tar.Header{Typeflag: tar.TypeReg, Name: ".wh.tmp"},
+			expected: LayerFile{Path: "/tmp", Type: FileTypeRegular, Whiteout: true, ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "opaque whiteout",
+			header:   tar.Header{Typeflag: tar.TypeReg, Name: "var/cache/.wh..wh..opq"},
+			expected: LayerFile{Path: "/var/cache", Type: FileTypeRegular, Opaque: true, ModTime: time.Time{}.UTC()},
+		},
+		{
+			name:     "path escaping the root",
+			header:   tar.Header{Typeflag: tar.TypeReg, Name: "../../etc/shadow"},
+			expected: LayerFile{Path: "/etc/shadow", Type: FileTypeRegular, ModTime: time.Time{}.UTC()},
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			file := mapTarHeader(&testCase.header)
+
+			// assert
+			s.Equal(testCase.expected, file)
+		})
+	}
+}
+
+func (s *LayersTestSuite) TestResolveHardlinks() {
+	chain := []LayerFile{regularFile("/target")}
+	for i := 0; i < maxHardlinkDepth; i++ {
+		previous := chain[len(chain)-1].Path
+		chain = append(chain, hardlink(previous+"-link", previous))
+	}
+
+	testCases := []struct {
+		name     string
+		files    []LayerFile
+		filePath string
+		expected string
+		err      error
+	}{
+		{
+			name:     "regular file",
+			files:    []LayerFile{regularFile("/a")},
+			filePath: "/a",
+			expected: "/a",
+		},
+		{
+			name:     "hard link",
+			files:    []LayerFile{regularFile("/a"), hardlink("/b", "/a")},
+			filePath: "/b",
+			expected: "/a",
+		},
+		{
+			name:     "chain of hard links",
+			files:    chain,
+			filePath: chain[len(chain)-1].Path,
+			expected: "/target",
+		},
+		{
+			name:     "chain of hard links longer than the limit",
+			files:    append(chain, hardlink("/too-long", chain[len(chain)-1].Path)),
+			filePath: "/too-long",
+			err:      apiError.ErrApiBadRequest,
+		},
+		{
+			name:     "hard link loop",
+			files:    []LayerFile{hardlink("/a", "/b"), hardlink("/b", "/a")},
+			filePath: "/a",
+			err:      apiError.ErrApiBadRequest,
+		},
+		{
+			name:     "missing file",
+			files:    []LayerFile{regularFile("/a")},
+			filePath: "/b",
+			err:      apiError.ErrApiFileNotFound,
+		},
+		{
+			name:     "hard link to a missing file",
+			files:    []LayerFile{hardlink("/b", "/a")},
+			filePath: "/b",
+			err:      apiError.ErrApiFileNotFound,
+		},
+		{
+			name:     "whiteout",
+			files:    []LayerFile{whiteout("/a")},
+			filePath: "/a",
+			err:      apiError.ErrApiFileNotFound,
+		},
+		{
+			name:     "directory",
+			files:    []LayerFile{directory("/a")},
+			filePath: "/a",
+			err:      apiError.ErrApiBadRequest,
+		},
+		{
+			name:     "hard link to a directory",
+			files:    []LayerFile{directory("/a"), hardlink("/b", "/a")},
+			filePath: "/b",
+			err:      apiError.ErrApiBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			file, err := resolveHardlinks(testCase.files, testCase.filePath)
+
+			// assert
+			if testCase.err != nil {
+				s.ErrorIs(err, testCase.err)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(testCase.expected, file.Path)
+		})
+	}
+}
+
+func (s *LayersTestSuite) TestSizeLimitedReader() {
+	testCases := []struct {
+		name    string
+		size    int
+		limit   int64
+		tooLong bool
+	}{
+		{name: "below the limit", size: 10, limit: 11},
+		{name: "at the limit", size: 10, limit: 10},
+		{name: "above the limit", size: 11, limit: 10, tooLong: true},
+		{name: "empty", size: 0, limit: 0},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			reader := &sizeLimitedReader{reader: bytes.NewReader(make([]byte, testCase.size)), remaining: testCase.limit}
+
+			// act
+			data, err := io.ReadAll(reader)
+
+			// assert
+			if testCase.tooLong {
+				s.ErrorIs(err, apiError.ErrApiBadRequest)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Len(data, testCase.size)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package images
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IndexMediaTypes are the media types of manifests listing other manifests, usually one per platform.
+var IndexMediaTypes = map[string]bool{
+	"application/vnd.oci.image.index.v1+json":                   true,
+	"application/vnd.docker.distribution.manifest.list.v2+json": true,
+}
+
+// ConfigMediaTypes are the media types of image configs, other configs belong to artifacts and are not parsed.
+var ConfigMediaTypes = map[string]bool{
+	"application/vnd.oci.image.config.v1+json":       true,
+	"application/vnd.docker.container.image.v1+json": true,
+}
+
+// Descriptor points to a blob or, in an index, to another manifest.
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType"`
+	Platform     *Platform         `json:"platform"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	OSVersion    string `json:"os.version"`
+	Variant      string `json:"variant"`
+}
+
+// Manifest is an image manifest or an image index, Config and Layers are only set for the former and Manifests only
+// for the latter.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        *Descriptor       `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject"`
+	Annotations   map[string]string `json:"annotations"`
+}
+
+// Config is the image config, it describes how the image was built and how containers of it are run.
+type Config struct {
+	Created      *time.Time      `json:"created"`
+	Author       string          `json:"author"`
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	OSVersion    string          `json:"os.version"`
+	Variant      string          `json:"variant"`
+	Config       ContainerConfig `json:"config"`
+	RootFS       RootFS          `json:"rootfs"`
+	History      []History       `json:"history"`
+}
+
+type ContainerConfig struct {
+	User         string              `json:"User"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	Env          []string            `json:"Env"`
+	Entrypoint   []string            `json:"Entrypoint"`
+	Cmd          []string            `json:"Cmd"`
+	Volumes      map[string]struct{} `json:"Volumes"`
+	WorkingDir   string              `json:"WorkingDir"`
+	Labels       map[string]string   `json:"Labels"`
+	StopSignal   string              `json:"StopSignal"`
+}
+
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIds []string `json:"diff_ids"`
+}
+
+// History describes a build step, steps that did not change the filesystem are marked as empty layers and have no
+// layer of their own.
+type History struct {
+	Created    *time.Time `json:"created"`
+	CreatedBy  string     `json:"created_by"`
+	Author     string     `json:"author"`
+	Comment    string     `json:"comment"`
+	EmptyLayer bool       `json:"empty_layer"`
+}
+
+func ParseManifest(body []byte) (*Manifest, error) {
+	var manifest Manifest
+	err := json.Unmarshal(body, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+func ParseConfig(body []byte) (*Config, error) {
+	var config Config
+	err := json.Unmarshal(body, &config)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// IsIndex reports whether the manifest lists other manifests. The media type of the stored manifest is used, as the
+// mediaType field of the manifest itself is optional.
+func IsIndex(mediaType string) bool {
+	return IndexMediaTypes[mediaType]
+}
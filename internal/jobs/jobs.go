@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+// Job is a unit of background work. It runs in its own dependency scope that is closed after the job returned. Closing
+// the scope saves the pending changes of the database context even if the job failed, so a job must not leave changes
+// behind that may only be saved if it succeeds.
+type Job func(ctx context.Context) error
+
+// Schedule runs the job every interval until the context is cancelled. Runs never overlap within one instance, jobs
+// that must not run concurrently across instances have to lock themselves.
+func Schedule(ctx context.Context, dp *ioc.DependencyProvider, name string, interval time.Duration, job Job) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				run(ctx, dp, name, job)
+			}
+		}
+	}()
+}
+
+func run(ctx context.Context, dp *ioc.DependencyProvider, name string, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.Logger.Errorf("job %s panicked: %v", name, r)
+		}
+	}()
+
+	scope := dp.NewScope()
+	ctx = middlewares.ContextWithScope(ctx, scope)
+
+	err := job(ctx)
+	if err != nil {
+		logging.Logger.Errorf("job %s failed: %v", name, err)
+	}
+
+	err = scope.Close()
+	if err != nil {
+		logging.Logger.Errorf("closing scope of job %s: %v", name, err)
+	}
+}
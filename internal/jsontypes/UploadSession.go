@@ -1,6 +1,9 @@
 package jsontypes
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
 
 type BlobUploadMode string
 
@@ -10,13 +13,14 @@ const (
 )
 
 type UploadSession struct {
-	Id             uuid.UUID         `json:"id"`
-	UploadMode     BlobUploadMode    `json:"uploadMode"`
-	TenantSlug     string            `json:"tenantSlug"`
-	ProjectSlug    string            `json:"projectSlug"`
-	RepositorySlug string            `json:"repositorySlug"`
-	RepositoryId   uuid.UUID         `json:"repositoryId"`
-	RangeEnd       int64             `json:"rangeEnd"`
-	DigestState    []byte            `json:"digestState"`
-	BackendState   map[string]string `json:"backendState"`
+	Id             uuid.UUID      `json:"id"`
+	UploadMode     BlobUploadMode `json:"uploadMode"`
+	TenantSlug     string         `json:"tenantSlug"`
+	ProjectSlug    string         `json:"projectSlug"`
+	RepositorySlug string         `json:"repositorySlug"`
+	RepositoryId   uuid.UUID      `json:"repositoryId"`
+	RangeEnd       int64          `json:"rangeEnd"`
+	// DigestState holds the resumable hasher state of every supported digest algorithm.
+	DigestState  map[digest.Algorithm][]byte `json:"digestState"`
+	BackendState map[string]string           `json:"backendState"`
 }
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// DistributionApiVersionMiddleware advertises the registry api version, docker clients use the header to detect that
+// they are talking to a v2 registry.
+func DistributionApiVersionMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package ociAuthentication
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+// RepositoryScope formats the token scope that grants the given access to a repository, e.g.
+// repository:project/repository:pull.
+func RepositoryScope(repoIdentifier middlewares.OciRepositoryIdentifier, access ...Access) string {
+	accessStrings := make([]string, len(access))
+	for i, a := range access {
+		accessStrings[i] = string(a)
+	}
+
+	return fmt.Sprintf("repository:%s/%s:%s", repoIdentifier.ProjectSlug, repoIdentifier.RepositorySlug, strings.Join(accessStrings, ","))
+}
+
+// Challenge builds the WWW-Authenticate header value that points clients to the token endpoint of the tenant. The
+// service matches the one the token endpoint expects, the scope is left out if it is empty.
+func Challenge(tenantSlug string, scope string) string {
+	realm := fmt.Sprintf("%s/v2/token", config.C.Server.ExternalUrl)
+	service := fmt.Sprintf("%s:%s", config.C.Server.ExternalDomain, tenantSlug)
+
+	challenge := fmt.Sprintf("Bearer realm=\"%s\",service=\"%s\"", realm, service)
+	if scope != "" {
+		challenge += fmt.Sprintf(",scope=\"%s\"", scope)
+	}
+
+	return challenge
+}
+
+// NewUnauthorizedError returns the 401 error that makes clients request a token with the given scope and retry.
+func NewUnauthorizedError(tenantSlug string, scope string, message string) *ociError.OciError {
+	return ociError.NewOciError(ociError.Unauthorized).
+		WithMessage(message).
+		WithHttpCode(http.StatusUnauthorized).
+		WithHeader("WWW-Authenticate", Challenge(tenantSlug, scope))
+}
+
+// NewInsufficientScopeError is like NewUnauthorizedError, but marks the challenge with error="insufficient_scope"
+// because the presented token is valid but does not cover the scope.
+func NewInsufficientScopeError(tenantSlug string, scope string) *ociError.OciError {
+	challenge := Challenge(tenantSlug, scope) + ",error=\"insufficient_scope\""
+
+	return ociError.NewOciError(ociError.Unauthorized).
+		WithMessage("token does not grant the required scope").
+		WithHttpCode(http.StatusUnauthorized).
+		WithHeader("WWW-Authenticate", challenge)
+}
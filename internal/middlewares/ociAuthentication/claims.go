@@ -0,0 +1,27 @@
+package ociAuthentication
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RepositoryResourceType = "repository"
+	RegistryResourceType   = "registry"
+
+	// CatalogResourceName together with the registry type and the * action grants listing the catalog.
+	CatalogResourceName = "catalog"
+)
+
+// AccessClaim is a single entry of the access claim as defined by the docker token specification, e.g.
+// {"type":"repository","name":"project/repository","actions":["pull","push"]}.
+type AccessClaim struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+// TokenClaims are the claims of the access tokens issued by the token endpoint.
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	Access []AccessClaim `json:"access,omitempty"`
+}
@@ -2,6 +2,7 @@ package ociAuthentication
 
 import (
 	"context"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/the127/dockyard/internal/middlewares"
@@ -10,16 +11,37 @@ import (
 type Access string
 
 const (
-	PushAccess Access = "push"
-	PullAccess Access = "pull"
+	PushAccess   Access = "push"
+	PullAccess   Access = "pull"
+	DeleteAccess Access = "delete"
 )
 
+// RepositoryAccess is the access a token grants to a single repository.
+type RepositoryAccess struct {
+	Repository middlewares.OciRepositoryIdentifier
+	Access     []Access
+}
+
 type CurrentUser struct {
 	TenantId        uuid.UUID
 	UserId          uuid.UUID
 	IsAuthenticated bool
-	Repository      *middlewares.OciRepositoryIdentifier
-	Access          []Access
+	// Repositories holds every repository scope granted by the token, a token can cover multiple repositories, e.g.
+	// the source and target of a cross repository mount.
+	Repositories []RepositoryAccess
+	// CatalogAccess is granted by the registry:catalog:* scope and allows listing the repositories of the tenant.
+	CatalogAccess bool
+}
+
+// HasAccess reports whether the token of the user grants the access to the repository.
+func (u CurrentUser) HasAccess(repoIdentifier middlewares.OciRepositoryIdentifier, access Access) bool {
+	for _, repositoryAccess := range u.Repositories {
+		if repositoryAccess.Repository.Equals(repoIdentifier) && slices.Contains(repositoryAccess.Access, access) {
+			return true
+		}
+	}
+
+	return false
 }
 
 var CurrentUserContextKey = &CurrentUser{}
@@ -3,6 +3,7 @@ package ociAuthentication
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/The127/ioc"
@@ -77,8 +78,10 @@ func getOciCurrentUser(r *http.Request) (*CurrentUser, error) {
 		return nil, fmt.Errorf("getting signing key: %w", err)
 	}
 
-	token, err := jwt.Parse(
+	claims := &TokenClaims{}
+	token, err := jwt.ParseWithClaims(
 		bearerToken,
+		claims,
 		func(token *jwt.Token) (interface{}, error) {
 			return signingKey.PublicKey()
 		},
@@ -87,66 +90,55 @@ func getOciCurrentUser(r *http.Request) (*CurrentUser, error) {
 		jwt.WithIssuedAt(),
 		jwt.WithExpirationRequired(),
 	)
-	if err != nil {
-		return nil, fmt.Errorf("parsing jwt: %w", err)
-	}
-
-	if !token.Valid {
-		return nil, ociError.NewOciError(ociError.Unauthorized).
-			WithMessage("invalid token").
-			WithHttpCode(http.StatusUnauthorized)
+	// expired or otherwise invalid tokens are challenged, so clients fetch a new one and retry
+	if err != nil || !token.Valid {
+		return nil, NewUnauthorizedError(tenantSlug, "", "invalid token")
 	}
 
-	claims := token.Claims.(jwt.MapClaims)
-	audClaimString := claims["aud"].(string)
-	tenantId, err := uuid.Parse(audClaimString)
-	if err != nil {
-		return nil, ociError.NewOciError(ociError.Unauthorized).
-			WithMessage("invalid tenant id").
-			WithHttpCode(http.StatusUnauthorized)
-	}
-
-	subClaimString := claims["sub"].(string)
-	userId, err := uuid.Parse(subClaimString)
+	userId, err := uuid.Parse(claims.Subject)
 	if err != nil {
 		return nil, ociError.NewOciError(ociError.Unauthorized).
 			WithMessage("invalid user id").
 			WithHttpCode(http.StatusUnauthorized)
 	}
 
-	var access []Access
+	var repositoryAccesses []RepositoryAccess
+	catalogAccess := false
 
-	accessClaim, ok := claims["access"]
-	if ok {
-		accessClaimSlice, ok := accessClaim.([]any)
-		if ok {
-			for i := range accessClaimSlice {
-				accessClaimString, ok := accessClaimSlice[i].(string)
-				if ok {
-					access = append(access, Access(accessClaimString))
-				}
+	for _, accessClaim := range claims.Access {
+		switch accessClaim.Type {
+		case RepositoryResourceType:
+			projectSlug, repositorySlug, ok := strings.Cut(accessClaim.Name, "/")
+			if !ok {
+				continue
+			}
+
+			access := make([]Access, len(accessClaim.Actions))
+			for i, action := range accessClaim.Actions {
+				access[i] = Access(action)
 			}
-		}
-	}
 
-	var repository *middlewares.OciRepositoryIdentifier
-	repositoryClaim, ok := claims["repository"]
-	if ok {
-		repositoryClaimMap, ok := repositoryClaim.(map[string]any)
-		if ok {
-			repository = &middlewares.OciRepositoryIdentifier{
-				TenantSlug:     repositoryClaimMap["tenant"].(string),
-				ProjectSlug:    repositoryClaimMap["project"].(string),
-				RepositorySlug: repositoryClaimMap["repository"].(string),
+			repositoryAccesses = append(repositoryAccesses, RepositoryAccess{
+				Repository: middlewares.OciRepositoryIdentifier{
+					TenantSlug:     tenantSlug,
+					ProjectSlug:    projectSlug,
+					RepositorySlug: repositorySlug,
+				},
+				Access: access,
+			})
+
+		case RegistryResourceType:
+			if accessClaim.Name == CatalogResourceName && slices.Contains(accessClaim.Actions, "*") {
+				catalogAccess = true
 			}
 		}
 	}
 
 	return &CurrentUser{
-		TenantId:        tenantId,
+		TenantId:        tenant.GetId(),
 		UserId:          userId,
 		IsAuthenticated: true,
-		Access:          access,
-		Repository:      repository,
+		Repositories:    repositoryAccesses,
+		CatalogAccess:   catalogAccess,
 	}, nil
 }
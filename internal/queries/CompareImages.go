@@ -0,0 +1,73 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// CompareImages compares two images of a repository, see images.Compare. The references are tags or digests.
+type CompareImages struct {
+	TenantSlug      string
+	ProjectSlug     string
+	RepositorySlug  string
+	BaseReference   string
+	TargetReference string
+
+	// WithFiles also compares the files of both images, which requires reading their layers unless they are cached.
+	WithFiles bool
+}
+
+type CompareImagesResponse struct {
+	BaseDigest   string
+	TargetDigest string
+	Comparison   images.Comparison
+}
+
+func HandleCompareImages(ctx context.Context, query CompareImages) (*CompareImagesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	baseManifest, err := getManifestByReference(ctx, dbContext, repository.GetId(), query.BaseReference)
+	if err != nil {
+		return nil, err
+	}
+
+	targetManifest, err := getManifestByReference(ctx, dbContext, repository.GetId(), query.TargetReference)
+	if err != nil {
+		return nil, err
+	}
+
+	base, _, err := images.ReadManifest(ctx, blobService, baseManifest.GetDigest())
+	if err != nil {
+		return nil, err
+	}
+
+	target, _, err := images.ReadManifest(ctx, blobService, targetManifest.GetDigest())
+	if err != nil {
+		return nil, err
+	}
+
+	comparison, err := images.Compare(ctx, blobService, kvStore, base, target, query.WithFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompareImagesResponse{
+		BaseDigest:   baseManifest.GetDigest(),
+		TargetDigest: targetManifest.GetDigest(),
+		Comparison:   *comparison,
+	}, nil
+}
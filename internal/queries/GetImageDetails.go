@@ -0,0 +1,45 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+)
+
+// GetImageDetails describes the manifest a tag or digest refers to, see images.GetDetails.
+type GetImageDetails struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	Reference      string
+}
+
+type GetImageDetailsResponse images.Details
+
+func HandleGetImageDetails(ctx context.Context, query GetImageDetails) (*GetImageDetailsResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := getManifestByReference(ctx, dbContext, repository.GetId(), query.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := images.GetDetails(ctx, dbContext, blobService, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("getting image details: %w", err)
+	}
+
+	return (*GetImageDetailsResponse)(details), nil
+}
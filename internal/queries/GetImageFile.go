@@ -0,0 +1,60 @@
+package queries
+
+import (
+	"context"
+	"io"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// GetImageFile opens a regular file of an image as it is seen with all layers applied.
+type GetImageFile struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	Reference      string
+	Path           string
+}
+
+type GetImageFileResponse struct {
+	File images.MergedFile
+	// Content has to be closed by the caller.
+	Content io.ReadCloser
+}
+
+func HandleGetImageFile(ctx context.Context, query GetImageFile) (*GetImageFileResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := getManifestByReference(ctx, dbContext, repository.GetId(), query.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, _, err := images.ReadManifest(ctx, blobService, manifest.GetDigest())
+	if err != nil {
+		return nil, err
+	}
+
+	file, content, err := images.OpenImageFile(ctx, blobService, kvStore, parsed, query.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetImageFileResponse{
+		File:    *file,
+		Content: content,
+	}, nil
+}
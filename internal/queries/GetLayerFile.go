@@ -0,0 +1,55 @@
+package queries
+
+import (
+	"context"
+	"io"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// GetLayerFile opens a regular file of a layer of the repository.
+type GetLayerFile struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	LayerDigest    string
+	Path           string
+}
+
+type GetLayerFileResponse struct {
+	File images.LayerFile
+	// Content has to be closed by the caller.
+	Content io.ReadCloser
+}
+
+func HandleGetLayerFile(ctx context.Context, query GetLayerFile) (*GetLayerFileResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRepositoryLayer(ctx, dbContext, repository.GetId(), query.LayerDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	file, content, err := images.OpenLayerFile(ctx, blobService, kvStore, query.LayerDigest, query.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetLayerFileResponse{
+		File:    *file,
+		Content: content,
+	}, nil
+}
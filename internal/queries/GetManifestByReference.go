@@ -2,15 +2,16 @@ package queries
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/The127/ioc"
 	"github.com/google/uuid"
 	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
@@ -28,31 +29,18 @@ func HandleGetManifestByReference(ctx context.Context, query GetManifestByRefere
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[db.Context](scope)
 
-	var manifestFilter *repositories.ManifestFilter
-	if !strings.HasPrefix(query.Reference, "sha256:") {
-		tag, err := dbContext.Tags().First(ctx, repositories.NewTagFilter().ByRepositoryId(query.RepositoryId).ByName(query.Reference))
-		if err != nil {
-			return nil, fmt.Errorf("getting tag: %w", err)
-		}
-		if tag == nil {
-			return nil, ociError.NewOciError(ociError.ManifestUnknown).
-				WithMessage(fmt.Sprintf("tag '%s' does not exist", query.Reference)).
-				WithHttpCode(http.StatusNotFound)
-		}
-
-		manifestFilter = repositories.NewManifestFilter().ById(tag.GetRepositoryManifestId())
-	} else {
-		manifestFilter = repositories.NewManifestFilter().ByRepositoryId(query.RepositoryId).ByDigest(query.Reference)
-	}
-
-	manifest, err := dbContext.Manifests().First(ctx, manifestFilter)
-	if err != nil {
-		return nil, fmt.Errorf("getting manifest: %w", err)
-	}
-	if manifest == nil {
+	manifest, err := getManifestByReference(ctx, dbContext, query.RepositoryId, query.Reference)
+	switch {
+	case errors.Is(err, apiError.ErrApiTagNotFound):
+		return nil, ociError.NewOciError(ociError.ManifestUnknown).
+			WithMessage(fmt.Sprintf("tag '%s' does not exist", query.Reference)).
+			WithHttpCode(http.StatusNotFound)
+	case errors.Is(err, apiError.ErrApiManifestNotFound):
 		return nil, ociError.NewOciError(ociError.ManifestUnknown).
 			WithMessage(fmt.Sprintf("manifest '%s' does not exist", query.Reference)).
 			WithHttpCode(http.StatusNotFound)
+	case err != nil:
+		return nil, err
 	}
 
 	blob, err := dbContext.Blobs().First(ctx, repositories.NewBlobFilter().ById(manifest.GetBlobId()))
@@ -10,6 +10,7 @@ import (
 	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/storageUsage"
 )
 
 type GetProject struct {
@@ -24,6 +25,10 @@ type GetProjectResponse struct {
 	Description *string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// StorageUsed counts every blob stored by the repositories once, StorageQuota is nil if the storage is not limited.
+	StorageUsed  int64
+	StorageQuota *int64
 }
 
 func HandleGetProject(ctx context.Context, query GetProject) (*GetProjectResponse, error) {
@@ -42,6 +47,11 @@ func HandleGetProject(ctx context.Context, query GetProject) (*GetProjectRespons
 		return nil, fmt.Errorf("getting project: %w", err)
 	}
 
+	usage, err := storageUsage.GetProjectUsage(ctx, dbContext, project)
+	if err != nil {
+		return nil, fmt.Errorf("getting storage usage: %w", err)
+	}
+
 	return &GetProjectResponse{
 		Id:          project.GetId(),
 		Slug:        project.GetSlug(),
@@ -49,5 +59,8 @@ func HandleGetProject(ctx context.Context, query GetProject) (*GetProjectRespons
 		Description: project.GetDescription(),
 		CreatedAt:   project.GetCreatedAt(),
 		UpdatedAt:   project.GetUpdatedAt(),
+
+		StorageUsed:  usage.Used,
+		StorageQuota: usage.Quota,
 	}, nil
 }
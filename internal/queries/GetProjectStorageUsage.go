@@ -0,0 +1,95 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/storageUsage"
+)
+
+// GetProjectStorageUsage reads the storage counters of the project and its repositories, they are refreshed in the
+// background and lag behind pushes and deletions.
+type GetProjectStorageUsage struct {
+	TenantSlug  string
+	ProjectSlug string
+}
+
+type GetProjectStorageUsageResponse struct {
+	storageUsage.Sizes
+
+	// ComputedAt is when the counters were refreshed for the last time, it is nil if they have not been refreshed yet.
+	ComputedAt *time.Time
+
+	Repositories []GetProjectStorageUsageResponseRepository
+}
+
+type GetProjectStorageUsageResponseRepository struct {
+	storageUsage.Sizes
+
+	Id   uuid.UUID
+	Slug string
+}
+
+func HandleGetProjectStorageUsage(ctx context.Context, query GetProjectStorageUsage) (*GetProjectStorageUsageResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	computedAt, err := storageUsage.GetComputedAt(ctx, kvStore)
+	if err != nil {
+		return nil, err
+	}
+
+	counters, _, err := dbContext.StorageUsages().List(ctx, repositories.NewStorageUsageFilter().ByProjectId(project.GetId()).ByKind(repositories.StorageUsageKindRepository))
+	if err != nil {
+		return nil, fmt.Errorf("listing storage usages: %w", err)
+	}
+
+	countersByRepository := make(map[uuid.UUID]*repositories.StorageUsage, len(counters))
+	for _, counter := range counters {
+		countersByRepository[counter.GetOwnerId()] = counter
+	}
+
+	projectCounter, err := dbContext.StorageUsages().First(ctx, repositories.NewStorageUsageFilter().ByKind(repositories.StorageUsageKindProject).ByOwnerId(project.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting storage usage: %w", err)
+	}
+
+	repos, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	response := &GetProjectStorageUsageResponse{
+		Sizes:        storageUsage.CounterSizes(projectCounter),
+		ComputedAt:   computedAt,
+		Repositories: make([]GetProjectStorageUsageResponseRepository, len(repos)),
+	}
+
+	for i, repository := range repos {
+		response.Repositories[i] = GetProjectStorageUsageResponseRepository{
+			Sizes: storageUsage.CounterSizes(countersByRepository[repository.GetId()]),
+			Id:    repository.GetId(),
+			Slug:  repository.GetSlug(),
+		}
+	}
+
+	return response, nil
+}
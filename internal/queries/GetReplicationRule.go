@@ -0,0 +1,86 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// GetReplicationRule returns the rule together with its replication status.
+type GetReplicationRule struct {
+	TenantSlug  string
+	ProjectSlug string
+	RuleId      uuid.UUID
+}
+
+type GetReplicationRuleResponse struct {
+	Id        uuid.UUID
+	Url       string
+	Namespace string
+	TagFilter *string
+	Username  *string
+	// HasPassword tells whether a password is configured, the password itself is never returned.
+	HasPassword bool
+	Enabled     bool
+
+	LastSuccessAt *time.Time
+	LastFailureAt *time.Time
+	LastError     *string
+	// PendingTasks is the number of tags waiting for their next attempt.
+	PendingTasks int
+	// FailedTasks is the number of tags that have been given up after too many failed attempts.
+	FailedTasks int
+}
+
+func HandleGetReplicationRule(ctx context.Context, query GetReplicationRule) (*GetReplicationRuleResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	rule, err := dbContext.ReplicationRules().Single(ctx, repositories.NewReplicationRuleFilter().ByProjectId(project.GetId()).ById(query.RuleId))
+	if err != nil {
+		return nil, fmt.Errorf("getting replication rule: %w", err)
+	}
+
+	taskFilter := repositories.NewReplicationTaskFilter().ByReplicationRuleId(rule.GetId())
+
+	_, pendingTasks, err := dbContext.ReplicationTasks().List(ctx, taskFilter.ByFailed(false))
+	if err != nil {
+		return nil, fmt.Errorf("listing pending replication tasks: %w", err)
+	}
+
+	_, failedTasks, err := dbContext.ReplicationTasks().List(ctx, taskFilter.ByFailed(true))
+	if err != nil {
+		return nil, fmt.Errorf("listing failed replication tasks: %w", err)
+	}
+
+	return &GetReplicationRuleResponse{
+		Id:            rule.GetId(),
+		Url:           rule.GetUrl(),
+		Namespace:     rule.GetNamespace(),
+		TagFilter:     rule.GetTagFilter(),
+		Username:      rule.GetUsername(),
+		HasPassword:   rule.GetEncryptedPassword() != nil,
+		Enabled:       rule.GetEnabled(),
+		LastSuccessAt: rule.GetLastSuccessAt(),
+		LastFailureAt: rule.GetLastFailureAt(),
+		LastError:     rule.GetLastError(),
+		PendingTasks:  pendingTasks,
+		FailedTasks:   failedTasks,
+	}, nil
+}
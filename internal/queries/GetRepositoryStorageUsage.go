@@ -0,0 +1,38 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/storageUsage"
+)
+
+// GetRepositoryStorageUsage computes the storage of the repository and its tags. Unlike the counters of tenants and
+// projects it is always up to date.
+type GetRepositoryStorageUsage struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+}
+
+type GetRepositoryStorageUsageResponse storageUsage.RepositoryUsage
+
+func HandleGetRepositoryStorageUsage(ctx context.Context, query GetRepositoryStorageUsage) (*GetRepositoryStorageUsageResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := storageUsage.GetRepositoryUsage(ctx, dbContext, repository.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("getting storage usage: %w", err)
+	}
+
+	return (*GetRepositoryStorageUsageResponse)(usage), nil
+}
@@ -10,6 +10,7 @@ import (
 	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/storageUsage"
 )
 
 type GetTenant struct {
@@ -22,6 +23,10 @@ type GetTenantResponse struct {
 	DisplayName string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// StorageUsed counts every blob stored by the repositories once, StorageQuota is nil if the storage is not limited.
+	StorageUsed  int64
+	StorageQuota *int64
 }
 
 func HandleGetTenant(ctx context.Context, query GetTenant) (*GetTenantResponse, error) {
@@ -34,11 +39,19 @@ func HandleGetTenant(ctx context.Context, query GetTenant) (*GetTenantResponse,
 		return nil, fmt.Errorf("failed to get tenant: %w", err)
 	}
 
+	usage, err := storageUsage.GetTenantUsage(ctx, dbContext, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("getting storage usage: %w", err)
+	}
+
 	return &GetTenantResponse{
 		Id:          tenant.GetId(),
 		Slug:        tenant.GetSlug(),
 		DisplayName: tenant.GetDisplayName(),
 		CreatedAt:   tenant.GetCreatedAt(),
 		UpdatedAt:   tenant.GetUpdatedAt(),
+
+		StorageUsed:  usage.Used,
+		StorageQuota: usage.Quota,
 	}, nil
 }
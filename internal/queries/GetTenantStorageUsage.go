@@ -0,0 +1,89 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/storageUsage"
+)
+
+// GetTenantStorageUsage reads the storage counters of the tenant and its projects, they are refreshed in the
+// background and lag behind pushes and deletions.
+type GetTenantStorageUsage struct {
+	TenantSlug string
+}
+
+type GetTenantStorageUsageResponse struct {
+	storageUsage.Sizes
+
+	// ComputedAt is when the counters were refreshed for the last time, it is nil if they have not been refreshed yet.
+	ComputedAt *time.Time
+
+	Projects []GetTenantStorageUsageResponseProject
+}
+
+type GetTenantStorageUsageResponseProject struct {
+	storageUsage.Sizes
+
+	Id   uuid.UUID
+	Slug string
+}
+
+func HandleGetTenantStorageUsage(ctx context.Context, query GetTenantStorageUsage) (*GetTenantStorageUsageResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	computedAt, err := storageUsage.GetComputedAt(ctx, kvStore)
+	if err != nil {
+		return nil, err
+	}
+
+	counters, _, err := dbContext.StorageUsages().List(ctx, repositories.NewStorageUsageFilter().ByTenantId(tenant.GetId()).ByKind(repositories.StorageUsageKindProject))
+	if err != nil {
+		return nil, fmt.Errorf("listing storage usages: %w", err)
+	}
+
+	countersByProject := make(map[uuid.UUID]*repositories.StorageUsage, len(counters))
+	for _, counter := range counters {
+		countersByProject[counter.GetOwnerId()] = counter
+	}
+
+	tenantCounter, err := dbContext.StorageUsages().First(ctx, repositories.NewStorageUsageFilter().ByKind(repositories.StorageUsageKindTenant).ByOwnerId(tenant.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("getting storage usage: %w", err)
+	}
+
+	projects, _, err := dbContext.Projects().List(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+
+	response := &GetTenantStorageUsageResponse{
+		Sizes:      storageUsage.CounterSizes(tenantCounter),
+		ComputedAt: computedAt,
+		Projects:   make([]GetTenantStorageUsageResponseProject, len(projects)),
+	}
+
+	for i, project := range projects {
+		response.Projects[i] = GetTenantStorageUsageResponseProject{
+			Sizes: storageUsage.CounterSizes(countersByProject[project.GetId()]),
+			Id:    project.GetId(),
+			Slug:  project.GetSlug(),
+		}
+	}
+
+	return response, nil
+}
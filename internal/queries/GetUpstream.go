@@ -0,0 +1,69 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// GetUpstream returns the upstream of a project, or of a repository if RepositorySlug is set.
+type GetUpstream struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+}
+
+type GetUpstreamResponse struct {
+	Id         uuid.UUID
+	Url        string
+	Repository string
+	Username   *string
+	// HasPassword tells whether a password is configured, the password itself is never returned.
+	HasPassword bool
+	TagTtl      time.Duration
+}
+
+func HandleGetUpstream(ctx context.Context, query GetUpstream) (*GetUpstreamResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	filter := repositories.NewUpstreamFilter().ByProjectId(project.GetId())
+	if query.RepositorySlug != nil {
+		repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()).BySlug(*query.RepositorySlug))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository: %w", err)
+		}
+
+		filter = repositories.NewUpstreamFilter().ByRepositoryId(repository.GetId())
+	}
+
+	upstream, err := dbContext.Upstreams().Single(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream: %w", err)
+	}
+
+	return &GetUpstreamResponse{
+		Id:          upstream.GetId(),
+		Url:         upstream.GetUrl(),
+		Repository:  upstream.GetRepository(),
+		Username:    upstream.GetUsername(),
+		HasPassword: upstream.GetEncryptedPassword() != nil,
+		TagTtl:      upstream.GetTagTtl(),
+	}, nil
+}
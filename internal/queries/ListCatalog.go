@@ -0,0 +1,99 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+type ListCatalog struct {
+	TenantSlug string
+	// UserId is the user the catalog is listed for, only public repositories are visible if it is uuid.Nil.
+	UserId uuid.UUID
+
+	// Last only returns repositories whose name sorts lexically after the given name.
+	Last *string
+	// Limit restricts the number of returned repositories, TotalCount still reflects all repositories after Last.
+	Limit *int
+}
+
+type ListCatalogResponse PagedResponse[ListCatalogResponseItem]
+
+type ListCatalogResponseItem struct {
+	// Name is the oci repository name in the form <project>/<repository>.
+	Name string
+}
+
+func HandleListCatalog(ctx context.Context, query ListCatalog) (*ListCatalogResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	projects, _, err := dbContext.Projects().List(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+
+	accessRoles := make(map[uuid.UUID]repositories.RepositoryAccessRole)
+	if query.UserId != uuid.Nil {
+		accesses, _, err := dbContext.RepositoryAccess().List(ctx, repositories.NewRepositoryAccessFilter().ByUserId(query.UserId))
+		if err != nil {
+			return nil, fmt.Errorf("listing repository access: %w", err)
+		}
+
+		for _, access := range accesses {
+			accessRoles[access.GetRepositoryId()] = access.GetRole()
+		}
+	}
+
+	var names []string
+	for _, project := range projects {
+		repos, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()))
+		if err != nil {
+			return nil, fmt.Errorf("listing repositories: %w", err)
+		}
+
+		for _, repository := range repos {
+			role, hasAccess := accessRoles[repository.GetId()]
+			if !repository.GetIsPublic() && !(hasAccess && role.AllowPull()) {
+				continue
+			}
+
+			name := fmt.Sprintf("%s/%s", project.GetSlug(), repository.GetSlug())
+			if query.Last != nil && name <= *query.Last {
+				continue
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	totalCount := len(names)
+
+	if query.Limit != nil && len(names) > *query.Limit {
+		names = names[:*query.Limit]
+	}
+
+	items := make([]ListCatalogResponseItem, len(names))
+	for i, name := range names {
+		items[i] = ListCatalogResponseItem{
+			Name: name,
+		}
+	}
+
+	return &ListCatalogResponse{
+		Items:      items,
+		TotalCount: totalCount,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// ListImageFiles lists the files of an image with all layers applied on top of each other.
+type ListImageFiles struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	Reference      string
+
+	// Path restricts the result to the files at or below the directory.
+	Path string
+}
+
+type ListImageFilesResponse struct {
+	Files []images.MergedFile
+}
+
+func HandleListImageFiles(ctx context.Context, query ListImageFiles) (*ListImageFilesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := getManifestByReference(ctx, dbContext, repository.GetId(), query.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, _, err := images.ReadManifest(ctx, blobService, manifest.GetDigest())
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := images.ListImageFiles(ctx, blobService, kvStore, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListImageFilesResponse{
+		Files: images.FilterFiles(files, query.Path),
+	}, nil
+}
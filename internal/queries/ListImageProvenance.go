@@ -0,0 +1,38 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/the127/dockyard/internal/supplyChain"
+)
+
+// ListImageProvenance lists the provenance attestations attached to an image, for an index also the attestations of
+// its platforms.
+type ListImageProvenance struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	Reference      string
+}
+
+type ListImageProvenanceResponse struct {
+	Provenance []supplyChain.AttachedDocument
+}
+
+func HandleListImageProvenance(ctx context.Context, query ListImageProvenance) (*ListImageProvenanceResponse, error) {
+	documents, err := loadImageDocuments(ctx, query.TenantSlug, query.ProjectSlug, query.RepositorySlug, query.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	var provenance []supplyChain.AttachedDocument
+	for _, document := range documents {
+		if document.Kind == supplyChain.DocumentKindProvenance {
+			provenance = append(provenance, document)
+		}
+	}
+
+	return &ListImageProvenanceResponse{
+		Provenance: provenance,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/supplyChain"
+)
+
+// ListImageSboms lists the SBOMs attached to an image, for an index also the SBOMs of its platforms.
+type ListImageSboms struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	Reference      string
+
+	// Search only returns the packages whose name or package URL contains the text.
+	Search string
+}
+
+type ListImageSbomsResponse struct {
+	Sboms []supplyChain.AttachedDocument
+}
+
+func HandleListImageSboms(ctx context.Context, query ListImageSboms) (*ListImageSbomsResponse, error) {
+	documents, err := loadImageDocuments(ctx, query.TenantSlug, query.ProjectSlug, query.RepositorySlug, query.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	var sboms []supplyChain.AttachedDocument
+	for _, document := range documents {
+		if document.Kind != supplyChain.DocumentKindSbom {
+			continue
+		}
+
+		if query.Search != "" {
+			var packages []supplyChain.Package
+			for _, documentPackage := range document.Packages {
+				if documentPackage.Contains(query.Search) {
+					packages = append(packages, documentPackage)
+				}
+			}
+			document.Packages = packages
+		}
+
+		sboms = append(sboms, document)
+	}
+
+	return &ListImageSbomsResponse{
+		Sboms: sboms,
+	}, nil
+}
+
+// loadImageDocuments loads the supply chain documents attached to the image a tag or digest refers to.
+func loadImageDocuments(ctx context.Context, tenantSlug string, projectSlug string, repositorySlug string, reference string) ([]supplyChain.AttachedDocument, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, tenantSlug, projectSlug, repositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := getManifestByReference(ctx, dbContext, repository.GetId(), reference)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := supplyChain.FindAttachments(ctx, dbContext, blobService, kvStore, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return supplyChain.LoadDocuments(ctx, blobService, kvStore, attachments)
+}
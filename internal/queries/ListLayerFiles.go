@@ -0,0 +1,53 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// ListLayerFiles lists the entries of a layer of the repository, including its whiteouts.
+type ListLayerFiles struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug string
+	LayerDigest    string
+
+	// Path restricts the result to the entries at or below the directory.
+	Path string
+}
+
+type ListLayerFilesResponse struct {
+	Files []images.LayerFile
+}
+
+func HandleListLayerFiles(ctx context.Context, query ListLayerFiles) (*ListLayerFilesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	repository, err := getRepositoryBySlugs(ctx, dbContext, query.TenantSlug, query.ProjectSlug, query.RepositorySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRepositoryLayer(ctx, dbContext, repository.GetId(), query.LayerDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := images.ListLayerFiles(ctx, blobService, kvStore, query.LayerDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListLayerFilesResponse{
+		Files: images.FilterFiles(files, query.Path),
+	}, nil
+}
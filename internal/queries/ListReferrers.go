@@ -0,0 +1,99 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type ListReferrers struct {
+	RepositoryId  uuid.UUID
+	SubjectDigest string
+
+	// ArtifactType only returns referrers of the given artifact type.
+	ArtifactType *string
+}
+
+type ListReferrersResponse PagedResponse[ListReferrersResponseItem]
+
+type ListReferrersResponseItem struct {
+	Digest       string
+	MediaType    string
+	Size         int64
+	ArtifactType string
+	Annotations  map[string]string
+}
+
+func HandleListReferrers(ctx context.Context, query ListReferrers) (*ListReferrersResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	referrerFilter := repositories.NewReferrerFilter().ByRepositoryId(query.RepositoryId).BySubjectDigest(query.SubjectDigest)
+	if query.ArtifactType != nil {
+		referrerFilter = referrerFilter.ByArtifactType(*query.ArtifactType)
+	}
+
+	referrers, totalCount, err := dbContext.Referrers().List(ctx, referrerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	manifestIds := make([]uuid.UUID, len(referrers))
+	for i, referrer := range referrers {
+		manifestIds[i] = referrer.GetManifestId()
+	}
+
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter().ByIds(manifestIds))
+	if err != nil {
+		return nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	manifestsById := make(map[uuid.UUID]*repositories.Manifest, len(manifests))
+	blobIds := make([]uuid.UUID, len(manifests))
+	for i, manifest := range manifests {
+		manifestsById[manifest.GetId()] = manifest
+		blobIds[i] = manifest.GetBlobId()
+	}
+
+	blobs, _, err := dbContext.Blobs().List(ctx, repositories.NewBlobFilter().ByIds(blobIds))
+	if err != nil {
+		return nil, fmt.Errorf("listing blobs: %w", err)
+	}
+
+	blobsById := make(map[uuid.UUID]*repositories.Blob, len(blobs))
+	for _, blob := range blobs {
+		blobsById[blob.GetId()] = blob
+	}
+
+	items := make([]ListReferrersResponseItem, len(referrers))
+	for i, referrer := range referrers {
+		manifest, ok := manifestsById[referrer.GetManifestId()]
+		if !ok {
+			return nil, fmt.Errorf("getting manifest %s: %w", referrer.GetManifestId(), apiError.ErrApiManifestNotFound)
+		}
+
+		blob, ok := blobsById[manifest.GetBlobId()]
+		if !ok {
+			return nil, fmt.Errorf("getting blob %s: %w", manifest.GetBlobId(), apiError.ErrApiBlobNotFound)
+		}
+
+		items[i] = ListReferrersResponseItem{
+			Digest:       manifest.GetDigest(),
+			MediaType:    manifest.GetMediaType(),
+			Size:         blob.GetSize(),
+			ArtifactType: referrer.GetArtifactType(),
+			Annotations:  referrer.GetAnnotations(),
+		}
+	}
+
+	return &ListReferrersResponse{
+		Items:      items,
+		TotalCount: totalCount,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+type ListReplicationRules struct {
+	TenantSlug  string
+	ProjectSlug string
+}
+
+type ListReplicationRulesResponse PagedResponse[ListReplicationRulesResponseItem]
+
+type ListReplicationRulesResponseItem struct {
+	Id            uuid.UUID
+	Url           string
+	Namespace     string
+	TagFilter     *string
+	Enabled       bool
+	LastSuccessAt *time.Time
+	LastFailureAt *time.Time
+}
+
+func HandleListReplicationRules(ctx context.Context, query ListReplicationRules) (*ListReplicationRulesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	rules, _, err := dbContext.ReplicationRules().List(ctx, repositories.NewReplicationRuleFilter().ByProjectId(project.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing replication rules: %w", err)
+	}
+
+	items := make([]ListReplicationRulesResponseItem, len(rules))
+	for i, rule := range rules {
+		items[i] = ListReplicationRulesResponseItem{
+			Id:            rule.GetId(),
+			Url:           rule.GetUrl(),
+			Namespace:     rule.GetNamespace(),
+			TagFilter:     rule.GetTagFilter(),
+			Enabled:       rule.GetEnabled(),
+			LastSuccessAt: rule.GetLastSuccessAt(),
+			LastFailureAt: rule.GetLastFailureAt(),
+		}
+	}
+
+	return &ListReplicationRulesResponse{
+		Items: items,
+	}, nil
+}
@@ -0,0 +1,87 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// ListReplicationTasks lists the tags of a rule that are waiting to be replicated or have been given up.
+type ListReplicationTasks struct {
+	TenantSlug  string
+	ProjectSlug string
+	RuleId      uuid.UUID
+}
+
+type ListReplicationTasksResponse PagedResponse[ListReplicationTasksResponseItem]
+
+type ListReplicationTasksResponseItem struct {
+	Id             uuid.UUID
+	RepositorySlug string
+	Tag            string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      *string
+	Failed         bool
+}
+
+func HandleListReplicationTasks(ctx context.Context, query ListReplicationTasks) (*ListReplicationTasksResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	rule, err := dbContext.ReplicationRules().Single(ctx, repositories.NewReplicationRuleFilter().ByProjectId(project.GetId()).ById(query.RuleId))
+	if err != nil {
+		return nil, fmt.Errorf("getting replication rule: %w", err)
+	}
+
+	tasks, totalCount, err := dbContext.ReplicationTasks().List(ctx, repositories.NewReplicationTaskFilter().ByReplicationRuleId(rule.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing replication tasks: %w", err)
+	}
+
+	repositorySlugs := make(map[uuid.UUID]string)
+	items := make([]ListReplicationTasksResponseItem, len(tasks))
+	for i, task := range tasks {
+		repositorySlug, ok := repositorySlugs[task.GetRepositoryId()]
+		if !ok {
+			repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(task.GetRepositoryId()))
+			if err != nil {
+				return nil, fmt.Errorf("getting repository: %w", err)
+			}
+
+			repositorySlug = repository.GetSlug()
+			repositorySlugs[task.GetRepositoryId()] = repositorySlug
+		}
+
+		items[i] = ListReplicationTasksResponseItem{
+			Id:             task.GetId(),
+			RepositorySlug: repositorySlug,
+			Tag:            task.GetTag(),
+			Attempts:       task.GetAttempts(),
+			NextAttemptAt:  task.GetNextAttemptAt(),
+			LastError:      task.GetLastError(),
+			Failed:         task.GetFailed(),
+		}
+	}
+
+	return &ListReplicationTasksResponse{
+		Items:      items,
+		TotalCount: totalCount,
+	}, nil
+}
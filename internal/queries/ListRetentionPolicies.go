@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// ListRetentionPolicies lists the retention policies of a project, or the policies that apply to a repository if
+// RepositorySlug is set. The policies of a repository include the ones inherited from its project.
+type ListRetentionPolicies struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+}
+
+type ListRetentionPoliciesResponse PagedResponse[ListRetentionPoliciesResponseItem]
+
+type ListRetentionPoliciesResponseItem struct {
+	Id              uuid.UUID
+	TagFilter       *string
+	KeepLast        *int
+	OlderThanDays   *int
+	NotPulledInDays *int
+	DeleteUntagged  bool
+	Enabled         bool
+	// Inherited is set for project policies listed for a repository, they can only be changed on the project.
+	Inherited bool
+}
+
+func HandleListRetentionPolicies(ctx context.Context, query ListRetentionPolicies) (*ListRetentionPoliciesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	projectPolicies, _, err := dbContext.RetentionPolicies().List(ctx, repositories.NewRetentionPolicyFilter().ByProjectId(project.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing project retention policies: %w", err)
+	}
+
+	var repositoryPolicies []*repositories.RetentionPolicy
+	if query.RepositorySlug != nil {
+		repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()).BySlug(*query.RepositorySlug))
+		if err != nil {
+			return nil, fmt.Errorf("getting repository: %w", err)
+		}
+
+		repositoryPolicies, _, err = dbContext.RetentionPolicies().List(ctx, repositories.NewRetentionPolicyFilter().ByRepositoryId(repository.GetId()))
+		if err != nil {
+			return nil, fmt.Errorf("listing repository retention policies: %w", err)
+		}
+	}
+
+	items := make([]ListRetentionPoliciesResponseItem, 0, len(projectPolicies)+len(repositoryPolicies))
+	for _, policy := range projectPolicies {
+		items = append(items, mapRetentionPolicy(policy, query.RepositorySlug != nil))
+	}
+
+	for _, policy := range repositoryPolicies {
+		items = append(items, mapRetentionPolicy(policy, false))
+	}
+
+	return &ListRetentionPoliciesResponse{
+		Items:      items,
+		TotalCount: len(items),
+	}, nil
+}
+
+func mapRetentionPolicy(policy *repositories.RetentionPolicy, inherited bool) ListRetentionPoliciesResponseItem {
+	return ListRetentionPoliciesResponseItem{
+		Id:              policy.GetId(),
+		TagFilter:       policy.GetTagFilter(),
+		KeepLast:        policy.GetKeepLast(),
+		OlderThanDays:   policy.GetOlderThanDays(),
+		NotPulledInDays: policy.GetNotPulledInDays(),
+		DeleteUntagged:  policy.GetDeleteUntagged(),
+		Enabled:         policy.GetEnabled(),
+		Inherited:       inherited,
+	}
+}
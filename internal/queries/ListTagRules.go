@@ -0,0 +1,86 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// ListTagRules lists the tag rules of a project, or the rules that apply to a repository if RepositorySlug is set. The
+// rules of a repository include the ones inherited from its project.
+type ListTagRules struct {
+	TenantSlug     string
+	ProjectSlug    string
+	RepositorySlug *string
+}
+
+type ListTagRulesResponse PagedResponse[ListTagRulesResponseItem]
+
+type ListTagRulesResponseItem struct {
+	Id      uuid.UUID
+	Pattern string
+	Kind    string
+	// Inherited is set for project rules listed for a repository, they can only be changed on the project.
+	Inherited bool
+}
+
+func HandleListTagRules(ctx context.Context, query ListTagRules) (*ListTagRulesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(query.ProjectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	projectRules, _, err := dbContext.TagRules().List(ctx, repositories.NewTagRuleFilter().ByProjectId(project.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing project tag rules: %w", err)
+	}
+
+	var repositoryRules []*repositories.TagRule
+	if query.RepositorySlug != nil {
+		repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()).BySlug(*query.RepositorySlug))
+		if err != nil {
+			return nil, fmt.Errorf("getting repository: %w", err)
+		}
+
+		repositoryRules, _, err = dbContext.TagRules().List(ctx, repositories.NewTagRuleFilter().ByRepositoryId(repository.GetId()))
+		if err != nil {
+			return nil, fmt.Errorf("listing repository tag rules: %w", err)
+		}
+	}
+
+	items := make([]ListTagRulesResponseItem, 0, len(projectRules)+len(repositoryRules))
+	for _, tagRule := range projectRules {
+		items = append(items, ListTagRulesResponseItem{
+			Id:        tagRule.GetId(),
+			Pattern:   tagRule.GetPattern(),
+			Kind:      string(tagRule.GetKind()),
+			Inherited: query.RepositorySlug != nil,
+		})
+	}
+
+	for _, tagRule := range repositoryRules {
+		items = append(items, ListTagRulesResponseItem{
+			Id:      tagRule.GetId(),
+			Pattern: tagRule.GetPattern(),
+			Kind:    string(tagRule.GetKind()),
+		})
+	}
+
+	return &ListTagRulesResponse{
+		Items:      items,
+		TotalCount: len(items),
+	}, nil
+}
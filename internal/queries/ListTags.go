@@ -9,12 +9,20 @@ import (
 	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/middlewares"
 	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/storageUsage"
 )
 
 type ListTags struct {
 	TenantSlug     string
 	ProjectSlug    string
 	RepositorySlug string
+
+	// Last only returns tags that sort lexically after the given tag name.
+	Last *string
+	// Limit restricts the number of returned tags, TotalCount still reflects all tags after Last.
+	Limit *int
+	// WithSizes computes the size of every tag, otherwise it is -1.
+	WithSizes bool
 }
 
 type ListTagsResponse PagedResponse[ListTagsResponseItem]
@@ -23,7 +31,8 @@ type ListTagsResponseItem struct {
 	Id     uuid.UUID
 	Name   string
 	Digest string
-	Size   int64
+	// Size counts every blob of the tagged manifest once, including the manifests of an index.
+	Size int64
 }
 
 func HandleListTags(ctx context.Context, query ListTags) (*ListTagsResponse, error) {
@@ -45,22 +54,44 @@ func HandleListTags(ctx context.Context, query ListTags) (*ListTagsResponse, err
 		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
 
-	tags, _, err := dbContext.Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(repository.GetId()).WithManifestInfo())
+	tagFilter := repositories.NewTagFilter().ByRepositoryId(repository.GetId()).WithManifestInfo()
+	if query.Last != nil {
+		tagFilter = tagFilter.ByNameAfter(*query.Last)
+	}
+	if query.Limit != nil {
+		tagFilter = tagFilter.WithLimit(*query.Limit)
+	}
+
+	tags, totalCount, err := dbContext.Tags().List(ctx, tagFilter)
 	if err != nil {
 		return nil, fmt.Errorf("listing tags: %w", err)
 	}
 
+	var sizes map[string]int64
+	if query.WithSizes {
+		sizes, err = storageUsage.GetManifestSizes(ctx, dbContext, repository.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("getting tag sizes: %w", err)
+		}
+	}
+
 	items := make([]ListTagsResponseItem, len(tags))
 	for i, tag := range tags {
+		size := int64(-1)
+		if sizes != nil {
+			size = sizes[tag.GetManifestInfo().Digest]
+		}
+
 		items[i] = ListTagsResponseItem{
 			Id:     tag.GetId(),
 			Name:   tag.GetName(),
 			Digest: tag.GetManifestInfo().Digest,
-			Size:   -1,
+			Size:   size,
 		}
 	}
 
 	return &ListTagsResponse{
-		Items: items,
+		Items:      items,
+		TotalCount: totalCount,
 	}, nil
 }
@@ -0,0 +1,103 @@
+package queries
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/The127/ioc"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+	"github.com/the127/dockyard/internal/supplyChain"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+// SearchPackages finds the images of a tenant whose SBOM lists a package, see supplyChain.Package.Matches.
+type SearchPackages struct {
+	TenantSlug string
+	// Name is the name or the package URL without version of the package.
+	Name string
+	// Version is optional, all versions are found if it is empty.
+	Version string
+
+	// Page is the 1-based page of the matches to return, PageSize the number of matches per page.
+	Page     int
+	PageSize int
+}
+
+type SearchPackagesResponse PagedResponse[SearchPackagesResponseItem]
+
+type SearchPackagesResponseItem struct {
+	supplyChain.PackageMatch
+
+	ProjectSlug    string
+	RepositorySlug string
+}
+
+func HandleSearchPackages(ctx context.Context, query SearchPackages) (*SearchPackagesResponse, error) {
+	if query.Page < 1 || query.PageSize < 1 {
+		return nil, fmt.Errorf("page and page size have to be positive: %w", apiError.ErrApiBadRequest)
+	}
+
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	blobService := ioc.GetDependency[blobStorage.Service](scope)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(query.TenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	projects, _, err := dbContext.Projects().List(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()))
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+
+	items := []SearchPackagesResponseItem{}
+	for _, project := range projects {
+		repos, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()))
+		if err != nil {
+			return nil, fmt.Errorf("listing repositories: %w", err)
+		}
+
+		for _, repository := range repos {
+			matches, err := supplyChain.FindPackage(ctx, dbContext, blobService, kvStore, repository.GetId(), query.Name, query.Version)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, match := range matches {
+				items = append(items, SearchPackagesResponseItem{
+					PackageMatch:   match,
+					ProjectSlug:    project.GetSlug(),
+					RepositorySlug: repository.GetSlug(),
+				})
+			}
+		}
+	}
+
+	// matches are sorted so that the pages do not overlap
+	slices.SortFunc(items, func(a, b SearchPackagesResponseItem) int {
+		return cmp.Or(
+			strings.Compare(a.ProjectSlug, b.ProjectSlug),
+			strings.Compare(a.RepositorySlug, b.RepositorySlug),
+			strings.Compare(a.ManifestDigest, b.ManifestDigest),
+			strings.Compare(a.Package.Name, b.Package.Name),
+			strings.Compare(a.Package.Version, b.Package.Version),
+		)
+	})
+
+	start := min((query.Page-1)*query.PageSize, len(items))
+	end := min(start+query.PageSize, len(items))
+
+	return &SearchPackagesResponse{
+		Items:      items[start:end],
+		TotalCount: len(items),
+	}, nil
+}
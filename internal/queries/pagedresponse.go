@@ -1,5 +1,6 @@
 package queries
 
 type PagedResponse[T any] struct {
-	Items []T
+	Items      []T
+	TotalCount int
 }
@@ -0,0 +1,72 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+// getRepositoryBySlugs looks up a repository by the slugs of its tenant, project and itself.
+func getRepositoryBySlugs(ctx context.Context, dbContext db.Context, tenantSlug string, projectSlug string, repositorySlug string) (*repositories.Repository, error) {
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().BySlug(tenantSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting tenant: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ByTenantId(tenant.GetId()).BySlug(projectSlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ByProjectId(project.GetId()).BySlug(repositorySlug))
+	if err != nil {
+		return nil, fmt.Errorf("getting repository: %w", err)
+	}
+
+	return repository, nil
+}
+
+// checkRepositoryLayer checks that the layer is stored in the repository, so that layers of other tenants cannot be read
+// by guessing their digest.
+func checkRepositoryLayer(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, layerDigest string) error {
+	_, err := dbContext.Blobs().Single(ctx, repositories.NewBlobFilter().ByRepositoryId(repositoryId).ByDigest(layerDigest))
+	if err != nil {
+		return fmt.Errorf("getting layer: %w", err)
+	}
+
+	return nil
+}
+
+// getManifestByReference returns the manifest of the repository the tag or digest refers to. It fails with
+// apiError.ErrApiTagNotFound or apiError.ErrApiManifestNotFound if there is none.
+func getManifestByReference(ctx context.Context, dbContext db.Context, repositoryId uuid.UUID, reference string) (*repositories.Manifest, error) {
+	var manifestFilter *repositories.ManifestFilter
+	if !digest.IsDigest(reference) {
+		tag, err := dbContext.Tags().First(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId).ByName(reference))
+		if err != nil {
+			return nil, fmt.Errorf("getting tag: %w", err)
+		}
+		if tag == nil {
+			return nil, fmt.Errorf("tag '%s': %w", reference, apiError.ErrApiTagNotFound)
+		}
+
+		manifestFilter = repositories.NewManifestFilter().ById(tag.GetRepositoryManifestId())
+	} else {
+		manifestFilter = repositories.NewManifestFilter().ByRepositoryId(repositoryId).ByDigest(reference)
+	}
+
+	manifest, err := dbContext.Manifests().First(ctx, manifestFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("manifest '%s': %w", reference, apiError.ErrApiManifestNotFound)
+	}
+
+	return manifest, nil
+}
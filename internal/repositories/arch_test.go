@@ -22,11 +22,12 @@ var noTrackChangeAllowlist = map[string]bool{
 // insertDeleteOnlyEntities are structs that embed BaseModel but intentionally
 // have no mutable fields and therefore do not need change tracking via change.List.
 var insertDeleteOnlyEntities = map[string]bool{
-	"Blob":           true,
-	"File":           true,
-	"Manifest":       true,
-	"Tag":            true,
-	"RepositoryBlob": true,
+	"Blob":              true,
+	"File":              true,
+	"Manifest":          true,
+	"RepositoryBlob":    true,
+	"Referrer":          true,
+	"ManifestReference": true,
 }
 
 type ChangeListArchTestSuite struct {
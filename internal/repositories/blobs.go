@@ -39,8 +39,12 @@ func (b *Blob) GetSize() int64 {
 }
 
 type BlobFilter struct {
-	id     *uuid.UUID
-	digest *string
+	id           *uuid.UUID
+	ids          []uuid.UUID
+	digest       *string
+	repositoryId *uuid.UUID
+	projectId    *uuid.UUID
+	tenantId     *uuid.UUID
 }
 
 func NewBlobFilter() *BlobFilter {
@@ -66,6 +70,24 @@ func (f *BlobFilter) GetId() uuid.UUID {
 	return pointer.DerefOrZero(f.id)
 }
 
+// ByIds restricts the result to the blobs with one of the ids, no blob matches an empty list.
+func (f *BlobFilter) ByIds(ids []uuid.UUID) *BlobFilter {
+	cloned := f.clone()
+	cloned.ids = ids
+	if cloned.ids == nil {
+		cloned.ids = []uuid.UUID{}
+	}
+	return cloned
+}
+
+func (f *BlobFilter) HasIds() bool {
+	return f.ids != nil
+}
+
+func (f *BlobFilter) GetIds() []uuid.UUID {
+	return f.ids
+}
+
 func (f *BlobFilter) ByDigest(digest string) *BlobFilter {
 	cloned := f.clone()
 	cloned.digest = &digest
@@ -80,10 +102,58 @@ func (f *BlobFilter) GetDigest() string {
 	return pointer.DerefOrZero(f.digest)
 }
 
+// ByRepositoryId restricts the result to blobs linked to the repository.
+func (f *BlobFilter) ByRepositoryId(repositoryId uuid.UUID) *BlobFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &repositoryId
+	return cloned
+}
+
+func (f *BlobFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *BlobFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+// ByProjectId restricts the result to blobs linked to at least one repository of the project. Every blob is returned
+// once, no matter how many repositories it is linked to.
+func (f *BlobFilter) ByProjectId(projectId uuid.UUID) *BlobFilter {
+	cloned := f.clone()
+	cloned.projectId = &projectId
+	return cloned
+}
+
+func (f *BlobFilter) HasProjectId() bool {
+	return f.projectId != nil
+}
+
+func (f *BlobFilter) GetProjectId() uuid.UUID {
+	return pointer.DerefOrZero(f.projectId)
+}
+
+// ByTenantId restricts the result to blobs linked to at least one repository of the tenant. Every blob is returned
+// once, no matter how many repositories it is linked to.
+func (f *BlobFilter) ByTenantId(tenantId uuid.UUID) *BlobFilter {
+	cloned := f.clone()
+	cloned.tenantId = &tenantId
+	return cloned
+}
+
+func (f *BlobFilter) HasTenantId() bool {
+	return f.tenantId != nil
+}
+
+func (f *BlobFilter) GetTenantId() uuid.UUID {
+	return pointer.DerefOrZero(f.tenantId)
+}
+
 type BlobRepository interface {
 	Single(ctx context.Context, filter *BlobFilter) (*Blob, error)
 	First(ctx context.Context, filter *BlobFilter) (*Blob, error)
 	List(ctx context.Context, filter *BlobFilter) ([]*Blob, int, error)
+	SumSizes(ctx context.Context, filter *BlobFilter) (int64, error)
 	Insert(blob *Blob)
 	Delete(blob *Blob)
 }
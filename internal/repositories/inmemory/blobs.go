@@ -3,7 +3,9 @@ package inmemory
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-memdb"
 	"github.com/the127/dockyard/internal/change"
 	"github.com/the127/dockyard/internal/repositories"
@@ -24,14 +26,19 @@ func NewInMemoryBlobRepository(txn *memdb.Txn, changeTracker *change.Tracker, en
 	}
 }
 
-func (r *BlobRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.BlobFilter) ([]*repositories.Blob, int) {
+func (r *BlobRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.BlobFilter) ([]*repositories.Blob, int, error) {
 	var result []*repositories.Blob
 
+	linkedBlobIds, err := r.getLinkedBlobIds(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	obj := iterator.Next()
 	for obj != nil {
 		typed := obj.(repositories.Blob)
 
-		if r.matches(&typed, filter) {
+		if r.matches(&typed, filter) && (linkedBlobIds == nil || linkedBlobIds[typed.GetId()]) {
 			result = append(result, &typed)
 		}
 
@@ -40,7 +47,55 @@ func (r *BlobRepository) applyFilter(iterator memdb.ResultIterator, filter *repo
 
 	count := len(result)
 
-	return result, count
+	return result, count, nil
+}
+
+// getLinkedBlobIds returns the blobs linked to the repositories the filter restricts the result to, or nil if it does
+// not restrict the repositories.
+func (r *BlobRepository) getLinkedBlobIds(filter *repositories.BlobFilter) (map[uuid.UUID]bool, error) {
+	if !filter.HasRepositoryId() && !filter.HasProjectId() && !filter.HasTenantId() {
+		return nil, nil
+	}
+
+	repositoryRepo := NewInMemoryRepositoryRepository(r.txn, r.changeTracker, -1)
+	projectRepo := NewInMemoryProjectRepository(r.txn, r.changeTracker, -1)
+	repositoryBlobRepo := NewInMemoryRepositoryBlobRepository(r.txn, r.changeTracker, -1)
+
+	repositoryBlobs, _, err := repositoryBlobRepo.List(context.Background(), repositories.NewRepositoryBlobFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	linkedBlobIds := make(map[uuid.UUID]bool)
+	for _, repositoryBlob := range repositoryBlobs {
+		if filter.HasRepositoryId() && repositoryBlob.GetRepositoryId() != filter.GetRepositoryId() {
+			continue
+		}
+
+		repository, err := repositoryRepo.Single(context.Background(), repositories.NewRepositoryFilter().ById(repositoryBlob.GetRepositoryId()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository for repository blob %s: %w", repositoryBlob.GetId(), err)
+		}
+
+		if filter.HasProjectId() && repository.GetProjectId() != filter.GetProjectId() {
+			continue
+		}
+
+		if filter.HasTenantId() {
+			project, err := projectRepo.Single(context.Background(), repositories.NewProjectFilter().ById(repository.GetProjectId()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get project for repository %s: %w", repository.GetId(), err)
+			}
+
+			if project.GetTenantId() != filter.GetTenantId() {
+				continue
+			}
+		}
+
+		linkedBlobIds[repositoryBlob.GetBlobId()] = true
+	}
+
+	return linkedBlobIds, nil
 }
 
 func (r *BlobRepository) matches(blob *repositories.Blob, filter *repositories.BlobFilter) bool {
@@ -50,6 +105,12 @@ func (r *BlobRepository) matches(blob *repositories.Blob, filter *repositories.B
 		}
 	}
 
+	if filter.HasIds() {
+		if !slices.Contains(filter.GetIds(), blob.GetId()) {
+			return false
+		}
+	}
+
 	if filter.HasDigest() {
 		if blob.GetDigest() != filter.GetDigest() {
 			return false
@@ -65,7 +126,10 @@ func (r *BlobRepository) First(_ context.Context, filter *repositories.BlobFilte
 		return nil, fmt.Errorf("failed to get blobs: %w", err)
 	}
 
-	result, _ := r.applyFilter(iterator, filter)
+	result, _, err := r.applyFilter(iterator, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(result) == 0 {
 		return nil, nil
@@ -91,9 +155,21 @@ func (r *BlobRepository) List(_ context.Context, filter *repositories.BlobFilter
 		return nil, 0, fmt.Errorf("failed to get blobs: %w", err)
 	}
 
-	result, count := r.applyFilter(iterator, filter)
+	return r.applyFilter(iterator, filter)
+}
 
-	return result, count, nil
+func (r *BlobRepository) SumSizes(ctx context.Context, filter *repositories.BlobFilter) (int64, error) {
+	blobs, _, err := r.List(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, blob := range blobs {
+		size += blob.GetSize()
+	}
+
+	return size, nil
 }
 
 func (r *BlobRepository) Insert(blob *repositories.Blob) {
@@ -114,7 +190,7 @@ func (r *BlobRepository) Delete(blob *repositories.Blob) {
 }
 
 func (r *BlobRepository) ExecuteDelete(tx *memdb.Txn, blob *repositories.Blob) error {
-	err := tx.Delete("blobs", blob)
+	err := tx.Delete("blobs", *blob)
 	if err != nil {
 		return fmt.Errorf("failed to delete blob: %w", err)
 	}
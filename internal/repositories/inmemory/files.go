@@ -114,7 +114,7 @@ func (r *FileRepository) Delete(file *repositories.File) {
 }
 
 func (r *FileRepository) ExecuteDelete(tx *memdb.Txn, file *repositories.File) error {
-	err := tx.Delete("files", file)
+	err := tx.Delete("files", *file)
 	if err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
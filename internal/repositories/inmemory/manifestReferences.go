@@ -0,0 +1,135 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type ManifestReferenceRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryManifestReferenceRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *ManifestReferenceRepository {
+	return &ManifestReferenceRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ManifestReferenceRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.ManifestReferenceFilter) ([]*repositories.ManifestReference, int) {
+	var result []*repositories.ManifestReference
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.ManifestReference)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *ManifestReferenceRepository) matches(manifestReference *repositories.ManifestReference, filter *repositories.ManifestReferenceFilter) bool {
+	if filter.HasId() {
+		if manifestReference.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasRepositoryId() {
+		if manifestReference.GetRepositoryId() != filter.GetRepositoryId() {
+			return false
+		}
+	}
+
+	if filter.HasManifestId() {
+		if manifestReference.GetManifestId() != filter.GetManifestId() {
+			return false
+		}
+	}
+
+	if filter.HasDigest() {
+		if manifestReference.GetDigest() != filter.GetDigest() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *ManifestReferenceRepository) First(_ context.Context, filter *repositories.ManifestReferenceFilter) (*repositories.ManifestReference, error) {
+	iterator, err := r.txn.Get("manifest_references", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest references: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *ManifestReferenceRepository) Single(_ context.Context, filter *repositories.ManifestReferenceFilter) (*repositories.ManifestReference, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiManifestReferenceNotFound
+	}
+	return result, nil
+}
+
+func (r *ManifestReferenceRepository) List(_ context.Context, filter *repositories.ManifestReferenceFilter) ([]*repositories.ManifestReference, int, error) {
+	iterator, err := r.txn.Get("manifest_references", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get manifest references: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *ManifestReferenceRepository) Insert(manifestReference *repositories.ManifestReference) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, manifestReference))
+}
+
+func (r *ManifestReferenceRepository) ExecuteInsert(tx *memdb.Txn, manifestReference *repositories.ManifestReference) error {
+	err := tx.Insert("manifest_references", *manifestReference)
+	if err != nil {
+		return fmt.Errorf("failed to insert manifest reference: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ManifestReferenceRepository) Delete(manifestReference *repositories.ManifestReference) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, manifestReference))
+}
+
+func (r *ManifestReferenceRepository) ExecuteDelete(tx *memdb.Txn, manifestReference *repositories.ManifestReference) error {
+	err := tx.Delete("manifest_references", *manifestReference)
+	if err != nil {
+		return fmt.Errorf("failed to delete manifest reference: %w", err)
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package inmemory
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/hashicorp/go-memdb"
 	"github.com/the127/dockyard/internal/change"
@@ -50,6 +51,12 @@ func (r *ManifestRepository) matches(manifest *repositories.Manifest, filter *re
 		}
 	}
 
+	if filter.HasIds() {
+		if !slices.Contains(filter.GetIds(), manifest.GetId()) {
+			return false
+		}
+	}
+
 	if filter.HasRepositoryId() {
 		if manifest.GetRepositoryId() != filter.GetRepositoryId() {
 			return false
@@ -139,7 +146,7 @@ func (r *ManifestRepository) Delete(manifest *repositories.Manifest) {
 }
 
 func (r *ManifestRepository) ExecuteDelete(tx *memdb.Txn, manifest *repositories.Manifest) error {
-	err := tx.Delete("manifests", manifest)
+	err := tx.Delete("manifests", *manifest)
 	if err != nil {
 		return fmt.Errorf("failed to delete manifest: %w", err)
 	}
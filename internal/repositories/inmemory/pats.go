@@ -131,7 +131,7 @@ func (r *PatRepository) Delete(pat *repositories.Pat) {
 }
 
 func (r *PatRepository) ExecuteDelete(tx *memdb.Txn, pat *repositories.Pat) error {
-	err := tx.Delete("pats", pat)
+	err := tx.Delete("pats", *pat)
 	if err != nil {
 		return fmt.Errorf("failed to delete pat: %w", err)
 	}
@@ -112,7 +112,7 @@ func (r *ProjectAccessRepository) Delete(projectAccess *repositories.ProjectAcce
 }
 
 func (r *ProjectAccessRepository) ExecuteDelete(tx *memdb.Txn, projectAccess *repositories.ProjectAccess) error {
-	err := tx.Delete("project_access", projectAccess)
+	err := tx.Delete("project_access", *projectAccess)
 	if err != nil {
 		return fmt.Errorf("failed to delete project access: %w", err)
 	}
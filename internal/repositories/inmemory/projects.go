@@ -135,7 +135,7 @@ func (r *ProjectRepository) Delete(project *repositories.Project) {
 }
 
 func (r *ProjectRepository) ExecuteDelete(tx *memdb.Txn, project *repositories.Project) error {
-	err := tx.Delete("projects", project)
+	err := tx.Delete("projects", *project)
 	if err != nil {
 		return fmt.Errorf("failed to delete project: %w", err)
 	}
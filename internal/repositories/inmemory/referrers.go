@@ -0,0 +1,141 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type ReferrerRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryReferrerRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *ReferrerRepository {
+	return &ReferrerRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ReferrerRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.ReferrerFilter) ([]*repositories.Referrer, int) {
+	var result []*repositories.Referrer
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.Referrer)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *ReferrerRepository) matches(referrer *repositories.Referrer, filter *repositories.ReferrerFilter) bool {
+	if filter.HasId() {
+		if referrer.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasRepositoryId() {
+		if referrer.GetRepositoryId() != filter.GetRepositoryId() {
+			return false
+		}
+	}
+
+	if filter.HasManifestId() {
+		if referrer.GetManifestId() != filter.GetManifestId() {
+			return false
+		}
+	}
+
+	if filter.HasSubjectDigest() {
+		if referrer.GetSubjectDigest() != filter.GetSubjectDigest() {
+			return false
+		}
+	}
+
+	if filter.HasArtifactType() {
+		if referrer.GetArtifactType() != filter.GetArtifactType() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *ReferrerRepository) First(_ context.Context, filter *repositories.ReferrerFilter) (*repositories.Referrer, error) {
+	iterator, err := r.txn.Get("referrers", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get referrers: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *ReferrerRepository) Single(_ context.Context, filter *repositories.ReferrerFilter) (*repositories.Referrer, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiReferrerNotFound
+	}
+	return result, nil
+}
+
+func (r *ReferrerRepository) List(_ context.Context, filter *repositories.ReferrerFilter) ([]*repositories.Referrer, int, error) {
+	iterator, err := r.txn.Get("referrers", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get referrers: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *ReferrerRepository) Insert(referrer *repositories.Referrer) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, referrer))
+}
+
+func (r *ReferrerRepository) ExecuteInsert(tx *memdb.Txn, referrer *repositories.Referrer) error {
+	err := tx.Insert("referrers", *referrer)
+	if err != nil {
+		return fmt.Errorf("failed to insert referrer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ReferrerRepository) Delete(referrer *repositories.Referrer) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, referrer))
+}
+
+func (r *ReferrerRepository) ExecuteDelete(tx *memdb.Txn, referrer *repositories.Referrer) error {
+	err := tx.Delete("referrers", *referrer)
+	if err != nil {
+		return fmt.Errorf("failed to delete referrer: %w", err)
+	}
+
+	return nil
+}
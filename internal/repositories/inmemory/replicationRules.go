@@ -0,0 +1,144 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type ReplicationRuleRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryReplicationRuleRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *ReplicationRuleRepository {
+	return &ReplicationRuleRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ReplicationRuleRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.ReplicationRuleFilter) ([]*repositories.ReplicationRule, int) {
+	var result []*repositories.ReplicationRule
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.ReplicationRule)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *ReplicationRuleRepository) matches(replicationRule *repositories.ReplicationRule, filter *repositories.ReplicationRuleFilter) bool {
+	if filter.HasId() {
+		if replicationRule.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasProjectId() {
+		if replicationRule.GetProjectId() != filter.GetProjectId() {
+			return false
+		}
+	}
+
+	if filter.HasEnabled() {
+		if replicationRule.GetEnabled() != filter.GetEnabled() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *ReplicationRuleRepository) First(_ context.Context, filter *repositories.ReplicationRuleFilter) (*repositories.ReplicationRule, error) {
+	iterator, err := r.txn.Get("replication_rules", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replication rules: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *ReplicationRuleRepository) Single(_ context.Context, filter *repositories.ReplicationRuleFilter) (*repositories.ReplicationRule, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiReplicationRuleNotFound
+	}
+	return result, nil
+}
+
+func (r *ReplicationRuleRepository) List(_ context.Context, filter *repositories.ReplicationRuleFilter) ([]*repositories.ReplicationRule, int, error) {
+	iterator, err := r.txn.Get("replication_rules", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get replication rules: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *ReplicationRuleRepository) Insert(replicationRule *repositories.ReplicationRule) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, replicationRule))
+}
+
+func (r *ReplicationRuleRepository) ExecuteInsert(tx *memdb.Txn, replicationRule *repositories.ReplicationRule) error {
+	err := tx.Insert("replication_rules", *replicationRule)
+	if err != nil {
+		return fmt.Errorf("failed to insert replication rule: %w", err)
+	}
+
+	replicationRule.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationRuleRepository) Update(replicationRule *repositories.ReplicationRule) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, replicationRule))
+}
+
+func (r *ReplicationRuleRepository) ExecuteUpdate(tx *memdb.Txn, replicationRule *repositories.ReplicationRule) error {
+	err := tx.Insert("replication_rules", *replicationRule)
+	if err != nil {
+		return fmt.Errorf("failed to update replication rule: %w", err)
+	}
+
+	replicationRule.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationRuleRepository) Delete(replicationRule *repositories.ReplicationRule) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, replicationRule))
+}
+
+func (r *ReplicationRuleRepository) ExecuteDelete(tx *memdb.Txn, replicationRule *repositories.ReplicationRule) error {
+	err := tx.Delete("replication_rules", *replicationRule)
+	if err != nil {
+		return fmt.Errorf("failed to delete replication rule: %w", err)
+	}
+
+	return nil
+}
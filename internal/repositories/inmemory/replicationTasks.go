@@ -0,0 +1,171 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type ReplicationTaskRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryReplicationTaskRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *ReplicationTaskRepository {
+	return &ReplicationTaskRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ReplicationTaskRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.ReplicationTaskFilter) ([]*repositories.ReplicationTask, int) {
+	var result []*repositories.ReplicationTask
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.ReplicationTask)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	slices.SortFunc(result, func(a, b *repositories.ReplicationTask) int {
+		return a.GetNextAttemptAt().Compare(b.GetNextAttemptAt())
+	})
+
+	count := len(result)
+
+	if filter.HasLimit() && len(result) > filter.GetLimit() {
+		result = result[:filter.GetLimit()]
+	}
+
+	return result, count
+}
+
+func (r *ReplicationTaskRepository) matches(replicationTask *repositories.ReplicationTask, filter *repositories.ReplicationTaskFilter) bool {
+	if filter.HasId() {
+		if replicationTask.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasReplicationRuleId() {
+		if replicationTask.GetReplicationRuleId() != filter.GetReplicationRuleId() {
+			return false
+		}
+	}
+
+	if filter.HasRepositoryId() {
+		if replicationTask.GetRepositoryId() != filter.GetRepositoryId() {
+			return false
+		}
+	}
+
+	if filter.HasTag() {
+		if replicationTask.GetTag() != filter.GetTag() {
+			return false
+		}
+	}
+
+	if filter.HasFailed() {
+		if replicationTask.GetFailed() != filter.GetFailed() {
+			return false
+		}
+	}
+
+	if filter.HasDueBefore() {
+		if replicationTask.GetNextAttemptAt().After(filter.GetDueBefore()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *ReplicationTaskRepository) First(_ context.Context, filter *repositories.ReplicationTaskFilter) (*repositories.ReplicationTask, error) {
+	iterator, err := r.txn.Get("replication_tasks", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replication tasks: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *ReplicationTaskRepository) Single(_ context.Context, filter *repositories.ReplicationTaskFilter) (*repositories.ReplicationTask, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiReplicationTaskNotFound
+	}
+	return result, nil
+}
+
+func (r *ReplicationTaskRepository) List(_ context.Context, filter *repositories.ReplicationTaskFilter) ([]*repositories.ReplicationTask, int, error) {
+	iterator, err := r.txn.Get("replication_tasks", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get replication tasks: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *ReplicationTaskRepository) Insert(replicationTask *repositories.ReplicationTask) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, replicationTask))
+}
+
+func (r *ReplicationTaskRepository) ExecuteInsert(tx *memdb.Txn, replicationTask *repositories.ReplicationTask) error {
+	err := tx.Insert("replication_tasks", *replicationTask)
+	if err != nil {
+		return fmt.Errorf("failed to insert replication task: %w", err)
+	}
+
+	replicationTask.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationTaskRepository) Update(replicationTask *repositories.ReplicationTask) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, replicationTask))
+}
+
+func (r *ReplicationTaskRepository) ExecuteUpdate(tx *memdb.Txn, replicationTask *repositories.ReplicationTask) error {
+	err := tx.Insert("replication_tasks", *replicationTask)
+	if err != nil {
+		return fmt.Errorf("failed to update replication task: %w", err)
+	}
+
+	replicationTask.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationTaskRepository) Delete(replicationTask *repositories.ReplicationTask) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, replicationTask))
+}
+
+func (r *ReplicationTaskRepository) ExecuteDelete(tx *memdb.Txn, replicationTask *repositories.ReplicationTask) error {
+	err := tx.Delete("replication_tasks", *replicationTask)
+	if err != nil {
+		return fmt.Errorf("failed to delete replication task: %w", err)
+	}
+
+	return nil
+}
@@ -135,7 +135,7 @@ func (r *RepositoryRepository) Delete(repository *repositories.Repository) {
 }
 
 func (r *RepositoryRepository) ExecuteDelete(tx *memdb.Txn, repository *repositories.Repository) error {
-	err := tx.Delete("repositories", repository)
+	err := tx.Delete("repositories", *repository)
 	if err != nil {
 		return fmt.Errorf("failed to delete repository: %w", err)
 	}
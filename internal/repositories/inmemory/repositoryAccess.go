@@ -79,6 +79,17 @@ func (r *RepositoryAccessRepository) First(_ context.Context, filter *repositori
 	return result[0], nil
 }
 
+func (r *RepositoryAccessRepository) List(_ context.Context, filter *repositories.RepositoryAccessFilter) ([]*repositories.RepositoryAccess, int, error) {
+	iterator, err := r.txn.Get("repository_access", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get repository access: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
 func (r *RepositoryAccessRepository) Insert(repositoryAccess *repositories.RepositoryAccess) {
 	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, repositoryAccess))
 }
@@ -112,7 +123,7 @@ func (r *RepositoryAccessRepository) Delete(repositoryAccess *repositories.Repos
 }
 
 func (r *RepositoryAccessRepository) ExecuteDelete(tx *memdb.Txn, repositoryAccess *repositories.RepositoryAccess) error {
-	err := tx.Delete("repository_access", repositoryAccess)
+	err := tx.Delete("repository_access", *repositoryAccess)
 	if err != nil {
 		return fmt.Errorf("failed to delete repository access: %w", err)
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-memdb"
 	"github.com/the127/dockyard/internal/change"
 	"github.com/the127/dockyard/internal/repositories"
@@ -24,14 +25,19 @@ func NewInMemoryRepositoryBlobRepository(txn *memdb.Txn, changeTracker *change.T
 	}
 }
 
-func (r *RepositoryBlobRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.RepositoryBlobFilter) ([]*repositories.RepositoryBlob, int) {
+func (r *RepositoryBlobRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.RepositoryBlobFilter) ([]*repositories.RepositoryBlob, int, error) {
 	var result []*repositories.RepositoryBlob
 
+	sharedBlobIds, err := r.getSharedBlobIds(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	obj := iterator.Next()
 	for obj != nil {
 		typed := obj.(repositories.RepositoryBlob)
 
-		if r.matches(&typed, filter) {
+		if r.matches(&typed, filter) && (sharedBlobIds == nil || sharedBlobIds[typed.GetBlobId()]) {
 			result = append(result, &typed)
 		}
 
@@ -40,7 +46,30 @@ func (r *RepositoryBlobRepository) applyFilter(iterator memdb.ResultIterator, fi
 
 	count := len(result)
 
-	return result, count
+	return result, count, nil
+}
+
+// getSharedBlobIds returns the blobs linked to the repository the filter restricts the result to, or nil if it does
+// not restrict the result to shared blobs.
+func (r *RepositoryBlobRepository) getSharedBlobIds(filter *repositories.RepositoryBlobFilter) (map[uuid.UUID]bool, error) {
+	if !filter.HasSharedWithRepositoryId() {
+		return nil, nil
+	}
+
+	iterator, err := r.txn.Get("repository_blobs", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository blobs: %w", err)
+	}
+
+	sharedBlobIds := make(map[uuid.UUID]bool)
+	for obj := iterator.Next(); obj != nil; obj = iterator.Next() {
+		typed := obj.(repositories.RepositoryBlob)
+		if typed.GetRepositoryId() == filter.GetSharedWithRepositoryId() {
+			sharedBlobIds[typed.GetBlobId()] = true
+		}
+	}
+
+	return sharedBlobIds, nil
 }
 
 func (r *RepositoryBlobRepository) matches(repositoryBlob *repositories.RepositoryBlob, filter *repositories.RepositoryBlobFilter) bool {
@@ -71,7 +100,10 @@ func (r *RepositoryBlobRepository) First(_ context.Context, filter *repositories
 		return nil, fmt.Errorf("failed to get repository blobs: %w", err)
 	}
 
-	result, _ := r.applyFilter(iterator, filter)
+	result, _, err := r.applyFilter(iterator, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(result) == 0 {
 		return nil, nil
@@ -97,9 +129,7 @@ func (r *RepositoryBlobRepository) List(_ context.Context, filter *repositories.
 		return nil, 0, fmt.Errorf("failed to get repository blobs: %w", err)
 	}
 
-	result, count := r.applyFilter(iterator, filter)
-
-	return result, count, nil
+	return r.applyFilter(iterator, filter)
 }
 
 func (r *RepositoryBlobRepository) Insert(repositoryBlob *repositories.RepositoryBlob) {
@@ -107,7 +137,22 @@ func (r *RepositoryBlobRepository) Insert(repositoryBlob *repositories.Repositor
 }
 
 func (r *RepositoryBlobRepository) ExecuteInsert(tx *memdb.Txn, repositoryBlob *repositories.RepositoryBlob) error {
-	err := tx.Insert("repository_blobs", *repositoryBlob)
+	// a blob is linked to a repository at most once, linking it again only refreshes the link, so that the garbage
+	// collector treats it as recently uploaded
+	existing, err := tx.First("repository_blobs", "repository_blob", repositoryBlob.GetRepositoryId().String(), repositoryBlob.GetBlobId().String())
+	if err != nil {
+		return fmt.Errorf("failed to get repository blob: %w", err)
+	}
+	if existing != nil {
+		existingRepositoryBlob := existing.(repositories.RepositoryBlob)
+		repositoryBlob = repositories.NewRepositoryBlobFromDB(
+			existingRepositoryBlob.GetRepositoryId(),
+			existingRepositoryBlob.GetBlobId(),
+			repositories.NewBaseModelFromDB(existingRepositoryBlob.GetId(), existingRepositoryBlob.GetCreatedAt(), repositoryBlob.GetUpdatedAt(), existingRepositoryBlob.GetVersion()),
+		)
+	}
+
+	err = tx.Insert("repository_blobs", *repositoryBlob)
 	if err != nil {
 		return fmt.Errorf("failed to insert repository blob: %w", err)
 	}
@@ -120,7 +165,7 @@ func (r *RepositoryBlobRepository) Delete(repositoryBlob *repositories.Repositor
 }
 
 func (r *RepositoryBlobRepository) ExecuteDelete(tx *memdb.Txn, repositoryBlob *repositories.RepositoryBlob) error {
-	err := tx.Delete("repository_blobs", repositoryBlob)
+	err := tx.Delete("repository_blobs", *repositoryBlob)
 	if err != nil {
 		return fmt.Errorf("failed to delete repository blob: %w", err)
 	}
@@ -0,0 +1,151 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type RetentionPolicyRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryRetentionPolicyRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *RetentionPolicyRepository {
+	return &RetentionPolicyRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *RetentionPolicyRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.RetentionPolicyFilter) ([]*repositories.RetentionPolicy, int) {
+	var result []*repositories.RetentionPolicy
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.RetentionPolicy)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *RetentionPolicyRepository) matches(retentionPolicy *repositories.RetentionPolicy, filter *repositories.RetentionPolicyFilter) bool {
+	if filter.HasId() {
+		if retentionPolicy.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasProjectId() {
+		if !pointer.Equal(retentionPolicy.GetProjectId(), pointer.To(filter.GetProjectId())) {
+			return false
+		}
+	}
+
+	if filter.HasRepositoryId() {
+		if !pointer.Equal(retentionPolicy.GetRepositoryId(), pointer.To(filter.GetRepositoryId())) {
+			return false
+		}
+	}
+
+	if filter.HasEnabled() {
+		if retentionPolicy.GetEnabled() != filter.GetEnabled() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *RetentionPolicyRepository) First(_ context.Context, filter *repositories.RetentionPolicyFilter) (*repositories.RetentionPolicy, error) {
+	iterator, err := r.txn.Get("retention_policies", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention policies: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *RetentionPolicyRepository) Single(_ context.Context, filter *repositories.RetentionPolicyFilter) (*repositories.RetentionPolicy, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiRetentionPolicyNotFound
+	}
+	return result, nil
+}
+
+func (r *RetentionPolicyRepository) List(_ context.Context, filter *repositories.RetentionPolicyFilter) ([]*repositories.RetentionPolicy, int, error) {
+	iterator, err := r.txn.Get("retention_policies", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get retention policies: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *RetentionPolicyRepository) Insert(retentionPolicy *repositories.RetentionPolicy) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, retentionPolicy))
+}
+
+func (r *RetentionPolicyRepository) ExecuteInsert(tx *memdb.Txn, retentionPolicy *repositories.RetentionPolicy) error {
+	err := tx.Insert("retention_policies", *retentionPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to insert retention policy: %w", err)
+	}
+
+	retentionPolicy.ClearChanges()
+	return nil
+}
+
+func (r *RetentionPolicyRepository) Update(retentionPolicy *repositories.RetentionPolicy) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, retentionPolicy))
+}
+
+func (r *RetentionPolicyRepository) ExecuteUpdate(tx *memdb.Txn, retentionPolicy *repositories.RetentionPolicy) error {
+	err := tx.Insert("retention_policies", *retentionPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to update retention policy: %w", err)
+	}
+
+	retentionPolicy.ClearChanges()
+	return nil
+}
+
+func (r *RetentionPolicyRepository) Delete(retentionPolicy *repositories.RetentionPolicy) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, retentionPolicy))
+}
+
+func (r *RetentionPolicyRepository) ExecuteDelete(tx *memdb.Txn, retentionPolicy *repositories.RetentionPolicy) error {
+	err := tx.Delete("retention_policies", *retentionPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+
+	return nil
+}
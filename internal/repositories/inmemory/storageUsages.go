@@ -0,0 +1,156 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type StorageUsageRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryStorageUsageRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *StorageUsageRepository {
+	return &StorageUsageRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *StorageUsageRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.StorageUsageFilter) ([]*repositories.StorageUsage, int) {
+	var result []*repositories.StorageUsage
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.StorageUsage)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *StorageUsageRepository) matches(storageUsage *repositories.StorageUsage, filter *repositories.StorageUsageFilter) bool {
+	if filter.HasId() {
+		if storageUsage.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasKind() {
+		if storageUsage.GetKind() != filter.GetKind() {
+			return false
+		}
+	}
+
+	if filter.HasOwnerId() {
+		if storageUsage.GetOwnerId() != filter.GetOwnerId() {
+			return false
+		}
+	}
+
+	if filter.HasTenantId() {
+		if storageUsage.GetTenantId() != filter.GetTenantId() {
+			return false
+		}
+	}
+
+	if filter.HasProjectId() {
+		if storageUsage.GetProjectId() == nil || *storageUsage.GetProjectId() != filter.GetProjectId() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *StorageUsageRepository) First(_ context.Context, filter *repositories.StorageUsageFilter) (*repositories.StorageUsage, error) {
+	iterator, err := r.txn.Get("storage_usages", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage usages: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *StorageUsageRepository) Single(_ context.Context, filter *repositories.StorageUsageFilter) (*repositories.StorageUsage, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiStorageUsageNotFound
+	}
+	return result, nil
+}
+
+func (r *StorageUsageRepository) List(_ context.Context, filter *repositories.StorageUsageFilter) ([]*repositories.StorageUsage, int, error) {
+	iterator, err := r.txn.Get("storage_usages", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get storage usages: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *StorageUsageRepository) Insert(storageUsage *repositories.StorageUsage) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, storageUsage))
+}
+
+func (r *StorageUsageRepository) ExecuteInsert(tx *memdb.Txn, storageUsage *repositories.StorageUsage) error {
+	err := tx.Insert("storage_usages", *storageUsage)
+	if err != nil {
+		return fmt.Errorf("failed to insert storage usage: %w", err)
+	}
+
+	storageUsage.ClearChanges()
+	return nil
+}
+
+func (r *StorageUsageRepository) Update(storageUsage *repositories.StorageUsage) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, storageUsage))
+}
+
+func (r *StorageUsageRepository) ExecuteUpdate(tx *memdb.Txn, storageUsage *repositories.StorageUsage) error {
+	err := tx.Insert("storage_usages", *storageUsage)
+	if err != nil {
+		return fmt.Errorf("failed to update storage usage: %w", err)
+	}
+
+	storageUsage.ClearChanges()
+	return nil
+}
+
+func (r *StorageUsageRepository) Delete(storageUsage *repositories.StorageUsage) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, storageUsage))
+}
+
+func (r *StorageUsageRepository) ExecuteDelete(tx *memdb.Txn, storageUsage *repositories.StorageUsage) error {
+	err := tx.Delete("storage_usages", *storageUsage)
+	if err != nil {
+		return fmt.Errorf("failed to delete storage usage: %w", err)
+	}
+
+	return nil
+}
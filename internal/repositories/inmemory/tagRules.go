@@ -0,0 +1,145 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type TagRuleRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryTagRuleRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *TagRuleRepository {
+	return &TagRuleRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *TagRuleRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.TagRuleFilter) ([]*repositories.TagRule, int) {
+	var result []*repositories.TagRule
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.TagRule)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *TagRuleRepository) matches(tagRule *repositories.TagRule, filter *repositories.TagRuleFilter) bool {
+	if filter.HasId() {
+		if tagRule.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasProjectId() {
+		if !pointer.Equal(tagRule.GetProjectId(), pointer.To(filter.GetProjectId())) {
+			return false
+		}
+	}
+
+	if filter.HasRepositoryId() {
+		if !pointer.Equal(tagRule.GetRepositoryId(), pointer.To(filter.GetRepositoryId())) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *TagRuleRepository) First(_ context.Context, filter *repositories.TagRuleFilter) (*repositories.TagRule, error) {
+	iterator, err := r.txn.Get("tag_rules", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag rules: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *TagRuleRepository) Single(_ context.Context, filter *repositories.TagRuleFilter) (*repositories.TagRule, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiTagRuleNotFound
+	}
+	return result, nil
+}
+
+func (r *TagRuleRepository) List(_ context.Context, filter *repositories.TagRuleFilter) ([]*repositories.TagRule, int, error) {
+	iterator, err := r.txn.Get("tag_rules", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get tag rules: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *TagRuleRepository) Insert(tagRule *repositories.TagRule) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, tagRule))
+}
+
+func (r *TagRuleRepository) ExecuteInsert(tx *memdb.Txn, tagRule *repositories.TagRule) error {
+	err := tx.Insert("tag_rules", *tagRule)
+	if err != nil {
+		return fmt.Errorf("failed to insert tag rule: %w", err)
+	}
+
+	tagRule.ClearChanges()
+	return nil
+}
+
+func (r *TagRuleRepository) Update(tagRule *repositories.TagRule) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, tagRule))
+}
+
+func (r *TagRuleRepository) ExecuteUpdate(tx *memdb.Txn, tagRule *repositories.TagRule) error {
+	err := tx.Insert("tag_rules", *tagRule)
+	if err != nil {
+		return fmt.Errorf("failed to update tag rule: %w", err)
+	}
+
+	tagRule.ClearChanges()
+	return nil
+}
+
+func (r *TagRuleRepository) Delete(tagRule *repositories.TagRule) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, tagRule))
+}
+
+func (r *TagRuleRepository) ExecuteDelete(tx *memdb.Txn, tagRule *repositories.TagRule) error {
+	err := tx.Delete("tag_rules", *tagRule)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag rule: %w", err)
+	}
+
+	return nil
+}
@@ -3,6 +3,8 @@ package inmemory
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/hashicorp/go-memdb"
 	"github.com/the127/dockyard/internal/change"
@@ -50,8 +52,16 @@ func (r *TagRepository) applyFilter(iterator memdb.ResultIterator, filter *repos
 		obj = iterator.Next()
 	}
 
+	slices.SortFunc(result, func(a, b *repositories.Tag) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+
 	count := len(result)
 
+	if filter.HasLimit() && len(result) > filter.GetLimit() {
+		result = result[:filter.GetLimit()]
+	}
+
 	return result, count, nil
 }
 
@@ -74,6 +84,12 @@ func (r *TagRepository) matches(tag *repositories.Tag, filter *repositories.TagF
 		}
 	}
 
+	if filter.HasNameAfter() {
+		if tag.GetName() <= filter.GetNameAfter() {
+			return false
+		}
+	}
+
 	if filter.HasRepositoryManifestId() {
 		if tag.GetRepositoryManifestId() != filter.GetRepositoryManifestId() {
 			return false
@@ -131,11 +147,36 @@ func (r *TagRepository) Insert(tag *repositories.Tag) {
 }
 
 func (r *TagRepository) ExecuteInsert(tx *memdb.Txn, tag *repositories.Tag) error {
-	err := tx.Insert("tags", *tag)
+	// tags are unique per repository and name, pushing an existing tag moves it to the new manifest
+	existing, err := tx.First("tags", "repository_name", tag.GetRepositoryId().String(), tag.GetName())
+	if err != nil {
+		return fmt.Errorf("failed to get existing tag: %w", err)
+	}
+	if existing != nil {
+		existingTag := existing.(repositories.Tag)
+		tag.SetId(existingTag.GetId())
+	}
+
+	err = tx.Insert("tags", *tag)
 	if err != nil {
 		return fmt.Errorf("failed to insert tag: %w", err)
 	}
 
+	tag.ClearChanges()
+	return nil
+}
+
+func (r *TagRepository) Update(tag *repositories.Tag) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, tag))
+}
+
+func (r *TagRepository) ExecuteUpdate(tx *memdb.Txn, tag *repositories.Tag) error {
+	err := tx.Insert("tags", *tag)
+	if err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	tag.ClearChanges()
 	return nil
 }
 
@@ -144,7 +185,7 @@ func (r *TagRepository) Delete(tag *repositories.Tag) {
 }
 
 func (r *TagRepository) ExecuteDelete(tx *memdb.Txn, tag *repositories.Tag) error {
-	err := tx.Delete("tags", tag)
+	err := tx.Delete("tags", *tag)
 	if err != nil {
 		return fmt.Errorf("failed to delete tag: %w", err)
 	}
@@ -0,0 +1,171 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	db "github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/database/inmemory"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type TagRepositoryTestSuite struct {
+	suite.Suite
+	database db.Database
+}
+
+func TestTagRepositoryTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(TagRepositoryTestSuite))
+}
+
+func (s *TagRepositoryTestSuite) SetupTest() {
+	database, err := inmemory.NewInMemoryDatabase()
+	s.Require().NoError(err)
+	s.database = database
+}
+
+func (s *TagRepositoryTestSuite) newContext() db.Context {
+	dbContext, err := s.database.NewContext(context.Background())
+	s.Require().NoError(err)
+
+	return dbContext
+}
+
+// insertTags stores a tag for every name, all of them pointing to the same manifest.
+func (s *TagRepositoryTestSuite) insertTags(repositoryId uuid.UUID, names ...string) {
+	dbContext := s.newContext()
+	manifestId := uuid.New()
+	for _, name := range names {
+		dbContext.Tags().Insert(repositories.NewTag(repositoryId, manifestId, name))
+	}
+
+	s.Require().NoError(dbContext.SaveChanges(context.Background()))
+}
+
+func tagNames(tags []*repositories.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.GetName()
+	}
+
+	return names
+}
+
+func (s *TagRepositoryTestSuite) TestList_Pagination() {
+	repositoryId := uuid.New()
+	// byte order, like the "C" collation the postgres repository sorts with: digits, upper case, underscore, lower case
+	s.insertTags(repositoryId, "v1.9", "latest", "V2", "_build", "1.0", "v1.10", "Latest")
+	s.insertTags(uuid.New(), "other")
+
+	testCases := []struct {
+		name       string
+		last       *string
+		limit      *int
+		expected   []string
+		totalCount int
+	}{
+		{
+			name:       "all tags",
+			expected:   []string{"1.0", "Latest", "V2", "_build", "latest", "v1.10", "v1.9"},
+			totalCount: 7,
+		},
+		{
+			name:       "first page",
+			limit:      pointer.To(3),
+			expected:   []string{"1.0", "Latest", "V2"},
+			totalCount: 7,
+		},
+		{
+			name:       "next page",
+			last:       pointer.To("V2"),
+			limit:      pointer.To(3),
+			expected:   []string{"_build", "latest", "v1.10"},
+			totalCount: 4,
+		},
+		{
+			name:       "last page",
+			last:       pointer.To("v1.10"),
+			limit:      pointer.To(3),
+			expected:   []string{"v1.9"},
+			totalCount: 1,
+		},
+		{
+			name:       "after a tag that does not exist",
+			last:       pointer.To("a"),
+			expected:   []string{"latest", "v1.10", "v1.9"},
+			totalCount: 3,
+		},
+		{
+			name:       "after the last tag",
+			last:       pointer.To("v1.9"),
+			limit:      pointer.To(3),
+			expected:   []string{},
+			totalCount: 0,
+		},
+		{
+			name:       "empty page",
+			limit:      pointer.To(0),
+			expected:   []string{},
+			totalCount: 7,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			filter := repositories.NewTagFilter().ByRepositoryId(repositoryId)
+			if testCase.last != nil {
+				filter = filter.ByNameAfter(*testCase.last)
+			}
+			if testCase.limit != nil {
+				filter = filter.WithLimit(*testCase.limit)
+			}
+
+			// act
+			tags, totalCount, err := s.newContext().Tags().List(context.Background(), filter)
+
+			// assert
+			s.Require().NoError(err)
+			s.Equal(testCase.expected, tagNames(tags))
+			s.Equal(testCase.totalCount, totalCount)
+		})
+	}
+}
+
+func (s *TagRepositoryTestSuite) TestInsert_MovesExistingTag() {
+	// arrange
+	ctx := context.Background()
+	repositoryId := uuid.New()
+	otherRepositoryId := uuid.New()
+	s.insertTags(repositoryId, "latest")
+	s.insertTags(otherRepositoryId, "latest")
+
+	existing, err := s.newContext().Tags().Single(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId).ByName("latest"))
+	s.Require().NoError(err)
+
+	other, err := s.newContext().Tags().Single(ctx, repositories.NewTagFilter().ByRepositoryId(otherRepositoryId).ByName("latest"))
+	s.Require().NoError(err)
+
+	// act
+	dbContext := s.newContext()
+	manifestId := uuid.New()
+	dbContext.Tags().Insert(repositories.NewTag(repositoryId, manifestId, "latest"))
+	s.Require().NoError(dbContext.SaveChanges(ctx))
+
+	// assert
+	tags, _, err := s.newContext().Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId))
+	s.Require().NoError(err)
+	s.Require().Len(tags, 1)
+	s.Equal(existing.GetId(), tags[0].GetId())
+	s.Equal(manifestId, tags[0].GetRepositoryManifestId())
+
+	otherTags, _, err := s.newContext().Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(otherRepositoryId))
+	s.Require().NoError(err)
+	s.Require().Len(otherTags, 1)
+	s.Equal(other.GetId(), otherTags[0].GetId())
+	s.Equal(other.GetRepositoryManifestId(), otherTags[0].GetRepositoryManifestId(), "tags of other repositories are kept")
+}
@@ -115,7 +115,7 @@ func (r *TenantRepository) Update(tenant *repositories.Tenant) {
 }
 
 func (r *TenantRepository) ExecuteUpdate(tx *memdb.Txn, tenant *repositories.Tenant) error {
-	err := r.txn.Insert("tenants", *tenant)
+	err := tx.Insert("tenants", *tenant)
 	if err != nil {
 		return fmt.Errorf("failed to insert tenant: %w", err)
 	}
@@ -129,7 +129,7 @@ func (r *TenantRepository) Delete(tenant *repositories.Tenant) {
 }
 
 func (r *TenantRepository) ExecuteDelete(tx *memdb.Txn, tenant *repositories.Tenant) error {
-	err := r.txn.Delete("tenants", tenant)
+	err := tx.Delete("tenants", *tenant)
 	if err != nil {
 		return fmt.Errorf("failed to delete tenant: %w", err)
 	}
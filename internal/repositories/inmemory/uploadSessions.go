@@ -0,0 +1,153 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type UploadSessionRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryUploadSessionRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *UploadSessionRepository {
+	return &UploadSessionRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *UploadSessionRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.UploadSessionFilter) ([]*repositories.UploadSession, int) {
+	var result []*repositories.UploadSession
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.UploadSession)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	slices.SortFunc(result, func(a, b *repositories.UploadSession) int {
+		return a.GetExpiresAt().Compare(b.GetExpiresAt())
+	})
+
+	count := len(result)
+
+	if filter.HasLimit() && len(result) > filter.GetLimit() {
+		result = result[:filter.GetLimit()]
+	}
+
+	return result, count
+}
+
+func (r *UploadSessionRepository) matches(uploadSession *repositories.UploadSession, filter *repositories.UploadSessionFilter) bool {
+	if filter.HasId() {
+		if uploadSession.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasSessionId() {
+		if uploadSession.GetSessionId() != filter.GetSessionId() {
+			return false
+		}
+	}
+
+	if filter.HasExpiredBefore() {
+		if uploadSession.GetExpiresAt().After(filter.GetExpiredBefore()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *UploadSessionRepository) First(_ context.Context, filter *repositories.UploadSessionFilter) (*repositories.UploadSession, error) {
+	iterator, err := r.txn.Get("upload_sessions", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload sessions: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *UploadSessionRepository) Single(_ context.Context, filter *repositories.UploadSessionFilter) (*repositories.UploadSession, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiUploadSessionNotFound
+	}
+	return result, nil
+}
+
+func (r *UploadSessionRepository) List(_ context.Context, filter *repositories.UploadSessionFilter) ([]*repositories.UploadSession, int, error) {
+	iterator, err := r.txn.Get("upload_sessions", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get upload sessions: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *UploadSessionRepository) Insert(uploadSession *repositories.UploadSession) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, uploadSession))
+}
+
+func (r *UploadSessionRepository) ExecuteInsert(tx *memdb.Txn, uploadSession *repositories.UploadSession) error {
+	err := tx.Insert("upload_sessions", *uploadSession)
+	if err != nil {
+		return fmt.Errorf("failed to insert upload session: %w", err)
+	}
+
+	uploadSession.ClearChanges()
+	return nil
+}
+
+func (r *UploadSessionRepository) Update(uploadSession *repositories.UploadSession) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, uploadSession))
+}
+
+func (r *UploadSessionRepository) ExecuteUpdate(tx *memdb.Txn, uploadSession *repositories.UploadSession) error {
+	err := tx.Insert("upload_sessions", *uploadSession)
+	if err != nil {
+		return fmt.Errorf("failed to update upload session: %w", err)
+	}
+
+	uploadSession.ClearChanges()
+	return nil
+}
+
+func (r *UploadSessionRepository) Delete(uploadSession *repositories.UploadSession) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, uploadSession))
+}
+
+func (r *UploadSessionRepository) ExecuteDelete(tx *memdb.Txn, uploadSession *repositories.UploadSession) error {
+	err := tx.Delete("upload_sessions", *uploadSession)
+	if err != nil {
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+
+	return nil
+}
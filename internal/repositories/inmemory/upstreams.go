@@ -0,0 +1,145 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type UpstreamRepository struct {
+	txn           *memdb.Txn
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewInMemoryUpstreamRepository(txn *memdb.Txn, changeTracker *change.Tracker, entityType int) *UpstreamRepository {
+	return &UpstreamRepository{
+		txn:           txn,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *UpstreamRepository) applyFilter(iterator memdb.ResultIterator, filter *repositories.UpstreamFilter) ([]*repositories.Upstream, int) {
+	var result []*repositories.Upstream
+
+	obj := iterator.Next()
+	for obj != nil {
+		typed := obj.(repositories.Upstream)
+
+		if r.matches(&typed, filter) {
+			result = append(result, &typed)
+		}
+
+		obj = iterator.Next()
+	}
+
+	count := len(result)
+
+	return result, count
+}
+
+func (r *UpstreamRepository) matches(upstream *repositories.Upstream, filter *repositories.UpstreamFilter) bool {
+	if filter.HasId() {
+		if upstream.GetId() != filter.GetId() {
+			return false
+		}
+	}
+
+	if filter.HasProjectId() {
+		if !pointer.Equal(upstream.GetProjectId(), pointer.To(filter.GetProjectId())) {
+			return false
+		}
+	}
+
+	if filter.HasRepositoryId() {
+		if !pointer.Equal(upstream.GetRepositoryId(), pointer.To(filter.GetRepositoryId())) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *UpstreamRepository) First(_ context.Context, filter *repositories.UpstreamFilter) (*repositories.Upstream, error) {
+	iterator, err := r.txn.Get("upstreams", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstreams: %w", err)
+	}
+
+	result, _ := r.applyFilter(iterator, filter)
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result[0], nil
+}
+
+func (r *UpstreamRepository) Single(_ context.Context, filter *repositories.UpstreamFilter) (*repositories.Upstream, error) {
+	result, err := r.First(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiUpstreamNotFound
+	}
+	return result, nil
+}
+
+func (r *UpstreamRepository) List(_ context.Context, filter *repositories.UpstreamFilter) ([]*repositories.Upstream, int, error) {
+	iterator, err := r.txn.Get("upstreams", "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get upstreams: %w", err)
+	}
+
+	result, count := r.applyFilter(iterator, filter)
+
+	return result, count, nil
+}
+
+func (r *UpstreamRepository) Insert(upstream *repositories.Upstream) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, upstream))
+}
+
+func (r *UpstreamRepository) ExecuteInsert(tx *memdb.Txn, upstream *repositories.Upstream) error {
+	err := tx.Insert("upstreams", *upstream)
+	if err != nil {
+		return fmt.Errorf("failed to insert upstream: %w", err)
+	}
+
+	upstream.ClearChanges()
+	return nil
+}
+
+func (r *UpstreamRepository) Update(upstream *repositories.Upstream) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, upstream))
+}
+
+func (r *UpstreamRepository) ExecuteUpdate(tx *memdb.Txn, upstream *repositories.Upstream) error {
+	err := tx.Insert("upstreams", *upstream)
+	if err != nil {
+		return fmt.Errorf("failed to update upstream: %w", err)
+	}
+
+	upstream.ClearChanges()
+	return nil
+}
+
+func (r *UpstreamRepository) Delete(upstream *repositories.Upstream) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, upstream))
+}
+
+func (r *UpstreamRepository) ExecuteDelete(tx *memdb.Txn, upstream *repositories.Upstream) error {
+	err := tx.Delete("upstreams", *upstream)
+	if err != nil {
+		return fmt.Errorf("failed to delete upstream: %w", err)
+	}
+
+	return nil
+}
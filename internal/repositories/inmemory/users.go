@@ -136,7 +136,7 @@ func (r *UserRepository) Delete(user *repositories.User) {
 }
 
 func (r *UserRepository) ExecuteDelete(tx *memdb.Txn, user *repositories.User) error {
-	err := tx.Delete("users", user)
+	err := tx.Delete("users", *user)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+// ManifestReference records that a manifest references a blob or, in case of an image index, another manifest through
+// one of its descriptors. The references form the graph that is walked to find out which blobs are still in use.
+type ManifestReference struct {
+	BaseModel
+
+	repositoryId uuid.UUID
+	manifestId   uuid.UUID
+
+	digest    string
+	mediaType string
+}
+
+func NewManifestReference(repositoryId uuid.UUID, manifestId uuid.UUID, digest string, mediaType string) *ManifestReference {
+	return &ManifestReference{
+		BaseModel:    NewBaseModel(),
+		repositoryId: repositoryId,
+		manifestId:   manifestId,
+		digest:       digest,
+		mediaType:    mediaType,
+	}
+}
+
+func NewManifestReferenceFromDB(repositoryId uuid.UUID, manifestId uuid.UUID, digest string, mediaType string, base BaseModel) *ManifestReference {
+	return &ManifestReference{
+		BaseModel:    base,
+		repositoryId: repositoryId,
+		manifestId:   manifestId,
+		digest:       digest,
+		mediaType:    mediaType,
+	}
+}
+
+func (r *ManifestReference) GetRepositoryId() uuid.UUID {
+	return r.repositoryId
+}
+
+// GetManifestId returns the id of the referencing (parent) manifest.
+func (r *ManifestReference) GetManifestId() uuid.UUID {
+	return r.manifestId
+}
+
+// GetDigest returns the digest of the referenced blob or child manifest.
+func (r *ManifestReference) GetDigest() string {
+	return r.digest
+}
+
+func (r *ManifestReference) GetMediaType() string {
+	return r.mediaType
+}
+
+type ManifestReferenceFilter struct {
+	id           *uuid.UUID
+	repositoryId *uuid.UUID
+	manifestId   *uuid.UUID
+	digest       *string
+}
+
+func NewManifestReferenceFilter() *ManifestReferenceFilter {
+	return &ManifestReferenceFilter{}
+}
+
+func (f *ManifestReferenceFilter) clone() *ManifestReferenceFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *ManifestReferenceFilter) ById(id uuid.UUID) *ManifestReferenceFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *ManifestReferenceFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *ManifestReferenceFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *ManifestReferenceFilter) ByRepositoryId(id uuid.UUID) *ManifestReferenceFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &id
+	return cloned
+}
+
+func (f *ManifestReferenceFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *ManifestReferenceFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+func (f *ManifestReferenceFilter) ByManifestId(id uuid.UUID) *ManifestReferenceFilter {
+	cloned := f.clone()
+	cloned.manifestId = &id
+	return cloned
+}
+
+func (f *ManifestReferenceFilter) HasManifestId() bool {
+	return f.manifestId != nil
+}
+
+func (f *ManifestReferenceFilter) GetManifestId() uuid.UUID {
+	return pointer.DerefOrZero(f.manifestId)
+}
+
+func (f *ManifestReferenceFilter) ByDigest(digest string) *ManifestReferenceFilter {
+	cloned := f.clone()
+	cloned.digest = &digest
+	return cloned
+}
+
+func (f *ManifestReferenceFilter) HasDigest() bool {
+	return f.digest != nil
+}
+
+func (f *ManifestReferenceFilter) GetDigest() string {
+	return pointer.DerefOrZero(f.digest)
+}
+
+type ManifestReferenceRepository interface {
+	Single(ctx context.Context, filter *ManifestReferenceFilter) (*ManifestReference, error)
+	First(ctx context.Context, filter *ManifestReferenceFilter) (*ManifestReference, error)
+	List(ctx context.Context, filter *ManifestReferenceFilter) ([]*ManifestReference, int, error)
+	Insert(manifestReference *ManifestReference)
+	Delete(manifestReference *ManifestReference)
+}
@@ -55,6 +55,7 @@ func (m *Manifest) GetMediaType() string {
 
 type ManifestFilter struct {
 	id           *uuid.UUID
+	ids          []uuid.UUID
 	repositoryId *uuid.UUID
 	blobId       *uuid.UUID
 	digest       *string
@@ -83,6 +84,24 @@ func (f *ManifestFilter) GetId() uuid.UUID {
 	return pointer.DerefOrZero(f.id)
 }
 
+// ByIds restricts the result to the manifests with one of the ids, no manifest matches an empty list.
+func (f *ManifestFilter) ByIds(ids []uuid.UUID) *ManifestFilter {
+	cloned := f.clone()
+	cloned.ids = ids
+	if cloned.ids == nil {
+		cloned.ids = []uuid.UUID{}
+	}
+	return cloned
+}
+
+func (f *ManifestFilter) HasIds() bool {
+	return f.ids != nil
+}
+
+func (f *ManifestFilter) GetIds() []uuid.UUID {
+	return f.ids
+}
+
 func (f *ManifestFilter) ByBlobId(id uuid.UUID) *ManifestFilter {
 	cloned := f.clone()
 	cloned.blobId = &id
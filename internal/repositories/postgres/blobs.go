@@ -75,7 +75,12 @@ func (r *BlobRepository) selectQuery(filter *repositories.BlobFilter) *sqlbuilde
 		"blobs.digest",
 		"blobs.size",
 	).From("blobs")
+	r.applyFilter(s, filter)
 
+	return s
+}
+
+func (r *BlobRepository) applyFilter(s *sqlbuilder.SelectBuilder, filter *repositories.BlobFilter) {
 	if filter.HasDigest() {
 		s.Where(s.Equal("blobs.digest", filter.GetDigest()))
 	}
@@ -84,7 +89,30 @@ func (r *BlobRepository) selectQuery(filter *repositories.BlobFilter) *sqlbuilde
 		s.Where(s.Equal("blobs.id", filter.GetId()))
 	}
 
-	return s
+	if filter.HasIds() {
+		s.Where(inIds(s, "blobs.id", filter.GetIds()))
+	}
+
+	if filter.HasRepositoryId() {
+		linked := sqlbuilder.Select("repository_blobs.blob_id").From("repository_blobs")
+		linked.Where(linked.Equal("repository_blobs.repository_id", filter.GetRepositoryId()))
+		s.Where(s.In("blobs.id", linked))
+	}
+
+	if filter.HasProjectId() {
+		linked := sqlbuilder.Select("repository_blobs.blob_id").From("repository_blobs")
+		linked.JoinWithOption(sqlbuilder.InnerJoin, "repositories", "repositories.id = repository_blobs.repository_id")
+		linked.Where(linked.Equal("repositories.project_id", filter.GetProjectId()))
+		s.Where(s.In("blobs.id", linked))
+	}
+
+	if filter.HasTenantId() {
+		linked := sqlbuilder.Select("repository_blobs.blob_id").From("repository_blobs")
+		linked.JoinWithOption(sqlbuilder.InnerJoin, "repositories", "repositories.id = repository_blobs.repository_id")
+		linked.JoinWithOption(sqlbuilder.InnerJoin, "projects", "projects.id = repositories.project_id")
+		linked.Where(linked.Equal("projects.tenant_id", filter.GetTenantId()))
+		s.Where(s.In("blobs.id", linked))
+	}
 }
 
 func (r *BlobRepository) First(ctx context.Context, filter *repositories.BlobFilter) (*repositories.Blob, error) {
@@ -144,6 +172,23 @@ func (r *BlobRepository) List(ctx context.Context, filter *repositories.BlobFilt
 	return blobs, totalCount, nil
 }
 
+func (r *BlobRepository) SumSizes(ctx context.Context, filter *repositories.BlobFilter) (int64, error) {
+	s := sqlbuilder.Select("coalesce(sum(blobs.size), 0)").From("blobs")
+	r.applyFilter(s, filter)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	var size int64
+	err := row.Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return size, nil
+}
+
 func (r *BlobRepository) Insert(blob *repositories.Blob) {
 	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, blob))
 }
@@ -189,7 +234,7 @@ func (r *BlobRepository) Delete(blob *repositories.Blob) {
 }
 
 func (r *BlobRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, blob *repositories.Blob) error {
-	s := sqlbuilder.DeleteFrom("blob")
+	s := sqlbuilder.DeleteFrom("blobs")
 	s.Where(s.Equal("id", blob.GetId()))
 
 	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresManifestReference struct {
+	postgresBaseModel
+	repositoryId uuid.UUID
+	manifestId   uuid.UUID
+	digest       string
+	mediaType    string
+}
+
+func mapManifestReference(manifestReference *repositories.ManifestReference) *postgresManifestReference {
+	return &postgresManifestReference{
+		postgresBaseModel: mapBase(manifestReference.BaseModel),
+		repositoryId:      manifestReference.GetRepositoryId(),
+		manifestId:        manifestReference.GetManifestId(),
+		digest:            manifestReference.GetDigest(),
+		mediaType:         manifestReference.GetMediaType(),
+	}
+}
+
+func (r *postgresManifestReference) Map() *repositories.ManifestReference {
+	return repositories.NewManifestReferenceFromDB(
+		r.repositoryId,
+		r.manifestId,
+		r.digest,
+		r.mediaType,
+		r.MapBase(),
+	)
+}
+
+func (r *postgresManifestReference) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&r.id,
+		&r.createdAt,
+		&r.updatedAt,
+		&r.xmin,
+		&r.repositoryId,
+		&r.manifestId,
+		&r.digest,
+		&r.mediaType,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type ManifestReferenceRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresManifestReferenceRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *ManifestReferenceRepository {
+	return &ManifestReferenceRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ManifestReferenceRepository) selectQuery(filter *repositories.ManifestReferenceFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"manifest_references.id",
+		"manifest_references.created_at",
+		"manifest_references.updated_at",
+		"manifest_references.xmin",
+		"manifest_references.repository_id",
+		"manifest_references.manifest_id",
+		"manifest_references.digest",
+		"manifest_references.media_type",
+	).From("manifest_references")
+
+	if filter.HasId() {
+		s.Where(s.Equal("manifest_references.id", filter.GetId()))
+	}
+
+	if filter.HasRepositoryId() {
+		s.Where(s.Equal("manifest_references.repository_id", filter.GetRepositoryId()))
+	}
+
+	if filter.HasManifestId() {
+		s.Where(s.Equal("manifest_references.manifest_id", filter.GetManifestId()))
+	}
+
+	if filter.HasDigest() {
+		s.Where(s.Equal("manifest_references.digest", filter.GetDigest()))
+	}
+
+	return s
+}
+
+func (r *ManifestReferenceRepository) First(ctx context.Context, filter *repositories.ManifestReferenceFilter) (*repositories.ManifestReference, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	manifestReference := &postgresManifestReference{}
+	err := manifestReference.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return manifestReference.Map(), nil
+}
+
+func (r *ManifestReferenceRepository) Single(ctx context.Context, filter *repositories.ManifestReferenceFilter) (*repositories.ManifestReference, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiManifestReferenceNotFound
+	}
+	return result, nil
+}
+
+func (r *ManifestReferenceRepository) List(ctx context.Context, filter *repositories.ManifestReferenceFilter) ([]*repositories.ManifestReference, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+	s.OrderBy("manifest_references.created_at")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var manifest_references []*repositories.ManifestReference
+	var totalCount int
+	for rows.Next() {
+		manifestReference := &postgresManifestReference{}
+		err := manifestReference.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+		manifest_references = append(manifest_references, manifestReference.Map())
+	}
+
+	return manifest_references, totalCount, nil
+}
+
+func (r *ManifestReferenceRepository) Insert(manifestReference *repositories.ManifestReference) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, manifestReference))
+}
+
+func (r *ManifestReferenceRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, manifestReference *repositories.ManifestReference) error {
+	mapped := mapManifestReference(manifestReference)
+
+	s := sqlbuilder.InsertInto("manifest_references").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"repository_id",
+			"manifest_id",
+			"digest",
+			"media_type",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.repositoryId,
+			mapped.manifestId,
+			mapped.digest,
+			mapped.mediaType,
+		)
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting manifest reference: %w", err)
+	}
+
+	manifestReference.SetVersion(xmin)
+	return nil
+}
+
+func (r *ManifestReferenceRepository) Delete(manifestReference *repositories.ManifestReference) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, manifestReference))
+}
+
+func (r *ManifestReferenceRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, manifestReference *repositories.ManifestReference) error {
+	s := sqlbuilder.DeleteFrom("manifest_references")
+	s.Where(s.Equal("id", manifestReference.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("deleting manifest reference: %w", err)
+	}
+
+	return nil
+}
@@ -97,6 +97,10 @@ func (r *ManifestRepository) selectQuery(filter *repositories.ManifestFilter) *s
 		s.Where(s.Equal("manifests.id", filter.GetId()))
 	}
 
+	if filter.HasIds() {
+		s.Where(inIds(s, "manifests.id", filter.GetIds()))
+	}
+
 	if filter.HasBlobId() {
 		s.Where(s.Equal("manifests.blob_id", filter.GetBlobId()))
 	}
@@ -212,7 +216,7 @@ func (r *ManifestRepository) Delete(manifest *repositories.Manifest) {
 }
 
 func (r *ManifestRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, manifest *repositories.Manifest) error {
-	s := sqlbuilder.DeleteFrom("manifest")
+	s := sqlbuilder.DeleteFrom("manifests")
 	s.Where(s.Equal("id", manifest.GetId()))
 
 	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
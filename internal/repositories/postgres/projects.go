@@ -17,10 +17,11 @@ import (
 
 type postgresProject struct {
 	postgresBaseModel
-	tenantId    uuid.UUID
-	slug        string
-	displayName string
-	description *string
+	tenantId     uuid.UUID
+	slug         string
+	displayName  string
+	description  *string
+	storageQuota *int64
 }
 
 func mapProject(p *repositories.Project) *postgresProject {
@@ -30,6 +31,7 @@ func mapProject(p *repositories.Project) *postgresProject {
 		slug:              p.GetSlug(),
 		displayName:       p.GetDisplayName(),
 		description:       p.GetDescription(),
+		storageQuota:      p.GetStorageQuota(),
 	}
 }
 
@@ -39,6 +41,7 @@ func (p *postgresProject) Map() *repositories.Project {
 		p.slug,
 		p.displayName,
 		p.description,
+		p.storageQuota,
 		p.MapBase(),
 	)
 }
@@ -53,6 +56,7 @@ func (p *postgresProject) scan(row RowScanner, totalCount *int) error {
 		&p.slug,
 		&p.displayName,
 		&p.description,
+		&p.storageQuota,
 	}
 	if totalCount != nil {
 		ptrs = append(ptrs, totalCount)
@@ -84,6 +88,7 @@ func (r *ProjectRepository) selectQuery(filter *repositories.ProjectFilter) *sql
 		"projects.slug",
 		"projects.display_name",
 		"projects.description",
+		"projects.storage_quota",
 	).From("projects")
 
 	if filter.HasId() {
@@ -174,6 +179,7 @@ func (r *ProjectRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, proje
 			"slug",
 			"display_name",
 			"description",
+			"storage_quota",
 		).
 		Values(
 			mapped.id,
@@ -183,6 +189,7 @@ func (r *ProjectRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, proje
 			mapped.slug,
 			mapped.displayName,
 			mapped.description,
+			mapped.storageQuota,
 		)
 
 	s.Returning("xmin")
@@ -224,6 +231,8 @@ func (r *ProjectRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, proje
 			s.SetMore(s.Assign("display_name", mapped.displayName))
 		case repositories.ProjectChangeDescription:
 			s.SetMore(s.Assign("description", mapped.description))
+		case repositories.ProjectChangeStorageQuota:
+			s.SetMore(s.Assign("storage_quota", mapped.storageQuota))
 		default:
 			panic(fmt.Errorf("unknown project change: %d", field))
 		}
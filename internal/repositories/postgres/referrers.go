@@ -0,0 +1,249 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq/hstore"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresReferrer struct {
+	postgresBaseModel
+	repositoryId  uuid.UUID
+	manifestId    uuid.UUID
+	subjectDigest string
+	artifactType  string
+	annotations   hstore.Hstore
+}
+
+func mapReferrer(referrer *repositories.Referrer) *postgresReferrer {
+	annotations := hstore.Hstore{
+		Map: make(map[string]sql.NullString),
+	}
+
+	for k, v := range referrer.GetAnnotations() {
+		annotations.Map[k] = sql.NullString{String: v, Valid: true}
+	}
+
+	return &postgresReferrer{
+		postgresBaseModel: mapBase(referrer.BaseModel),
+		repositoryId:      referrer.GetRepositoryId(),
+		manifestId:        referrer.GetManifestId(),
+		subjectDigest:     referrer.GetSubjectDigest(),
+		artifactType:      referrer.GetArtifactType(),
+		annotations:       annotations,
+	}
+}
+
+func (r *postgresReferrer) Map() *repositories.Referrer {
+	annotations := make(map[string]string)
+	for k, v := range r.annotations.Map {
+		annotations[k] = v.String
+	}
+
+	return repositories.NewReferrerFromDB(
+		r.repositoryId,
+		r.manifestId,
+		r.subjectDigest,
+		r.artifactType,
+		annotations,
+		r.MapBase(),
+	)
+}
+
+func (r *postgresReferrer) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&r.id,
+		&r.createdAt,
+		&r.updatedAt,
+		&r.xmin,
+		&r.repositoryId,
+		&r.manifestId,
+		&r.subjectDigest,
+		&r.artifactType,
+		&r.annotations,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type ReferrerRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresReferrerRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *ReferrerRepository {
+	return &ReferrerRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ReferrerRepository) selectQuery(filter *repositories.ReferrerFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"referrers.id",
+		"referrers.created_at",
+		"referrers.updated_at",
+		"referrers.xmin",
+		"referrers.repository_id",
+		"referrers.manifest_id",
+		"referrers.subject_digest",
+		"referrers.artifact_type",
+		"referrers.annotations",
+	).From("referrers")
+
+	if filter.HasId() {
+		s.Where(s.Equal("referrers.id", filter.GetId()))
+	}
+
+	if filter.HasRepositoryId() {
+		s.Where(s.Equal("referrers.repository_id", filter.GetRepositoryId()))
+	}
+
+	if filter.HasManifestId() {
+		s.Where(s.Equal("referrers.manifest_id", filter.GetManifestId()))
+	}
+
+	if filter.HasSubjectDigest() {
+		s.Where(s.Equal("referrers.subject_digest", filter.GetSubjectDigest()))
+	}
+
+	if filter.HasArtifactType() {
+		s.Where(s.Equal("referrers.artifact_type", filter.GetArtifactType()))
+	}
+
+	return s
+}
+
+func (r *ReferrerRepository) First(ctx context.Context, filter *repositories.ReferrerFilter) (*repositories.Referrer, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	referrer := &postgresReferrer{}
+	err := referrer.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return referrer.Map(), nil
+}
+
+func (r *ReferrerRepository) Single(ctx context.Context, filter *repositories.ReferrerFilter) (*repositories.Referrer, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiReferrerNotFound
+	}
+	return result, nil
+}
+
+func (r *ReferrerRepository) List(ctx context.Context, filter *repositories.ReferrerFilter) ([]*repositories.Referrer, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+	s.OrderBy("referrers.created_at")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var referrers []*repositories.Referrer
+	var totalCount int
+	for rows.Next() {
+		referrer := &postgresReferrer{}
+		err := referrer.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+		referrers = append(referrers, referrer.Map())
+	}
+
+	return referrers, totalCount, nil
+}
+
+func (r *ReferrerRepository) Insert(referrer *repositories.Referrer) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, referrer))
+}
+
+func (r *ReferrerRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, referrer *repositories.Referrer) error {
+	mapped := mapReferrer(referrer)
+
+	s := sqlbuilder.InsertInto("referrers").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"repository_id",
+			"manifest_id",
+			"subject_digest",
+			"artifact_type",
+			"annotations",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.repositoryId,
+			mapped.manifestId,
+			mapped.subjectDigest,
+			mapped.artifactType,
+			mapped.annotations,
+		)
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting referrer: %w", err)
+	}
+
+	referrer.SetVersion(xmin)
+	return nil
+}
+
+func (r *ReferrerRepository) Delete(referrer *repositories.Referrer) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, referrer))
+}
+
+func (r *ReferrerRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, referrer *repositories.Referrer) error {
+	s := sqlbuilder.DeleteFrom("referrers")
+	s.Where(s.Equal("id", referrer.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("deleting referrer: %w", err)
+	}
+
+	return nil
+}
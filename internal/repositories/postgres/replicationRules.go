@@ -0,0 +1,326 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresReplicationRule struct {
+	postgresBaseModel
+	projectId         uuid.UUID
+	url               string
+	namespace         string
+	tagFilter         *string
+	username          *string
+	encryptedPassword []byte
+	enabled           bool
+	lastSuccessAt     *time.Time
+	lastFailureAt     *time.Time
+	lastError         *string
+}
+
+func mapReplicationRule(replicationRule *repositories.ReplicationRule) *postgresReplicationRule {
+	return &postgresReplicationRule{
+		postgresBaseModel: mapBase(replicationRule.BaseModel),
+		projectId:         replicationRule.GetProjectId(),
+		url:               replicationRule.GetUrl(),
+		namespace:         replicationRule.GetNamespace(),
+		tagFilter:         replicationRule.GetTagFilter(),
+		username:          replicationRule.GetUsername(),
+		encryptedPassword: replicationRule.GetEncryptedPassword(),
+		enabled:           replicationRule.GetEnabled(),
+		lastSuccessAt:     replicationRule.GetLastSuccessAt(),
+		lastFailureAt:     replicationRule.GetLastFailureAt(),
+		lastError:         replicationRule.GetLastError(),
+	}
+}
+
+func (r *postgresReplicationRule) Map() *repositories.ReplicationRule {
+	return repositories.NewReplicationRuleFromDB(
+		r.projectId,
+		r.url,
+		r.namespace,
+		r.tagFilter,
+		r.username,
+		r.encryptedPassword,
+		r.enabled,
+		r.lastSuccessAt,
+		r.lastFailureAt,
+		r.lastError,
+		r.MapBase(),
+	)
+}
+
+func (r *postgresReplicationRule) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&r.id,
+		&r.createdAt,
+		&r.updatedAt,
+		&r.xmin,
+		&r.projectId,
+		&r.url,
+		&r.namespace,
+		&r.tagFilter,
+		&r.username,
+		&r.encryptedPassword,
+		&r.enabled,
+		&r.lastSuccessAt,
+		&r.lastFailureAt,
+		&r.lastError,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type ReplicationRuleRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresReplicationRuleRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *ReplicationRuleRepository {
+	return &ReplicationRuleRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ReplicationRuleRepository) selectQuery(filter *repositories.ReplicationRuleFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"replication_rules.id",
+		"replication_rules.created_at",
+		"replication_rules.updated_at",
+		"replication_rules.xmin",
+		"replication_rules.project_id",
+		"replication_rules.url",
+		"replication_rules.namespace",
+		"replication_rules.tag_filter",
+		"replication_rules.username",
+		"replication_rules.encrypted_password",
+		"replication_rules.enabled",
+		"replication_rules.last_success_at",
+		"replication_rules.last_failure_at",
+		"replication_rules.last_error",
+	).From("replication_rules")
+
+	if filter.HasId() {
+		s.Where(s.Equal("replication_rules.id", filter.GetId()))
+	}
+
+	if filter.HasProjectId() {
+		s.Where(s.Equal("replication_rules.project_id", filter.GetProjectId()))
+	}
+
+	if filter.HasEnabled() {
+		s.Where(s.Equal("replication_rules.enabled", filter.GetEnabled()))
+	}
+
+	return s
+}
+
+func (r *ReplicationRuleRepository) First(ctx context.Context, filter *repositories.ReplicationRuleFilter) (*repositories.ReplicationRule, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	replicationRule := &postgresReplicationRule{}
+	err := replicationRule.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return replicationRule.Map(), nil
+}
+
+func (r *ReplicationRuleRepository) Single(ctx context.Context, filter *repositories.ReplicationRuleFilter) (*repositories.ReplicationRule, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiReplicationRuleNotFound
+	}
+	return result, nil
+}
+
+func (r *ReplicationRuleRepository) List(ctx context.Context, filter *repositories.ReplicationRuleFilter) ([]*repositories.ReplicationRule, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var replicationRules []*repositories.ReplicationRule
+	var totalCount int
+	for rows.Next() {
+		replicationRule := &postgresReplicationRule{}
+		err := replicationRule.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		replicationRules = append(replicationRules, replicationRule.Map())
+	}
+
+	return replicationRules, totalCount, nil
+}
+
+func (r *ReplicationRuleRepository) Insert(replicationRule *repositories.ReplicationRule) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, replicationRule))
+}
+
+func (r *ReplicationRuleRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, replicationRule *repositories.ReplicationRule) error {
+	mapped := mapReplicationRule(replicationRule)
+
+	s := sqlbuilder.InsertInto("replication_rules").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"project_id",
+			"url",
+			"namespace",
+			"tag_filter",
+			"username",
+			"encrypted_password",
+			"enabled",
+			"last_success_at",
+			"last_failure_at",
+			"last_error",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.projectId,
+			mapped.url,
+			mapped.namespace,
+			mapped.tagFilter,
+			mapped.username,
+			mapped.encryptedPassword,
+			mapped.enabled,
+			mapped.lastSuccessAt,
+			mapped.lastFailureAt,
+			mapped.lastError,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting replication rule: %w", err)
+	}
+
+	replicationRule.SetVersion(xmin)
+	replicationRule.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationRuleRepository) Update(replicationRule *repositories.ReplicationRule) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, replicationRule))
+}
+
+func (r *ReplicationRuleRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, replicationRule *repositories.ReplicationRule) error {
+	if !replicationRule.HasChanges() {
+		return nil
+	}
+
+	mapped := mapReplicationRule(replicationRule)
+
+	s := sqlbuilder.Update("replication_rules")
+	s.Where(s.Equal("id", replicationRule.GetId()))
+	s.Where(s.Equal("xmin", replicationRule.GetVersion()))
+
+	for _, field := range replicationRule.GetChanges() {
+		switch field {
+		case repositories.ReplicationRuleChangeUrl:
+			s.SetMore(s.Assign("url", mapped.url))
+		case repositories.ReplicationRuleChangeNamespace:
+			s.SetMore(s.Assign("namespace", mapped.namespace))
+		case repositories.ReplicationRuleChangeTagFilter:
+			s.SetMore(s.Assign("tag_filter", mapped.tagFilter))
+		case repositories.ReplicationRuleChangeCredentials:
+			s.SetMore(s.Assign("username", mapped.username))
+			s.SetMore(s.Assign("encrypted_password", mapped.encryptedPassword))
+		case repositories.ReplicationRuleChangeEnabled:
+			s.SetMore(s.Assign("enabled", mapped.enabled))
+		case repositories.ReplicationRuleChangeStatus:
+			s.SetMore(s.Assign("last_success_at", mapped.lastSuccessAt))
+			s.SetMore(s.Assign("last_failure_at", mapped.lastFailureAt))
+			s.SetMore(s.Assign("last_error", mapped.lastError))
+
+		default:
+			panic(fmt.Errorf("unknown replication rule change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating replication rule: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating replication rule: %w", err)
+	}
+
+	replicationRule.SetVersion(xmin)
+	replicationRule.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationRuleRepository) Delete(replicationRule *repositories.ReplicationRule) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, replicationRule))
+}
+
+func (r *ReplicationRuleRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, replicationRule *repositories.ReplicationRule) error {
+	s := sqlbuilder.DeleteFrom("replication_rules")
+	s.Where(s.Equal("id", replicationRule.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
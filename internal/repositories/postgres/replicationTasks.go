@@ -0,0 +1,312 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresReplicationTask struct {
+	postgresBaseModel
+	replicationRuleId uuid.UUID
+	repositoryId      uuid.UUID
+	tag               string
+	attempts          int
+	nextAttemptAt     time.Time
+	lastError         *string
+	failed            bool
+}
+
+func mapReplicationTask(replicationTask *repositories.ReplicationTask) *postgresReplicationTask {
+	return &postgresReplicationTask{
+		postgresBaseModel: mapBase(replicationTask.BaseModel),
+		replicationRuleId: replicationTask.GetReplicationRuleId(),
+		repositoryId:      replicationTask.GetRepositoryId(),
+		tag:               replicationTask.GetTag(),
+		attempts:          replicationTask.GetAttempts(),
+		nextAttemptAt:     replicationTask.GetNextAttemptAt(),
+		lastError:         replicationTask.GetLastError(),
+		failed:            replicationTask.GetFailed(),
+	}
+}
+
+func (t *postgresReplicationTask) Map() *repositories.ReplicationTask {
+	return repositories.NewReplicationTaskFromDB(
+		t.replicationRuleId,
+		t.repositoryId,
+		t.tag,
+		t.attempts,
+		t.nextAttemptAt,
+		t.lastError,
+		t.failed,
+		t.MapBase(),
+	)
+}
+
+func (t *postgresReplicationTask) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&t.id,
+		&t.createdAt,
+		&t.updatedAt,
+		&t.xmin,
+		&t.replicationRuleId,
+		&t.repositoryId,
+		&t.tag,
+		&t.attempts,
+		&t.nextAttemptAt,
+		&t.lastError,
+		&t.failed,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type ReplicationTaskRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresReplicationTaskRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *ReplicationTaskRepository {
+	return &ReplicationTaskRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ReplicationTaskRepository) selectQuery(filter *repositories.ReplicationTaskFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"replication_tasks.id",
+		"replication_tasks.created_at",
+		"replication_tasks.updated_at",
+		"replication_tasks.xmin",
+		"replication_tasks.replication_rule_id",
+		"replication_tasks.repository_id",
+		"replication_tasks.tag",
+		"replication_tasks.attempts",
+		"replication_tasks.next_attempt_at",
+		"replication_tasks.last_error",
+		"replication_tasks.failed",
+	).From("replication_tasks")
+
+	if filter.HasId() {
+		s.Where(s.Equal("replication_tasks.id", filter.GetId()))
+	}
+
+	if filter.HasReplicationRuleId() {
+		s.Where(s.Equal("replication_tasks.replication_rule_id", filter.GetReplicationRuleId()))
+	}
+
+	if filter.HasRepositoryId() {
+		s.Where(s.Equal("replication_tasks.repository_id", filter.GetRepositoryId()))
+	}
+
+	if filter.HasTag() {
+		s.Where(s.Equal("replication_tasks.tag", filter.GetTag()))
+	}
+
+	if filter.HasFailed() {
+		s.Where(s.Equal("replication_tasks.failed", filter.GetFailed()))
+	}
+
+	if filter.HasDueBefore() {
+		s.Where(s.LessEqualThan("replication_tasks.next_attempt_at", filter.GetDueBefore()))
+	}
+
+	return s
+}
+
+func (r *ReplicationTaskRepository) First(ctx context.Context, filter *repositories.ReplicationTaskFilter) (*repositories.ReplicationTask, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	replicationTask := &postgresReplicationTask{}
+	err := replicationTask.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return replicationTask.Map(), nil
+}
+
+func (r *ReplicationTaskRepository) Single(ctx context.Context, filter *repositories.ReplicationTaskFilter) (*repositories.ReplicationTask, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiReplicationTaskNotFound
+	}
+	return result, nil
+}
+
+func (r *ReplicationTaskRepository) List(ctx context.Context, filter *repositories.ReplicationTaskFilter) ([]*repositories.ReplicationTask, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+	s.OrderBy("replication_tasks.next_attempt_at")
+
+	if filter.HasLimit() {
+		s.Limit(filter.GetLimit())
+	}
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var replicationTasks []*repositories.ReplicationTask
+	var totalCount int
+	for rows.Next() {
+		replicationTask := &postgresReplicationTask{}
+		err := replicationTask.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		replicationTasks = append(replicationTasks, replicationTask.Map())
+	}
+
+	return replicationTasks, totalCount, nil
+}
+
+func (r *ReplicationTaskRepository) Insert(replicationTask *repositories.ReplicationTask) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, replicationTask))
+}
+
+func (r *ReplicationTaskRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, replicationTask *repositories.ReplicationTask) error {
+	mapped := mapReplicationTask(replicationTask)
+
+	s := sqlbuilder.InsertInto("replication_tasks").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"replication_rule_id",
+			"repository_id",
+			"tag",
+			"attempts",
+			"next_attempt_at",
+			"last_error",
+			"failed",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.replicationRuleId,
+			mapped.repositoryId,
+			mapped.tag,
+			mapped.attempts,
+			mapped.nextAttemptAt,
+			mapped.lastError,
+			mapped.failed,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting replication task: %w", err)
+	}
+
+	replicationTask.SetVersion(xmin)
+	replicationTask.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationTaskRepository) Update(replicationTask *repositories.ReplicationTask) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, replicationTask))
+}
+
+func (r *ReplicationTaskRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, replicationTask *repositories.ReplicationTask) error {
+	if !replicationTask.HasChanges() {
+		return nil
+	}
+
+	mapped := mapReplicationTask(replicationTask)
+
+	s := sqlbuilder.Update("replication_tasks")
+	s.Where(s.Equal("id", replicationTask.GetId()))
+	s.Where(s.Equal("xmin", replicationTask.GetVersion()))
+
+	for _, field := range replicationTask.GetChanges() {
+		switch field {
+		case repositories.ReplicationTaskChangeAttempt:
+			s.SetMore(s.Assign("attempts", mapped.attempts))
+			s.SetMore(s.Assign("next_attempt_at", mapped.nextAttemptAt))
+			s.SetMore(s.Assign("last_error", mapped.lastError))
+			s.SetMore(s.Assign("failed", mapped.failed))
+
+		default:
+			panic(fmt.Errorf("unknown replication task change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating replication task: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating replication task: %w", err)
+	}
+
+	replicationTask.SetVersion(xmin)
+	replicationTask.ClearChanges()
+	return nil
+}
+
+func (r *ReplicationTaskRepository) Delete(replicationTask *repositories.ReplicationTask) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, replicationTask))
+}
+
+func (r *ReplicationTaskRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, replicationTask *repositories.ReplicationTask) error {
+	s := sqlbuilder.DeleteFrom("replication_tasks")
+	s.Where(s.Equal("id", replicationTask.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
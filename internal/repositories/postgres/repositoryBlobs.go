@@ -77,7 +77,7 @@ func (r *RepositoryBlobRepository) selectQuery(filter *repositories.RepositoryBl
 	).From("repository_blobs")
 
 	if filter.HasId() {
-		s.Where(s.Equal("project_accesses.id", filter.GetId()))
+		s.Where(s.Equal("repository_blobs.id", filter.GetId()))
 	}
 
 	if filter.HasRepositoryId() {
@@ -88,6 +88,12 @@ func (r *RepositoryBlobRepository) selectQuery(filter *repositories.RepositoryBl
 		s.Where(s.Equal("repository_blobs.blob_id", filter.GetBlobId()))
 	}
 
+	if filter.HasSharedWithRepositoryId() {
+		shared := sqlbuilder.Select("shared.blob_id").From("repository_blobs shared")
+		shared.Where(shared.Equal("shared.repository_id", filter.GetSharedWithRepositoryId()))
+		s.Where(s.In("repository_blobs.blob_id", shared))
+	}
+
 	return s
 }
 
@@ -171,7 +177,8 @@ func (r *RepositoryBlobRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx
 			mapped.blobId,
 		)
 
-	s.SQL("ON CONFLICT (repository_id, blob_id) DO NOTHING")
+	// linking a blob again refreshes the link, so that the garbage collector treats it as recently uploaded
+	s.SQL("ON CONFLICT (repository_id, blob_id) DO UPDATE SET updated_at = EXCLUDED.updated_at")
 	s.Returning("xmin")
 
 	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
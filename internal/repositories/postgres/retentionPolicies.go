@@ -0,0 +1,310 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresRetentionPolicy struct {
+	postgresBaseModel
+	projectId       *uuid.UUID
+	repositoryId    *uuid.UUID
+	tagFilter       *string
+	keepLast        *int
+	olderThanDays   *int
+	notPulledInDays *int
+	deleteUntagged  bool
+	enabled         bool
+}
+
+func mapRetentionPolicy(retentionPolicy *repositories.RetentionPolicy) *postgresRetentionPolicy {
+	return &postgresRetentionPolicy{
+		postgresBaseModel: mapBase(retentionPolicy.BaseModel),
+		projectId:         retentionPolicy.GetProjectId(),
+		repositoryId:      retentionPolicy.GetRepositoryId(),
+		tagFilter:         retentionPolicy.GetTagFilter(),
+		keepLast:          retentionPolicy.GetKeepLast(),
+		olderThanDays:     retentionPolicy.GetOlderThanDays(),
+		notPulledInDays:   retentionPolicy.GetNotPulledInDays(),
+		deleteUntagged:    retentionPolicy.GetDeleteUntagged(),
+		enabled:           retentionPolicy.GetEnabled(),
+	}
+}
+
+func (p *postgresRetentionPolicy) Map() *repositories.RetentionPolicy {
+	return repositories.NewRetentionPolicyFromDB(
+		p.projectId,
+		p.repositoryId,
+		p.tagFilter,
+		p.keepLast,
+		p.olderThanDays,
+		p.notPulledInDays,
+		p.deleteUntagged,
+		p.enabled,
+		p.MapBase(),
+	)
+}
+
+func (p *postgresRetentionPolicy) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&p.id,
+		&p.createdAt,
+		&p.updatedAt,
+		&p.xmin,
+		&p.projectId,
+		&p.repositoryId,
+		&p.tagFilter,
+		&p.keepLast,
+		&p.olderThanDays,
+		&p.notPulledInDays,
+		&p.deleteUntagged,
+		&p.enabled,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type RetentionPolicyRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresRetentionPolicyRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *RetentionPolicyRepository {
+	return &RetentionPolicyRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *RetentionPolicyRepository) selectQuery(filter *repositories.RetentionPolicyFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"retention_policies.id",
+		"retention_policies.created_at",
+		"retention_policies.updated_at",
+		"retention_policies.xmin",
+		"retention_policies.project_id",
+		"retention_policies.repository_id",
+		"retention_policies.tag_filter",
+		"retention_policies.keep_last",
+		"retention_policies.older_than_days",
+		"retention_policies.not_pulled_in_days",
+		"retention_policies.delete_untagged",
+		"retention_policies.enabled",
+	).From("retention_policies")
+
+	if filter.HasId() {
+		s.Where(s.Equal("retention_policies.id", filter.GetId()))
+	}
+
+	if filter.HasProjectId() {
+		s.Where(s.Equal("retention_policies.project_id", filter.GetProjectId()))
+	}
+
+	if filter.HasRepositoryId() {
+		s.Where(s.Equal("retention_policies.repository_id", filter.GetRepositoryId()))
+	}
+
+	if filter.HasEnabled() {
+		s.Where(s.Equal("retention_policies.enabled", filter.GetEnabled()))
+	}
+
+	return s
+}
+
+func (r *RetentionPolicyRepository) First(ctx context.Context, filter *repositories.RetentionPolicyFilter) (*repositories.RetentionPolicy, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	retentionPolicy := &postgresRetentionPolicy{}
+	err := retentionPolicy.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return retentionPolicy.Map(), nil
+}
+
+func (r *RetentionPolicyRepository) Single(ctx context.Context, filter *repositories.RetentionPolicyFilter) (*repositories.RetentionPolicy, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiRetentionPolicyNotFound
+	}
+	return result, nil
+}
+
+func (r *RetentionPolicyRepository) List(ctx context.Context, filter *repositories.RetentionPolicyFilter) ([]*repositories.RetentionPolicy, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var retentionPolicies []*repositories.RetentionPolicy
+	var totalCount int
+	for rows.Next() {
+		retentionPolicy := &postgresRetentionPolicy{}
+		err := retentionPolicy.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		retentionPolicies = append(retentionPolicies, retentionPolicy.Map())
+	}
+
+	return retentionPolicies, totalCount, nil
+}
+
+func (r *RetentionPolicyRepository) Insert(retentionPolicy *repositories.RetentionPolicy) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, retentionPolicy))
+}
+
+func (r *RetentionPolicyRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, retentionPolicy *repositories.RetentionPolicy) error {
+	mapped := mapRetentionPolicy(retentionPolicy)
+
+	s := sqlbuilder.InsertInto("retention_policies").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"project_id",
+			"repository_id",
+			"tag_filter",
+			"keep_last",
+			"older_than_days",
+			"not_pulled_in_days",
+			"delete_untagged",
+			"enabled",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.projectId,
+			mapped.repositoryId,
+			mapped.tagFilter,
+			mapped.keepLast,
+			mapped.olderThanDays,
+			mapped.notPulledInDays,
+			mapped.deleteUntagged,
+			mapped.enabled,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting retention policy: %w", err)
+	}
+
+	retentionPolicy.SetVersion(xmin)
+	retentionPolicy.ClearChanges()
+	return nil
+}
+
+func (r *RetentionPolicyRepository) Update(retentionPolicy *repositories.RetentionPolicy) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, retentionPolicy))
+}
+
+func (r *RetentionPolicyRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, retentionPolicy *repositories.RetentionPolicy) error {
+	if !retentionPolicy.HasChanges() {
+		return nil
+	}
+
+	mapped := mapRetentionPolicy(retentionPolicy)
+
+	s := sqlbuilder.Update("retention_policies")
+	s.Where(s.Equal("id", retentionPolicy.GetId()))
+	s.Where(s.Equal("xmin", retentionPolicy.GetVersion()))
+
+	for _, field := range retentionPolicy.GetChanges() {
+		switch field {
+		case repositories.RetentionPolicyChangeTagFilter:
+			s.SetMore(s.Assign("tag_filter", mapped.tagFilter))
+		case repositories.RetentionPolicyChangeCriteria:
+			s.SetMore(s.Assign("keep_last", mapped.keepLast))
+			s.SetMore(s.Assign("older_than_days", mapped.olderThanDays))
+			s.SetMore(s.Assign("not_pulled_in_days", mapped.notPulledInDays))
+		case repositories.RetentionPolicyChangeDeleteUntagged:
+			s.SetMore(s.Assign("delete_untagged", mapped.deleteUntagged))
+		case repositories.RetentionPolicyChangeEnabled:
+			s.SetMore(s.Assign("enabled", mapped.enabled))
+
+		default:
+			panic(fmt.Errorf("unknown retention policy change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating retention policy: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating retention policy: %w", err)
+	}
+
+	retentionPolicy.SetVersion(xmin)
+	retentionPolicy.ClearChanges()
+	return nil
+}
+
+func (r *RetentionPolicyRepository) Delete(retentionPolicy *repositories.RetentionPolicy) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, retentionPolicy))
+}
+
+func (r *RetentionPolicyRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, retentionPolicy *repositories.RetentionPolicy) error {
+	s := sqlbuilder.DeleteFrom("retention_policies")
+	s.Where(s.Equal("id", retentionPolicy.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresStorageUsage struct {
+	postgresBaseModel
+	kind        string
+	ownerId     uuid.UUID
+	tenantId    uuid.UUID
+	projectId   *uuid.UUID
+	logicalSize int64
+	storedSize  int64
+	uniqueSize  int64
+}
+
+func mapStorageUsage(storageUsage *repositories.StorageUsage) *postgresStorageUsage {
+	return &postgresStorageUsage{
+		postgresBaseModel: mapBase(storageUsage.BaseModel),
+		kind:              string(storageUsage.GetKind()),
+		ownerId:           storageUsage.GetOwnerId(),
+		tenantId:          storageUsage.GetTenantId(),
+		projectId:         storageUsage.GetProjectId(),
+		logicalSize:       storageUsage.GetLogicalSize(),
+		storedSize:        storageUsage.GetStoredSize(),
+		uniqueSize:        storageUsage.GetUniqueSize(),
+	}
+}
+
+func (s *postgresStorageUsage) Map() *repositories.StorageUsage {
+	return repositories.NewStorageUsageFromDB(
+		repositories.StorageUsageKind(s.kind),
+		s.ownerId,
+		s.tenantId,
+		s.projectId,
+		s.logicalSize,
+		s.storedSize,
+		s.uniqueSize,
+		s.MapBase(),
+	)
+}
+
+func (s *postgresStorageUsage) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&s.id,
+		&s.createdAt,
+		&s.updatedAt,
+		&s.xmin,
+		&s.kind,
+		&s.ownerId,
+		&s.tenantId,
+		&s.projectId,
+		&s.logicalSize,
+		&s.storedSize,
+		&s.uniqueSize,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type StorageUsageRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresStorageUsageRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *StorageUsageRepository {
+	return &StorageUsageRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *StorageUsageRepository) selectQuery(filter *repositories.StorageUsageFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"storage_usages.id",
+		"storage_usages.created_at",
+		"storage_usages.updated_at",
+		"storage_usages.xmin",
+		"storage_usages.kind",
+		"storage_usages.owner_id",
+		"storage_usages.tenant_id",
+		"storage_usages.project_id",
+		"storage_usages.logical_size",
+		"storage_usages.stored_size",
+		"storage_usages.unique_size",
+	).From("storage_usages")
+
+	if filter.HasId() {
+		s.Where(s.Equal("storage_usages.id", filter.GetId()))
+	}
+
+	if filter.HasKind() {
+		s.Where(s.Equal("storage_usages.kind", string(filter.GetKind())))
+	}
+
+	if filter.HasOwnerId() {
+		s.Where(s.Equal("storage_usages.owner_id", filter.GetOwnerId()))
+	}
+
+	if filter.HasTenantId() {
+		s.Where(s.Equal("storage_usages.tenant_id", filter.GetTenantId()))
+	}
+
+	if filter.HasProjectId() {
+		s.Where(s.Equal("storage_usages.project_id", filter.GetProjectId()))
+	}
+
+	return s
+}
+
+func (r *StorageUsageRepository) First(ctx context.Context, filter *repositories.StorageUsageFilter) (*repositories.StorageUsage, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	storageUsage := &postgresStorageUsage{}
+	err := storageUsage.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return storageUsage.Map(), nil
+}
+
+func (r *StorageUsageRepository) Single(ctx context.Context, filter *repositories.StorageUsageFilter) (*repositories.StorageUsage, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiStorageUsageNotFound
+	}
+	return result, nil
+}
+
+func (r *StorageUsageRepository) List(ctx context.Context, filter *repositories.StorageUsageFilter) ([]*repositories.StorageUsage, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var storageUsages []*repositories.StorageUsage
+	var totalCount int
+	for rows.Next() {
+		storageUsage := &postgresStorageUsage{}
+		err := storageUsage.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		storageUsages = append(storageUsages, storageUsage.Map())
+	}
+
+	return storageUsages, totalCount, nil
+}
+
+func (r *StorageUsageRepository) Insert(storageUsage *repositories.StorageUsage) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, storageUsage))
+}
+
+func (r *StorageUsageRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, storageUsage *repositories.StorageUsage) error {
+	mapped := mapStorageUsage(storageUsage)
+
+	s := sqlbuilder.InsertInto("storage_usages").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"kind",
+			"owner_id",
+			"tenant_id",
+			"project_id",
+			"logical_size",
+			"stored_size",
+			"unique_size",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.kind,
+			mapped.ownerId,
+			mapped.tenantId,
+			mapped.projectId,
+			mapped.logicalSize,
+			mapped.storedSize,
+			mapped.uniqueSize,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting storage usage: %w", err)
+	}
+
+	storageUsage.SetVersion(xmin)
+	storageUsage.ClearChanges()
+	return nil
+}
+
+func (r *StorageUsageRepository) Update(storageUsage *repositories.StorageUsage) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, storageUsage))
+}
+
+func (r *StorageUsageRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, storageUsage *repositories.StorageUsage) error {
+	if !storageUsage.HasChanges() {
+		return nil
+	}
+
+	mapped := mapStorageUsage(storageUsage)
+
+	s := sqlbuilder.Update("storage_usages")
+	s.Where(s.Equal("id", storageUsage.GetId()))
+	s.Where(s.Equal("xmin", storageUsage.GetVersion()))
+
+	for _, field := range storageUsage.GetChanges() {
+		switch field {
+		case repositories.StorageUsageChangeSizes:
+			s.SetMore(s.Assign("logical_size", mapped.logicalSize))
+			s.SetMore(s.Assign("stored_size", mapped.storedSize))
+			s.SetMore(s.Assign("unique_size", mapped.uniqueSize))
+
+		default:
+			panic(fmt.Errorf("unknown storage usage change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating storage usage: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating storage usage: %w", err)
+	}
+
+	storageUsage.SetVersion(xmin)
+	storageUsage.ClearChanges()
+	return nil
+}
+
+func (r *StorageUsageRepository) Delete(storageUsage *repositories.StorageUsage) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, storageUsage))
+}
+
+func (r *StorageUsageRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, storageUsage *repositories.StorageUsage) error {
+	s := sqlbuilder.DeleteFrom("storage_usages")
+	s.Where(s.Equal("id", storageUsage.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
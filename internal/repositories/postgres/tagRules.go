@@ -0,0 +1,272 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresTagRule struct {
+	postgresBaseModel
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+	pattern      string
+	kind         string
+}
+
+func mapTagRule(tagRule *repositories.TagRule) *postgresTagRule {
+	return &postgresTagRule{
+		postgresBaseModel: mapBase(tagRule.BaseModel),
+		projectId:         tagRule.GetProjectId(),
+		repositoryId:      tagRule.GetRepositoryId(),
+		pattern:           tagRule.GetPattern(),
+		kind:              string(tagRule.GetKind()),
+	}
+}
+
+func (r *postgresTagRule) Map() *repositories.TagRule {
+	return repositories.NewTagRuleFromDB(
+		r.projectId,
+		r.repositoryId,
+		r.pattern,
+		repositories.TagRuleKind(r.kind),
+		r.MapBase(),
+	)
+}
+
+func (r *postgresTagRule) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&r.id,
+		&r.createdAt,
+		&r.updatedAt,
+		&r.xmin,
+		&r.projectId,
+		&r.repositoryId,
+		&r.pattern,
+		&r.kind,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type TagRuleRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresTagRuleRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *TagRuleRepository {
+	return &TagRuleRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *TagRuleRepository) selectQuery(filter *repositories.TagRuleFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"tag_rules.id",
+		"tag_rules.created_at",
+		"tag_rules.updated_at",
+		"tag_rules.xmin",
+		"tag_rules.project_id",
+		"tag_rules.repository_id",
+		"tag_rules.pattern",
+		"tag_rules.kind",
+	).From("tag_rules")
+
+	if filter.HasId() {
+		s.Where(s.Equal("tag_rules.id", filter.GetId()))
+	}
+
+	if filter.HasProjectId() {
+		s.Where(s.Equal("tag_rules.project_id", filter.GetProjectId()))
+	}
+
+	if filter.HasRepositoryId() {
+		s.Where(s.Equal("tag_rules.repository_id", filter.GetRepositoryId()))
+	}
+
+	return s
+}
+
+func (r *TagRuleRepository) First(ctx context.Context, filter *repositories.TagRuleFilter) (*repositories.TagRule, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	tagRule := &postgresTagRule{}
+	err := tagRule.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return tagRule.Map(), nil
+}
+
+func (r *TagRuleRepository) Single(ctx context.Context, filter *repositories.TagRuleFilter) (*repositories.TagRule, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiTagRuleNotFound
+	}
+	return result, nil
+}
+
+func (r *TagRuleRepository) List(ctx context.Context, filter *repositories.TagRuleFilter) ([]*repositories.TagRule, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var tag_rules []*repositories.TagRule
+	var totalCount int
+	for rows.Next() {
+		tagRule := &postgresTagRule{}
+		err := tagRule.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		tag_rules = append(tag_rules, tagRule.Map())
+	}
+
+	return tag_rules, totalCount, nil
+}
+
+func (r *TagRuleRepository) Insert(tagRule *repositories.TagRule) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, tagRule))
+}
+
+func (r *TagRuleRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, tagRule *repositories.TagRule) error {
+	mapped := mapTagRule(tagRule)
+
+	s := sqlbuilder.InsertInto("tag_rules").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"project_id",
+			"repository_id",
+			"pattern",
+			"kind",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.projectId,
+			mapped.repositoryId,
+			mapped.pattern,
+			mapped.kind,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting tag rule: %w", err)
+	}
+
+	tagRule.SetVersion(xmin)
+	tagRule.ClearChanges()
+	return nil
+}
+
+func (r *TagRuleRepository) Update(tagRule *repositories.TagRule) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, tagRule))
+}
+
+func (r *TagRuleRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, tagRule *repositories.TagRule) error {
+	if !tagRule.HasChanges() {
+		return nil
+	}
+
+	mapped := mapTagRule(tagRule)
+
+	s := sqlbuilder.Update("tag_rules")
+	s.Where(s.Equal("id", tagRule.GetId()))
+	s.Where(s.Equal("xmin", tagRule.GetVersion()))
+
+	for _, field := range tagRule.GetChanges() {
+		switch field {
+		case repositories.TagRuleChangePattern:
+			s.SetMore(s.Assign("pattern", mapped.pattern))
+		case repositories.TagRuleChangeKind:
+			s.SetMore(s.Assign("kind", mapped.kind))
+
+		default:
+			panic(fmt.Errorf("unknown tag rule change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating tag rule: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating tag rule: %w", err)
+	}
+
+	tagRule.SetVersion(xmin)
+	tagRule.ClearChanges()
+	return nil
+}
+
+func (r *TagRuleRepository) Delete(tagRule *repositories.TagRule) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, tagRule))
+}
+
+func (r *TagRuleRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, tagRule *repositories.TagRule) error {
+	s := sqlbuilder.DeleteFrom("tag_rules")
+	s.Where(s.Equal("id", tagRule.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
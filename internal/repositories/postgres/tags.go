@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
@@ -20,6 +21,7 @@ type postgresTag struct {
 	repositoryId uuid.UUID
 	manifestId   uuid.UUID
 	name         string
+	lastPulledAt *time.Time
 	manifestInfo *tagManifestInfo
 }
 
@@ -33,6 +35,7 @@ func mapTag(tag *repositories.Tag) *postgresTag {
 		repositoryId:      tag.GetRepositoryId(),
 		manifestId:        tag.GetRepositoryManifestId(),
 		name:              tag.GetName(),
+		lastPulledAt:      tag.GetLastPulledAt(),
 	}
 }
 
@@ -48,6 +51,7 @@ func (t *postgresTag) Map() *repositories.Tag {
 		t.repositoryId,
 		t.manifestId,
 		t.name,
+		t.lastPulledAt,
 		manifestInfo,
 		t.MapBase(),
 	)
@@ -64,6 +68,7 @@ func (t *postgresTag) scan(row RowScanner, filter *repositories.TagFilter, total
 		&t.repositoryId,
 		&t.manifestId,
 		&t.name,
+		&t.lastPulledAt,
 	}
 
 	if filter.GetIncludeManifestInfo() {
@@ -101,6 +106,7 @@ func (r *TagRepository) selectQuery(filter *repositories.TagFilter) *sqlbuilder.
 		"tags.repository_id",
 		"tags.manifest_id",
 		"tags.name",
+		"tags.last_pulled_at",
 	).From("tags")
 
 	if filter.HasId() {
@@ -119,6 +125,11 @@ func (r *TagRepository) selectQuery(filter *repositories.TagFilter) *sqlbuilder.
 		s.Where(s.Equal("tags.name", filter.GetName()))
 	}
 
+	if filter.HasNameAfter() {
+		// compare bytewise so paging matches the lexical order required by the oci distribution spec
+		s.Where(s.GreaterThan(`tags.name collate "C"`, filter.GetNameAfter()))
+	}
+
 	if filter.GetIncludeManifestInfo() {
 		s.JoinWithOption(sqlbuilder.InnerJoin, "manifests", "manifests.id = tags.manifest_id")
 		s.SelectMore("manifests.digest as manifest_digest")
@@ -158,9 +169,22 @@ func (r *TagRepository) Single(ctx context.Context, filter *repositories.TagFilt
 	return result, nil
 }
 
-func (r *TagRepository) List(ctx context.Context, filter *repositories.TagFilter) ([]*repositories.Tag, int, error) {
+// listQuery sorts the tags bytewise like the in-memory repository does, so that paging with ByNameAfter follows the
+// lexical order required by the oci distribution spec regardless of the collation of the database.
+func (r *TagRepository) listQuery(filter *repositories.TagFilter) *sqlbuilder.SelectBuilder {
 	s := r.selectQuery(filter)
 	s.SelectMore("count(*) over() as total_count")
+	s.OrderBy(`tags.name collate "C"`)
+
+	if filter.HasLimit() {
+		s.Limit(filter.GetLimit())
+	}
+
+	return s
+}
+
+func (r *TagRepository) List(ctx context.Context, filter *repositories.TagFilter) ([]*repositories.Tag, int, error) {
+	s := r.listQuery(filter)
 
 	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
 	logging.Logger.Debugf("query: %s, args: %+v", query, args)
@@ -200,6 +224,7 @@ func (r *TagRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, tag *repo
 			"repository_id",
 			"manifest_id",
 			"name",
+			"last_pulled_at",
 		).
 		Values(
 			mapped.id,
@@ -208,8 +233,9 @@ func (r *TagRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, tag *repo
 			mapped.repositoryId,
 			mapped.manifestId,
 			mapped.name,
+			mapped.lastPulledAt,
 		)
-	s.SQL("ON CONFLICT (repository_id, name) DO UPDATE SET manifest_id = EXCLUDED.manifest_id, updated_at = EXCLUDED.updated_at")
+	s.SQL("ON CONFLICT (repository_id, name) DO UPDATE SET manifest_id = EXCLUDED.manifest_id, updated_at = EXCLUDED.updated_at, last_pulled_at = EXCLUDED.last_pulled_at")
 	s.Returning("xmin")
 
 	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
@@ -223,6 +249,44 @@ func (r *TagRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, tag *repo
 	}
 
 	tag.SetVersion(xmin)
+	tag.ClearChanges()
+	return nil
+}
+
+func (r *TagRepository) Update(tag *repositories.Tag) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, tag))
+}
+
+// ExecuteUpdate does not check the version of the tag, the only mutable field is the pull time and concurrent pulls
+// racing to record it are harmless.
+func (r *TagRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, tag *repositories.Tag) error {
+	if !tag.HasChanges() {
+		return nil
+	}
+
+	mapped := mapTag(tag)
+
+	s := sqlbuilder.Update("tags")
+	s.Where(s.Equal("id", tag.GetId()))
+
+	for _, field := range tag.GetChanges() {
+		switch field {
+		case repositories.TagChangeLastPulledAt:
+			s.SetMore(s.Assign("last_pulled_at", mapped.lastPulledAt))
+
+		default:
+			panic(fmt.Errorf("unknown tag change: %d", field))
+		}
+	}
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("updating tag: %w", err)
+	}
+
+	tag.ClearChanges()
 	return nil
 }
 
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+type TagRepositoryTestSuite struct {
+	suite.Suite
+}
+
+func TestTagRepositoryTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(TagRepositoryTestSuite))
+}
+
+func (s *TagRepositoryTestSuite) TestListQuery() {
+	// arrange
+	repositoryId := uuid.New()
+	filter := repositories.NewTagFilter().ByRepositoryId(repositoryId).ByNameAfter("v1.9").WithLimit(3)
+
+	// act
+	query, args := (&TagRepository{}).listQuery(filter).BuildWithFlavor(sqlbuilder.PostgreSQL)
+
+	// assert
+	// the "C" collation compares bytes like the in-memory repository, instead of the locale of the database
+	s.Contains(query, `WHERE tags.repository_id = $1 AND tags.name collate "C" > $2`)
+	s.Contains(query, `ORDER BY tags.name collate "C" LIMIT $3`)
+	s.Contains(query, "count(*) over() as total_count")
+	s.Equal([]any{repositoryId, "v1.9", 3}, args)
+}
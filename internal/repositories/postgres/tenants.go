@@ -25,6 +25,8 @@ type postgresTenant struct {
 	oidcRoleClaim       string
 	oidcRoleClaimFormat string
 	oidcRoleMapping     hstore.Hstore
+
+	storageQuota *int64
 }
 
 func mapTenant(tenant *repositories.Tenant) *postgresTenant {
@@ -45,6 +47,7 @@ func mapTenant(tenant *repositories.Tenant) *postgresTenant {
 		oidcRoleClaim:       tenant.GetOidcRoleClaim(),
 		oidcRoleClaimFormat: tenant.GetOidcRoleClaimFormat(),
 		oidcRoleMapping:     oidcRoleMapping,
+		storageQuota:        tenant.GetStorageQuota(),
 	}
 }
 
@@ -64,6 +67,7 @@ func (t *postgresTenant) Map() *repositories.Tenant {
 			t.oidcRoleClaimFormat,
 			oidcRoleMapping,
 		),
+		t.storageQuota,
 		t.MapBase(),
 	)
 }
@@ -81,6 +85,7 @@ func (t *postgresTenant) scan(row RowScanner, totalCount *int) error {
 		&t.oidcRoleClaim,
 		&t.oidcRoleClaimFormat,
 		&t.oidcRoleMapping,
+		&t.storageQuota,
 	}
 	if totalCount != nil {
 		ptrs = append(ptrs, totalCount)
@@ -115,6 +120,7 @@ func (r *TenantRepository) selectQuery(filter *repositories.TenantFilter) *sqlbu
 		"tenants.oidc_role_claim",
 		"tenants.oidc_role_claim_format",
 		"tenants.oidc_role_mapping",
+		"tenants.storage_quota",
 	).From("tenants")
 
 	if filter.HasId() {
@@ -204,6 +210,7 @@ func (r *TenantRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, tenant
 			"oidc_role_claim",
 			"oidc_role_claim_format",
 			"oidc_role_mapping",
+			"storage_quota",
 		).
 		Values(
 			mapped.id,
@@ -216,6 +223,7 @@ func (r *TenantRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, tenant
 			mapped.oidcRoleClaim,
 			mapped.oidcRoleClaimFormat,
 			mapped.oidcRoleMapping,
+			mapped.storageQuota,
 		)
 
 	s.Returning("xmin")
@@ -259,6 +267,8 @@ func (r *TenantRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, tenant
 			s.SetMore(s.Assign("oidc_issuer", mapped.oidcIssuer))
 		case repositories.TenantChangeOidcRoleMapping:
 			s.SetMore(s.Assign("oidc_role_mapping", mapped.oidcRoleMapping))
+		case repositories.TenantChangeStorageQuota:
+			s.SetMore(s.Assign("storage_quota", mapped.storageQuota))
 		case repositories.TenantChangeOidcRoleClaim:
 			s.SetMore(s.Assign("oidc_role_claim", mapped.oidcRoleClaim))
 		case repositories.TenantChangeOidcRoleClaimFormat:
@@ -0,0 +1,291 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq/hstore"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresUploadSession struct {
+	postgresBaseModel
+	sessionId    uuid.UUID
+	repositoryId uuid.UUID
+	backendState hstore.Hstore
+	expiresAt    time.Time
+}
+
+func mapUploadSession(uploadSession *repositories.UploadSession) *postgresUploadSession {
+	backendState := hstore.Hstore{
+		Map: make(map[string]sql.NullString),
+	}
+
+	for k, v := range uploadSession.GetBackendState() {
+		backendState.Map[k] = sql.NullString{String: v, Valid: true}
+	}
+
+	return &postgresUploadSession{
+		postgresBaseModel: mapBase(uploadSession.BaseModel),
+		sessionId:         uploadSession.GetSessionId(),
+		repositoryId:      uploadSession.GetRepositoryId(),
+		backendState:      backendState,
+		expiresAt:         uploadSession.GetExpiresAt(),
+	}
+}
+
+func (u *postgresUploadSession) Map() *repositories.UploadSession {
+	backendState := make(map[string]string)
+	for k, v := range u.backendState.Map {
+		backendState[k] = v.String
+	}
+
+	return repositories.NewUploadSessionFromDB(
+		u.sessionId,
+		u.repositoryId,
+		backendState,
+		u.expiresAt,
+		u.MapBase(),
+	)
+}
+
+func (u *postgresUploadSession) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&u.id,
+		&u.createdAt,
+		&u.updatedAt,
+		&u.xmin,
+		&u.sessionId,
+		&u.repositoryId,
+		&u.backendState,
+		&u.expiresAt,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type UploadSessionRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresUploadSessionRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *UploadSessionRepository {
+	return &UploadSessionRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *UploadSessionRepository) selectQuery(filter *repositories.UploadSessionFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"upload_sessions.id",
+		"upload_sessions.created_at",
+		"upload_sessions.updated_at",
+		"upload_sessions.xmin",
+		"upload_sessions.session_id",
+		"upload_sessions.repository_id",
+		"upload_sessions.backend_state",
+		"upload_sessions.expires_at",
+	).From("upload_sessions")
+
+	if filter.HasId() {
+		s.Where(s.Equal("upload_sessions.id", filter.GetId()))
+	}
+
+	if filter.HasSessionId() {
+		s.Where(s.Equal("upload_sessions.session_id", filter.GetSessionId()))
+	}
+
+	if filter.HasExpiredBefore() {
+		s.Where(s.LessEqualThan("upload_sessions.expires_at", filter.GetExpiredBefore()))
+	}
+
+	return s
+}
+
+func (r *UploadSessionRepository) First(ctx context.Context, filter *repositories.UploadSessionFilter) (*repositories.UploadSession, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	uploadSession := &postgresUploadSession{}
+	err := uploadSession.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return uploadSession.Map(), nil
+}
+
+func (r *UploadSessionRepository) Single(ctx context.Context, filter *repositories.UploadSessionFilter) (*repositories.UploadSession, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiUploadSessionNotFound
+	}
+	return result, nil
+}
+
+func (r *UploadSessionRepository) List(ctx context.Context, filter *repositories.UploadSessionFilter) ([]*repositories.UploadSession, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+	s.OrderBy("upload_sessions.expires_at")
+
+	if filter.HasLimit() {
+		s.Limit(filter.GetLimit())
+	}
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var uploadSessions []*repositories.UploadSession
+	var totalCount int
+	for rows.Next() {
+		uploadSession := &postgresUploadSession{}
+		err := uploadSession.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		uploadSessions = append(uploadSessions, uploadSession.Map())
+	}
+
+	return uploadSessions, totalCount, nil
+}
+
+func (r *UploadSessionRepository) Insert(uploadSession *repositories.UploadSession) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, uploadSession))
+}
+
+func (r *UploadSessionRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, uploadSession *repositories.UploadSession) error {
+	mapped := mapUploadSession(uploadSession)
+
+	s := sqlbuilder.InsertInto("upload_sessions").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"session_id",
+			"repository_id",
+			"backend_state",
+			"expires_at",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.sessionId,
+			mapped.repositoryId,
+			mapped.backendState,
+			mapped.expiresAt,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting upload session: %w", err)
+	}
+
+	uploadSession.SetVersion(xmin)
+	uploadSession.ClearChanges()
+	return nil
+}
+
+func (r *UploadSessionRepository) Update(uploadSession *repositories.UploadSession) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, uploadSession))
+}
+
+func (r *UploadSessionRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, uploadSession *repositories.UploadSession) error {
+	if !uploadSession.HasChanges() {
+		return nil
+	}
+
+	mapped := mapUploadSession(uploadSession)
+
+	s := sqlbuilder.Update("upload_sessions")
+	s.Where(s.Equal("id", uploadSession.GetId()))
+	s.Where(s.Equal("xmin", uploadSession.GetVersion()))
+
+	for _, field := range uploadSession.GetChanges() {
+		switch field {
+		case repositories.UploadSessionChangeProgress:
+			s.SetMore(s.Assign("backend_state", mapped.backendState))
+			s.SetMore(s.Assign("expires_at", mapped.expiresAt))
+
+		default:
+			panic(fmt.Errorf("unknown upload session change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating upload session: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating upload session: %w", err)
+	}
+
+	uploadSession.SetVersion(xmin)
+	uploadSession.ClearChanges()
+	return nil
+}
+
+func (r *UploadSessionRepository) Delete(uploadSession *repositories.UploadSession) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, uploadSession))
+}
+
+func (r *UploadSessionRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, uploadSession *repositories.UploadSession) error {
+	s := sqlbuilder.DeleteFrom("upload_sessions")
+	s.Where(s.Equal("id", uploadSession.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type postgresUpstream struct {
+	postgresBaseModel
+	projectId         *uuid.UUID
+	repositoryId      *uuid.UUID
+	url               string
+	repository        string
+	username          *string
+	encryptedPassword []byte
+	tagTtlSeconds     int64
+}
+
+func mapUpstream(upstream *repositories.Upstream) *postgresUpstream {
+	return &postgresUpstream{
+		postgresBaseModel: mapBase(upstream.BaseModel),
+		projectId:         upstream.GetProjectId(),
+		repositoryId:      upstream.GetRepositoryId(),
+		url:               upstream.GetUrl(),
+		repository:        upstream.GetRepository(),
+		username:          upstream.GetUsername(),
+		encryptedPassword: upstream.GetEncryptedPassword(),
+		tagTtlSeconds:     int64(upstream.GetTagTtl().Seconds()),
+	}
+}
+
+func (u *postgresUpstream) Map() *repositories.Upstream {
+	return repositories.NewUpstreamFromDB(
+		u.projectId,
+		u.repositoryId,
+		u.url,
+		u.repository,
+		u.username,
+		u.encryptedPassword,
+		time.Duration(u.tagTtlSeconds)*time.Second,
+		u.MapBase(),
+	)
+}
+
+func (u *postgresUpstream) scan(row RowScanner, totalCount *int) error {
+	ptrs := []any{
+		&u.id,
+		&u.createdAt,
+		&u.updatedAt,
+		&u.xmin,
+		&u.projectId,
+		&u.repositoryId,
+		&u.url,
+		&u.repository,
+		&u.username,
+		&u.encryptedPassword,
+		&u.tagTtlSeconds,
+	}
+	if totalCount != nil {
+		ptrs = append(ptrs, totalCount)
+	}
+	return row.Scan(ptrs...)
+}
+
+type UpstreamRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewPostgresUpstreamRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *UpstreamRepository {
+	return &UpstreamRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *UpstreamRepository) selectQuery(filter *repositories.UpstreamFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"upstreams.id",
+		"upstreams.created_at",
+		"upstreams.updated_at",
+		"upstreams.xmin",
+		"upstreams.project_id",
+		"upstreams.repository_id",
+		"upstreams.url",
+		"upstreams.repository",
+		"upstreams.username",
+		"upstreams.encrypted_password",
+		"upstreams.tag_ttl_seconds",
+	).From("upstreams")
+
+	if filter.HasId() {
+		s.Where(s.Equal("upstreams.id", filter.GetId()))
+	}
+
+	if filter.HasProjectId() {
+		s.Where(s.Equal("upstreams.project_id", filter.GetProjectId()))
+	}
+
+	if filter.HasRepositoryId() {
+		s.Where(s.Equal("upstreams.repository_id", filter.GetRepositoryId()))
+	}
+
+	return s
+}
+
+func (r *UpstreamRepository) First(ctx context.Context, filter *repositories.UpstreamFilter) (*repositories.Upstream, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	upstream := &postgresUpstream{}
+	err := upstream.scan(row, nil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return upstream.Map(), nil
+}
+
+func (r *UpstreamRepository) Single(ctx context.Context, filter *repositories.UpstreamFilter) (*repositories.Upstream, error) {
+	result, err := r.First(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apiError.ErrApiUpstreamNotFound
+	}
+	return result, nil
+}
+
+func (r *UpstreamRepository) List(ctx context.Context, filter *repositories.UpstreamFilter) ([]*repositories.Upstream, int, error) {
+	s := r.selectQuery(filter)
+	s.SelectMore("count(*) over() as total_count")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var upstreams []*repositories.Upstream
+	var totalCount int
+	for rows.Next() {
+		upstream := &postgresUpstream{}
+		err := upstream.scan(rows, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+
+		upstreams = append(upstreams, upstream.Map())
+	}
+
+	return upstreams, totalCount, nil
+}
+
+func (r *UpstreamRepository) Insert(upstream *repositories.Upstream) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, upstream))
+}
+
+func (r *UpstreamRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, upstream *repositories.Upstream) error {
+	mapped := mapUpstream(upstream)
+
+	s := sqlbuilder.InsertInto("upstreams").
+		Cols(
+			"id",
+			"created_at",
+			"updated_at",
+			"project_id",
+			"repository_id",
+			"url",
+			"repository",
+			"username",
+			"encrypted_password",
+			"tag_ttl_seconds",
+		).
+		Values(
+			mapped.id,
+			mapped.createdAt,
+			mapped.updatedAt,
+			mapped.projectId,
+			mapped.repositoryId,
+			mapped.url,
+			mapped.repository,
+			mapped.username,
+			mapped.encryptedPassword,
+			mapped.tagTtlSeconds,
+		)
+
+	s.Returning("xmin")
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("inserting upstream: %w", err)
+	}
+
+	upstream.SetVersion(xmin)
+	upstream.ClearChanges()
+	return nil
+}
+
+func (r *UpstreamRepository) Update(upstream *repositories.Upstream) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, upstream))
+}
+
+func (r *UpstreamRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, upstream *repositories.Upstream) error {
+	if !upstream.HasChanges() {
+		return nil
+	}
+
+	mapped := mapUpstream(upstream)
+
+	s := sqlbuilder.Update("upstreams")
+	s.Where(s.Equal("id", upstream.GetId()))
+	s.Where(s.Equal("xmin", upstream.GetVersion()))
+
+	for _, field := range upstream.GetChanges() {
+		switch field {
+		case repositories.UpstreamChangeUrl:
+			s.SetMore(s.Assign("url", mapped.url))
+		case repositories.UpstreamChangeRepository:
+			s.SetMore(s.Assign("repository", mapped.repository))
+		case repositories.UpstreamChangeCredentials:
+			s.SetMore(s.Assign("username", mapped.username))
+			s.SetMore(s.Assign("encrypted_password", mapped.encryptedPassword))
+		case repositories.UpstreamChangeTagTtl:
+			s.SetMore(s.Assign("tag_ttl_seconds", mapped.tagTtlSeconds))
+
+		default:
+			panic(fmt.Errorf("unknown upstream change: %d", field))
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+
+	err := row.Scan(&xmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		// no row was updated, which means the row was either already deleted or concurrently updated
+		return fmt.Errorf("updating upstream: %w", apiError.ErrApiConcurrentUpdate)
+	}
+
+	if err != nil {
+		return fmt.Errorf("updating upstream: %w", err)
+	}
+
+	upstream.SetVersion(xmin)
+	upstream.ClearChanges()
+	return nil
+}
+
+func (r *UpstreamRepository) Delete(upstream *repositories.Upstream) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, upstream))
+}
+
+func (r *UpstreamRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, upstream *repositories.Upstream) error {
+	s := sqlbuilder.DeleteFrom("upstreams")
+	s.Where(s.Equal("id", upstream.GetId()))
+
+	query, args := s.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	logging.Logger.Debugf("query: %s, args: %+v", query, args)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+
+	return nil
+}
@@ -1,5 +1,24 @@
 package postgres
 
+import (
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
 type RowScanner interface {
 	Scan(...interface{}) error
 }
+
+// inIds builds an IN condition for a list of ids, an empty list matches nothing as IN () is not valid SQL.
+func inIds(s *sqlbuilder.SelectBuilder, field string, ids []uuid.UUID) string {
+	if len(ids) == 0 {
+		return "FALSE"
+	}
+
+	values := make([]any, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+
+	return s.In(field, values...)
+}
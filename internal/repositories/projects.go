@@ -13,6 +13,7 @@ type ProjectChange int
 const (
 	ProjectChangeDisplayName ProjectChange = iota
 	ProjectChangeDescription
+	ProjectChangeStorageQuota
 )
 
 type Project struct {
@@ -25,6 +26,9 @@ type Project struct {
 	displayName string
 
 	description *string
+
+	// storageQuota limits the bytes stored by the repositories of the project, nil means unlimited.
+	storageQuota *int64
 }
 
 func NewProject(tenantId uuid.UUID, slug string, displayName string) *Project {
@@ -37,14 +41,15 @@ func NewProject(tenantId uuid.UUID, slug string, displayName string) *Project {
 	}
 }
 
-func NewProjectFromDB(tenantId uuid.UUID, slug string, displayName string, description *string, base BaseModel) *Project {
+func NewProjectFromDB(tenantId uuid.UUID, slug string, displayName string, description *string, storageQuota *int64, base BaseModel) *Project {
 	return &Project{
-		BaseModel:   base,
-		List:        change.NewChanges[ProjectChange](),
-		tenantId:    tenantId,
-		slug:        slug,
-		displayName: displayName,
-		description: description,
+		BaseModel:    base,
+		List:         change.NewChanges[ProjectChange](),
+		tenantId:     tenantId,
+		slug:         slug,
+		displayName:  displayName,
+		description:  description,
+		storageQuota: storageQuota,
 	}
 }
 
@@ -82,6 +87,19 @@ func (p *Project) SetDescription(description *string) {
 	p.TrackChange(ProjectChangeDescription)
 }
 
+func (p *Project) GetStorageQuota() *int64 {
+	return p.storageQuota
+}
+
+func (p *Project) SetStorageQuota(storageQuota *int64) {
+	if pointer.Equal(p.storageQuota, storageQuota) {
+		return
+	}
+
+	p.storageQuota = storageQuota
+	p.TrackChange(ProjectChangeStorageQuota)
+}
+
 type ProjectFilter struct {
 	tenantId *uuid.UUID
 	id       *uuid.UUID
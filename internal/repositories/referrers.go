@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+// Referrer records that a manifest refers to another manifest through its subject field. The subject is stored by
+// digest because the oci spec allows pushing referrers before the subject itself exists.
+type Referrer struct {
+	BaseModel
+
+	repositoryId uuid.UUID
+	manifestId   uuid.UUID
+
+	subjectDigest string
+	artifactType  string
+	annotations   map[string]string
+}
+
+func NewReferrer(repositoryId uuid.UUID, manifestId uuid.UUID, subjectDigest string, artifactType string, annotations map[string]string) *Referrer {
+	return &Referrer{
+		BaseModel:     NewBaseModel(),
+		repositoryId:  repositoryId,
+		manifestId:    manifestId,
+		subjectDigest: subjectDigest,
+		artifactType:  artifactType,
+		annotations:   annotations,
+	}
+}
+
+func NewReferrerFromDB(repositoryId uuid.UUID, manifestId uuid.UUID, subjectDigest string, artifactType string, annotations map[string]string, base BaseModel) *Referrer {
+	return &Referrer{
+		BaseModel:     base,
+		repositoryId:  repositoryId,
+		manifestId:    manifestId,
+		subjectDigest: subjectDigest,
+		artifactType:  artifactType,
+		annotations:   annotations,
+	}
+}
+
+func (r *Referrer) GetRepositoryId() uuid.UUID {
+	return r.repositoryId
+}
+
+func (r *Referrer) GetManifestId() uuid.UUID {
+	return r.manifestId
+}
+
+func (r *Referrer) GetSubjectDigest() string {
+	return r.subjectDigest
+}
+
+func (r *Referrer) GetArtifactType() string {
+	return r.artifactType
+}
+
+func (r *Referrer) GetAnnotations() map[string]string {
+	return r.annotations
+}
+
+type ReferrerFilter struct {
+	id            *uuid.UUID
+	repositoryId  *uuid.UUID
+	manifestId    *uuid.UUID
+	subjectDigest *string
+	artifactType  *string
+}
+
+func NewReferrerFilter() *ReferrerFilter {
+	return &ReferrerFilter{}
+}
+
+func (f *ReferrerFilter) clone() *ReferrerFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *ReferrerFilter) ById(id uuid.UUID) *ReferrerFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *ReferrerFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *ReferrerFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *ReferrerFilter) ByRepositoryId(id uuid.UUID) *ReferrerFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &id
+	return cloned
+}
+
+func (f *ReferrerFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *ReferrerFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+func (f *ReferrerFilter) ByManifestId(id uuid.UUID) *ReferrerFilter {
+	cloned := f.clone()
+	cloned.manifestId = &id
+	return cloned
+}
+
+func (f *ReferrerFilter) HasManifestId() bool {
+	return f.manifestId != nil
+}
+
+func (f *ReferrerFilter) GetManifestId() uuid.UUID {
+	return pointer.DerefOrZero(f.manifestId)
+}
+
+func (f *ReferrerFilter) BySubjectDigest(digest string) *ReferrerFilter {
+	cloned := f.clone()
+	cloned.subjectDigest = &digest
+	return cloned
+}
+
+func (f *ReferrerFilter) HasSubjectDigest() bool {
+	return f.subjectDigest != nil
+}
+
+func (f *ReferrerFilter) GetSubjectDigest() string {
+	return pointer.DerefOrZero(f.subjectDigest)
+}
+
+func (f *ReferrerFilter) ByArtifactType(artifactType string) *ReferrerFilter {
+	cloned := f.clone()
+	cloned.artifactType = &artifactType
+	return cloned
+}
+
+func (f *ReferrerFilter) HasArtifactType() bool {
+	return f.artifactType != nil
+}
+
+func (f *ReferrerFilter) GetArtifactType() string {
+	return pointer.DerefOrZero(f.artifactType)
+}
+
+type ReferrerRepository interface {
+	Single(ctx context.Context, filter *ReferrerFilter) (*Referrer, error)
+	First(ctx context.Context, filter *ReferrerFilter) (*Referrer, error)
+	List(ctx context.Context, filter *ReferrerFilter) ([]*Referrer, int, error)
+	Insert(referrer *Referrer)
+	Delete(referrer *Referrer)
+}
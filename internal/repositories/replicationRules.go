@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type ReplicationRuleChange int
+
+const (
+	ReplicationRuleChangeUrl ReplicationRuleChange = iota
+	ReplicationRuleChangeNamespace
+	ReplicationRuleChangeTagFilter
+	ReplicationRuleChangeCredentials
+	ReplicationRuleChangeEnabled
+	ReplicationRuleChangeStatus
+)
+
+// ReplicationRule pushes the tags of all repositories of a project to a repository with the same slug in a remote
+// registry.
+type ReplicationRule struct {
+	BaseModel
+	change.List[ReplicationRuleChange]
+
+	projectId uuid.UUID
+
+	url string
+	// namespace is an optional prefix of the remote repository names.
+	namespace string
+	// tagFilter is a regular expression that has to match the whole tag, all tags are replicated if it is nil.
+	tagFilter *string
+
+	username *string
+	// encryptedPassword is encrypted with the secrets service, it is nil if no password is configured.
+	encryptedPassword []byte
+
+	enabled bool
+
+	lastSuccessAt *time.Time
+	lastFailureAt *time.Time
+	lastError     *string
+}
+
+func NewReplicationRule(projectId uuid.UUID, url string, namespace string, tagFilter *string) *ReplicationRule {
+	return &ReplicationRule{
+		BaseModel: NewBaseModel(),
+		List:      change.NewChanges[ReplicationRuleChange](),
+		projectId: projectId,
+		url:       url,
+		namespace: namespace,
+		tagFilter: tagFilter,
+		enabled:   true,
+	}
+}
+
+func NewReplicationRuleFromDB(
+	projectId uuid.UUID,
+	url string,
+	namespace string,
+	tagFilter *string,
+	username *string,
+	encryptedPassword []byte,
+	enabled bool,
+	lastSuccessAt *time.Time,
+	lastFailureAt *time.Time,
+	lastError *string,
+	base BaseModel,
+) *ReplicationRule {
+	return &ReplicationRule{
+		BaseModel:         base,
+		List:              change.NewChanges[ReplicationRuleChange](),
+		projectId:         projectId,
+		url:               url,
+		namespace:         namespace,
+		tagFilter:         tagFilter,
+		username:          username,
+		encryptedPassword: encryptedPassword,
+		enabled:           enabled,
+		lastSuccessAt:     lastSuccessAt,
+		lastFailureAt:     lastFailureAt,
+		lastError:         lastError,
+	}
+}
+
+func (r *ReplicationRule) GetProjectId() uuid.UUID {
+	return r.projectId
+}
+
+func (r *ReplicationRule) GetUrl() string {
+	return r.url
+}
+
+func (r *ReplicationRule) SetUrl(url string) {
+	if r.url == url {
+		return
+	}
+
+	r.url = url
+	r.TrackChange(ReplicationRuleChangeUrl)
+}
+
+func (r *ReplicationRule) GetNamespace() string {
+	return r.namespace
+}
+
+func (r *ReplicationRule) SetNamespace(namespace string) {
+	if r.namespace == namespace {
+		return
+	}
+
+	r.namespace = namespace
+	r.TrackChange(ReplicationRuleChangeNamespace)
+}
+
+// ResolveRepository returns the name of the remote repository the repository with the slug is replicated to.
+func (r *ReplicationRule) ResolveRepository(repositorySlug string) string {
+	if r.namespace == "" {
+		return repositorySlug
+	}
+
+	return r.namespace + "/" + repositorySlug
+}
+
+func (r *ReplicationRule) GetTagFilter() *string {
+	return r.tagFilter
+}
+
+func (r *ReplicationRule) SetTagFilter(tagFilter *string) {
+	if pointer.Equal(r.tagFilter, tagFilter) {
+		return
+	}
+
+	r.tagFilter = tagFilter
+	r.TrackChange(ReplicationRuleChangeTagFilter)
+}
+
+// MatchesTag reports whether the tag filter of the rule selects the tag. A tag filter that is not a valid regular
+// expression matches nothing.
+func (r *ReplicationRule) MatchesTag(tag string) bool {
+	if r.tagFilter == nil {
+		return true
+	}
+
+	tagRegex, err := regexp.Compile("^(?:" + *r.tagFilter + ")$")
+	if err != nil {
+		return false
+	}
+
+	return tagRegex.MatchString(tag)
+}
+
+func (r *ReplicationRule) GetUsername() *string {
+	return r.username
+}
+
+func (r *ReplicationRule) GetEncryptedPassword() []byte {
+	return r.encryptedPassword
+}
+
+func (r *ReplicationRule) SetCredentials(username *string, encryptedPassword []byte) {
+	if pointer.Equal(r.username, username) && bytes.Equal(r.encryptedPassword, encryptedPassword) {
+		return
+	}
+
+	r.username = username
+	r.encryptedPassword = encryptedPassword
+	r.TrackChange(ReplicationRuleChangeCredentials)
+}
+
+func (r *ReplicationRule) GetEnabled() bool {
+	return r.enabled
+}
+
+func (r *ReplicationRule) SetEnabled(enabled bool) {
+	if r.enabled == enabled {
+		return
+	}
+
+	r.enabled = enabled
+	r.TrackChange(ReplicationRuleChangeEnabled)
+}
+
+func (r *ReplicationRule) GetLastSuccessAt() *time.Time {
+	return r.lastSuccessAt
+}
+
+func (r *ReplicationRule) GetLastFailureAt() *time.Time {
+	return r.lastFailureAt
+}
+
+// GetLastError returns the error of the last failed replication, it is kept after later successes.
+func (r *ReplicationRule) GetLastError() *string {
+	return r.lastError
+}
+
+func (r *ReplicationRule) RecordSuccess(at time.Time) {
+	r.lastSuccessAt = &at
+	r.TrackChange(ReplicationRuleChangeStatus)
+}
+
+func (r *ReplicationRule) RecordFailure(at time.Time, message string) {
+	r.lastFailureAt = &at
+	r.lastError = &message
+	r.TrackChange(ReplicationRuleChangeStatus)
+}
+
+type ReplicationRuleFilter struct {
+	id        *uuid.UUID
+	projectId *uuid.UUID
+	enabled   *bool
+}
+
+func NewReplicationRuleFilter() *ReplicationRuleFilter {
+	return &ReplicationRuleFilter{}
+}
+
+func (f *ReplicationRuleFilter) clone() *ReplicationRuleFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *ReplicationRuleFilter) ById(id uuid.UUID) *ReplicationRuleFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *ReplicationRuleFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *ReplicationRuleFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *ReplicationRuleFilter) ByProjectId(projectId uuid.UUID) *ReplicationRuleFilter {
+	cloned := f.clone()
+	cloned.projectId = &projectId
+	return cloned
+}
+
+func (f *ReplicationRuleFilter) HasProjectId() bool {
+	return f.projectId != nil
+}
+
+func (f *ReplicationRuleFilter) GetProjectId() uuid.UUID {
+	return pointer.DerefOrZero(f.projectId)
+}
+
+func (f *ReplicationRuleFilter) ByEnabled(enabled bool) *ReplicationRuleFilter {
+	cloned := f.clone()
+	cloned.enabled = &enabled
+	return cloned
+}
+
+func (f *ReplicationRuleFilter) HasEnabled() bool {
+	return f.enabled != nil
+}
+
+func (f *ReplicationRuleFilter) GetEnabled() bool {
+	return pointer.DerefOrZero(f.enabled)
+}
+
+type ReplicationRuleRepository interface {
+	Single(ctx context.Context, filter *ReplicationRuleFilter) (*ReplicationRule, error)
+	First(ctx context.Context, filter *ReplicationRuleFilter) (*ReplicationRule, error)
+	List(ctx context.Context, filter *ReplicationRuleFilter) ([]*ReplicationRule, int, error)
+	Insert(replicationRule *ReplicationRule)
+	Update(replicationRule *ReplicationRule)
+	Delete(replicationRule *ReplicationRule)
+}
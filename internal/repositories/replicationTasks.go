@@ -0,0 +1,247 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type ReplicationTaskChange int
+
+const (
+	ReplicationTaskChangeAttempt ReplicationTaskChange = iota
+)
+
+// ReplicationTask is a queued push of a tag to the remote registry of a replication rule. Tasks are deleted once the
+// tag has been replicated, failed attempts are retried with backoff until the task is given up.
+type ReplicationTask struct {
+	BaseModel
+	change.List[ReplicationTaskChange]
+
+	replicationRuleId uuid.UUID
+	repositoryId      uuid.UUID
+	tag               string
+
+	attempts      int
+	nextAttemptAt time.Time
+	lastError     *string
+	// failed is set once the task ran out of attempts, it is only retried when the rule is replicated manually.
+	failed bool
+}
+
+func NewReplicationTask(replicationRuleId uuid.UUID, repositoryId uuid.UUID, tag string, nextAttemptAt time.Time) *ReplicationTask {
+	return &ReplicationTask{
+		BaseModel:         NewBaseModel(),
+		List:              change.NewChanges[ReplicationTaskChange](),
+		replicationRuleId: replicationRuleId,
+		repositoryId:      repositoryId,
+		tag:               tag,
+		nextAttemptAt:     nextAttemptAt,
+	}
+}
+
+func NewReplicationTaskFromDB(
+	replicationRuleId uuid.UUID,
+	repositoryId uuid.UUID,
+	tag string,
+	attempts int,
+	nextAttemptAt time.Time,
+	lastError *string,
+	failed bool,
+	base BaseModel,
+) *ReplicationTask {
+	return &ReplicationTask{
+		BaseModel:         base,
+		List:              change.NewChanges[ReplicationTaskChange](),
+		replicationRuleId: replicationRuleId,
+		repositoryId:      repositoryId,
+		tag:               tag,
+		attempts:          attempts,
+		nextAttemptAt:     nextAttemptAt,
+		lastError:         lastError,
+		failed:            failed,
+	}
+}
+
+func (t *ReplicationTask) GetReplicationRuleId() uuid.UUID {
+	return t.replicationRuleId
+}
+
+func (t *ReplicationTask) GetRepositoryId() uuid.UUID {
+	return t.repositoryId
+}
+
+func (t *ReplicationTask) GetTag() string {
+	return t.tag
+}
+
+func (t *ReplicationTask) GetAttempts() int {
+	return t.attempts
+}
+
+func (t *ReplicationTask) GetNextAttemptAt() time.Time {
+	return t.nextAttemptAt
+}
+
+func (t *ReplicationTask) GetLastError() *string {
+	return t.lastError
+}
+
+func (t *ReplicationTask) GetFailed() bool {
+	return t.failed
+}
+
+// RecordFailure counts a failed attempt. The task is retried at nextAttemptAt, or given up if nextAttemptAt is nil.
+func (t *ReplicationTask) RecordFailure(message string, nextAttemptAt *time.Time) {
+	t.attempts++
+	t.lastError = &message
+
+	if nextAttemptAt != nil {
+		t.nextAttemptAt = *nextAttemptAt
+	} else {
+		t.failed = true
+	}
+
+	t.TrackChange(ReplicationTaskChangeAttempt)
+}
+
+// Reset schedules the task as if it had just been queued.
+func (t *ReplicationTask) Reset(nextAttemptAt time.Time) {
+	t.attempts = 0
+	t.nextAttemptAt = nextAttemptAt
+	t.lastError = nil
+	t.failed = false
+	t.TrackChange(ReplicationTaskChangeAttempt)
+}
+
+type ReplicationTaskFilter struct {
+	id                *uuid.UUID
+	replicationRuleId *uuid.UUID
+	repositoryId      *uuid.UUID
+	tag               *string
+	failed            *bool
+	dueBefore         *time.Time
+	limit             *int
+}
+
+func NewReplicationTaskFilter() *ReplicationTaskFilter {
+	return &ReplicationTaskFilter{}
+}
+
+func (f *ReplicationTaskFilter) clone() *ReplicationTaskFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *ReplicationTaskFilter) ById(id uuid.UUID) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *ReplicationTaskFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *ReplicationTaskFilter) ByReplicationRuleId(replicationRuleId uuid.UUID) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.replicationRuleId = &replicationRuleId
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasReplicationRuleId() bool {
+	return f.replicationRuleId != nil
+}
+
+func (f *ReplicationTaskFilter) GetReplicationRuleId() uuid.UUID {
+	return pointer.DerefOrZero(f.replicationRuleId)
+}
+
+func (f *ReplicationTaskFilter) ByRepositoryId(repositoryId uuid.UUID) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &repositoryId
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *ReplicationTaskFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+func (f *ReplicationTaskFilter) ByTag(tag string) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.tag = &tag
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasTag() bool {
+	return f.tag != nil
+}
+
+func (f *ReplicationTaskFilter) GetTag() string {
+	return pointer.DerefOrZero(f.tag)
+}
+
+func (f *ReplicationTaskFilter) ByFailed(failed bool) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.failed = &failed
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasFailed() bool {
+	return f.failed != nil
+}
+
+func (f *ReplicationTaskFilter) GetFailed() bool {
+	return pointer.DerefOrZero(f.failed)
+}
+
+// ByDueBefore restricts the result to tasks whose next attempt is due at the given time. Tasks are ordered by the time
+// of their next attempt.
+func (f *ReplicationTaskFilter) ByDueBefore(dueBefore time.Time) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.dueBefore = &dueBefore
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasDueBefore() bool {
+	return f.dueBefore != nil
+}
+
+func (f *ReplicationTaskFilter) GetDueBefore() time.Time {
+	return pointer.DerefOrZero(f.dueBefore)
+}
+
+// WithLimit restricts the number of returned tasks. The total count returned by List is not affected by the limit.
+func (f *ReplicationTaskFilter) WithLimit(limit int) *ReplicationTaskFilter {
+	cloned := f.clone()
+	cloned.limit = &limit
+	return cloned
+}
+
+func (f *ReplicationTaskFilter) HasLimit() bool {
+	return f.limit != nil
+}
+
+func (f *ReplicationTaskFilter) GetLimit() int {
+	return pointer.DerefOrZero(f.limit)
+}
+
+type ReplicationTaskRepository interface {
+	Single(ctx context.Context, filter *ReplicationTaskFilter) (*ReplicationTask, error)
+	First(ctx context.Context, filter *ReplicationTaskFilter) (*ReplicationTask, error)
+	List(ctx context.Context, filter *ReplicationTaskFilter) ([]*ReplicationTask, int, error)
+	Insert(replicationTask *ReplicationTask)
+	Update(replicationTask *ReplicationTask)
+	Delete(replicationTask *ReplicationTask)
+}
@@ -31,6 +31,10 @@ func (r RepositoryAccessRole) AllowPull() bool {
 	return true
 }
 
+func (r RepositoryAccessRole) AllowDelete() bool {
+	return r == RepositoryAccessRoleAdmin
+}
+
 type RepositoryAccess struct {
 	BaseModel
 	change.List[RepositoryAccessChange]
@@ -141,6 +145,7 @@ func (f *RepositoryAccessFilter) GetUserId() uuid.UUID {
 
 type RepositoryAccessRepository interface {
 	First(ctx context.Context, filter *RepositoryAccessFilter) (*RepositoryAccess, error)
+	List(ctx context.Context, filter *RepositoryAccessFilter) ([]*RepositoryAccess, int, error)
 	Insert(entity *RepositoryAccess)
 	Update(entity *RepositoryAccess)
 	Delete(entity *RepositoryAccess)
@@ -39,9 +39,10 @@ func (r *RepositoryBlob) GetBlobId() uuid.UUID {
 }
 
 type RepositoryBlobFilter struct {
-	id           *uuid.UUID
-	blobId       *uuid.UUID
-	repositoryId *uuid.UUID
+	id                     *uuid.UUID
+	blobId                 *uuid.UUID
+	repositoryId           *uuid.UUID
+	sharedWithRepositoryId *uuid.UUID
 }
 
 func NewRepositoryBlobFilter() *RepositoryBlobFilter {
@@ -95,6 +96,22 @@ func (f *RepositoryBlobFilter) GetRepositoryId() uuid.UUID {
 	return pointer.DerefOrZero(f.repositoryId)
 }
 
+// BySharedWithRepositoryId restricts the result to the links of blobs that are linked to the repository, including the
+// links of the repository itself. This returns every repository holding on to a blob of the repository at once.
+func (f *RepositoryBlobFilter) BySharedWithRepositoryId(id uuid.UUID) *RepositoryBlobFilter {
+	cloned := f.clone()
+	cloned.sharedWithRepositoryId = &id
+	return cloned
+}
+
+func (f *RepositoryBlobFilter) HasSharedWithRepositoryId() bool {
+	return f.sharedWithRepositoryId != nil
+}
+
+func (f *RepositoryBlobFilter) GetSharedWithRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.sharedWithRepositoryId)
+}
+
 type RepositoryBlobRepository interface {
 	Single(ctx context.Context, filter *RepositoryBlobFilter) (*RepositoryBlob, error)
 	First(ctx context.Context, filter *RepositoryBlobFilter) (*RepositoryBlob, error)
@@ -0,0 +1,257 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type RetentionPolicyChange int
+
+const (
+	RetentionPolicyChangeTagFilter RetentionPolicyChange = iota
+	RetentionPolicyChangeCriteria
+	RetentionPolicyChangeDeleteUntagged
+	RetentionPolicyChangeEnabled
+)
+
+// RetentionPolicy removes old tags and untagged manifests of a repository or of all repositories of a project. Exactly
+// one of projectId and repositoryId is set, the policies of a repository apply in addition to the ones of its project.
+//
+// A tag is removed if it matches the tag filter, is not one of the keepLast most recently pushed matching tags and
+// fulfills every age criterion that is set. A policy without any tag criteria never removes tags.
+type RetentionPolicy struct {
+	BaseModel
+	change.List[RetentionPolicyChange]
+
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+
+	// tagFilter is a regular expression that has to match the whole tag, the policy applies to all tags if it is nil.
+	tagFilter *string
+
+	keepLast        *int
+	olderThanDays   *int
+	notPulledInDays *int
+
+	// deleteUntagged removes manifests that are neither tagged nor referenced by another manifest.
+	deleteUntagged bool
+	enabled        bool
+}
+
+func NewProjectRetentionPolicy(projectId uuid.UUID) *RetentionPolicy {
+	return &RetentionPolicy{
+		BaseModel: NewBaseModel(),
+		List:      change.NewChanges[RetentionPolicyChange](),
+		projectId: &projectId,
+		enabled:   true,
+	}
+}
+
+func NewRepositoryRetentionPolicy(repositoryId uuid.UUID) *RetentionPolicy {
+	return &RetentionPolicy{
+		BaseModel:    NewBaseModel(),
+		List:         change.NewChanges[RetentionPolicyChange](),
+		repositoryId: &repositoryId,
+		enabled:      true,
+	}
+}
+
+func NewRetentionPolicyFromDB(
+	projectId *uuid.UUID,
+	repositoryId *uuid.UUID,
+	tagFilter *string,
+	keepLast *int,
+	olderThanDays *int,
+	notPulledInDays *int,
+	deleteUntagged bool,
+	enabled bool,
+	base BaseModel,
+) *RetentionPolicy {
+	return &RetentionPolicy{
+		BaseModel:       base,
+		List:            change.NewChanges[RetentionPolicyChange](),
+		projectId:       projectId,
+		repositoryId:    repositoryId,
+		tagFilter:       tagFilter,
+		keepLast:        keepLast,
+		olderThanDays:   olderThanDays,
+		notPulledInDays: notPulledInDays,
+		deleteUntagged:  deleteUntagged,
+		enabled:         enabled,
+	}
+}
+
+func (p *RetentionPolicy) GetProjectId() *uuid.UUID {
+	return p.projectId
+}
+
+func (p *RetentionPolicy) GetRepositoryId() *uuid.UUID {
+	return p.repositoryId
+}
+
+func (p *RetentionPolicy) GetTagFilter() *string {
+	return p.tagFilter
+}
+
+func (p *RetentionPolicy) SetTagFilter(tagFilter *string) {
+	if pointer.Equal(p.tagFilter, tagFilter) {
+		return
+	}
+
+	p.tagFilter = tagFilter
+	p.TrackChange(RetentionPolicyChangeTagFilter)
+}
+
+// MatchesTag reports whether the tag filter of the policy selects the tag. A tag filter that is not a valid regular
+// expression matches nothing.
+func (p *RetentionPolicy) MatchesTag(tag string) bool {
+	if p.tagFilter == nil {
+		return true
+	}
+
+	tagRegex, err := regexp.Compile("^(?:" + *p.tagFilter + ")$")
+	if err != nil {
+		return false
+	}
+
+	return tagRegex.MatchString(tag)
+}
+
+func (p *RetentionPolicy) GetKeepLast() *int {
+	return p.keepLast
+}
+
+func (p *RetentionPolicy) GetOlderThanDays() *int {
+	return p.olderThanDays
+}
+
+func (p *RetentionPolicy) GetNotPulledInDays() *int {
+	return p.notPulledInDays
+}
+
+func (p *RetentionPolicy) SetCriteria(keepLast *int, olderThanDays *int, notPulledInDays *int) {
+	if pointer.Equal(p.keepLast, keepLast) && pointer.Equal(p.olderThanDays, olderThanDays) && pointer.Equal(p.notPulledInDays, notPulledInDays) {
+		return
+	}
+
+	p.keepLast = keepLast
+	p.olderThanDays = olderThanDays
+	p.notPulledInDays = notPulledInDays
+	p.TrackChange(RetentionPolicyChangeCriteria)
+}
+
+// HasTagCriteria reports whether the policy removes tags at all.
+func (p *RetentionPolicy) HasTagCriteria() bool {
+	return p.keepLast != nil || p.olderThanDays != nil || p.notPulledInDays != nil
+}
+
+func (p *RetentionPolicy) GetDeleteUntagged() bool {
+	return p.deleteUntagged
+}
+
+func (p *RetentionPolicy) SetDeleteUntagged(deleteUntagged bool) {
+	if p.deleteUntagged == deleteUntagged {
+		return
+	}
+
+	p.deleteUntagged = deleteUntagged
+	p.TrackChange(RetentionPolicyChangeDeleteUntagged)
+}
+
+func (p *RetentionPolicy) GetEnabled() bool {
+	return p.enabled
+}
+
+func (p *RetentionPolicy) SetEnabled(enabled bool) {
+	if p.enabled == enabled {
+		return
+	}
+
+	p.enabled = enabled
+	p.TrackChange(RetentionPolicyChangeEnabled)
+}
+
+type RetentionPolicyFilter struct {
+	id           *uuid.UUID
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+	enabled      *bool
+}
+
+func NewRetentionPolicyFilter() *RetentionPolicyFilter {
+	return &RetentionPolicyFilter{}
+}
+
+func (f *RetentionPolicyFilter) clone() *RetentionPolicyFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *RetentionPolicyFilter) ById(id uuid.UUID) *RetentionPolicyFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *RetentionPolicyFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *RetentionPolicyFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *RetentionPolicyFilter) ByProjectId(projectId uuid.UUID) *RetentionPolicyFilter {
+	cloned := f.clone()
+	cloned.projectId = &projectId
+	return cloned
+}
+
+func (f *RetentionPolicyFilter) HasProjectId() bool {
+	return f.projectId != nil
+}
+
+func (f *RetentionPolicyFilter) GetProjectId() uuid.UUID {
+	return pointer.DerefOrZero(f.projectId)
+}
+
+func (f *RetentionPolicyFilter) ByRepositoryId(repositoryId uuid.UUID) *RetentionPolicyFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &repositoryId
+	return cloned
+}
+
+func (f *RetentionPolicyFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *RetentionPolicyFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+func (f *RetentionPolicyFilter) ByEnabled(enabled bool) *RetentionPolicyFilter {
+	cloned := f.clone()
+	cloned.enabled = &enabled
+	return cloned
+}
+
+func (f *RetentionPolicyFilter) HasEnabled() bool {
+	return f.enabled != nil
+}
+
+func (f *RetentionPolicyFilter) GetEnabled() bool {
+	return pointer.DerefOrZero(f.enabled)
+}
+
+type RetentionPolicyRepository interface {
+	Single(ctx context.Context, filter *RetentionPolicyFilter) (*RetentionPolicy, error)
+	First(ctx context.Context, filter *RetentionPolicyFilter) (*RetentionPolicy, error)
+	List(ctx context.Context, filter *RetentionPolicyFilter) ([]*RetentionPolicy, int, error)
+	Insert(retentionPolicy *RetentionPolicy)
+	Update(retentionPolicy *RetentionPolicy)
+	Delete(retentionPolicy *RetentionPolicy)
+}
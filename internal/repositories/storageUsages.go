@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type StorageUsageChange int
+
+const (
+	StorageUsageChangeSizes StorageUsageChange = iota
+)
+
+type StorageUsageKind string
+
+const (
+	StorageUsageKindTenant     StorageUsageKind = "tenant"
+	StorageUsageKindProject    StorageUsageKind = "project"
+	StorageUsageKindRepository StorageUsageKind = "repository"
+)
+
+// StorageUsage holds the storage counters of a tenant, project or repository as of its last update. The counters are
+// recomputed in the background, so reading them does not require going through all blobs of the owner.
+type StorageUsage struct {
+	BaseModel
+	change.List[StorageUsageChange]
+
+	kind StorageUsageKind
+	// ownerId is the id of the tenant, project or repository, depending on the kind.
+	ownerId   uuid.UUID
+	tenantId  uuid.UUID
+	projectId *uuid.UUID
+
+	// logicalSize is the sum of the sizes of all manifests and the blobs they refer to, blobs referred to by several
+	// manifests are counted several times.
+	logicalSize int64
+	// storedSize counts every blob linked to the owner once.
+	storedSize int64
+	// uniqueSize counts the blobs that are not linked to anything but the owner, it is freed if the owner is deleted.
+	uniqueSize int64
+}
+
+func NewStorageUsage(kind StorageUsageKind, ownerId uuid.UUID, tenantId uuid.UUID, projectId *uuid.UUID) *StorageUsage {
+	return &StorageUsage{
+		BaseModel: NewBaseModel(),
+		List:      change.NewChanges[StorageUsageChange](),
+		kind:      kind,
+		ownerId:   ownerId,
+		tenantId:  tenantId,
+		projectId: projectId,
+	}
+}
+
+func NewStorageUsageFromDB(
+	kind StorageUsageKind,
+	ownerId uuid.UUID,
+	tenantId uuid.UUID,
+	projectId *uuid.UUID,
+	logicalSize int64,
+	storedSize int64,
+	uniqueSize int64,
+	base BaseModel,
+) *StorageUsage {
+	return &StorageUsage{
+		BaseModel:   base,
+		List:        change.NewChanges[StorageUsageChange](),
+		kind:        kind,
+		ownerId:     ownerId,
+		tenantId:    tenantId,
+		projectId:   projectId,
+		logicalSize: logicalSize,
+		storedSize:  storedSize,
+		uniqueSize:  uniqueSize,
+	}
+}
+
+func (s *StorageUsage) GetKind() StorageUsageKind {
+	return s.kind
+}
+
+func (s *StorageUsage) GetOwnerId() uuid.UUID {
+	return s.ownerId
+}
+
+func (s *StorageUsage) GetTenantId() uuid.UUID {
+	return s.tenantId
+}
+
+// GetProjectId returns the project of project and repository counters, it is nil for tenant counters.
+func (s *StorageUsage) GetProjectId() *uuid.UUID {
+	return s.projectId
+}
+
+func (s *StorageUsage) GetLogicalSize() int64 {
+	return s.logicalSize
+}
+
+func (s *StorageUsage) GetStoredSize() int64 {
+	return s.storedSize
+}
+
+func (s *StorageUsage) GetUniqueSize() int64 {
+	return s.uniqueSize
+}
+
+func (s *StorageUsage) SetSizes(logicalSize int64, storedSize int64, uniqueSize int64) {
+	if s.logicalSize == logicalSize && s.storedSize == storedSize && s.uniqueSize == uniqueSize {
+		return
+	}
+
+	s.logicalSize = logicalSize
+	s.storedSize = storedSize
+	s.uniqueSize = uniqueSize
+	s.TrackChange(StorageUsageChangeSizes)
+}
+
+type StorageUsageFilter struct {
+	id        *uuid.UUID
+	kind      *StorageUsageKind
+	ownerId   *uuid.UUID
+	tenantId  *uuid.UUID
+	projectId *uuid.UUID
+}
+
+func NewStorageUsageFilter() *StorageUsageFilter {
+	return &StorageUsageFilter{}
+}
+
+func (f *StorageUsageFilter) clone() *StorageUsageFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *StorageUsageFilter) ById(id uuid.UUID) *StorageUsageFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *StorageUsageFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *StorageUsageFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *StorageUsageFilter) ByKind(kind StorageUsageKind) *StorageUsageFilter {
+	cloned := f.clone()
+	cloned.kind = &kind
+	return cloned
+}
+
+func (f *StorageUsageFilter) HasKind() bool {
+	return f.kind != nil
+}
+
+func (f *StorageUsageFilter) GetKind() StorageUsageKind {
+	return pointer.DerefOrZero(f.kind)
+}
+
+func (f *StorageUsageFilter) ByOwnerId(ownerId uuid.UUID) *StorageUsageFilter {
+	cloned := f.clone()
+	cloned.ownerId = &ownerId
+	return cloned
+}
+
+func (f *StorageUsageFilter) HasOwnerId() bool {
+	return f.ownerId != nil
+}
+
+func (f *StorageUsageFilter) GetOwnerId() uuid.UUID {
+	return pointer.DerefOrZero(f.ownerId)
+}
+
+func (f *StorageUsageFilter) ByTenantId(tenantId uuid.UUID) *StorageUsageFilter {
+	cloned := f.clone()
+	cloned.tenantId = &tenantId
+	return cloned
+}
+
+func (f *StorageUsageFilter) HasTenantId() bool {
+	return f.tenantId != nil
+}
+
+func (f *StorageUsageFilter) GetTenantId() uuid.UUID {
+	return pointer.DerefOrZero(f.tenantId)
+}
+
+func (f *StorageUsageFilter) ByProjectId(projectId uuid.UUID) *StorageUsageFilter {
+	cloned := f.clone()
+	cloned.projectId = &projectId
+	return cloned
+}
+
+func (f *StorageUsageFilter) HasProjectId() bool {
+	return f.projectId != nil
+}
+
+func (f *StorageUsageFilter) GetProjectId() uuid.UUID {
+	return pointer.DerefOrZero(f.projectId)
+}
+
+type StorageUsageRepository interface {
+	Single(ctx context.Context, filter *StorageUsageFilter) (*StorageUsage, error)
+	First(ctx context.Context, filter *StorageUsageFilter) (*StorageUsage, error)
+	List(ctx context.Context, filter *StorageUsageFilter) ([]*StorageUsage, int, error)
+	Insert(storageUsage *StorageUsage)
+	Update(storageUsage *StorageUsage)
+	Delete(storageUsage *StorageUsage)
+}
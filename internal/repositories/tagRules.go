@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type TagRuleKind string
+
+const (
+	// TagRuleKindImmutable prevents matching tags from being moved to another manifest or deleted once they exist.
+	TagRuleKindImmutable TagRuleKind = "immutable"
+	// TagRuleKindAdminOnly only allows repository admins to push matching tags.
+	TagRuleKindAdminOnly TagRuleKind = "admin_only"
+)
+
+func (k TagRuleKind) IsValid() bool {
+	return k == TagRuleKindImmutable || k == TagRuleKindAdminOnly
+}
+
+type TagRuleChange int
+
+const (
+	TagRuleChangePattern TagRuleChange = iota
+	TagRuleChangeKind
+)
+
+// TagRule protects the tags that match its pattern in a repository or in all repositories of a project. Exactly one of
+// projectId and repositoryId is set, the rules of a repository apply in addition to the ones of its project.
+type TagRule struct {
+	BaseModel
+	change.List[TagRuleChange]
+
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+
+	// pattern is a shell pattern as understood by path.Match, e.g. v* or release-*.
+	pattern string
+	kind    TagRuleKind
+}
+
+func NewProjectTagRule(projectId uuid.UUID, pattern string, kind TagRuleKind) *TagRule {
+	return &TagRule{
+		BaseModel: NewBaseModel(),
+		List:      change.NewChanges[TagRuleChange](),
+		projectId: &projectId,
+		pattern:   pattern,
+		kind:      kind,
+	}
+}
+
+func NewRepositoryTagRule(repositoryId uuid.UUID, pattern string, kind TagRuleKind) *TagRule {
+	return &TagRule{
+		BaseModel:    NewBaseModel(),
+		List:         change.NewChanges[TagRuleChange](),
+		repositoryId: &repositoryId,
+		pattern:      pattern,
+		kind:         kind,
+	}
+}
+
+func NewTagRuleFromDB(projectId *uuid.UUID, repositoryId *uuid.UUID, pattern string, kind TagRuleKind, base BaseModel) *TagRule {
+	return &TagRule{
+		BaseModel:    base,
+		List:         change.NewChanges[TagRuleChange](),
+		projectId:    projectId,
+		repositoryId: repositoryId,
+		pattern:      pattern,
+		kind:         kind,
+	}
+}
+
+func (r *TagRule) GetProjectId() *uuid.UUID {
+	return r.projectId
+}
+
+func (r *TagRule) GetRepositoryId() *uuid.UUID {
+	return r.repositoryId
+}
+
+func (r *TagRule) GetPattern() string {
+	return r.pattern
+}
+
+func (r *TagRule) SetPattern(pattern string) {
+	if r.pattern == pattern {
+		return
+	}
+
+	r.pattern = pattern
+	r.TrackChange(TagRuleChangePattern)
+}
+
+func (r *TagRule) GetKind() TagRuleKind {
+	return r.kind
+}
+
+func (r *TagRule) SetKind(kind TagRuleKind) {
+	if r.kind == kind {
+		return
+	}
+
+	r.kind = kind
+	r.TrackChange(TagRuleChangeKind)
+}
+
+// Matches reports whether the rule applies to the tag. A malformed pattern matches no tag.
+func (r *TagRule) Matches(tag string) bool {
+	matched, err := path.Match(r.pattern, tag)
+	return err == nil && matched
+}
+
+type TagRuleFilter struct {
+	id           *uuid.UUID
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+}
+
+func NewTagRuleFilter() *TagRuleFilter {
+	return &TagRuleFilter{}
+}
+
+func (f *TagRuleFilter) clone() *TagRuleFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *TagRuleFilter) ById(id uuid.UUID) *TagRuleFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *TagRuleFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *TagRuleFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *TagRuleFilter) ByProjectId(projectId uuid.UUID) *TagRuleFilter {
+	cloned := f.clone()
+	cloned.projectId = &projectId
+	return cloned
+}
+
+func (f *TagRuleFilter) HasProjectId() bool {
+	return f.projectId != nil
+}
+
+func (f *TagRuleFilter) GetProjectId() uuid.UUID {
+	return pointer.DerefOrZero(f.projectId)
+}
+
+func (f *TagRuleFilter) ByRepositoryId(repositoryId uuid.UUID) *TagRuleFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &repositoryId
+	return cloned
+}
+
+func (f *TagRuleFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *TagRuleFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+type TagRuleRepository interface {
+	Single(ctx context.Context, filter *TagRuleFilter) (*TagRule, error)
+	First(ctx context.Context, filter *TagRuleFilter) (*TagRule, error)
+	List(ctx context.Context, filter *TagRuleFilter) ([]*TagRule, int, error)
+	Insert(tagRule *TagRule)
+	Update(tagRule *TagRule)
+	Delete(tagRule *TagRule)
+}
@@ -2,41 +2,56 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
 	"github.com/the127/dockyard/internal/utils/pointer"
 )
 
+type TagChange int
+
+const (
+	TagChangeLastPulledAt TagChange = iota
+)
+
 type TagManifestInfo struct {
 	Digest string
 }
 
 type Tag struct {
 	BaseModel
+	change.List[TagChange]
 
 	repositoryId         uuid.UUID
 	repositoryManifestId uuid.UUID
 
 	name string
 
+	// lastPulledAt is nil until the tag is pulled for the first time, it is reset when the tag is pushed again.
+	lastPulledAt *time.Time
+
 	manifestInfo *TagManifestInfo
 }
 
 func NewTag(repositoryId uuid.UUID, repositoryManifestId uuid.UUID, name string) *Tag {
 	return &Tag{
 		BaseModel:            NewBaseModel(),
+		List:                 change.NewChanges[TagChange](),
 		repositoryId:         repositoryId,
 		repositoryManifestId: repositoryManifestId,
 		name:                 name,
 	}
 }
 
-func NewTagFromDB(repositoryId uuid.UUID, repositoryManifestId uuid.UUID, name string, manifestInfo *TagManifestInfo, base BaseModel) *Tag {
+func NewTagFromDB(repositoryId uuid.UUID, repositoryManifestId uuid.UUID, name string, lastPulledAt *time.Time, manifestInfo *TagManifestInfo, base BaseModel) *Tag {
 	return &Tag{
 		BaseModel:            base,
+		List:                 change.NewChanges[TagChange](),
 		repositoryId:         repositoryId,
 		repositoryManifestId: repositoryManifestId,
 		name:                 name,
+		lastPulledAt:         lastPulledAt,
 		manifestInfo:         manifestInfo,
 	}
 }
@@ -53,6 +68,24 @@ func (t *Tag) GetRepositoryId() uuid.UUID {
 	return t.repositoryId
 }
 
+func (t *Tag) GetLastPulledAt() *time.Time {
+	return t.lastPulledAt
+}
+
+func (t *Tag) RecordPull(at time.Time) {
+	t.lastPulledAt = &at
+	t.TrackChange(TagChangeLastPulledAt)
+}
+
+// GetLastUsedAt returns when the tag was last pulled, or pushed if it has not been pulled since.
+func (t *Tag) GetLastUsedAt() time.Time {
+	if t.lastPulledAt != nil && t.lastPulledAt.After(t.GetUpdatedAt()) {
+		return *t.lastPulledAt
+	}
+
+	return t.GetUpdatedAt()
+}
+
 func (t *Tag) GetManifestInfo() *TagManifestInfo {
 	return t.manifestInfo
 }
@@ -67,6 +100,8 @@ type TagFilter struct {
 	repositoryId         *uuid.UUID
 	repositoryManifestId *uuid.UUID
 	name                 *string
+	nameAfter            *string
+	limit                *int
 
 	includeManifestInfo bool
 }
@@ -136,6 +171,37 @@ func (f *TagFilter) GetName() string {
 	return pointer.DerefOrZero(f.name)
 }
 
+// ByNameAfter restricts the result to tags whose name sorts lexically after the given name.
+// Together with WithLimit this is used to page through the tags of a repository.
+func (f *TagFilter) ByNameAfter(name string) *TagFilter {
+	cloned := f.clone()
+	cloned.nameAfter = &name
+	return cloned
+}
+
+func (f *TagFilter) HasNameAfter() bool {
+	return f.nameAfter != nil
+}
+
+func (f *TagFilter) GetNameAfter() string {
+	return pointer.DerefOrZero(f.nameAfter)
+}
+
+// WithLimit restricts the number of returned tags. The total count returned by List is not affected by the limit.
+func (f *TagFilter) WithLimit(limit int) *TagFilter {
+	cloned := f.clone()
+	cloned.limit = &limit
+	return cloned
+}
+
+func (f *TagFilter) HasLimit() bool {
+	return f.limit != nil
+}
+
+func (f *TagFilter) GetLimit() int {
+	return pointer.DerefOrZero(f.limit)
+}
+
 func (f *TagFilter) WithManifestInfo() *TagFilter {
 	cloned := f.clone()
 	cloned.includeManifestInfo = true
@@ -151,5 +217,6 @@ type TagRepository interface {
 	First(ctx context.Context, filter *TagFilter) (*Tag, error)
 	List(ctx context.Context, filter *TagFilter) ([]*Tag, int, error)
 	Insert(tag *Tag)
+	Update(tag *Tag)
 	Delete(tag *Tag)
 }
@@ -19,6 +19,7 @@ const (
 	TenantChangeOidcRoleClaim
 	TenantChangeOidcRoleClaimFormat
 	TenantChangeOidcRoleMapping
+	TenantChangeStorageQuota
 )
 
 type Tenant struct {
@@ -33,6 +34,9 @@ type Tenant struct {
 	oidcRoleClaim       string
 	oidcRoleClaimFormat string
 	oidcRoleMapping     map[string]string
+
+	// storageQuota limits the bytes stored by all repositories of the tenant, nil means unlimited.
+	storageQuota *int64
 }
 
 type TenantOidcConfig struct {
@@ -73,7 +77,7 @@ func NewTenant(slug string, displayName string, oidcConfig TenantOidcConfig) *Te
 	}
 }
 
-func NewTenantFromDB(slug string, displayName string, oidcConfig TenantOidcConfig, base BaseModel) *Tenant {
+func NewTenantFromDB(slug string, displayName string, oidcConfig TenantOidcConfig, storageQuota *int64, base BaseModel) *Tenant {
 	return &Tenant{
 		BaseModel:           base,
 		List:                change.NewChanges[TenantChange](),
@@ -84,6 +88,7 @@ func NewTenantFromDB(slug string, displayName string, oidcConfig TenantOidcConfi
 		oidcRoleClaim:       oidcConfig.RoleClaim,
 		oidcRoleClaimFormat: oidcConfig.RoleClaimFormat,
 		oidcRoleMapping:     oidcConfig.RoleClaimMapping,
+		storageQuota:        storageQuota,
 	}
 }
 
@@ -169,6 +174,19 @@ func (t *Tenant) SetOidcRoleMapping(oidcRoleMapping map[string]string) {
 	t.TrackChange(TenantChangeOidcRoleMapping)
 }
 
+func (t *Tenant) GetStorageQuota() *int64 {
+	return t.storageQuota
+}
+
+func (t *Tenant) SetStorageQuota(storageQuota *int64) {
+	if pointer.Equal(t.storageQuota, storageQuota) {
+		return
+	}
+
+	t.storageQuota = storageQuota
+	t.TrackChange(TenantChangeStorageQuota)
+}
+
 type TenantFilter struct {
 	id   *uuid.UUID
 	slug *string
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type UploadSessionChange int
+
+const (
+	UploadSessionChangeProgress UploadSessionChange = iota
+)
+
+// UploadSession tracks a blob upload that has been started but not completed yet. The session itself lives in the kv
+// store and vanishes once it expires, the tracked backend state allows to clean up the partially uploaded data.
+type UploadSession struct {
+	BaseModel
+	change.List[UploadSessionChange]
+
+	sessionId    uuid.UUID
+	repositoryId uuid.UUID
+	backendState map[string]string
+	expiresAt    time.Time
+}
+
+func NewUploadSession(sessionId uuid.UUID, repositoryId uuid.UUID, backendState map[string]string, expiresAt time.Time) *UploadSession {
+	return &UploadSession{
+		BaseModel:    NewBaseModel(),
+		List:         change.NewChanges[UploadSessionChange](),
+		sessionId:    sessionId,
+		repositoryId: repositoryId,
+		backendState: backendState,
+		expiresAt:    expiresAt,
+	}
+}
+
+func NewUploadSessionFromDB(
+	sessionId uuid.UUID,
+	repositoryId uuid.UUID,
+	backendState map[string]string,
+	expiresAt time.Time,
+	base BaseModel,
+) *UploadSession {
+	return &UploadSession{
+		BaseModel:    base,
+		List:         change.NewChanges[UploadSessionChange](),
+		sessionId:    sessionId,
+		repositoryId: repositoryId,
+		backendState: backendState,
+		expiresAt:    expiresAt,
+	}
+}
+
+func (u *UploadSession) GetSessionId() uuid.UUID {
+	return u.sessionId
+}
+
+func (u *UploadSession) GetRepositoryId() uuid.UUID {
+	return u.repositoryId
+}
+
+func (u *UploadSession) GetBackendState() map[string]string {
+	return u.backendState
+}
+
+func (u *UploadSession) GetExpiresAt() time.Time {
+	return u.expiresAt
+}
+
+// RecordProgress stores the backend state after a chunk has been written and extends the session.
+func (u *UploadSession) RecordProgress(backendState map[string]string, expiresAt time.Time) {
+	u.backendState = backendState
+	u.expiresAt = expiresAt
+	u.TrackChange(UploadSessionChangeProgress)
+}
+
+type UploadSessionFilter struct {
+	id            *uuid.UUID
+	sessionId     *uuid.UUID
+	expiredBefore *time.Time
+	limit         *int
+}
+
+func NewUploadSessionFilter() *UploadSessionFilter {
+	return &UploadSessionFilter{}
+}
+
+func (f *UploadSessionFilter) clone() *UploadSessionFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *UploadSessionFilter) ById(id uuid.UUID) *UploadSessionFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *UploadSessionFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *UploadSessionFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *UploadSessionFilter) BySessionId(sessionId uuid.UUID) *UploadSessionFilter {
+	cloned := f.clone()
+	cloned.sessionId = &sessionId
+	return cloned
+}
+
+func (f *UploadSessionFilter) HasSessionId() bool {
+	return f.sessionId != nil
+}
+
+func (f *UploadSessionFilter) GetSessionId() uuid.UUID {
+	return pointer.DerefOrZero(f.sessionId)
+}
+
+// ByExpiredBefore restricts the result to sessions that expired at the given time. Sessions are ordered by their
+// expiry.
+func (f *UploadSessionFilter) ByExpiredBefore(expiredBefore time.Time) *UploadSessionFilter {
+	cloned := f.clone()
+	cloned.expiredBefore = &expiredBefore
+	return cloned
+}
+
+func (f *UploadSessionFilter) HasExpiredBefore() bool {
+	return f.expiredBefore != nil
+}
+
+func (f *UploadSessionFilter) GetExpiredBefore() time.Time {
+	return pointer.DerefOrZero(f.expiredBefore)
+}
+
+// WithLimit restricts the number of returned sessions. The total count returned by List is not affected by the limit.
+func (f *UploadSessionFilter) WithLimit(limit int) *UploadSessionFilter {
+	cloned := f.clone()
+	cloned.limit = &limit
+	return cloned
+}
+
+func (f *UploadSessionFilter) HasLimit() bool {
+	return f.limit != nil
+}
+
+func (f *UploadSessionFilter) GetLimit() int {
+	return pointer.DerefOrZero(f.limit)
+}
+
+type UploadSessionRepository interface {
+	Single(ctx context.Context, filter *UploadSessionFilter) (*UploadSession, error)
+	First(ctx context.Context, filter *UploadSessionFilter) (*UploadSession, error)
+	List(ctx context.Context, filter *UploadSessionFilter) ([]*UploadSession, int, error)
+	Insert(uploadSession *UploadSession)
+	Update(uploadSession *UploadSession)
+	Delete(uploadSession *UploadSession)
+}
@@ -0,0 +1,232 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/change"
+	"github.com/the127/dockyard/internal/utils/pointer"
+)
+
+type UpstreamChange int
+
+const (
+	UpstreamChangeUrl UpstreamChange = iota
+	UpstreamChangeRepository
+	UpstreamChangeCredentials
+	UpstreamChangeTagTtl
+)
+
+// Upstream turns a repository or all repositories of a project into a pull-through cache of a repository in another
+// registry. Exactly one of projectId and repositoryId is set, an upstream of the repository takes precedence over the
+// one of its project.
+type Upstream struct {
+	BaseModel
+	change.List[UpstreamChange]
+
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+
+	url string
+	// repository is the upstream repository name for repository upstreams, e.g. library/nginx. For project upstreams
+	// it is an optional namespace the repository slugs are resolved in.
+	repository string
+
+	username *string
+	// encryptedPassword is encrypted with the secrets service, it is nil if no password is configured.
+	encryptedPassword []byte
+
+	tagTtl time.Duration
+}
+
+func NewProjectUpstream(projectId uuid.UUID, url string, namespace string, tagTtl time.Duration) *Upstream {
+	return &Upstream{
+		BaseModel:  NewBaseModel(),
+		List:       change.NewChanges[UpstreamChange](),
+		projectId:  &projectId,
+		url:        url,
+		repository: namespace,
+		tagTtl:     tagTtl,
+	}
+}
+
+func NewRepositoryUpstream(repositoryId uuid.UUID, url string, repository string, tagTtl time.Duration) *Upstream {
+	return &Upstream{
+		BaseModel:    NewBaseModel(),
+		List:         change.NewChanges[UpstreamChange](),
+		repositoryId: &repositoryId,
+		url:          url,
+		repository:   repository,
+		tagTtl:       tagTtl,
+	}
+}
+
+func NewUpstreamFromDB(
+	projectId *uuid.UUID,
+	repositoryId *uuid.UUID,
+	url string,
+	repository string,
+	username *string,
+	encryptedPassword []byte,
+	tagTtl time.Duration,
+	base BaseModel,
+) *Upstream {
+	return &Upstream{
+		BaseModel:         base,
+		List:              change.NewChanges[UpstreamChange](),
+		projectId:         projectId,
+		repositoryId:      repositoryId,
+		url:               url,
+		repository:        repository,
+		username:          username,
+		encryptedPassword: encryptedPassword,
+		tagTtl:            tagTtl,
+	}
+}
+
+func (u *Upstream) GetProjectId() *uuid.UUID {
+	return u.projectId
+}
+
+func (u *Upstream) GetRepositoryId() *uuid.UUID {
+	return u.repositoryId
+}
+
+func (u *Upstream) GetUrl() string {
+	return u.url
+}
+
+func (u *Upstream) SetUrl(url string) {
+	if u.url == url {
+		return
+	}
+
+	u.url = url
+	u.TrackChange(UpstreamChangeUrl)
+}
+
+func (u *Upstream) GetRepository() string {
+	return u.repository
+}
+
+func (u *Upstream) SetRepository(repository string) {
+	if u.repository == repository {
+		return
+	}
+
+	u.repository = repository
+	u.TrackChange(UpstreamChangeRepository)
+}
+
+// ResolveRepository returns the name of the upstream repository that is proxied by the repository with the slug.
+func (u *Upstream) ResolveRepository(repositorySlug string) string {
+	if u.repositoryId != nil {
+		return u.repository
+	}
+
+	if u.repository == "" {
+		return repositorySlug
+	}
+
+	return u.repository + "/" + repositorySlug
+}
+
+func (u *Upstream) GetUsername() *string {
+	return u.username
+}
+
+func (u *Upstream) GetEncryptedPassword() []byte {
+	return u.encryptedPassword
+}
+
+func (u *Upstream) SetCredentials(username *string, encryptedPassword []byte) {
+	if pointer.Equal(u.username, username) && bytes.Equal(u.encryptedPassword, encryptedPassword) {
+		return
+	}
+
+	u.username = username
+	u.encryptedPassword = encryptedPassword
+	u.TrackChange(UpstreamChangeCredentials)
+}
+
+// GetTagTtl returns how long a tag fetched from the upstream is served before it is revalidated.
+func (u *Upstream) GetTagTtl() time.Duration {
+	return u.tagTtl
+}
+
+func (u *Upstream) SetTagTtl(tagTtl time.Duration) {
+	if u.tagTtl == tagTtl {
+		return
+	}
+
+	u.tagTtl = tagTtl
+	u.TrackChange(UpstreamChangeTagTtl)
+}
+
+type UpstreamFilter struct {
+	id           *uuid.UUID
+	projectId    *uuid.UUID
+	repositoryId *uuid.UUID
+}
+
+func NewUpstreamFilter() *UpstreamFilter {
+	return &UpstreamFilter{}
+}
+
+func (f *UpstreamFilter) clone() *UpstreamFilter {
+	cloned := *f
+	return &cloned
+}
+
+func (f *UpstreamFilter) ById(id uuid.UUID) *UpstreamFilter {
+	cloned := f.clone()
+	cloned.id = &id
+	return cloned
+}
+
+func (f *UpstreamFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *UpstreamFilter) GetId() uuid.UUID {
+	return pointer.DerefOrZero(f.id)
+}
+
+func (f *UpstreamFilter) ByProjectId(projectId uuid.UUID) *UpstreamFilter {
+	cloned := f.clone()
+	cloned.projectId = &projectId
+	return cloned
+}
+
+func (f *UpstreamFilter) HasProjectId() bool {
+	return f.projectId != nil
+}
+
+func (f *UpstreamFilter) GetProjectId() uuid.UUID {
+	return pointer.DerefOrZero(f.projectId)
+}
+
+func (f *UpstreamFilter) ByRepositoryId(repositoryId uuid.UUID) *UpstreamFilter {
+	cloned := f.clone()
+	cloned.repositoryId = &repositoryId
+	return cloned
+}
+
+func (f *UpstreamFilter) HasRepositoryId() bool {
+	return f.repositoryId != nil
+}
+
+func (f *UpstreamFilter) GetRepositoryId() uuid.UUID {
+	return pointer.DerefOrZero(f.repositoryId)
+}
+
+type UpstreamRepository interface {
+	Single(ctx context.Context, filter *UpstreamFilter) (*Upstream, error)
+	First(ctx context.Context, filter *UpstreamFilter) (*Upstream, error)
+	List(ctx context.Context, filter *UpstreamFilter) ([]*Upstream, int, error)
+	Insert(upstream *Upstream)
+	Update(upstream *Upstream)
+	Delete(upstream *Upstream)
+}
@@ -81,7 +81,7 @@ func mapBlobApi(r *mux.Router) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	// TODO: implement authentication
+	// requests are authenticated by the signature of the link, see blobStorage.Service.VerifyBlobDownloadLink
 	apiRouter.HandleFunc("/{digest}", blobhandlers.DownloadBlob).Methods(http.MethodGet, http.MethodOptions)
 }
 
@@ -99,6 +99,7 @@ func mapAdminApi(r *mux.Router) {
 	authApiRouter.HandleFunc("/tenants", adminhandlers.CreateTenant).Methods(http.MethodPost, http.MethodOptions)
 	authApiRouter.HandleFunc("/tenants", adminhandlers.ListTenants).Methods(http.MethodGet, http.MethodOptions)
 	authApiRouter.HandleFunc("/tenants/{tenant}", adminhandlers.GetTenant).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/tenants/{tenant}/quota", adminhandlers.SetTenantStorageQuota).Methods(http.MethodPut, http.MethodOptions)
 }
 
 func mapApi(r *mux.Router) {
@@ -116,9 +117,37 @@ func mapApi(r *mux.Router) {
 	authApiRouter.HandleFunc("/pats", apihandlers.CreatePat).Methods(http.MethodPost, http.MethodOptions)
 	authApiRouter.HandleFunc("/pats", apihandlers.ListPats).Methods(http.MethodGet, http.MethodOptions)
 
+	authApiRouter.HandleFunc("/usage", apihandlers.GetTenantStorageUsage).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/packages", apihandlers.SearchPackages).Methods(http.MethodGet, http.MethodOptions)
+
 	authApiRouter.HandleFunc("/projects", apihandlers.CreateProject).Methods(http.MethodPost, http.MethodOptions)
 	authApiRouter.HandleFunc("/projects", apihandlers.ListProjects).Methods(http.MethodGet, http.MethodOptions)
 	authApiRouter.HandleFunc("/projects/{project}", apihandlers.GetProject).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/quota", apihandlers.SetProjectStorageQuota).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/usage", apihandlers.GetProjectStorageUsage).Methods(http.MethodGet, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/upstream", apihandlers.GetUpstream).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/upstream", apihandlers.SetUpstream).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/upstream", apihandlers.DeleteUpstream).Methods(http.MethodDelete, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/tag-rules", apihandlers.CreateTagRule).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/tag-rules", apihandlers.ListTagRules).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/tag-rules/{rule}", apihandlers.UpdateTagRule).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/tag-rules/{rule}", apihandlers.DeleteTagRule).Methods(http.MethodDelete, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/retention-policies", apihandlers.CreateRetentionPolicy).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/retention-policies", apihandlers.ListRetentionPolicies).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/retention-policies/run", apihandlers.ApplyRetentionPolicies).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/retention-policies/{policy}", apihandlers.UpdateRetentionPolicy).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/retention-policies/{policy}", apihandlers.DeleteRetentionPolicy).Methods(http.MethodDelete, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules", apihandlers.CreateReplicationRule).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules", apihandlers.ListReplicationRules).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules/{rule}", apihandlers.GetReplicationRule).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules/{rule}", apihandlers.UpdateReplicationRule).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules/{rule}", apihandlers.DeleteReplicationRule).Methods(http.MethodDelete, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules/{rule}/replicate", apihandlers.ReplicateNow).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/replication-rules/{rule}/tasks", apihandlers.ListReplicationTasks).Methods(http.MethodGet, http.MethodOptions)
 
 	authApiRouter.HandleFunc("/projects/{project}/repositories", apihandlers.CreateRepository).Methods(http.MethodPost, http.MethodOptions)
 	authApiRouter.HandleFunc("/projects/{project}/repositories", apihandlers.ListRepositories).Methods(http.MethodGet, http.MethodOptions)
@@ -129,16 +158,42 @@ func mapApi(r *mux.Router) {
 	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/readme", apihandlers.UpdateRepositoryReadme).Methods(http.MethodPut, http.MethodOptions)
 
 	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/tags", apihandlers.ListTags).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/usage", apihandlers.GetRepositoryStorageUsage).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/manifests/{reference}", apihandlers.GetManifest).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/manifests/{reference}/files", apihandlers.ListImageFiles).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/manifests/{reference}/file", apihandlers.DownloadImageFile).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/manifests/{reference}/sbom", apihandlers.ListImageSboms).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/manifests/{reference}/provenance", apihandlers.ListImageProvenance).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/layers/{digest}/files", apihandlers.ListLayerFiles).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/layers/{digest}/file", apihandlers.DownloadLayerFile).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/compare", apihandlers.CompareImages).Methods(http.MethodGet, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/tag-rules", apihandlers.CreateTagRule).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/tag-rules", apihandlers.ListTagRules).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/tag-rules/{rule}", apihandlers.UpdateTagRule).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/tag-rules/{rule}", apihandlers.DeleteTagRule).Methods(http.MethodDelete, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/retention-policies", apihandlers.CreateRetentionPolicy).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/retention-policies", apihandlers.ListRetentionPolicies).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/retention-policies/run", apihandlers.ApplyRetentionPolicies).Methods(http.MethodPost, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/retention-policies/{policy}", apihandlers.UpdateRetentionPolicy).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/retention-policies/{policy}", apihandlers.DeleteRetentionPolicy).Methods(http.MethodDelete, http.MethodOptions)
+
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/upstream", apihandlers.GetUpstream).Methods(http.MethodGet, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/upstream", apihandlers.SetUpstream).Methods(http.MethodPut, http.MethodOptions)
+	authApiRouter.HandleFunc("/projects/{project}/repositories/{repository}/upstream", apihandlers.DeleteUpstream).Methods(http.MethodDelete, http.MethodOptions)
 }
 
 func mapOciApi(r *mux.Router) {
 	v2Router := r.PathPrefix("/v2").Subrouter()
+	v2Router.Use(middlewares.DistributionApiVersionMiddleware())
 	v2Router.Use(ociAuthentication.AuthenticationMiddleware())
 
 	v2Router.HandleFunc("/token", ocihandlers.Tokens).Methods(http.MethodPost, http.MethodGet, http.MethodOptions)
 
 	// implement end-1 api endpoint that shows the support for the oci api specification
 	v2Router.HandleFunc("/", ocihandlers.Root).Methods(http.MethodGet, http.MethodOptions)
+	v2Router.HandleFunc("/_catalog", ocihandlers.Catalog).Methods(http.MethodGet, http.MethodOptions)
 
 	projectRepoRouter := v2Router.PathPrefix("/{project}/{repository}").Subrouter()
 	projectRepoRouter.Use(middlewares.OciNameMiddleware())
@@ -148,13 +203,20 @@ func mapOciApi(r *mux.Router) {
 func mapNamedOciApi(r *mux.Router) {
 	r.HandleFunc("/blobs/{digest}", ocihandlers.BlobsDownload).Methods(http.MethodGet, http.MethodOptions)
 	r.HandleFunc("/blobs/{digest}", ocihandlers.BlobExists).Methods(http.MethodHead, http.MethodOptions)
+	r.HandleFunc("/blobs/{digest}", ocihandlers.BlobsDelete).Methods(http.MethodDelete, http.MethodOptions)
 
 	r.HandleFunc("/manifests/{reference}", ocihandlers.ManifestsDownload).Methods(http.MethodGet, http.MethodOptions)
 	r.HandleFunc("/manifests/{reference}", ocihandlers.ManifestsExists).Methods(http.MethodHead, http.MethodOptions)
 
 	r.HandleFunc("/blobs/uploads/", ocihandlers.BlobsUploadStart).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/blobs/uploads/{reference}", ocihandlers.UploadStatus).Methods(http.MethodGet, http.MethodOptions)
 	r.HandleFunc("/blobs/uploads/{reference}", ocihandlers.UploadChunk).Methods(http.MethodPatch, http.MethodOptions)
 	r.HandleFunc("/blobs/uploads/{reference}", ocihandlers.FinishUpload).Methods(http.MethodPut, http.MethodOptions)
 
 	r.HandleFunc("/manifests/{reference}", ocihandlers.UploadManifest).Methods(http.MethodPut, http.MethodOptions)
+	r.HandleFunc("/manifests/{reference}", ocihandlers.ManifestsDelete).Methods(http.MethodDelete, http.MethodOptions)
+
+	r.HandleFunc("/tags/list", ocihandlers.TagsList).Methods(http.MethodGet, http.MethodOptions)
+
+	r.HandleFunc("/referrers/{digest}", ocihandlers.ReferrersList).Methods(http.MethodGet, http.MethodOptions)
 }
@@ -0,0 +1,187 @@
+package blobStorage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/The127/signr"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/storageBackends"
+	"github.com/the127/dockyard/internal/utils/apiError"
+)
+
+type BlobDownloadLinkParams struct {
+	Digest     string
+	TenantSlug string
+	// ClientIp is the address of the client the link is issued to, see Service.ClientIp.
+	ClientIp string
+}
+
+// linkKeyDerivationLabel is signed with the tenant's key to derive the HMAC key of its links. EdDSA signatures are
+// deterministic, so every instance sharing the key manager derives the same HMAC key.
+const linkKeyDerivationLabel = "dockyard blob download link"
+
+const (
+	linkTenantParam    = "tenant"
+	linkExpiresParam   = "expires"
+	linkClientIpParam  = "ip"
+	linkSignatureParam = "signature"
+)
+
+func (s *service) GetBlobDownloadLink(ctx context.Context, params BlobDownloadLinkParams) (string, error) {
+	if linkProvider, ok := s.backend.(storageBackends.DownloadLinkProvider); ok {
+		return linkProvider.GetDownloadLink(ctx, params.Digest)
+	}
+
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	expires := clockService.Now().Add(s.linkExpiry).Unix()
+
+	var clientIp string
+	if s.bindLinksToClientIp {
+		clientIp = params.ClientIp
+	}
+
+	signature, err := signLink(ctx, params.Digest, params.TenantSlug, expires, clientIp)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set(linkTenantParam, params.TenantSlug)
+	query.Set(linkExpiresParam, strconv.FormatInt(expires, 10))
+	if clientIp != "" {
+		query.Set(linkClientIpParam, clientIp)
+	}
+	query.Set(linkSignatureParam, signature)
+
+	return fmt.Sprintf("%s/blobs/api/v1/%s?%s", s.externalUrl, params.Digest, query.Encode()), nil
+}
+
+func (s *service) VerifyBlobDownloadLink(ctx context.Context, r *http.Request, digest string) (time.Time, error) {
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	query := r.URL.Query()
+	tenantSlug := query.Get(linkTenantParam)
+	clientIp := query.Get(linkClientIpParam)
+	signature := query.Get(linkSignatureParam)
+
+	if tenantSlug == "" || signature == "" {
+		return time.Time{}, fmt.Errorf("missing link signature: %w", apiError.ErrApiForbidden)
+	}
+
+	expires, err := strconv.ParseInt(query.Get(linkExpiresParam), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid link expiry: %w", apiError.ErrApiForbidden)
+	}
+
+	expectedSignature, err := signLink(ctx, digest, tenantSlug, expires, clientIp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
+		return time.Time{}, fmt.Errorf("invalid link signature: %w", apiError.ErrApiForbidden)
+	}
+
+	expiresAt := time.Unix(expires, 0)
+	if !clockService.Now().Before(expiresAt) {
+		return time.Time{}, fmt.Errorf("link expired: %w", apiError.ErrApiForbidden)
+	}
+
+	if clientIp != "" && clientIp != s.ClientIp(r) {
+		return time.Time{}, fmt.Errorf("link was issued to another client: %w", apiError.ErrApiForbidden)
+	}
+
+	return expiresAt, nil
+}
+
+// signLink computes the signature of a download link. Fields are separated by newlines, which none of them can
+// contain.
+func signLink(ctx context.Context, digest string, tenantSlug string, expires int64, clientIp string) (string, error) {
+	scope := middlewares.GetScope(ctx)
+	keyManager := ioc.GetDependency[signr.KeyManager](scope)
+
+	signingKey, err := keyManager.
+		GetGroup(fmt.Sprintf("blob-link-signing-key:%s", tenantSlug)).
+		GetKey("EdDSA")
+	if err != nil {
+		return "", fmt.Errorf("getting signing key: %w", err)
+	}
+
+	hmacKey, err := signingKey.Sign([]byte(linkKeyDerivationLabel))
+	if err != nil {
+		return "", fmt.Errorf("deriving link key: %w", err)
+	}
+
+	payload := strings.Join([]string{digest, tenantSlug, strconv.FormatInt(expires, 10), clientIp}, "\n")
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (s *service) ClientIp(r *http.Request) string {
+	remoteAddr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+
+	if !s.isTrustedProxy(remoteAddr) {
+		return remoteAddr
+	}
+
+	// every proxy appends the address it received the request from, the client is the last one that is not a trusted
+	// proxy itself. Entries before it are set by the client and cannot be trusted.
+	var forwardedFor []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(header, ",") {
+			forwardedFor = append(forwardedFor, strings.TrimSpace(address))
+		}
+	}
+
+	clientIp := remoteAddr
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		_, err := netip.ParseAddr(forwardedFor[i])
+		if err != nil {
+			break
+		}
+
+		clientIp = forwardedFor[i]
+		if !s.isTrustedProxy(clientIp) {
+			break
+		}
+	}
+
+	return clientIp
+}
+
+func (s *service) isTrustedProxy(address string) bool {
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+
+	for _, trustedProxy := range s.trustedProxies {
+		if trustedProxy.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,385 @@
+package blobStorage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/The127/signr"
+	signrMemory "github.com/The127/signr/backends/memory"
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/storageBackends/inmemory"
+	"github.com/the127/dockyard/internal/utils/apiError"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+const linkTestExpiry = 20 * time.Minute
+
+var linkTestDigest = digest.SHA256.FromBytes([]byte("hello"))
+
+type LinksTestSuite struct {
+	suite.Suite
+	ctx     context.Context
+	now     time.Time
+	setTime clock.TimeSetterFn
+}
+
+func TestLinksTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(LinksTestSuite))
+}
+
+func (s *LinksTestSuite) SetupTest() {
+	s.now = time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	var clockService clock.Service
+	clockService, s.setTime = clock.NewMockClock(s.now)
+
+	dc := ioc.NewDependencyCollection()
+	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) signr.KeyManager {
+		keyManager, err := signr.New(signr.Config{
+			Backend: signrMemory.Config{
+				Clock: ioc.GetDependency[clock.Service](dp),
+			},
+		})
+		s.Require().NoError(err)
+
+		return keyManager
+	})
+
+	s.ctx = middlewares.ContextWithScope(context.Background(), dc.BuildProvider().NewScope())
+}
+
+func (s *LinksTestSuite) newService(bindLinksToClientIp bool, trustedProxies ...string) Service {
+	return NewBlobStorageService(inmemory.New(), config.BlobStorageConfig{
+		ExternalUrl:         "https://registry.example.com/",
+		LinkExpiry:          linkTestExpiry,
+		BindLinksToClientIp: bindLinksToClientIp,
+		TrustedProxies:      trustedProxies,
+	})
+}
+
+func (s *LinksTestSuite) getLink(service Service, clientIp string) *url.URL {
+	link, err := service.GetBlobDownloadLink(s.ctx, BlobDownloadLinkParams{
+		Digest:     linkTestDigest,
+		TenantSlug: "tenant",
+		ClientIp:   clientIp,
+	})
+	s.Require().NoError(err)
+
+	parsed, err := url.Parse(link)
+	s.Require().NoError(err)
+
+	return parsed
+}
+
+func newLinkRequest(link *url.URL, remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, link.String(), nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func (s *LinksTestSuite) TestGetBlobDownloadLink() {
+	// arrange
+	service := s.newService(false)
+
+	// act
+	link := s.getLink(service, "192.0.2.1")
+
+	// assert
+	s.Equal("registry.example.com", link.Host)
+	s.Equal("/blobs/api/v1/"+linkTestDigest, link.Path)
+	s.Equal("tenant", link.Query().Get(linkTenantParam))
+	s.Equal(fmt.Sprint(s.now.Add(linkTestExpiry).Unix()), link.Query().Get(linkExpiresParam))
+	s.False(link.Query().Has(linkClientIpParam))
+	s.NotEmpty(link.Query().Get(linkSignatureParam))
+}
+
+func (s *LinksTestSuite) TestVerifyBlobDownloadLink() {
+	// arrange
+	service := s.newService(false)
+	link := s.getLink(service, "192.0.2.1")
+
+	// act
+	expiresAt, err := service.VerifyBlobDownloadLink(s.ctx, newLinkRequest(link, "198.51.100.1:1234"), linkTestDigest)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(s.now.Add(linkTestExpiry).Unix(), expiresAt.Unix())
+}
+
+func (s *LinksTestSuite) TestVerifyBlobDownloadLink_Tampered() {
+	testCases := []struct {
+		name   string
+		tamper func(query url.Values) string
+	}{
+		{
+			name: "other digest",
+			tamper: func(query url.Values) string {
+				return digest.SHA256.FromBytes([]byte("other"))
+			},
+		},
+		{
+			name: "other tenant",
+			tamper: func(query url.Values) string {
+				query.Set(linkTenantParam, "other")
+				return linkTestDigest
+			},
+		},
+		{
+			name: "extended expiry",
+			tamper: func(query url.Values) string {
+				query.Set(linkExpiresParam, fmt.Sprint(s.now.Add(24*time.Hour).Unix()))
+				return linkTestDigest
+			},
+		},
+		{
+			name: "invalid expiry",
+			tamper: func(query url.Values) string {
+				query.Set(linkExpiresParam, "never")
+				return linkTestDigest
+			},
+		},
+		{
+			name: "other signature",
+			tamper: func(query url.Values) string {
+				query.Set(linkSignatureParam, "c2lnbmF0dXJl")
+				return linkTestDigest
+			},
+		},
+		{
+			name: "missing signature",
+			tamper: func(query url.Values) string {
+				query.Del(linkSignatureParam)
+				return linkTestDigest
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			service := s.newService(false)
+			link := s.getLink(service, "192.0.2.1")
+
+			query := link.Query()
+			requestedDigest := testCase.tamper(query)
+			link.RawQuery = query.Encode()
+
+			// act
+			_, err := service.VerifyBlobDownloadLink(s.ctx, newLinkRequest(link, "192.0.2.1:1234"), requestedDigest)
+
+			// assert
+			s.ErrorIs(err, apiError.ErrApiForbidden)
+		})
+	}
+}
+
+func (s *LinksTestSuite) TestVerifyBlobDownloadLink_Expired() {
+	// arrange
+	service := s.newService(false)
+	link := s.getLink(service, "192.0.2.1")
+
+	s.setTime(s.now.Add(linkTestExpiry))
+
+	// act
+	_, err := service.VerifyBlobDownloadLink(s.ctx, newLinkRequest(link, "192.0.2.1:1234"), linkTestDigest)
+
+	// assert
+	s.ErrorIs(err, apiError.ErrApiForbidden)
+	s.ErrorContains(err, "expired")
+}
+
+func (s *LinksTestSuite) TestVerifyBlobDownloadLink_BoundToClientIp() {
+	testCases := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		// removeIp drops the client IP from the link.
+		removeIp bool
+		valid    bool
+	}{
+		{
+			name:       "same client",
+			remoteAddr: "192.0.2.1:1234",
+			valid:      true,
+		},
+		{
+			name:       "other client",
+			remoteAddr: "198.51.100.1:1234",
+		},
+		{
+			name:       "client IP removed from the link",
+			remoteAddr: "198.51.100.1:1234",
+			removeIp:   true,
+		},
+		{
+			name:           "same client behind a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   "192.0.2.1",
+			valid:          true,
+		},
+		{
+			name:           "other client behind a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   "198.51.100.1",
+		},
+		{
+			name:         "forwarded for header of an untrusted client",
+			remoteAddr:   "198.51.100.1:1234",
+			forwardedFor: "192.0.2.1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			service := s.newService(true, testCase.trustedProxies...)
+			link := s.getLink(service, "192.0.2.1")
+			s.Equal("192.0.2.1", link.Query().Get(linkClientIpParam))
+
+			if testCase.removeIp {
+				query := link.Query()
+				query.Del(linkClientIpParam)
+				link.RawQuery = query.Encode()
+			}
+
+			r := newLinkRequest(link, testCase.remoteAddr)
+			if testCase.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", testCase.forwardedFor)
+			}
+
+			// act
+			_, err := service.VerifyBlobDownloadLink(s.ctx, r, linkTestDigest)
+
+			// assert
+			if testCase.valid {
+				s.NoError(err)
+			} else {
+				s.ErrorIs(err, apiError.ErrApiForbidden)
+			}
+		})
+	}
+}
+
+func (s *LinksTestSuite) TestClientIp() {
+	testCases := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		clientIp       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "192.0.2.1:1234",
+			clientIp:   "192.0.2.1",
+		},
+		{
+			name:       "direct IPv6 client",
+			remoteAddr: "[2001:db8::1]:1234",
+			clientIp:   "2001:db8::1",
+		},
+		{
+			name:         "forwarded for header without trusted proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"192.0.2.1"},
+			clientIp:     "10.0.0.1",
+		},
+		{
+			name:           "forwarded for header of an untrusted client",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "198.51.100.1:1234",
+			forwardedFor:   []string{"192.0.2.1"},
+			clientIp:       "198.51.100.1",
+		},
+		{
+			name:           "trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"192.0.2.1"},
+			clientIp:       "192.0.2.1",
+		},
+		{
+			name:           "chain of trusted proxies",
+			trustedProxies: []string{"10.0.0.0/8", "172.16.0.0/12"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"192.0.2.1, 172.16.0.1"},
+			clientIp:       "192.0.2.1",
+		},
+		{
+			name:           "chain split across headers",
+			trustedProxies: []string{"10.0.0.0/8", "172.16.0.0/12"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"192.0.2.1", "172.16.0.1"},
+			clientIp:       "192.0.2.1",
+		},
+		{
+			name:           "addresses set by the client are ignored",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"203.0.113.1, 192.0.2.1"},
+			clientIp:       "192.0.2.1",
+		},
+		{
+			name:           "invalid address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"192.0.2.1, unknown, 10.0.0.2"},
+			clientIp:       "10.0.0.2",
+		},
+		{
+			name:           "only trusted proxies",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"10.0.0.2"},
+			clientIp:       "10.0.0.2",
+		},
+		{
+			name:           "trusted proxy without forwarded for header",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:1234",
+			clientIp:       "10.0.0.1",
+		},
+		{
+			name:           "IPv4 mapped IPv6 proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "[::ffff:10.0.0.1]:1234",
+			forwardedFor:   []string{"192.0.2.1"},
+			clientIp:       "192.0.2.1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			service := s.newService(true, testCase.trustedProxies...)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = testCase.remoteAddr
+			for _, forwardedFor := range testCase.forwardedFor {
+				r.Header.Add("X-Forwarded-For", forwardedFor)
+			}
+
+			// act
+			clientIp := service.ClientIp(r)
+
+			// assert
+			s.Equal(testCase.clientIp, clientIp)
+		})
+	}
+}
@@ -2,20 +2,28 @@ package blobStorage
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/config"
+	db "github.com/the127/dockyard/internal/database"
 	"github.com/the127/dockyard/internal/jsontypes"
+	"github.com/the127/dockyard/internal/logging"
 	"github.com/the127/dockyard/internal/middlewares"
+	"github.com/the127/dockyard/internal/repositories"
 	"github.com/the127/dockyard/internal/services/kv"
 	"github.com/the127/dockyard/internal/storageBackends"
+	"github.com/the127/dockyard/internal/utils/digest"
 	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
@@ -49,6 +57,11 @@ type UploadCompleteBlobResponse struct {
 	Size   int64
 }
 
+type DownloadBlobResponse struct {
+	// StatusCode is the status of the response, 206 for range requests and 304 if the client has the blob already.
+	StatusCode int
+}
+
 type BlobContentType string
 
 const (
@@ -58,38 +71,99 @@ const (
 
 type Service interface {
 	StartUploadSession(ctx context.Context, params StartUploadSessionParams) (*StartUploadSessionResponse, error)
-	UploadWriteChunk(ctx context.Context, sessionId uuid.UUID, reader io.Reader) (*UploadWriteChunkResponse, error)
+	// UploadWriteChunk appends the data of reader to the upload session. If rangeStart is not nil it must match the
+	// current end of the upload, otherwise a 416 error carrying the current Range header is returned.
+	UploadWriteChunk(ctx context.Context, sessionId uuid.UUID, rangeStart *int64, reader io.Reader) (*UploadWriteChunkResponse, error)
 	CompleteUpload(ctx context.Context, sessionId uuid.UUID, digest string) (*CompleteUploadResponse, error)
 	GetUploadRangeEnd(ctx context.Context, sessionId uuid.UUID) (int64, error)
+	GetChunkMinLength() int64
+	// AbortExpiredUploadSession removes the partially uploaded data of an upload session that was not completed in
+	// time. Sessions that are in use or have been extended in the meantime are kept, false is returned for them.
+	AbortExpiredUploadSession(ctx context.Context, sessionId uuid.UUID) (bool, error)
 
 	UploadCompleteBlob(ctx context.Context, digest string, reader io.Reader, contentType BlobContentType) (*UploadCompleteBlobResponse, error)
 
 	DeleteBlob(ctx context.Context, digest string) error
 
-	GetBlobDownloadLink(ctx context.Context, digest string) (string, error)
-	DownloadBlob(ctx context.Context, w http.ResponseWriter, digest string) error
+	// GetBlobDownloadLink returns the URL a client is redirected to for downloading a blob. Links to the blob API are
+	// signed and expire, see VerifyBlobDownloadLink.
+	GetBlobDownloadLink(ctx context.Context, params BlobDownloadLinkParams) (string, error)
+	// VerifyBlobDownloadLink checks the signature and expiry of a request to the blob API. It returns when the link
+	// expires, or an ErrApiForbidden error if the link is invalid.
+	VerifyBlobDownloadLink(ctx context.Context, r *http.Request, digest string) (time.Time, error)
+	// ClientIp returns the IP address of the client that sent the request, links bound to a client are issued to and
+	// verified against it. For requests forwarded by a trusted proxy it is taken from the X-Forwarded-For header.
+	ClientIp(r *http.Request) string
+	// DownloadBlob writes the blob to w, answering range and conditional requests. The digest is used as ETag. The ETag
+	// and the cacheControl header, if not empty, are only sent if the blob is served, so that errors are never cached.
+	DownloadBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, digest string, cacheControl string) (*DownloadBlobResponse, error)
+	// OpenBlob opens the blob for reading, the caller has to close the returned reader.
+	OpenBlob(ctx context.Context, digest string) (io.ReadCloser, error)
 }
 
+const (
+	// sessionLockExpiration should be longer than the longest request writing to a session, a lock that expires while
+	// its request is still running lets another request write to the session at the same time.
+	sessionLockExpiration = time.Minute * 15
+)
+
 func buildSessionCacheKey(sessionId uuid.UUID) string {
 	return fmt.Sprintf("blob_upload_session:%s", sessionId)
 }
 
+func buildSessionLockKey(sessionId uuid.UUID) string {
+	return fmt.Sprintf("blob_upload_session_lock:%s", sessionId)
+}
+
+// FormatRange formats the Range header value of an upload session whose data ends at rangeEnd (exclusive).
+func FormatRange(rangeEnd int64) string {
+	if rangeEnd == 0 {
+		return "0-0"
+	}
+
+	return fmt.Sprintf("0-%d", rangeEnd-1)
+}
+
 type service struct {
 	backend storageBackends.StorageBackend
+	// sessionTtl is how long an upload session lives without receiving data.
+	sessionTtl time.Duration
+
+	externalUrl         string
+	linkExpiry          time.Duration
+	bindLinksToClientIp bool
+	trustedProxies      []netip.Prefix
 }
 
-func NewBlobStorageService(backend storageBackends.StorageBackend) Service {
+// NewBlobStorageService creates the service, the trusted proxies of the config have to be valid networks.
+func NewBlobStorageService(backend storageBackends.StorageBackend, c config.BlobStorageConfig) Service {
+	trustedProxies := make([]netip.Prefix, len(c.TrustedProxies))
+	for i, trustedProxy := range c.TrustedProxies {
+		trustedProxies[i] = netip.MustParsePrefix(trustedProxy)
+	}
+
 	return &service{
-		backend: backend,
+		backend:             backend,
+		sessionTtl:          c.UploadSessionTtl,
+		externalUrl:         strings.TrimSuffix(c.ExternalUrl, "/"),
+		linkExpiry:          c.LinkExpiry,
+		bindLinksToClientIp: c.BindLinksToClientIp,
+		trustedProxies:      trustedProxies,
 	}
 }
 
 func (s *service) StartUploadSession(ctx context.Context, params StartUploadSessionParams) (*StartUploadSessionResponse, error) {
 	scope := middlewares.GetScope(ctx)
 
-	digestState, err := sha256.New().(encoding.BinaryMarshaler).MarshalBinary()
+	// the client only names the digest algorithm when the upload is completed, so the data is hashed with all of them
+	hashers := make(map[digest.Algorithm]hash.Hash)
+	for _, algorithm := range digest.Algorithms() {
+		hashers[algorithm] = algorithm.New()
+	}
+
+	digestState, err := marshalHashers(hashers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal digest state: %w", err)
+		return nil, err
 	}
 
 	id := uuid.New()
@@ -117,11 +191,16 @@ func (s *service) StartUploadSession(ctx context.Context, params StartUploadSess
 	}
 
 	kvStore := ioc.GetDependency[kv.Store](scope)
-	err = kvStore.Set(ctx, buildSessionCacheKey(session.Id), string(jsonBytes), kv.WithExpiration(time.Minute*5))
+	err = kvStore.Set(ctx, buildSessionCacheKey(session.Id), string(jsonBytes), kv.WithExpiration(s.sessionTtl))
 	if err != nil {
 		return nil, fmt.Errorf("failed to set session: %w", err)
 	}
 
+	err = s.trackUploadSession(ctx, &session)
+	if err != nil {
+		return nil, err
+	}
+
 	return &StartUploadSessionResponse{
 		SessionId: session.Id,
 	}, nil
@@ -138,31 +217,39 @@ func (r *countReader) Read(p []byte) (n int, err error) {
 	return
 }
 
-func (s *service) UploadWriteChunk(ctx context.Context, sessionId uuid.UUID, reader io.Reader) (*UploadWriteChunkResponse, error) {
+func (s *service) UploadWriteChunk(ctx context.Context, sessionId uuid.UUID, rangeStart *int64, reader io.Reader) (*UploadWriteChunkResponse, error) {
 	scope := middlewares.GetScope(ctx)
 	kvStore := ioc.GetDependency[kv.Store](scope)
 
-	value, ok, err := kvStore.Get(ctx, buildSessionCacheKey(sessionId))
+	unlock, err := lockSession(ctx, kvStore, sessionId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
-	}
-	if !ok {
-		return nil, fmt.Errorf("session not found")
+		return nil, err
 	}
+	defer unlock()
 
-	var session jsontypes.UploadSession
-	err = json.Unmarshal([]byte(value), &session)
+	session, err := getSession(ctx, kvStore, sessionId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+		return nil, err
 	}
 
-	hasher := sha256.New()
-	err = hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.DigestState)
+	if rangeStart != nil && *rangeStart != session.RangeEnd {
+		return nil, ociError.NewOciError(ociError.BlobUploadInvalid).
+			WithMessage("chunk is out of order").
+			WithHttpCode(http.StatusRequestedRangeNotSatisfiable).
+			WithHeader("Range", FormatRange(session.RangeEnd))
+	}
+
+	hashers, err := unmarshalHashers(session.DigestState)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal digest state: %w", err)
+		return nil, err
 	}
 
-	teeReader := io.TeeReader(reader, hasher)
+	hashWriters := make([]io.Writer, 0, len(hashers))
+	for _, hasher := range hashers {
+		hashWriters = append(hashWriters, hasher)
+	}
+
+	teeReader := io.TeeReader(reader, io.MultiWriter(hashWriters...))
 	countReader := &countReader{teeReader, 0}
 
 	newBackendState, err := s.backend.UploadAddChunk(ctx, session.BackendState, countReader)
@@ -170,9 +257,9 @@ func (s *service) UploadWriteChunk(ctx context.Context, sessionId uuid.UUID, rea
 		return nil, err
 	}
 
-	digestState, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	digestState, err := marshalHashers(hashers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal digest state: %w", err)
+		return nil, err
 	}
 
 	session.DigestState = digestState
@@ -184,11 +271,16 @@ func (s *service) UploadWriteChunk(ctx context.Context, sessionId uuid.UUID, rea
 		return nil, fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	err = kvStore.Set(ctx, buildSessionCacheKey(session.Id), string(jsonBytes), kv.WithExpiration(time.Minute*5))
+	err = kvStore.Set(ctx, buildSessionCacheKey(session.Id), string(jsonBytes), kv.WithExpiration(s.sessionTtl))
 	if err != nil {
 		return nil, fmt.Errorf("failed to set session: %w", err)
 	}
 
+	err = s.trackUploadSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
 	return &UploadWriteChunkResponse{
 		Size: session.RangeEnd,
 	}, nil
@@ -198,43 +290,59 @@ func (s *service) CompleteUpload(ctx context.Context, sessionId uuid.UUID, expec
 	scope := middlewares.GetScope(ctx)
 	kvStore := ioc.GetDependency[kv.Store](scope)
 
-	value, ok, err := kvStore.Get(ctx, buildSessionCacheKey(sessionId))
+	algorithm, _, err := digest.Parse(expectedDigest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("session not found")
+
+	unlock, err := lockSession(ctx, kvStore, sessionId)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	var session jsontypes.UploadSession
-	err = json.Unmarshal([]byte(value), &session)
+	session, err := getSession(ctx, kvStore, sessionId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+		return nil, err
 	}
 
-	hash := sha256.New()
-	err = hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.DigestState)
+	hashers, err := unmarshalHashers(session.DigestState)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal digest state: %w", err)
+		return nil, err
 	}
 
-	digest := fmt.Sprintf("sha256:%x", hash.Sum(nil))
+	hasher, ok := hashers[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("missing digest state for algorithm %s", algorithm)
+	}
 
-	if expectedDigest != digest {
-		err := s.backend.AbortUpload(ctx, session.BackendState)
+	computedDigest := algorithm.FromHash(hasher)
+
+	if expectedDigest != computedDigest {
+		err := s.abortUploadSession(ctx, kvStore, session.Id, session.BackendState)
 		if err != nil {
 			return nil, fmt.Errorf("failed to abort upload: %w, additional error occurred while aborting: %w", ociError.NewOciError(ociError.DigestInvalid), err)
 		}
 		return nil, ociError.NewOciError(ociError.DigestInvalid)
 	}
 
-	err = s.backend.CompleteUpload(ctx, digest, session.BackendState)
+	err = s.backend.CompleteUpload(ctx, computedDigest, session.BackendState)
+	if err != nil {
+		return nil, err
+	}
+
+	err = kvStore.Delete(ctx, buildSessionCacheKey(sessionId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	err = untrackUploadSession(ctx, sessionId)
 	if err != nil {
 		return nil, err
 	}
 
 	return &CompleteUploadResponse{
-		ComputedDigest: digest,
+		ComputedDigest: computedDigest,
 		Size:           session.RangeEnd,
 		TenantSlug:     session.TenantSlug,
 		ProjectSlug:    session.ProjectSlug,
@@ -247,76 +355,335 @@ func (s *service) GetUploadRangeEnd(ctx context.Context, sessionId uuid.UUID) (i
 	scope := middlewares.GetScope(ctx)
 	kvStore := ioc.GetDependency[kv.Store](scope)
 
+	session, err := getSession(ctx, kvStore, sessionId)
+	if err != nil {
+		return 0, err
+	}
+
+	return session.RangeEnd, nil
+}
+
+func (s *service) GetChunkMinLength() int64 {
+	return s.backend.ChunkMinLength()
+}
+
+func (s *service) AbortExpiredUploadSession(ctx context.Context, sessionId uuid.UUID) (bool, error) {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[kv.Store](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	dbFactory := ioc.GetDependency[db.Factory](scope)
+
+	unlock, locked, err := tryLockSession(ctx, kvStore, sessionId)
+	if err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer unlock()
+
+	// the session is read after locking, a chunk written in the meantime has extended it
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to create db context: %w", err)
+	}
+
+	uploadSession, err := dbContext.UploadSessions().First(ctx, repositories.NewUploadSessionFilter().BySessionId(sessionId))
+	if err != nil {
+		return false, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	if uploadSession == nil || uploadSession.GetExpiresAt().After(clockService.Now()) {
+		return false, nil
+	}
+
+	err = s.abortUploadSession(ctx, kvStore, sessionId, uploadSession.GetBackendState())
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// abortUploadSession removes the data of an upload session and forgets the session. The session must be locked.
+func (s *service) abortUploadSession(ctx context.Context, kvStore kv.Store, sessionId uuid.UUID, backendState storageBackends.StorageBackendState) error {
+	err := s.backend.AbortUpload(ctx, backendState)
+	if err != nil {
+		return err
+	}
+
+	err = kvStore.Delete(ctx, buildSessionCacheKey(sessionId))
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return untrackUploadSession(ctx, sessionId)
+}
+
+// trackUploadSession stores the backend state of an upload session in the database and extends its expiry, so that
+// the data can be cleaned up after the session expired from the kv store.
+func (s *service) trackUploadSession(ctx context.Context, session *jsontypes.UploadSession) error {
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	dbFactory := ioc.GetDependency[db.Factory](scope)
+
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create db context: %w", err)
+	}
+
+	expiresAt := clockService.Now().Add(s.sessionTtl)
+
+	uploadSession, err := dbContext.UploadSessions().First(ctx, repositories.NewUploadSessionFilter().BySessionId(session.Id))
+	if err != nil {
+		return fmt.Errorf("failed to get upload session: %w", err)
+	}
+
+	if uploadSession == nil {
+		dbContext.UploadSessions().Insert(repositories.NewUploadSession(session.Id, session.RepositoryId, session.BackendState, expiresAt))
+	} else {
+		uploadSession.RecordProgress(session.BackendState, expiresAt)
+		dbContext.UploadSessions().Update(uploadSession)
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to track upload session: %w", err)
+	}
+
+	return nil
+}
+
+func untrackUploadSession(ctx context.Context, sessionId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	dbFactory := ioc.GetDependency[db.Factory](scope)
+
+	dbContext, err := dbFactory.NewDbContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create db context: %w", err)
+	}
+
+	uploadSession, err := dbContext.UploadSessions().First(ctx, repositories.NewUploadSessionFilter().BySessionId(sessionId))
+	if err != nil {
+		return fmt.Errorf("failed to get upload session: %w", err)
+	}
+	if uploadSession == nil {
+		return nil
+	}
+
+	dbContext.UploadSessions().Delete(uploadSession)
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to untrack upload session: %w", err)
+	}
+
+	return nil
+}
+
+func marshalHashers(hashers map[digest.Algorithm]hash.Hash) (map[digest.Algorithm][]byte, error) {
+	digestState := make(map[digest.Algorithm][]byte, len(hashers))
+	for algorithm, hasher := range hashers {
+		state, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s digest state: %w", algorithm, err)
+		}
+		digestState[algorithm] = state
+	}
+
+	return digestState, nil
+}
+
+func unmarshalHashers(digestState map[digest.Algorithm][]byte) (map[digest.Algorithm]hash.Hash, error) {
+	hashers := make(map[digest.Algorithm]hash.Hash, len(digestState))
+	for algorithm, state := range digestState {
+		if !algorithm.Available() {
+			return nil, fmt.Errorf("unknown digest algorithm in session: %s", algorithm)
+		}
+
+		hasher := algorithm.New()
+		err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s digest state: %w", algorithm, err)
+		}
+		hashers[algorithm] = hasher
+	}
+
+	return hashers, nil
+}
+
+func getSession(ctx context.Context, kvStore kv.Store, sessionId uuid.UUID) (*jsontypes.UploadSession, error) {
 	value, ok, err := kvStore.Get(ctx, buildSessionCacheKey(sessionId))
 	if err != nil {
-		return 0, fmt.Errorf("failed to get session: %w", err)
+		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 	if !ok {
-		return 0, fmt.Errorf("session not found")
+		return nil, ociError.NewOciError(ociError.BlobUploadUnknown).
+			WithHttpCode(http.StatusNotFound)
 	}
 
 	var session jsontypes.UploadSession
 	err = json.Unmarshal([]byte(value), &session)
 	if err != nil {
-		return 0, fmt.Errorf("failed to unmarshal session: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
 	}
 
-	return session.RangeEnd, nil
+	return &session, nil
 }
 
-func (s *service) UploadCompleteBlob(ctx context.Context, digest string, reader io.Reader, contentType BlobContentType) (*UploadCompleteBlobResponse, error) {
-	uploadState, err := s.backend.InitiateUpload(ctx, uuid.New(), string(contentType))
+// lockSession makes sure that only one request at a time modifies an upload session, concurrent writes would
+// otherwise corrupt the digest and backend state. The returned function releases the lock.
+func lockSession(ctx context.Context, kvStore kv.Store, sessionId uuid.UUID) (func(), error) {
+	unlock, ok, err := tryLockSession(ctx, kvStore, sessionId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initiate upload: %w", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, ociError.NewOciError(ociError.BlobUploadInvalid).
+			WithMessage("upload session is in use by another request").
+			WithHttpCode(http.StatusConflict)
+	}
+
+	return unlock, nil
+}
+
+// tryLockSession is like lockSession, but reports a session that is in use by returning false instead of an error.
+func tryLockSession(ctx context.Context, kvStore kv.Store, sessionId uuid.UUID) (func(), bool, error) {
+	lockKey := buildSessionLockKey(sessionId)
+	// the token identifies this request, so that it does not release a lock another request took after this one
+	// expired
+	token := uuid.New().String()
+
+	ok, err := kvStore.SetIfNotExists(ctx, lockKey, token, kv.WithExpiration(sessionLockExpiration))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock session: %w", err)
+	}
+	if !ok {
+		return nil, false, nil
 	}
 
-	hash := sha256.New()
-	cr := &countReader{Reader: io.TeeReader(reader, hash)}
+	return func() {
+		released, err := kvStore.DeleteIfEquals(context.WithoutCancel(ctx), lockKey, token)
+		if err != nil {
+			logging.Logger.Errorf("failed to unlock upload session %s: %s", sessionId, err)
+			return
+		}
+		if !released {
+			logging.Logger.Warnf("lock of upload session %s expired before the request finished", sessionId)
+		}
+	}, true, nil
+}
 
-	uploadState, err = s.backend.UploadAddChunk(ctx, uploadState, cr)
+func (s *service) UploadCompleteBlob(ctx context.Context, expectedDigest string, reader io.Reader, contentType BlobContentType) (*UploadCompleteBlobResponse, error) {
+	algorithm, _, err := digest.Parse(expectedDigest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload chunk: %w", err)
+		return nil, err
 	}
 
-	gotSha256 := hash.Sum(nil)
-	gotDigest := fmt.Sprintf("sha256:%x", gotSha256)
+	uploadState, err := s.backend.InitiateUpload(ctx, uuid.New(), string(contentType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate upload: %w", err)
+	}
+
+	// the temporary upload is removed again unless the blob is stored
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
 
-	if gotDigest != digest {
 		err := s.backend.AbortUpload(ctx, uploadState)
 		if err != nil {
-			return nil, fmt.Errorf("failed to abort upload: %w, additional error occurred while aborting: %w", ociError.NewOciError(ociError.DigestInvalid), err)
+			logging.Logger.Errorf("failed to abort upload of blob %s: %s", expectedDigest, err)
 		}
+	}()
+
+	hasher := algorithm.New()
+	cr := &countReader{Reader: io.TeeReader(reader, hasher)}
+
+	newUploadState, err := s.backend.UploadAddChunk(ctx, uploadState, cr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload chunk: %w", err)
+	}
+	uploadState = newUploadState
+
+	gotDigest := algorithm.FromHash(hasher)
 
+	if gotDigest != expectedDigest {
 		return nil, ociError.NewOciError(ociError.DigestInvalid)
 	}
 
-	err = s.backend.CompleteUpload(ctx, digest, uploadState)
+	err = s.backend.CompleteUpload(ctx, gotDigest, uploadState)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete upload: %w", err)
 	}
+	completed = true
 
 	return &UploadCompleteBlobResponse{
-		Digest: digest,
+		Digest: gotDigest,
 		Size:   cr.count,
 	}, nil
 }
 
 func (s *service) DeleteBlob(ctx context.Context, digest string) error {
-	// TODO implement me
-	panic("implement me")
-}
+	err := s.backend.DeleteBlob(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
 
-func (s *service) GetBlobDownloadLink(ctx context.Context, digest string) (string, error) {
-	// TODO: signed url
-	return fmt.Sprintf("/blobs/api/v1/%s", digest), nil
+	return nil
 }
 
-func (s *service) DownloadBlob(ctx context.Context, w http.ResponseWriter, digest string) error {
-	// TODO: check url signature?
-	err := s.backend.DownloadBlob(ctx, w, digest)
+func (s *service) DownloadBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, digest string, cacheControl string) (*DownloadBlobResponse, error) {
+	// the ETag has to be set before the backend answers the conditional headers of the request
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", digest))
+
+	downloadWriter := &downloadResponseWriter{
+		ResponseWriter: w,
+		cacheControl:   cacheControl,
+	}
+
+	err := s.backend.DownloadBlob(ctx, downloadWriter, r, digest)
 	if err != nil {
-		return err
+		w.Header().Del("ETag")
+		return nil, err
 	}
 
-	return nil
+	return &DownloadBlobResponse{
+		StatusCode: downloadWriter.statusCode,
+	}, nil
+}
+
+// downloadResponseWriter adds the caching headers of a blob download once the status is known. Error responses of the
+// backend, e.g. for a missing blob or an unsatisfiable range, are sent without them.
+type downloadResponseWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	statusCode   int
+}
+
+func (w *downloadResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+
+		switch {
+		case statusCode >= http.StatusBadRequest:
+			w.Header().Del("ETag")
+		case w.cacheControl != "":
+			w.Header().Set("Cache-Control", w.cacheControl)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *downloadResponseWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (s *service) OpenBlob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	return s.backend.OpenBlob(ctx, digest)
 }
@@ -0,0 +1,176 @@
+package blobStorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/storageBackends/directory"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
+	"go.uber.org/zap"
+)
+
+type ServiceTestSuite struct {
+	suite.Suite
+	tempPath string
+	service  Service
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ServiceTestSuite))
+}
+
+func (s *ServiceTestSuite) SetupSuite() {
+	if logging.Logger == nil {
+		logging.Logger = zap.NewNop().Sugar()
+	}
+}
+
+func (s *ServiceTestSuite) SetupTest() {
+	dir := s.T().TempDir()
+	s.tempPath = path.Join(dir, "temp")
+
+	backend, err := directory.New(config.DirectoryBlobStorageConfig{
+		Path:     path.Join(dir, "blobs"),
+		TempPath: s.tempPath,
+	})
+	s.Require().NoError(err)
+	s.service = NewBlobStorageService(backend, config.BlobStorageConfig{})
+}
+
+// failingReader fails after its data was read, like a connection that drops during an upload.
+type failingReader struct {
+	reader io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, errors.New("connection reset")
+	}
+
+	return n, err
+}
+
+func (s *ServiceTestSuite) TestUploadCompleteBlob() {
+	blobDigest := digest.SHA256.FromBytes([]byte("hello"))
+
+	testCases := []struct {
+		name   string
+		reader io.Reader
+		failed bool
+		// code is the expected error code, if the upload is rejected by the registry.
+		code ociError.ErrorCode
+	}{
+		{name: "stored", reader: strings.NewReader("hello")},
+		{name: "digest mismatch", reader: strings.NewReader("world"), failed: true, code: ociError.DigestInvalid},
+		{name: "read fails", reader: &failingReader{reader: strings.NewReader("hel")}, failed: true},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			response, err := s.service.UploadCompleteBlob(context.Background(), blobDigest, testCase.reader, BlobContentTypeOctetStream)
+
+			// assert
+			if testCase.failed {
+				s.Require().Error(err)
+				if testCase.code != "" {
+					var ociErr *ociError.OciError
+					s.Require().ErrorAs(err, &ociErr)
+					s.Equal(testCase.code, ociErr.Code)
+				}
+			} else {
+				s.Require().NoError(err)
+				s.Equal(blobDigest, response.Digest)
+			}
+
+			tempFiles, err := os.ReadDir(s.tempPath)
+			s.Require().NoError(err)
+			s.Empty(tempFiles, "temporary upload is removed")
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestDownloadBlob() {
+	blobDigest := digest.SHA256.FromBytes([]byte("hello world"))
+	_, err := s.service.UploadCompleteBlob(context.Background(), blobDigest, strings.NewReader("hello world"), BlobContentTypeOctetStream)
+	s.Require().NoError(err)
+
+	etag := "\"" + blobDigest + "\""
+	cacheControl := "max-age=60, immutable"
+
+	testCases := []struct {
+		name    string
+		digest  string
+		headers map[string]string
+		status  int
+		body    string
+		// cached is true if the response carries the validator and caching headers.
+		cached bool
+	}{
+		{name: "complete blob", digest: blobDigest, status: http.StatusOK, body: "hello world", cached: true},
+		{name: "range", digest: blobDigest, headers: map[string]string{"Range": "bytes=6-"}, status: http.StatusPartialContent, body: "world", cached: true},
+		{
+			name:    "range of the same blob",
+			digest:  blobDigest,
+			headers: map[string]string{"Range": "bytes=0-4", "If-Range": etag},
+			status:  http.StatusPartialContent,
+			body:    "hello",
+			cached:  true,
+		},
+		{
+			name:    "range of another blob",
+			digest:  blobDigest,
+			headers: map[string]string{"Range": "bytes=0-4", "If-Range": `"sha256:other"`},
+			status:  http.StatusOK,
+			body:    "hello world",
+			cached:  true,
+		},
+		{name: "client has the blob", digest: blobDigest, headers: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified, cached: true},
+		{name: "client has another blob", digest: blobDigest, headers: map[string]string{"If-None-Match": `"sha256:other"`}, status: http.StatusOK, body: "hello world", cached: true},
+		{name: "unsatisfiable range", digest: blobDigest, headers: map[string]string{"Range": "bytes=20-"}, status: http.StatusRequestedRangeNotSatisfiable},
+		{name: "missing blob", digest: digest.SHA256.FromBytes([]byte("missing")), status: http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, value := range testCase.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			// act
+			response, err := s.service.DownloadBlob(context.Background(), w, r, testCase.digest, cacheControl)
+
+			// assert
+			s.Require().NoError(err)
+			s.Equal(testCase.status, response.StatusCode)
+			s.Equal(testCase.status, w.Code)
+			if testCase.status < http.StatusBadRequest {
+				s.Equal(testCase.body, w.Body.String())
+			}
+
+			if testCase.cached {
+				s.Equal(etag, w.Header().Get("ETag"))
+				s.Equal(cacheControl, w.Header().Get("Cache-Control"))
+			} else {
+				s.Empty(w.Header().Get("ETag"))
+				s.Empty(w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
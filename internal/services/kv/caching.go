@@ -20,5 +20,10 @@ func WithExpiration(expiration time.Duration) Option {
 type Store interface {
 	Get(ctx context.Context, key string) (value string, ok bool, error error)
 	Set(ctx context.Context, key string, value string, opts ...Option) error
+	// SetIfNotExists sets the key only if it does not exist yet and reports whether it was set.
+	SetIfNotExists(ctx context.Context, key string, value string, opts ...Option) (bool, error)
 	Delete(ctx context.Context, key string) error
+	// DeleteIfEquals deletes the key only if it holds the value and reports whether it was deleted. It is used to
+	// release locks, so that a lock that expired and was taken by someone else is left alone.
+	DeleteIfEquals(ctx context.Context, key string, value string) (bool, error)
 }
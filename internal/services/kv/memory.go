@@ -2,6 +2,7 @@ package kv
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -15,11 +16,16 @@ func NewMemoryStore() Store {
 
 type memoryStore struct {
 	cache *cache.Cache
+	// mutex makes DeleteIfEquals atomic with respect to the writes of the store.
+	mutex sync.Mutex
 }
 
 func (m *memoryStore) Get(ctx context.Context, key string) (value string, ok bool, error error) {
 	result, ok := m.cache.Get(key)
-	return result.(string), ok, nil
+	if !ok {
+		return "", false, nil
+	}
+	return result.(string), true, nil
 }
 
 func (m *memoryStore) Set(ctx context.Context, key string, value string, opts ...Option) error {
@@ -28,11 +34,43 @@ func (m *memoryStore) Set(ctx context.Context, key string, value string, opts ..
 		opt(&options)
 	}
 
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	m.cache.Set(key, value, options.Expiration)
 	return nil
 }
 
+func (m *memoryStore) SetIfNotExists(ctx context.Context, key string, value string, opts ...Option) (bool, error) {
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	err := m.cache.Add(key, value, options.Expiration)
+	return err == nil, nil
+}
+
 func (m *memoryStore) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	m.cache.Delete(key)
 	return nil
 }
+
+func (m *memoryStore) DeleteIfEquals(ctx context.Context, key string, value string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	current, ok := m.cache.Get(key)
+	if !ok || current.(string) != value {
+		return false, nil
+	}
+
+	m.cache.Delete(key)
+	return true, nil
+}
@@ -28,6 +28,15 @@ func (r *redisKvStore) Set(ctx context.Context, key string, value string, opts .
 	return client.Set(ctx, key, value, options.Expiration).Err()
 }
 
+func (r *redisKvStore) SetIfNotExists(ctx context.Context, key string, value string, opts ...Option) (bool, error) {
+	client := r.newRedisClient()
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return client.SetNX(ctx, key, value, options.Expiration).Result()
+}
+
 func (r *redisKvStore) Get(ctx context.Context, key string) (string, bool, error) {
 	client := r.newRedisClient()
 	result, err := client.Get(ctx, key).Result()
@@ -46,6 +55,23 @@ func (r *redisKvStore) Delete(ctx context.Context, key string) error {
 	return err
 }
 
+// deleteIfEqualsScript compares and deletes in one step, redis runs scripts atomically.
+var deleteIfEqualsScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *redisKvStore) DeleteIfEquals(ctx context.Context, key string, value string) (bool, error) {
+	client := r.newRedisClient()
+	deleted, err := deleteIfEqualsScript.Run(ctx, client, []string{key}, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
 func (r *redisKvStore) newRedisClient() *redis.Client {
 	return redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", r.kvConfig.Redis.Host, r.kvConfig.Redis.Port),
@@ -0,0 +1,458 @@
+package registryClient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+// ErrNotFound is returned when the remote registry does not know the requested manifest or blob.
+var ErrNotFound = errors.New("not found in remote registry")
+
+// maxManifestSize limits how much of a manifest is read from a remote registry.
+const maxManifestSize = 4 * 1024 * 1024
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// Registry addresses a repository in a remote registry.
+type Registry struct {
+	// Url is the base url of the registry, e.g. https://registry-1.docker.io.
+	Url        string
+	Repository string
+	Username   *string
+	Password   *string
+}
+
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type Manifest struct {
+	MediaType string
+	Digest    string
+	Body      []byte
+
+	// Blobs are the config and layer descriptors of an image manifest.
+	Blobs []Descriptor
+	// Manifests are the child manifest descriptors of an image index.
+	Manifests []Descriptor
+}
+
+type Client interface {
+	// HeadManifest resolves the reference to the digest of the manifest without downloading it.
+	HeadManifest(ctx context.Context, registry Registry, reference string) (string, error)
+	// GetManifest downloads the manifest and verifies its digest.
+	GetManifest(ctx context.Context, registry Registry, reference string) (*Manifest, error)
+	// GetBlob opens the blob for reading, the caller has to close the reader and verify the digest of the content.
+	GetBlob(ctx context.Context, registry Registry, digest string) (io.ReadCloser, error)
+	// HeadBlob returns the size of the blob without downloading it.
+	HeadBlob(ctx context.Context, registry Registry, digest string) (int64, error)
+
+	// BlobExists reports whether the repository already contains the blob.
+	BlobExists(ctx context.Context, registry Registry, digest string) (bool, error)
+	// PushBlob uploads the blob in a single request.
+	PushBlob(ctx context.Context, registry Registry, digest string, size int64, reader io.Reader) error
+	// PushManifest uploads the manifest under the reference, which is either a tag or its digest.
+	PushManifest(ctx context.Context, registry Registry, reference string, mediaType string, body []byte) error
+}
+
+type client struct {
+	httpClient *http.Client
+
+	// authorizations caches the authorization header per repository, so that requests with a body, which cannot be
+	// retried, are sent with the authorization obtained by an earlier request.
+	authorizations sync.Map
+}
+
+// request describes a request to a remote registry. Requests with a body are not retried after an authentication
+// challenge, the body has already been consumed by then.
+type request struct {
+	method        string
+	url           string
+	accept        string
+	contentType   string
+	body          io.Reader
+	contentLength int64
+}
+
+func NewClient(httpClient *http.Client) Client {
+	return &client{
+		httpClient: httpClient,
+	}
+}
+
+func (c *client) HeadManifest(ctx context.Context, registry Registry, reference string) (string, error) {
+	response, err := c.do(ctx, registry, request{
+		method: http.MethodHead,
+		url:    repositoryUrl(registry, "manifests/"+reference),
+		accept: strings.Join(manifestMediaTypes, ", "),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	manifestDigest := response.Header.Get("Docker-Content-Digest")
+	if manifestDigest != "" {
+		return manifestDigest, nil
+	}
+
+	// the digest header is optional, without it the manifest has to be downloaded to know its digest
+	manifest, err := c.GetManifest(ctx, registry, reference)
+	if err != nil {
+		return "", err
+	}
+
+	return manifest.Digest, nil
+}
+
+func (c *client) GetManifest(ctx context.Context, registry Registry, reference string) (*Manifest, error) {
+	response, err := c.do(ctx, registry, request{
+		method: http.MethodGet,
+		url:    repositoryUrl(registry, "manifests/"+reference),
+		accept: strings.Join(manifestMediaTypes, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	if len(body) > maxManifestSize {
+		return nil, fmt.Errorf("manifest exceeds %d bytes", maxManifestSize)
+	}
+
+	// the client either asked for a specific digest or trusts the digest announced by the registry, in both cases the
+	// content has to match it
+	expectedDigest := response.Header.Get("Docker-Content-Digest")
+	if digest.IsDigest(reference) {
+		expectedDigest = reference
+	}
+
+	algorithm := digest.Canonical
+	if expectedDigest != "" {
+		algorithm, _, err = digest.Parse(expectedDigest)
+		if err != nil {
+			return nil, fmt.Errorf("parsing remote digest: %w", err)
+		}
+	}
+
+	manifestDigest := algorithm.FromBytes(body)
+	if expectedDigest != "" && manifestDigest != expectedDigest {
+		return nil, fmt.Errorf("remote manifest has digest '%s', expected '%s'", manifestDigest, expectedDigest)
+	}
+
+	var parsed struct {
+		MediaType string       `json:"mediaType"`
+		Config    *Descriptor  `json:"config"`
+		Layers    []Descriptor `json:"layers"`
+		Manifests []Descriptor `json:"manifests"`
+	}
+	err = json.Unmarshal(body, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	mediaType := parsed.MediaType
+	if contentType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type")); err == nil && mediaType == "" {
+		mediaType = contentType
+	}
+
+	manifest := &Manifest{
+		MediaType: mediaType,
+		Digest:    manifestDigest,
+		Body:      body,
+		Blobs:     parsed.Layers,
+		Manifests: parsed.Manifests,
+	}
+
+	if parsed.Config != nil {
+		manifest.Blobs = append([]Descriptor{*parsed.Config}, manifest.Blobs...)
+	}
+
+	return manifest, nil
+}
+
+func (c *client) GetBlob(ctx context.Context, registry Registry, digest string) (io.ReadCloser, error) {
+	response, err := c.do(ctx, registry, request{
+		method: http.MethodGet,
+		url:    repositoryUrl(registry, "blobs/"+digest),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+func (c *client) HeadBlob(ctx context.Context, registry Registry, digest string) (int64, error) {
+	response, err := c.do(ctx, registry, request{
+		method: http.MethodHead,
+		url:    repositoryUrl(registry, "blobs/"+digest),
+	})
+	if err != nil {
+		return 0, err
+	}
+	_ = response.Body.Close()
+
+	if response.ContentLength < 0 {
+		return 0, fmt.Errorf("remote registry did not report the size of blob '%s'", digest)
+	}
+
+	return response.ContentLength, nil
+}
+
+func (c *client) BlobExists(ctx context.Context, registry Registry, digest string) (bool, error) {
+	response, err := c.do(ctx, registry, request{
+		method: http.MethodHead,
+		url:    repositoryUrl(registry, "blobs/"+digest),
+	})
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_ = response.Body.Close()
+
+	return true, nil
+}
+
+func (c *client) PushBlob(ctx context.Context, registry Registry, digest string, size int64, reader io.Reader) error {
+	response, err := c.do(ctx, registry, request{
+		method: http.MethodPost,
+		url:    repositoryUrl(registry, "blobs/uploads/"),
+	})
+	if err != nil {
+		return fmt.Errorf("starting upload: %w", err)
+	}
+	_ = response.Body.Close()
+
+	// the location is relative to the request url if it is not absolute
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("parsing upload location: %w", err)
+	}
+
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	response, err = c.do(ctx, registry, request{
+		method:        http.MethodPut,
+		url:           location.String(),
+		contentType:   "application/octet-stream",
+		body:          reader,
+		contentLength: size,
+	})
+	if err != nil {
+		return fmt.Errorf("uploading blob: %w", err)
+	}
+	_ = response.Body.Close()
+
+	return nil
+}
+
+func (c *client) PushManifest(ctx context.Context, registry Registry, reference string, mediaType string, body []byte) error {
+	response, err := c.do(ctx, registry, request{
+		method:        http.MethodPut,
+		url:           repositoryUrl(registry, "manifests/"+reference),
+		contentType:   mediaType,
+		body:          bytes.NewReader(body),
+		contentLength: int64(len(body)),
+	})
+	if err != nil {
+		return err
+	}
+	_ = response.Body.Close()
+
+	return nil
+}
+
+func repositoryUrl(registry Registry, path string) string {
+	return fmt.Sprintf("%s/v2/%s/%s", strings.TrimSuffix(registry.Url, "/"), registry.Repository, path)
+}
+
+// do sends the request to the registry. Registries that require authentication answer with a challenge, which is
+// solved by either fetching a bearer token or sending the credentials as basic auth, before the request is retried
+// once. The resulting authorization is reused for later requests to the same repository.
+func (c *client) do(ctx context.Context, registry Registry, req request) (*http.Response, error) {
+	cacheKey := registry.Url + "/" + registry.Repository
+
+	authorization := ""
+	if cached, ok := c.authorizations.Load(cacheKey); ok {
+		authorization = cached.(string)
+	}
+
+	response, err := c.send(ctx, req, authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized && req.body == nil {
+		challenge := response.Header.Get("WWW-Authenticate")
+		_ = response.Body.Close()
+
+		authorization, err = c.authorize(ctx, registry, challenge)
+		if err != nil {
+			return nil, err
+		}
+		c.authorizations.Store(cacheKey, authorization)
+
+		response, err = c.send(ctx, req, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		_ = response.Body.Close()
+		return nil, ErrNotFound
+
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		_ = response.Body.Close()
+		return nil, fmt.Errorf("remote registry responded to %s %s with status %d", req.method, req.url, response.StatusCode)
+	}
+
+	return response, nil
+}
+
+func (c *client) send(ctx context.Context, req request, authorization string) (*http.Response, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, req.method, req.url, req.body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	if req.body != nil {
+		httpRequest.ContentLength = req.contentLength
+	}
+
+	if req.accept != "" {
+		httpRequest.Header.Set("Accept", req.accept)
+	}
+
+	if req.contentType != "" {
+		httpRequest.Header.Set("Content-Type", req.contentType)
+	}
+
+	if authorization != "" {
+		httpRequest.Header.Set("Authorization", authorization)
+	}
+
+	response, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("requesting remote registry: %w", err)
+	}
+
+	return response, nil
+}
+
+var challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize returns the authorization header value that answers the challenge.
+func (c *client) authorize(ctx context.Context, registry Registry, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if registry.Username == nil || registry.Password == nil {
+			return "", fmt.Errorf("remote registry requires credentials")
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		request.SetBasicAuth(*registry.Username, *registry.Password)
+		return request.Header.Get("Authorization"), nil
+
+	case "bearer":
+		values := make(map[string]string)
+		for _, match := range challengeParamRegex.FindAllStringSubmatch(params, -1) {
+			values[strings.ToLower(match[1])] = match[2]
+		}
+
+		token, err := c.fetchToken(ctx, registry, values["realm"], values["service"], values["scope"])
+		if err != nil {
+			return "", err
+		}
+
+		return "Bearer " + token, nil
+
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge '%s'", challenge)
+	}
+}
+
+func (c *client) fetchToken(ctx context.Context, registry Registry, realm string, service string, scope string) (string, error) {
+	if realm == "" {
+		return "", fmt.Errorf("authentication challenge has no realm")
+	}
+
+	tokenUrl, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("parsing realm: %w", err)
+	}
+
+	query := tokenUrl.Query()
+	if service != "" {
+		query.Set("service", service)
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	tokenUrl.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenUrl.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+
+	if registry.Username != nil && registry.Password != nil {
+		request.SetBasicAuth(*registry.Username, *registry.Password)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("requesting token: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with status %d", response.StatusCode)
+	}
+
+	var tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&tokenResponse)
+	if err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+
+	if tokenResponse.Token != "" {
+		return tokenResponse.Token, nil
+	}
+
+	return tokenResponse.AccessToken, nil
+}
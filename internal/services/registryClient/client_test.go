@@ -0,0 +1,243 @@
+package registryClient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824","size":5}]}`
+
+type ClientTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	client Client
+
+	// pushed holds the blobs and manifests uploaded to the mirror/app repository by path.
+	pushed map[string][]byte
+}
+
+func TestClientTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ClientTestSuite))
+}
+
+// SetupTest starts a registry that only answers requests with a bearer token obtained from its token endpoint.
+func (s *ClientTestSuite) SetupTest() {
+	mux := http.NewServeMux()
+	s.server = httptest.NewServer(mux)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("scope") != "repository:library/app:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"token":"valid"}`)
+	})
+
+	mux.HandleFunc("/v2/library/app/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:library/app:pull"`, s.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/library/app/manifests/latest":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", digest.SHA256.FromBytes([]byte(testManifest)))
+			_, _ = fmt.Fprint(w, testManifest)
+
+		case "/v2/library/app/manifests/tampered":
+			w.Header().Set("Docker-Content-Digest", digest.SHA256.FromBytes([]byte("other")))
+			_, _ = fmt.Fprint(w, testManifest)
+
+		case "/v2/library/app/blobs/sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824":
+			_, _ = fmt.Fprint(w, "hello")
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	s.pushed = make(map[string][]byte)
+	mux.HandleFunc("/v2/mirror/app/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			if _, ok := s.pushed[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/mirror/app/blobs/uploads/":
+			w.Header().Set("Location", "/v2/mirror/app/blobs/uploads/session?state=abc")
+			w.WriteHeader(http.StatusAccepted)
+
+		case r.Method == http.MethodPut && r.URL.Path == "/v2/mirror/app/blobs/uploads/session":
+			if r.URL.Query().Get("state") != "abc" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			s.pushed["/v2/mirror/app/blobs/"+r.URL.Query().Get("digest")] = body
+			w.WriteHeader(http.StatusCreated)
+
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			s.pushed[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	s.client = NewClient(s.server.Client())
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ClientTestSuite) registry() Registry {
+	username := "user"
+	password := "secret"
+
+	return Registry{
+		Url:        s.server.URL,
+		Repository: "library/app",
+		Username:   &username,
+		Password:   &password,
+	}
+}
+
+func (s *ClientTestSuite) TestGetManifest() {
+	// act
+	manifest, err := s.client.GetManifest(context.Background(), s.registry(), "latest")
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(digest.SHA256.FromBytes([]byte(testManifest)), manifest.Digest)
+	s.Equal("application/vnd.oci.image.manifest.v1+json", manifest.MediaType)
+	s.Require().Len(manifest.Blobs, 2)
+	s.Equal("application/vnd.oci.image.config.v1+json", manifest.Blobs[0].MediaType)
+	s.Equal(int64(5), manifest.Blobs[1].Size)
+	s.Empty(manifest.Manifests)
+}
+
+func (s *ClientTestSuite) TestGetManifest_DigestMismatch() {
+	// act
+	_, err := s.client.GetManifest(context.Background(), s.registry(), "tampered")
+
+	// assert
+	s.Error(err)
+}
+
+func (s *ClientTestSuite) TestHeadManifest() {
+	// act
+	manifestDigest, err := s.client.HeadManifest(context.Background(), s.registry(), "latest")
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(digest.SHA256.FromBytes([]byte(testManifest)), manifestDigest)
+}
+
+func (s *ClientTestSuite) TestGetBlob() {
+	// act
+	reader, err := s.client.GetBlob(context.Background(), s.registry(), "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+
+	// assert
+	s.Require().NoError(err)
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	s.Require().NoError(err)
+	s.Equal("hello", string(content))
+}
+
+func (s *ClientTestSuite) TestGetBlob_NotFound() {
+	// act
+	_, err := s.client.GetBlob(context.Background(), s.registry(), "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a")
+
+	// assert
+	s.True(errors.Is(err, ErrNotFound))
+}
+
+func (s *ClientTestSuite) TestHeadBlob() {
+	// act
+	size, err := s.client.HeadBlob(context.Background(), s.registry(), "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(int64(5), size)
+}
+
+func (s *ClientTestSuite) TestGetManifest_WrongCredentials() {
+	// arrange
+	registry := s.registry()
+	password := "wrong"
+	registry.Password = &password
+
+	// act
+	_, err := s.client.GetManifest(context.Background(), registry, "latest")
+
+	// assert
+	s.Error(err)
+	s.False(errors.Is(err, ErrNotFound))
+}
+
+func (s *ClientTestSuite) TestPushBlob() {
+	// arrange
+	registry := Registry{Url: s.server.URL, Repository: "mirror/app"}
+	blobDigest := digest.SHA256.FromBytes([]byte("hello"))
+
+	// act
+	err := s.client.PushBlob(context.Background(), registry, blobDigest, 5, strings.NewReader("hello"))
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal("hello", string(s.pushed["/v2/mirror/app/blobs/"+blobDigest]))
+
+	exists, err := s.client.BlobExists(context.Background(), registry, blobDigest)
+	s.Require().NoError(err)
+	s.True(exists)
+}
+
+func (s *ClientTestSuite) TestBlobExists_Missing() {
+	// arrange
+	registry := Registry{Url: s.server.URL, Repository: "mirror/app"}
+
+	// act
+	exists, err := s.client.BlobExists(context.Background(), registry, digest.SHA256.FromBytes([]byte("missing")))
+
+	// assert
+	s.Require().NoError(err)
+	s.False(exists)
+}
+
+func (s *ClientTestSuite) TestPushManifest() {
+	// arrange
+	registry := Registry{Url: s.server.URL, Repository: "mirror/app"}
+
+	// act
+	err := s.client.PushManifest(context.Background(), registry, "v1", "application/vnd.oci.image.manifest.v1+json", []byte(testManifest))
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(testManifest, string(s.pushed["/v2/mirror/app/manifests/v1"]))
+}
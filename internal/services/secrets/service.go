@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrDecryptionFailed is returned for secrets that were encrypted with another key or were tampered with.
+var ErrDecryptionFailed = errors.New("decrypting secret failed")
+
+// Service encrypts secrets that have to be stored, e.g. the passwords of upstream registries, so that they cannot be
+// read by anyone with access to the database or its backups.
+type Service interface {
+	Encrypt(plaintext string) ([]byte, error)
+	Decrypt(ciphertext []byte) (string, error)
+}
+
+type service struct {
+	aead cipher.AEAD
+}
+
+// NewService creates a service that encrypts with AES-GCM, the key has to be 32 bytes long.
+func NewService(key []byte) (Service, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key has %d bytes, expected 32", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating gcm: %w", err)
+	}
+
+	return &service{
+		aead: aead,
+	}, nil
+}
+
+// Encrypt returns the random nonce followed by the sealed plaintext.
+func (s *service) Encrypt(plaintext string) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return s.aead.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+func (s *service) Decrypt(ciphertext []byte) (string, error) {
+	nonceSize := s.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", ErrDecryptionFailed
+	}
+
+	plaintext, err := s.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+
+	return string(plaintext), nil
+}
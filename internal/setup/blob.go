@@ -8,13 +8,14 @@ import (
 	"github.com/the127/dockyard/internal/services/blobStorage"
 	"github.com/the127/dockyard/internal/storageBackends/directory"
 	"github.com/the127/dockyard/internal/storageBackends/inmemory"
+	"github.com/the127/dockyard/internal/storageBackends/s3"
 )
 
 func Blob(dc *ioc.DependencyCollection, c config.BlobStorageConfig) {
 	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) blobStorage.Service {
 		switch c.Mode {
 		case config.BlobStorageModeInMemory:
-			return blobStorage.NewBlobStorageService(inmemory.New())
+			return blobStorage.NewBlobStorageService(inmemory.New(), c)
 
 		case config.BlobStorageModeDirectory:
 			storageBackend, err := directory.New(c.Directory)
@@ -22,7 +23,15 @@ func Blob(dc *ioc.DependencyCollection, c config.BlobStorageConfig) {
 				panic(fmt.Errorf("initializing directory blob storage: %w", err))
 			}
 
-			return blobStorage.NewBlobStorageService(storageBackend)
+			return blobStorage.NewBlobStorageService(storageBackend, c)
+
+		case config.BlobStorageModeS3:
+			storageBackend, err := s3.New(c.S3)
+			if err != nil {
+				panic(fmt.Errorf("initializing s3 blob storage: %w", err))
+			}
+
+			return blobStorage.NewBlobStorageService(storageBackend, c)
 
 		default:
 			panic(fmt.Errorf("unsupported blob storage mode: %s", c.Mode))
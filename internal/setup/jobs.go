@@ -0,0 +1,61 @@
+package setup
+
+import (
+	"context"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/the127/dockyard/internal/commands"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/jobs"
+	"github.com/the127/dockyard/internal/middlewares"
+)
+
+const (
+	replicationInterval  = 10 * time.Second
+	replicationBatchSize = 20
+
+	retentionInterval = time.Hour
+
+	uploadCleanupInterval  = time.Minute
+	uploadCleanupBatchSize = 100
+
+	storageUsageInterval = 5 * time.Minute
+)
+
+// Jobs starts the background jobs, they stop when the context is cancelled.
+func Jobs(ctx context.Context, dp *ioc.DependencyProvider, gc config.GcConfig) {
+	jobs.Schedule(ctx, dp, "replication", replicationInterval, func(ctx context.Context) error {
+		_, err := mediatr.Send[*commands.ProcessReplicationTasksResponse](ctx, middlewares.GetMediator(ctx), commands.ProcessReplicationTasks{
+			Limit: replicationBatchSize,
+		})
+		return err
+	})
+
+	jobs.Schedule(ctx, dp, "retention", retentionInterval, func(ctx context.Context) error {
+		_, err := mediatr.Send[*commands.ProcessRetentionPoliciesResponse](ctx, middlewares.GetMediator(ctx), commands.ProcessRetentionPolicies{})
+		return err
+	})
+
+	jobs.Schedule(ctx, dp, "upload cleanup", uploadCleanupInterval, func(ctx context.Context) error {
+		_, err := mediatr.Send[*commands.AbortExpiredUploadsResponse](ctx, middlewares.GetMediator(ctx), commands.AbortExpiredUploads{
+			Limit: uploadCleanupBatchSize,
+		})
+		return err
+	})
+
+	jobs.Schedule(ctx, dp, "storage usage", storageUsageInterval, func(ctx context.Context) error {
+		_, err := mediatr.Send[*commands.RefreshStorageUsageResponse](ctx, middlewares.GetMediator(ctx), commands.RefreshStorageUsage{})
+		return err
+	})
+
+	if !gc.Disabled {
+		jobs.Schedule(ctx, dp, "garbage collection", gc.Interval, func(ctx context.Context) error {
+			_, err := mediatr.Send[*commands.CollectGarbageResponse](ctx, middlewares.GetMediator(ctx), commands.CollectGarbage{
+				GracePeriod: gc.GracePeriod,
+			})
+			return err
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package setup
 
 import (
+	"encoding/base64"
 	"fmt"
 
 	"github.com/The127/go-clock"
@@ -8,6 +9,7 @@ import (
 	"github.com/The127/signr"
 	signrMemory "github.com/The127/signr/backends/memory"
 	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/services/secrets"
 )
 
 func Kms(dc *ioc.DependencyCollection, c config.KmsConfig) {
@@ -29,4 +31,18 @@ func Kms(dc *ioc.DependencyCollection, c config.KmsConfig) {
 	default:
 		panic(fmt.Errorf("unsupported kms mode: %s", c.Mode))
 	}
+
+	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) secrets.Service {
+		key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+		if err != nil {
+			panic(fmt.Errorf("failed to decode encryption key: %w", err))
+		}
+
+		secretsService, err := secrets.NewService(key)
+		if err != nil {
+			panic(fmt.Errorf("failed to create secrets service: %w", err))
+		}
+
+		return secretsService
+	})
 }
@@ -14,6 +14,8 @@ func Mediator(dc *ioc.DependencyCollection) {
 	mediatr.RegisterHandler(mediator, queries.HandleListTenants)
 	mediatr.RegisterHandler(mediator, queries.HandleGetTenant)
 	mediatr.RegisterHandler(mediator, queries.HandleGetTenantOidcInfo)
+	mediatr.RegisterHandler(mediator, commands.HandleSetStorageQuota)
+	mediatr.RegisterHandler(mediator, queries.HandleGetTenantStorageUsage)
 
 	mediatr.RegisterHandler(mediator, queries.HandleListUsers)
 
@@ -23,6 +25,7 @@ func Mediator(dc *ioc.DependencyCollection) {
 	mediatr.RegisterHandler(mediator, commands.HandleCreateProject)
 	mediatr.RegisterHandler(mediator, queries.HandleListProjects)
 	mediatr.RegisterHandler(mediator, queries.HandleGetProject)
+	mediatr.RegisterHandler(mediator, queries.HandleGetProjectStorageUsage)
 
 	mediatr.RegisterHandler(mediator, commands.HandleCreateRepository)
 	mediatr.RegisterHandler(mediator, queries.HandleListRepositories)
@@ -33,11 +36,59 @@ func Mediator(dc *ioc.DependencyCollection) {
 	mediatr.RegisterHandler(mediator, queries.HandleGetRepositoryReadme)
 
 	mediatr.RegisterHandler(mediator, queries.HandleListTags)
+	mediatr.RegisterHandler(mediator, queries.HandleGetRepositoryStorageUsage)
+	mediatr.RegisterHandler(mediator, queries.HandleGetImageDetails)
+	mediatr.RegisterHandler(mediator, queries.HandleListImageFiles)
+	mediatr.RegisterHandler(mediator, queries.HandleGetImageFile)
+	mediatr.RegisterHandler(mediator, queries.HandleListLayerFiles)
+	mediatr.RegisterHandler(mediator, queries.HandleGetLayerFile)
+	mediatr.RegisterHandler(mediator, queries.HandleCompareImages)
+	mediatr.RegisterHandler(mediator, queries.HandleListImageSboms)
+	mediatr.RegisterHandler(mediator, queries.HandleListImageProvenance)
+	mediatr.RegisterHandler(mediator, queries.HandleSearchPackages)
+
+	mediatr.RegisterHandler(mediator, commands.HandleSetUpstream)
+	mediatr.RegisterHandler(mediator, commands.HandleDeleteUpstream)
+	mediatr.RegisterHandler(mediator, queries.HandleGetUpstream)
+
+	mediatr.RegisterHandler(mediator, commands.HandleCreateTagRule)
+	mediatr.RegisterHandler(mediator, commands.HandleUpdateTagRule)
+	mediatr.RegisterHandler(mediator, commands.HandleDeleteTagRule)
+	mediatr.RegisterHandler(mediator, queries.HandleListTagRules)
+
+	mediatr.RegisterHandler(mediator, commands.HandleCreateRetentionPolicy)
+	mediatr.RegisterHandler(mediator, commands.HandleUpdateRetentionPolicy)
+	mediatr.RegisterHandler(mediator, commands.HandleDeleteRetentionPolicy)
+	mediatr.RegisterHandler(mediator, commands.HandleApplyRetentionPolicies)
+	mediatr.RegisterHandler(mediator, commands.HandleProcessRetentionPolicies)
+	mediatr.RegisterHandler(mediator, queries.HandleListRetentionPolicies)
+
+	mediatr.RegisterHandler(mediator, commands.HandleCollectGarbage)
+	mediatr.RegisterHandler(mediator, commands.HandleAbortExpiredUploads)
+	mediatr.RegisterHandler(mediator, commands.HandleRefreshStorageUsage)
+
+	mediatr.RegisterHandler(mediator, commands.HandleCreateReplicationRule)
+	mediatr.RegisterHandler(mediator, commands.HandleUpdateReplicationRule)
+	mediatr.RegisterHandler(mediator, commands.HandleDeleteReplicationRule)
+	mediatr.RegisterHandler(mediator, commands.HandleReplicateNow)
+	mediatr.RegisterHandler(mediator, commands.HandleProcessReplicationTasks)
+	mediatr.RegisterHandler(mediator, queries.HandleListReplicationRules)
+	mediatr.RegisterHandler(mediator, queries.HandleGetReplicationRule)
+	mediatr.RegisterHandler(mediator, queries.HandleListReplicationTasks)
 
 	mediatr.RegisterHandler(mediator, queries.HandleGetManifestByReference)
 	mediatr.RegisterHandler(mediator, queries.HandleGetRepositoryBlob)
 	mediatr.RegisterHandler(mediator, commands.HandleUploadManifest)
 	mediatr.RegisterHandler(mediator, commands.HandleFinishUpload)
+	mediatr.RegisterHandler(mediator, commands.HandleProxyManifest)
+	mediatr.RegisterHandler(mediator, commands.HandleProxyBlob)
+	mediatr.RegisterHandler(mediator, commands.HandleRecordManifestPull)
+	mediatr.RegisterHandler(mediator, commands.HandleMountBlob)
+	mediatr.RegisterHandler(mediator, commands.HandleCheckPushAllowed)
+	mediatr.RegisterHandler(mediator, commands.HandleDeleteManifest)
+	mediatr.RegisterHandler(mediator, commands.HandleDeleteRepositoryBlob)
+	mediatr.RegisterHandler(mediator, queries.HandleListReferrers)
+	mediatr.RegisterHandler(mediator, queries.HandleListCatalog)
 
 	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) mediatr.Mediator {
 		return mediator
@@ -0,0 +1,17 @@
+package setup
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/the127/dockyard/internal/services/registryClient"
+)
+
+func RegistryClient(dc *ioc.DependencyCollection) {
+	ioc.RegisterSingleton(dc, func(_ *ioc.DependencyProvider) registryClient.Client {
+		return registryClient.NewClient(&http.Client{
+			Timeout: 10 * time.Minute,
+		})
+	})
+}
@@ -14,6 +14,11 @@ type StorageBackendState map[string]string
 // StorageBackend defines the interface for backend storage operations with support for blob uploads, downloads, and deletion.
 type StorageBackend interface {
 
+	// ChunkMinLength returns the size in bytes every chunk but the last one of a chunked upload should have at least,
+	// it is sent to clients as OCI-Chunk-Min-Length. Smaller chunks are accepted but may be held back by the backend.
+	// A value of 0 means that the backend has no preferred chunk size.
+	ChunkMinLength() int64
+
 	// InitiateUpload begins a new upload session for a blob, returning the storage backend state and any encountered error.
 	InitiateUpload(ctx context.Context, id uuid.UUID, contentType string) (StorageBackendState, error)
 
@@ -24,12 +29,27 @@ type StorageBackend interface {
 	CompleteUpload(ctx context.Context, digest string, state StorageBackendState) error
 
 	// AbortUpload aborts an ongoing upload and cleans up related state for the specified storage backend operation.
+	// Aborting an upload whose data is already gone is not an error.
 	AbortUpload(ctx context.Context, state StorageBackendState) error
 
-	// DeleteBlob removes a blob identified by the specified digest from the storage backend. Returns an error if deletion fails.
+	// DeleteBlob removes a blob identified by the specified digest from the storage backend. Deleting a blob that does not
+	// exist is not an error. Returns an error if deletion fails.
 	DeleteBlob(ctx context.Context, digest string) error
 
 	// DownloadBlob retrieves a blob by its digest and writes it to the provided HTTP response writer.
-	// It sets the appropriate Content-Type header using the blob's metadata. Returns an error if the operation fails.
-	DownloadBlob(ctx context.Context, w http.ResponseWriter, digest string) error
+	// It sets the appropriate Content-Type header using the blob's metadata and honors the Range and conditional headers
+	// of the request, validators like the ETag header must be set by the caller. Returns an error if the operation fails.
+	DownloadBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, digest string) error
+
+	// OpenBlob opens a blob by its digest for reading. The caller has to close the returned reader. Returns a
+	// BLOB_UNKNOWN error if the blob does not exist.
+	OpenBlob(ctx context.Context, digest string) (io.ReadCloser, error)
+}
+
+// DownloadLinkProvider is implemented by storage backends that serve blobs to clients themselves, e.g. through
+// presigned URLs. Blobs of other backends are served by the blob API of dockyard.
+type DownloadLinkProvider interface {
+
+	// GetDownloadLink returns a URL the client is redirected to for downloading the blob with the specified digest.
+	GetDownloadLink(ctx context.Context, digest string) (string, error)
 }
@@ -2,17 +2,22 @@ package directory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path"
-	"strings"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/the127/dockyard/internal/config"
 	"github.com/the127/dockyard/internal/storageBackends"
 	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
 )
 
 type backend struct {
@@ -24,6 +29,9 @@ type tempState struct {
 	id          string
 	filePath    string
 	contentType string
+	// size is the number of bytes of all chunks that were written completely. Bytes of a failed chunk past it are
+	// truncated before the next chunk is written.
+	size int64
 }
 
 func (t tempState) encode() storageBackends.StorageBackendState {
@@ -31,6 +39,7 @@ func (t tempState) encode() storageBackends.StorageBackendState {
 		"id":          t.id,
 		"filePath":    t.filePath,
 		"contentType": t.contentType,
+		"size":        strconv.FormatInt(t.size, 10),
 	}
 }
 
@@ -50,10 +59,21 @@ func decodeState(state storageBackends.StorageBackendState) (tempState, error) {
 		return tempState{}, fmt.Errorf("missing contentType in state")
 	}
 
+	// states of uploads started before the size was tracked have none, their data file is taken as it is
+	size := int64(-1)
+	if sizeString, ok := state["size"]; ok {
+		var err error
+		size, err = strconv.ParseInt(sizeString, 10, 64)
+		if err != nil {
+			return tempState{}, fmt.Errorf("decoding size: %w", err)
+		}
+	}
+
 	return tempState{
 		id:          id,
 		filePath:    filePath,
 		contentType: contentType,
+		size:        size,
 	}, nil
 }
 
@@ -74,9 +94,13 @@ func New(c config.DirectoryBlobStorageConfig) (storageBackends.StorageBackend, e
 	}, nil
 }
 
+func (b *backend) ChunkMinLength() int64 {
+	return 0
+}
+
 func (b *backend) InitiateUpload(_ context.Context, id uuid.UUID, contentType string) (storageBackends.StorageBackendState, error) {
-	// create the data file
-	filePath := path.Join(b.path, id.String())
+	// create the data file, it is moved into the storage directory once the upload is complete
+	filePath := path.Join(b.tempPath, id.String())
 	err := os.WriteFile(filePath, []byte{}, os.ModePerm)
 	if err != nil {
 		return nil, fmt.Errorf("creating data file: %w", err)
@@ -95,18 +119,38 @@ func (b *backend) UploadAddChunk(_ context.Context, state storageBackends.Storag
 		return nil, fmt.Errorf("decoding state: %w", err)
 	}
 
-	tmpDataFile, err := os.OpenFile(decodedState.filePath, os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	tmpDataFile, err := os.OpenFile(decodedState.filePath, os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		return nil, fmt.Errorf("opening data file: %w", err)
 	}
 
 	defer utils.PanicOnError(tmpDataFile.Close, "closing data file")
 
-	_, err = io.Copy(tmpDataFile, reader)
+	if decodedState.size < 0 {
+		info, err := tmpDataFile.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("reading data file size: %w", err)
+		}
+		decodedState.size = info.Size()
+	}
+
+	// drop the bytes of a previous chunk that failed midway, its state was never saved
+	err = tmpDataFile.Truncate(decodedState.size)
+	if err != nil {
+		return nil, fmt.Errorf("truncating data file: %w", err)
+	}
+
+	_, err = tmpDataFile.Seek(decodedState.size, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("seeking data file: %w", err)
+	}
+
+	written, err := io.Copy(tmpDataFile, reader)
 	if err != nil {
 		return nil, fmt.Errorf("writing chunk to data file: %w", err)
 	}
 
+	decodedState.size += written
 	return decodedState.encode(), nil
 }
 
@@ -116,15 +160,26 @@ func (b *backend) CompleteUpload(_ context.Context, digest string, state storage
 		return fmt.Errorf("decoding state: %w", err)
 	}
 
-	dataPath := b.getDataFilePath(digest)
+	if decodedState.size >= 0 {
+		err = os.Truncate(decodedState.filePath, decodedState.size)
+		if err != nil {
+			return fmt.Errorf("truncating data file: %w", err)
+		}
+	}
+
+	dataPath, err := b.getDataFilePath(digest)
+	if err != nil {
+		return err
+	}
+
 	err = os.MkdirAll(path.Dir(dataPath), os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("ensuring directory exists: %w", err)
 	}
 
-	err = os.Rename(decodedState.filePath, dataPath)
+	err = moveFile(decodedState.filePath, dataPath)
 	if err != nil {
-		return fmt.Errorf("renaming data file: %w", err)
+		return fmt.Errorf("moving data file: %w", err)
 	}
 
 	err = os.WriteFile(dataPath+".info", []byte(decodedState.contentType), os.ModePerm)
@@ -142,30 +197,85 @@ func (b *backend) AbortUpload(_ context.Context, state storageBackends.StorageBa
 	}
 
 	err = os.Remove(decodedState.filePath)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("removing data file: %w", err)
 	}
 
 	return nil
 }
 
+// moveFile renames src to dst. The temp path may be on another file system than the storage directory, in that case
+// the file is copied next to dst first, so that dst never contains partial data.
+func moveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening source file: %w", err)
+	}
+	defer utils.PanicOnError(srcFile.Close, "closing source file")
+
+	tmpPath := dst + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("creating destination file: %w", err)
+	}
+
+	_, err = io.Copy(tmpFile, srcFile)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("copying file: %w", err)
+	}
+
+	err = os.Rename(tmpPath, dst)
+	if err != nil {
+		return fmt.Errorf("renaming destination file: %w", err)
+	}
+
+	err = os.Remove(src)
+	if err != nil {
+		return fmt.Errorf("removing source file: %w", err)
+	}
+
+	return nil
+}
+
 func (b *backend) DeleteBlob(_ context.Context, digest string) error {
-	dataPath := b.getDataFilePath(digest)
-	err := os.Remove(dataPath)
+	dataPath, err := b.getDataFilePath(digest)
 	if err != nil {
+		return err
+	}
+
+	err = os.Remove(dataPath)
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("removing data file: %w", err)
 	}
 
 	err = os.Remove(dataPath + ".info")
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("removing info file: %w", err)
 	}
 
 	return nil
 }
 
-func (b *backend) DownloadBlob(_ context.Context, w http.ResponseWriter, digest string) error {
-	dataPath := b.getDataFilePath(digest)
+func (b *backend) DownloadBlob(_ context.Context, w http.ResponseWriter, r *http.Request, digest string) error {
+	dataPath, err := b.getDataFilePath(digest)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+
 	infoPath := dataPath + ".info"
 	contentType, err := os.ReadFile(infoPath)
 	switch {
@@ -186,19 +296,42 @@ func (b *backend) DownloadBlob(_ context.Context, w http.ResponseWriter, digest
 
 	defer utils.PanicOnError(dataFile.Close, "closing data file")
 
-	_, err = io.Copy(w, dataFile)
+	http.ServeContent(w, r, "", time.Time{}, dataFile)
+	return nil
+}
+
+func (b *backend) OpenBlob(_ context.Context, digest string) (io.ReadCloser, error) {
+	dataPath, err := b.getDataFilePath(digest)
 	if err != nil {
-		return fmt.Errorf("writing data to response writer: %w", err)
+		return nil, err
 	}
 
-	return nil
+	dataFile, err := os.Open(dataPath)
+	switch {
+	case os.IsNotExist(err):
+		return nil, ociError.NewOciError(ociError.BlobUnknown)
+
+	case err != nil:
+		return nil, fmt.Errorf("opening data file: %w", err)
+	}
+
+	return dataFile, nil
 }
 
-func (b *backend) getDataFilePath(digest string) string {
-	trimmed := strings.TrimPrefix(digest, "sha256:")
+// getDataFilePath returns the path of the data file of a blob. Blobs are sharded by the first bytes of their encoded
+// digest. sha256 blobs live directly in the storage directory, all other algorithms get their own subdirectory.
+func (b *backend) getDataFilePath(blobDigest string) (string, error) {
+	algorithm, encoded, err := digest.Parse(blobDigest)
+	if err != nil {
+		return "", err
+	}
 
-	first := trimmed[:2]
-	second := trimmed[2:4]
+	first := encoded[:2]
+	second := encoded[2:4]
+
+	if algorithm == digest.SHA256 {
+		return path.Join(b.path, first, second, encoded), nil
+	}
 
-	return path.Join(b.path, first, second, trimmed)
+	return path.Join(b.path, string(algorithm), first, second, encoded), nil
 }
@@ -0,0 +1,124 @@
+package directory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/storageBackends"
+	"github.com/the127/dockyard/internal/utils/digest"
+)
+
+const testContentType = "application/octet-stream"
+
+type BackendTestSuite struct {
+	suite.Suite
+	backend storageBackends.StorageBackend
+}
+
+func TestBackendTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(BackendTestSuite))
+}
+
+func (s *BackendTestSuite) SetupTest() {
+	dir := s.T().TempDir()
+
+	backend, err := New(config.DirectoryBlobStorageConfig{
+		Path:     path.Join(dir, "blobs"),
+		TempPath: path.Join(dir, "temp"),
+	})
+	s.Require().NoError(err)
+	s.backend = backend
+}
+
+// failingReader returns its data and then fails, like a connection that drops in the middle of a chunk.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *BackendTestSuite) readBlob(ctx context.Context, blobDigest string) []byte {
+	reader, err := s.backend.OpenBlob(ctx, blobDigest)
+	s.Require().NoError(err)
+	defer func() { s.Require().NoError(reader.Close()) }()
+
+	data, err := io.ReadAll(reader)
+	s.Require().NoError(err)
+	return data
+}
+
+func (s *BackendTestSuite) TestUpload() {
+	// arrange
+	ctx := context.Background()
+	state, err := s.backend.InitiateUpload(ctx, uuid.New(), testContentType)
+	s.Require().NoError(err)
+
+	// act
+	state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader([]byte("hello ")))
+	s.Require().NoError(err)
+	state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader([]byte("world")))
+	s.Require().NoError(err)
+
+	blobDigest := digest.SHA256.FromBytes([]byte("hello world"))
+	err = s.backend.CompleteUpload(ctx, blobDigest, state)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal("hello world", string(s.readBlob(ctx, blobDigest)))
+}
+
+func (s *BackendTestSuite) TestUpload_FailedChunk() {
+	testCases := []struct {
+		name   string
+		resume bool
+	}{
+		{name: "chunk is written again", resume: true},
+		{name: "upload is completed without the chunk"},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			ctx := context.Background()
+			state, err := s.backend.InitiateUpload(ctx, uuid.New(), testContentType)
+			s.Require().NoError(err)
+
+			state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader([]byte("hello ")))
+			s.Require().NoError(err)
+
+			// act
+			_, err = s.backend.UploadAddChunk(ctx, state, &failingReader{data: []byte("wor")})
+			s.Require().Error(err)
+
+			expected := "hello "
+			if testCase.resume {
+				state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader([]byte("world")))
+				s.Require().NoError(err)
+				expected = "hello world"
+			}
+
+			blobDigest := digest.SHA256.FromBytes([]byte(expected))
+			err = s.backend.CompleteUpload(ctx, blobDigest, state)
+
+			// assert
+			s.Require().NoError(err)
+			s.Equal(expected, string(s.readBlob(ctx, blobDigest)))
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/the127/dockyard/internal/storageBackends"
@@ -67,6 +68,10 @@ func decodeTempState(state storageBackends.StorageBackendState) (tempState, erro
 	}, nil
 }
 
+func (b *backend) ChunkMinLength() int64 {
+	return 0
+}
+
 func (b *backend) InitiateUpload(_ context.Context, id uuid.UUID, contentType string) (storageBackends.StorageBackendState, error) {
 	b.setTemp(id.String(), &tempBlob{
 		buffer: &bytes.Buffer{},
@@ -134,7 +139,7 @@ func (b *backend) DeleteBlob(_ context.Context, digest string) error {
 	return nil
 }
 
-func (b *backend) DownloadBlob(_ context.Context, w http.ResponseWriter, digest string) error {
+func (b *backend) DownloadBlob(_ context.Context, w http.ResponseWriter, r *http.Request, digest string) error {
 	blob := b.getBlob(digest)
 	if blob == nil {
 		return ociError.NewOciError(ociError.BlobUnknown)
@@ -142,12 +147,17 @@ func (b *backend) DownloadBlob(_ context.Context, w http.ResponseWriter, digest
 
 	w.Header().Set("Content-Type", blob.contentType)
 
-	_, err := w.Write(blob.data)
-	if err != nil {
-		return fmt.Errorf("failed to write blob: %w", err)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob.data))
+	return nil
+}
+
+func (b *backend) OpenBlob(_ context.Context, digest string) (io.ReadCloser, error) {
+	blob := b.getBlob(digest)
+	if blob == nil {
+		return nil, ociError.NewOciError(ociError.BlobUnknown)
 	}
 
-	return nil
+	return io.NopCloser(bytes.NewReader(blob.data)), nil
 }
 
 func (b *backend) setBlob(digest string, blob *blobInfo) {
@@ -0,0 +1,512 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/storageBackends"
+	"github.com/the127/dockyard/internal/utils"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+const (
+	// chunkMinLength is the minimum size of every part but the last one of a multipart upload. Smaller chunks are
+	// accepted, but are held back until enough data for a part arrived.
+	chunkMinLength = 5 * 1024 * 1024
+
+	// maxParts is the maximum number of parts of a multipart upload.
+	maxParts = 10000
+)
+
+type backend struct {
+	client        *minio.Core
+	bucket        string
+	prefix        string
+	presignExpiry time.Duration
+	tempPath      string
+}
+
+// uploadState is the state of a multipart upload. The parts are uploaded to a temporary object, it is moved to the
+// key of the blob once the digest is known. Chunks smaller than the minimum part size are collected in a pending
+// object until enough data arrived for a part, or until the upload completes and they become the last part.
+type uploadState struct {
+	id          string
+	key         string
+	uploadId    string
+	contentType string
+	parts       []minio.CompletePart
+	pendingSize int64
+}
+
+func (u uploadState) pendingKey() string {
+	return u.key + ".pending"
+}
+
+type uploadPart struct {
+	PartNumber int    `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+func (u uploadState) encode() (storageBackends.StorageBackendState, error) {
+	parts := make([]uploadPart, 0, len(u.parts))
+	for _, part := range u.parts {
+		parts = append(parts, uploadPart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	partsJson, err := json.Marshal(parts)
+	if err != nil {
+		return nil, fmt.Errorf("encoding parts: %w", err)
+	}
+
+	return storageBackends.StorageBackendState{
+		"id":          u.id,
+		"key":         u.key,
+		"uploadId":    u.uploadId,
+		"contentType": u.contentType,
+		"parts":       string(partsJson),
+		"pendingSize": strconv.FormatInt(u.pendingSize, 10),
+	}, nil
+}
+
+func decodeState(state storageBackends.StorageBackendState) (uploadState, error) {
+	id, ok := state["id"]
+	if !ok {
+		return uploadState{}, fmt.Errorf("missing id in state")
+	}
+
+	key, ok := state["key"]
+	if !ok {
+		return uploadState{}, fmt.Errorf("missing key in state")
+	}
+
+	uploadId, ok := state["uploadId"]
+	if !ok {
+		return uploadState{}, fmt.Errorf("missing uploadId in state")
+	}
+
+	contentType, ok := state["contentType"]
+	if !ok {
+		return uploadState{}, fmt.Errorf("missing contentType in state")
+	}
+
+	partsJson, ok := state["parts"]
+	if !ok {
+		return uploadState{}, fmt.Errorf("missing parts in state")
+	}
+
+	// states of uploads started before pending chunks were introduced have no pending size
+	var pendingSize int64
+	if pendingSizeString, ok := state["pendingSize"]; ok {
+		var err error
+		pendingSize, err = strconv.ParseInt(pendingSizeString, 10, 64)
+		if err != nil {
+			return uploadState{}, fmt.Errorf("decoding pendingSize: %w", err)
+		}
+	}
+
+	var parts []uploadPart
+	err := json.Unmarshal([]byte(partsJson), &parts)
+	if err != nil {
+		return uploadState{}, fmt.Errorf("decoding parts: %w", err)
+	}
+
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	return uploadState{
+		id:          id,
+		key:         key,
+		uploadId:    uploadId,
+		contentType: contentType,
+		parts:       completeParts,
+		pendingSize: pendingSize,
+	}, nil
+}
+
+func New(c config.S3BlobStorageConfig) (storageBackends.StorageBackend, error) {
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint: %w", err)
+	}
+
+	err = os.MkdirAll(c.TempPath, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("ensuring temp path exists: %w", err)
+	}
+
+	var creds *credentials.Credentials
+	if c.AccessKeyId != "" {
+		creds = credentials.NewStaticV4(c.AccessKeyId, c.SecretAccessKey, c.SessionToken)
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+	}
+
+	bucketLookup := minio.BucketLookupDNS
+	if c.PathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.NewCore(endpoint.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       endpoint.Scheme == "https",
+		Region:       c.Region,
+		BucketLookup: bucketLookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	exists, err := client.BucketExists(context.Background(), c.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("checking bucket: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", c.Bucket)
+	}
+
+	return &backend{
+		client:        client,
+		bucket:        c.Bucket,
+		prefix:        c.Prefix,
+		presignExpiry: c.PresignExpiry,
+		tempPath:      c.TempPath,
+	}, nil
+}
+
+func (b *backend) ChunkMinLength() int64 {
+	return chunkMinLength
+}
+
+func (b *backend) InitiateUpload(ctx context.Context, id uuid.UUID, contentType string) (storageBackends.StorageBackendState, error) {
+	key := path.Join(b.prefix, "_uploads", id.String())
+
+	uploadId, err := b.client.NewMultipartUpload(ctx, b.bucket, key, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating multipart upload: %w", err)
+	}
+
+	return uploadState{
+		id:          id.String(),
+		key:         key,
+		uploadId:    uploadId,
+		contentType: contentType,
+	}.encode()
+}
+
+func (b *backend) UploadAddChunk(ctx context.Context, state storageBackends.StorageBackendState, reader io.Reader) (storageBackends.StorageBackendState, error) {
+	decodedState, err := decodeState(state)
+	if err != nil {
+		return nil, fmt.Errorf("decoding state: %w", err)
+	}
+
+	// parts need a known length, the chunk is buffered in a temp file to find it out
+	partFile, err := os.CreateTemp(b.tempPath, "dockyard-part-")
+	if err != nil {
+		return nil, fmt.Errorf("creating part file: %w", err)
+	}
+	defer utils.PanicOnError(func() error {
+		return os.Remove(partFile.Name())
+	}, "removing part file")
+	defer utils.PanicOnError(partFile.Close, "closing part file")
+
+	// data of earlier chunks that was too small for a part goes first
+	if decodedState.pendingSize > 0 {
+		err = b.copyPending(ctx, decodedState, partFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chunkSize, err := io.Copy(partFile, reader)
+	if err != nil {
+		return nil, fmt.Errorf("writing part file: %w", err)
+	}
+
+	// an empty part would end the upload, more data may follow
+	if chunkSize == 0 {
+		return state, nil
+	}
+
+	size := decodedState.pendingSize + chunkSize
+	if size < chunkMinLength {
+		_, err = b.client.PutObject(ctx, b.bucket, decodedState.pendingKey(), io.NewSectionReader(partFile, 0, size), size, "", "", minio.PutObjectOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("writing pending object: %w", err)
+		}
+
+		decodedState.pendingSize = size
+		return decodedState.encode()
+	}
+
+	err = b.putPart(ctx, &decodedState, io.NewSectionReader(partFile, 0, size), size)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodedState.encode()
+}
+
+// putPart uploads the next part, data that was pending is part of it and is removed.
+func (b *backend) putPart(ctx context.Context, state *uploadState, reader io.Reader, size int64) error {
+	partNumber := len(state.parts) + 1
+	if partNumber > maxParts {
+		return ociError.NewOciError(ociError.BlobUploadInvalid).
+			WithMessage(fmt.Sprintf("uploads consist of at most %d chunks", maxParts)).
+			WithHttpCode(http.StatusRequestEntityTooLarge)
+	}
+
+	part, err := b.client.PutObjectPart(ctx, b.bucket, state.key, state.uploadId, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return fmt.Errorf("uploading part %d: %w", partNumber, err)
+	}
+
+	state.parts = append(state.parts, minio.CompletePart{
+		PartNumber: partNumber,
+		ETag:       part.ETag,
+	})
+
+	if state.pendingSize > 0 {
+		err = b.client.RemoveObject(ctx, b.bucket, state.pendingKey(), minio.RemoveObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("removing pending object: %w", err)
+		}
+
+		state.pendingSize = 0
+	}
+
+	return nil
+}
+
+func (b *backend) copyPending(ctx context.Context, state uploadState, w io.Writer) error {
+	pending, err := b.client.Client.GetObject(ctx, b.bucket, state.pendingKey(), minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("getting pending object: %w", err)
+	}
+	defer utils.PanicOnError(pending.Close, "closing pending object")
+
+	copied, err := io.Copy(w, pending)
+	if err != nil {
+		return fmt.Errorf("reading pending object: %w", err)
+	}
+	if copied != state.pendingSize {
+		return fmt.Errorf("pending object has %d bytes, expected %d", copied, state.pendingSize)
+	}
+
+	return nil
+}
+
+func (b *backend) CompleteUpload(ctx context.Context, digest string, state storageBackends.StorageBackendState) error {
+	decodedState, err := decodeState(state)
+	if err != nil {
+		return fmt.Errorf("decoding state: %w", err)
+	}
+
+	dataKey, err := b.getDataKey(digest)
+	if err != nil {
+		return err
+	}
+
+	// the last part may be smaller than the minimum
+	if decodedState.pendingSize > 0 {
+		pending, err := b.client.Client.GetObject(ctx, b.bucket, decodedState.pendingKey(), minio.GetObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("getting pending object: %w", err)
+		}
+		defer utils.PanicOnError(pending.Close, "closing pending object")
+
+		// the client closes readers it sends, the pending object is closed by the deferred call only
+		err = b.putPart(ctx, &decodedState, io.LimitReader(pending, decodedState.pendingSize), decodedState.pendingSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	// a multipart upload needs at least one part, empty blobs are written directly
+	if len(decodedState.parts) == 0 {
+		_, err = b.client.PutObject(ctx, b.bucket, dataKey, http.NoBody, 0, "", "", minio.PutObjectOptions{
+			ContentType: decodedState.contentType,
+		})
+		if err != nil {
+			return fmt.Errorf("writing empty object: %w", err)
+		}
+
+		return b.AbortUpload(ctx, state)
+	}
+
+	_, err = b.client.CompleteMultipartUpload(ctx, b.bucket, decodedState.key, decodedState.uploadId, decodedState.parts, minio.PutObjectOptions{
+		ContentType: decodedState.contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("completing multipart upload: %w", err)
+	}
+
+	_, err = b.client.ComposeObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:      b.bucket,
+			Object:      dataKey,
+			ContentType: decodedState.contentType,
+		},
+		minio.CopySrcOptions{
+			Bucket: b.bucket,
+			Object: decodedState.key,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("moving object: %w", err)
+	}
+
+	err = b.client.RemoveObject(ctx, b.bucket, decodedState.key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("removing upload object: %w", err)
+	}
+
+	return nil
+}
+
+func (b *backend) AbortUpload(ctx context.Context, state storageBackends.StorageBackendState) error {
+	decodedState, err := decodeState(state)
+	if err != nil {
+		return fmt.Errorf("decoding state: %w", err)
+	}
+
+	err = b.client.AbortMultipartUpload(ctx, b.bucket, decodedState.key, decodedState.uploadId)
+	if err != nil && minio.ToErrorResponse(err).Code != minio.NoSuchUpload {
+		return fmt.Errorf("aborting multipart upload: %w", err)
+	}
+
+	// the upload may have been completed before moving the object failed
+	err = b.client.RemoveObject(ctx, b.bucket, decodedState.key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("removing upload object: %w", err)
+	}
+
+	err = b.client.RemoveObject(ctx, b.bucket, decodedState.pendingKey(), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("removing pending object: %w", err)
+	}
+
+	return nil
+}
+
+func (b *backend) DeleteBlob(ctx context.Context, digest string) error {
+	dataKey, err := b.getDataKey(digest)
+	if err != nil {
+		return err
+	}
+
+	err = b.client.RemoveObject(ctx, b.bucket, dataKey, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("removing object: %w", err)
+	}
+
+	return nil
+}
+
+func (b *backend) DownloadBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, digest string) error {
+	object, info, err := b.openObject(ctx, digest)
+	if errors.Is(err, errObjectNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	defer utils.PanicOnError(object.Close, "closing object")
+
+	w.Header().Set("Content-Type", info.ContentType)
+	http.ServeContent(w, r, "", time.Time{}, object)
+	return nil
+}
+
+func (b *backend) OpenBlob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	object, _, err := b.openObject(ctx, digest)
+	if errors.Is(err, errObjectNotFound) {
+		return nil, ociError.NewOciError(ociError.BlobUnknown)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+func (b *backend) GetDownloadLink(ctx context.Context, digest string) (string, error) {
+	dataKey, err := b.getDataKey(digest)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := b.client.PresignedGetObject(ctx, b.bucket, dataKey, b.presignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("presigning download link: %w", err)
+	}
+
+	return link.String(), nil
+}
+
+var errObjectNotFound = errors.New("object not found")
+
+// openObject opens the object of a blob, errObjectNotFound is returned if the blob does not exist.
+func (b *backend) openObject(ctx context.Context, digest string) (*minio.Object, minio.ObjectInfo, error) {
+	dataKey, err := b.getDataKey(digest)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+
+	object, err := b.client.Client.GetObject(ctx, b.bucket, dataKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, minio.ObjectInfo{}, fmt.Errorf("getting object: %w", err)
+	}
+
+	// the object is fetched lazily, stat reports whether it exists
+	info, err := object.Stat()
+	if err != nil {
+		utils.PanicOnError(object.Close, "closing object")
+
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, minio.ObjectInfo{}, errObjectNotFound
+		}
+		return nil, minio.ObjectInfo{}, fmt.Errorf("getting object info: %w", err)
+	}
+
+	return object, info, nil
+}
+
+// getDataKey returns the key of the object of a blob. Like in the directory backend, blobs are sharded by the first
+// bytes of their encoded digest and every algorithm gets its own prefix.
+func (b *backend) getDataKey(blobDigest string) (string, error) {
+	algorithm, encoded, err := digest.Parse(blobDigest)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(b.prefix, "blobs", string(algorithm), encoded[:2], encoded[2:4], encoded), nil
+}
@@ -0,0 +1,268 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/config"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/storageBackends"
+	"github.com/the127/dockyard/internal/utils/digest"
+	"github.com/the127/dockyard/internal/utils/ociError"
+	"go.uber.org/zap"
+)
+
+const testContentType = "application/octet-stream"
+
+type BackendTestSuite struct {
+	suite.Suite
+	s3       *fakeS3
+	server   *httptest.Server
+	tempPath string
+	backend  storageBackends.StorageBackend
+}
+
+func TestBackendTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(BackendTestSuite))
+}
+
+func (s *BackendTestSuite) SetupSuite() {
+	if logging.Logger == nil {
+		logging.Logger = zap.NewNop().Sugar()
+	}
+}
+
+func (s *BackendTestSuite) SetupTest() {
+	s.s3 = newFakeS3("registry")
+	s.server = httptest.NewServer(s.s3)
+	s.tempPath = s.T().TempDir()
+
+	backend, err := New(config.S3BlobStorageConfig{
+		Endpoint:        s.server.URL,
+		Bucket:          "registry",
+		Region:          "us-east-1",
+		PathStyle:       true,
+		AccessKeyId:     "key",
+		SecretAccessKey: "secret",
+		Prefix:          "dockyard",
+		PresignExpiry:   time.Minute,
+		TempPath:        s.tempPath,
+	})
+	s.Require().NoError(err)
+	s.backend = backend
+}
+
+func (s *BackendTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// upload uploads the chunks as one blob and returns its digest.
+func (s *BackendTestSuite) upload(ctx context.Context, chunks ...[]byte) string {
+	state, err := s.backend.InitiateUpload(ctx, uuid.New(), testContentType)
+	s.Require().NoError(err)
+
+	var data []byte
+	for _, chunk := range chunks {
+		state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader(chunk))
+		s.Require().NoError(err)
+		data = append(data, chunk...)
+	}
+
+	blobDigest := digest.SHA256.FromBytes(data)
+	s.Require().NoError(s.backend.CompleteUpload(ctx, blobDigest, state))
+
+	return blobDigest
+}
+
+func (s *BackendTestSuite) readBlob(ctx context.Context, blobDigest string) []byte {
+	reader, err := s.backend.OpenBlob(ctx, blobDigest)
+	s.Require().NoError(err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	s.Require().NoError(err)
+
+	return data
+}
+
+func (s *BackendTestSuite) dataKey(blobDigest string) string {
+	dataKey, err := s.backend.(*backend).getDataKey(blobDigest)
+	s.Require().NoError(err)
+
+	return dataKey
+}
+
+func (s *BackendTestSuite) TestInitiateUpload() {
+	// act
+	state, err := s.backend.InitiateUpload(context.Background(), uuid.New(), testContentType)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal([]int{0}, s.s3.partCounts())
+
+	decodedState, err := decodeState(state)
+	s.Require().NoError(err)
+	s.Equal(testContentType, decodedState.contentType)
+	s.Equal(int64(0), decodedState.pendingSize)
+}
+
+func (s *BackendTestSuite) TestUpload() {
+	ctx := context.Background()
+	large := bytes.Repeat([]byte("a"), chunkMinLength)
+
+	testCases := []struct {
+		name   string
+		chunks [][]byte
+	}{
+		{
+			name: "empty blob",
+		},
+		{
+			name:   "single small chunk",
+			chunks: [][]byte{[]byte("hello")},
+		},
+		{
+			name:   "chunks of the minimum part size",
+			chunks: [][]byte{large, large, []byte("tail")},
+		},
+		{
+			name:   "undersized chunks are combined into parts",
+			chunks: [][]byte{[]byte("small"), large, []byte("small"), []byte("small")},
+		},
+		{
+			name:   "empty chunk",
+			chunks: [][]byte{[]byte("hello"), {}, []byte("world")},
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// arrange
+			data := []byte{}
+			for _, chunk := range testCase.chunks {
+				data = append(data, chunk...)
+			}
+
+			// act
+			blobDigest := s.upload(ctx, testCase.chunks...)
+
+			// assert
+			s.Equal(data, s.readBlob(ctx, blobDigest))
+
+			object, ok := s.s3.object(s.dataKey(blobDigest))
+			s.Require().True(ok)
+			s.Equal(testContentType, object.contentType)
+
+			s.Empty(s.s3.partCounts())
+			tempFiles, err := os.ReadDir(s.tempPath)
+			s.Require().NoError(err)
+			s.Empty(tempFiles, "part files are removed")
+
+			s.Require().NoError(s.backend.DeleteBlob(ctx, blobDigest))
+			s.Empty(s.s3.keys())
+		})
+	}
+}
+
+func (s *BackendTestSuite) TestUploadAddChunk_HoldsBackUndersizedChunks() {
+	// arrange
+	ctx := context.Background()
+	state, err := s.backend.InitiateUpload(ctx, uuid.New(), testContentType)
+	s.Require().NoError(err)
+
+	// act
+	state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader([]byte("small")))
+	s.Require().NoError(err)
+
+	// assert
+	decodedState, err := decodeState(state)
+	s.Require().NoError(err)
+	s.Equal(int64(len("small")), decodedState.pendingSize)
+	s.Equal([]int{0}, s.s3.partCounts())
+	s.Equal([]string{decodedState.pendingKey()}, s.s3.keys())
+
+	// act
+	state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader(bytes.Repeat([]byte("a"), chunkMinLength)))
+	s.Require().NoError(err)
+
+	// assert
+	decodedState, err = decodeState(state)
+	s.Require().NoError(err)
+	s.Equal(int64(0), decodedState.pendingSize)
+	s.Equal([]int{1}, s.s3.partCounts())
+	s.Empty(s.s3.keys())
+}
+
+func (s *BackendTestSuite) TestAbortUpload() {
+	// arrange
+	ctx := context.Background()
+	state, err := s.backend.InitiateUpload(ctx, uuid.New(), testContentType)
+	s.Require().NoError(err)
+
+	state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader(bytes.Repeat([]byte("a"), chunkMinLength)))
+	s.Require().NoError(err)
+
+	state, err = s.backend.UploadAddChunk(ctx, state, bytes.NewReader([]byte("small")))
+	s.Require().NoError(err)
+
+	// act
+	err = s.backend.AbortUpload(ctx, state)
+
+	// assert
+	s.Require().NoError(err)
+	s.Empty(s.s3.partCounts())
+	s.Empty(s.s3.keys())
+
+	s.NoError(s.backend.AbortUpload(ctx, state), "aborting twice")
+}
+
+func (s *BackendTestSuite) TestOpenBlob_Unknown() {
+	// act
+	_, err := s.backend.OpenBlob(context.Background(), digest.SHA256.FromBytes([]byte("unknown")))
+
+	// assert
+	var ociErr *ociError.OciError
+	s.Require().True(errors.As(err, &ociErr))
+	s.Equal(ociError.BlobUnknown, ociErr.Code)
+}
+
+func (s *BackendTestSuite) TestGetDownloadLink() {
+	// arrange
+	ctx := context.Background()
+	blobDigest := s.upload(ctx, []byte("hello"))
+
+	linkProvider, ok := s.backend.(storageBackends.DownloadLinkProvider)
+	s.Require().True(ok)
+
+	// act
+	link, err := linkProvider.GetDownloadLink(ctx, blobDigest)
+
+	// assert
+	s.Require().NoError(err)
+
+	parsed, err := url.Parse(link)
+	s.Require().NoError(err)
+	s.Equal("/registry/"+s.dataKey(blobDigest), parsed.Path)
+	s.Equal("60", parsed.Query().Get("X-Amz-Expires"))
+	s.NotEmpty(parsed.Query().Get("X-Amz-Signature"))
+
+	response, err := http.Get(link)
+	s.Require().NoError(err)
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, response.StatusCode)
+	s.Equal("hello", string(data))
+}
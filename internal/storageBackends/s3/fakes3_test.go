@@ -0,0 +1,332 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeModTime is the modification time of every object, the client refuses objects without one.
+var fakeModTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+type fakeUpload struct {
+	key         string
+	contentType string
+	parts       map[int][]byte
+}
+
+// fakeS3 implements the part of the S3 API the backend uses for a single bucket with path style addressing. Like S3
+// it rejects multipart uploads whose parts but the last one are smaller than the minimum part size. Signatures are not
+// checked.
+type fakeS3 struct {
+	bucket string
+
+	mutex   sync.Mutex
+	objects map[string]fakeObject
+	uploads map[string]*fakeUpload
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeObject),
+		uploads: make(map[string]*fakeUpload),
+	}
+}
+
+func (f *fakeS3) keys() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	object, ok := f.objects[key]
+	return object, ok
+}
+
+// partCounts returns the number of parts uploaded so far for every multipart upload in progress.
+func (f *fakeS3) partCounts() []int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	partCounts := make([]int, 0, len(f.uploads))
+	for _, upload := range f.uploads {
+		partCounts = append(partCounts, len(upload.parts))
+	}
+
+	return partCounts
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	// the body is read before locking, the client may stream it from another object of the fake
+	body, err := readFakeBody(r)
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case key == "":
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadId := uuid.New().String()
+		f.uploads[uploadId] = &fakeUpload{
+			key:         key,
+			contentType: r.Header.Get("Content-Type"),
+			parts:       make(map[int][]byte),
+		}
+
+		writeFakeXml(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: uploadId})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+
+		partNumber, err := strconv.Atoi(query.Get("partNumber"))
+		if err != nil {
+			writeFakeError(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+
+		if r.Header.Get("X-Amz-Copy-Source") == "" {
+			upload.parts[partNumber] = body
+			w.Header().Set("ETag", fakeETag(body))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		object, ok := f.copySource(r)
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		var start, end int
+		_, err = fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &start, &end)
+		if err != nil || start > end || end >= len(object.data) {
+			writeFakeError(w, http.StatusBadRequest, "InvalidRange")
+			return
+		}
+
+		upload.parts[partNumber] = object.data[start : end+1]
+		writeFakeXml(w, struct {
+			XMLName      xml.Name `xml:"CopyPartResult"`
+			ETag         string
+			LastModified string
+		}{ETag: fakeETag(upload.parts[partNumber]), LastModified: fakeModTime.Format(time.RFC3339)})
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.completeUpload(w, query.Get("uploadId"), body)
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		_, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		object, ok := f.copySource(r)
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			object.contentType = r.Header.Get("Content-Type")
+		}
+		f.objects[key] = object
+
+		writeFakeXml(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: fakeETag(object.data), LastModified: fakeModTime.Format(time.RFC3339)})
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = fakeObject{
+			data:        body,
+			contentType: r.Header.Get("Content-Type"),
+		}
+		w.Header().Set("ETag", fakeETag(body))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("ETag", fakeETag(object.data))
+		http.ServeContent(w, r, "", fakeModTime, bytes.NewReader(object.data))
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// copySource returns the object a copy request reads from.
+func (f *fakeS3) copySource(r *http.Request) (fakeObject, bool) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return fakeObject{}, false
+	}
+
+	object, ok := f.objects[strings.TrimPrefix(strings.TrimPrefix(source, "/"), f.bucket+"/")]
+	return object, ok
+}
+
+func (f *fakeS3) completeUpload(w http.ResponseWriter, uploadId string, body []byte) {
+	upload, ok := f.uploads[uploadId]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var request struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	err := xml.Unmarshal(body, &request)
+	if err != nil || len(request.Parts) == 0 {
+		writeFakeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	var data []byte
+	for i, part := range request.Parts {
+		partData, ok := upload.parts[part.PartNumber]
+		if !ok || strings.Trim(part.ETag, `"`) != strings.Trim(fakeETag(partData), `"`) {
+			writeFakeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+
+		if i < len(request.Parts)-1 && len(partData) < chunkMinLength {
+			writeFakeError(w, http.StatusBadRequest, "EntityTooSmall")
+			return
+		}
+
+		data = append(data, partData...)
+	}
+
+	f.objects[upload.key] = fakeObject{
+		data:        data,
+		contentType: upload.contentType,
+	}
+	delete(f.uploads, uploadId)
+
+	writeFakeXml(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: f.bucket, Key: upload.key, ETag: fakeETag(data)})
+}
+
+// readFakeBody reads the body of an upload, which the client sends in signed chunks over plain HTTP.
+func readFakeBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+
+		chunk := make([]byte, size+2)
+		_, err = io.ReadFull(reader, chunk)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func fakeETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeFakeXml(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message><RequestId>fake</RequestId></Error>", code, code)
+}
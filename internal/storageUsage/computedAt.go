@@ -0,0 +1,40 @@
+package storageUsage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+const computedAtKey = "storage_usage_computed_at"
+
+// SetComputedAt records when the storage counters were refreshed for the last time.
+func SetComputedAt(ctx context.Context, kvStore kv.Store, computedAt time.Time) error {
+	err := kvStore.Set(ctx, computedAtKey, computedAt.Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("storing storage usage refresh time: %w", err)
+	}
+
+	return nil
+}
+
+// GetComputedAt returns when the storage counters were refreshed for the last time, or nil if they have not been
+// refreshed yet.
+func GetComputedAt(ctx context.Context, kvStore kv.Store) (*time.Time, error) {
+	value, ok, err := kvStore.Get(ctx, computedAtKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting storage usage refresh time: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	computedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing storage usage refresh time: %w", err)
+	}
+
+	return &computedAt, nil
+}
@@ -0,0 +1,120 @@
+package storageUsage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+// Usage is the storage used by the repositories of a project or tenant. Blobs are stored once, no matter how many
+// repositories they are linked to, so every blob is only counted once.
+type Usage struct {
+	Used int64
+	// Quota is nil if the storage is not limited.
+	Quota *int64
+}
+
+func GetProjectUsage(ctx context.Context, dbContext database.Context, project *repositories.Project) (*Usage, error) {
+	used, err := sumBlobSizes(ctx, dbContext, repositories.NewBlobFilter().ByProjectId(project.GetId()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		Used:  used,
+		Quota: project.GetStorageQuota(),
+	}, nil
+}
+
+func GetTenantUsage(ctx context.Context, dbContext database.Context, tenant *repositories.Tenant) (*Usage, error) {
+	used, err := sumBlobSizes(ctx, dbContext, repositories.NewBlobFilter().ByTenantId(tenant.GetId()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		Used:  used,
+		Quota: tenant.GetStorageQuota(),
+	}, nil
+}
+
+// CheckQuota returns a quota exceeded error if storing size more bytes in the repository exceeds the storage quota of
+// its project or tenant. If digest is set and the blob is already stored in the project or tenant, it takes no
+// additional space there.
+//
+// Uploads are checked when they start, with the size they reach with every chunk and once more with the size of the
+// blob when they finish. Concurrent pushes are checked independently of each other, so they may exceed the quota by the
+// size of the pushes in flight.
+func CheckQuota(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID, digest string, size int64) error {
+	repository, err := dbContext.Repositories().Single(ctx, repositories.NewRepositoryFilter().ById(repositoryId))
+	if err != nil {
+		return fmt.Errorf("getting repository: %w", err)
+	}
+
+	project, err := dbContext.Projects().Single(ctx, repositories.NewProjectFilter().ById(repository.GetProjectId()))
+	if err != nil {
+		return fmt.Errorf("getting project: %w", err)
+	}
+
+	if project.GetStorageQuota() != nil {
+		filter := repositories.NewBlobFilter().ByProjectId(project.GetId())
+		err = checkQuota(ctx, dbContext, filter, *project.GetStorageQuota(), digest, size, fmt.Sprintf("project '%s'", project.GetSlug()))
+		if err != nil {
+			return err
+		}
+	}
+
+	tenant, err := dbContext.Tenants().Single(ctx, repositories.NewTenantFilter().ById(project.GetTenantId()))
+	if err != nil {
+		return fmt.Errorf("getting tenant: %w", err)
+	}
+
+	if tenant.GetStorageQuota() != nil {
+		filter := repositories.NewBlobFilter().ByTenantId(tenant.GetId())
+		err = checkQuota(ctx, dbContext, filter, *tenant.GetStorageQuota(), digest, size, fmt.Sprintf("tenant '%s'", tenant.GetSlug()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkQuota(ctx context.Context, dbContext database.Context, filter *repositories.BlobFilter, quota int64, digest string, size int64, owner string) error {
+	if digest != "" {
+		stored, err := dbContext.Blobs().First(ctx, filter.ByDigest(digest))
+		if err != nil {
+			return fmt.Errorf("getting blob: %w", err)
+		}
+		if stored != nil {
+			return nil
+		}
+	}
+
+	used, err := sumBlobSizes(ctx, dbContext, filter)
+	if err != nil {
+		return err
+	}
+
+	if used+size > quota {
+		return ociError.NewOciError(ociError.QuotaExceeded).
+			WithMessage(fmt.Sprintf("storing %d more bytes exceeds the storage quota of %s, %d of %d bytes are used", size, owner, used, quota)).
+			WithHttpCode(http.StatusForbidden)
+	}
+
+	return nil
+}
+
+func sumBlobSizes(ctx context.Context, dbContext database.Context, filter *repositories.BlobFilter) (int64, error) {
+	size, err := dbContext.Blobs().SumSizes(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("summing blob sizes: %w", err)
+	}
+
+	return size, nil
+}
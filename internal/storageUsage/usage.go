@@ -0,0 +1,471 @@
+package storageUsage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/repositories"
+)
+
+// Sizes describes the storage of a tag, repository, project or tenant in bytes.
+type Sizes struct {
+	// LogicalSize is what the content would take without deduplication: every manifest counts with its own size and the
+	// sizes of the blobs it refers to, even if other manifests refer to the same blobs.
+	LogicalSize int64
+	// StoredSize counts every blob once.
+	StoredSize int64
+	// UniqueSize counts the blobs nothing else holds on to, it is what deleting the content would free.
+	UniqueSize int64
+}
+
+// Snapshot holds the sizes of every tenant, project and repository by their id.
+type Snapshot struct {
+	Tenants      map[uuid.UUID]Sizes
+	Projects     map[uuid.UUID]Sizes
+	Repositories map[uuid.UUID]Sizes
+}
+
+// ComputeSnapshot computes the sizes of every tenant, project and repository at once. It goes through all blobs and
+// manifests, so it is meant to run in the background, see repositories.StorageUsage.
+func ComputeSnapshot(ctx context.Context, dbContext database.Context) (*Snapshot, error) {
+	tenants, _, err := dbContext.Tenants().List(ctx, repositories.NewTenantFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing tenants: %w", err)
+	}
+
+	projects, _, err := dbContext.Projects().List(ctx, repositories.NewProjectFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+
+	repos, _, err := dbContext.Repositories().List(ctx, repositories.NewRepositoryFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	blobs, _, err := dbContext.Blobs().List(ctx, repositories.NewBlobFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing blobs: %w", err)
+	}
+
+	repositoryBlobs, _, err := dbContext.RepositoryBlobs().List(ctx, repositories.NewRepositoryBlobFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing repository blobs: %w", err)
+	}
+
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	manifestReferences, _, err := dbContext.ManifestReferences().List(ctx, repositories.NewManifestReferenceFilter())
+	if err != nil {
+		return nil, fmt.Errorf("listing manifest references: %w", err)
+	}
+
+	snapshot := &Snapshot{
+		Tenants:      make(map[uuid.UUID]Sizes, len(tenants)),
+		Projects:     make(map[uuid.UUID]Sizes, len(projects)),
+		Repositories: make(map[uuid.UUID]Sizes, len(repos)),
+	}
+
+	tenantOfProject := make(map[uuid.UUID]uuid.UUID, len(projects))
+	for _, tenant := range tenants {
+		snapshot.Tenants[tenant.GetId()] = Sizes{}
+	}
+	for _, project := range projects {
+		snapshot.Projects[project.GetId()] = Sizes{}
+		tenantOfProject[project.GetId()] = project.GetTenantId()
+	}
+
+	projectOfRepository := make(map[uuid.UUID]uuid.UUID, len(repos))
+	for _, repository := range repos {
+		snapshot.Repositories[repository.GetId()] = Sizes{}
+		projectOfRepository[repository.GetId()] = repository.GetProjectId()
+	}
+
+	blobsById := make(map[uuid.UUID]*repositories.Blob, len(blobs))
+	for _, blob := range blobs {
+		blobsById[blob.GetId()] = blob
+	}
+
+	// the repositories, projects and tenants holding on to every blob
+	type holders struct {
+		repositories map[uuid.UUID]bool
+		projects     map[uuid.UUID]bool
+		tenants      map[uuid.UUID]bool
+	}
+	holdersByBlob := make(map[uuid.UUID]*holders)
+	linkedSizes := make(map[uuid.UUID]map[string]int64)
+	for _, repositoryBlob := range repositoryBlobs {
+		blob, ok := blobsById[repositoryBlob.GetBlobId()]
+		if !ok {
+			continue
+		}
+
+		repositoryId := repositoryBlob.GetRepositoryId()
+		projectId := projectOfRepository[repositoryId]
+
+		blobHolders, ok := holdersByBlob[blob.GetId()]
+		if !ok {
+			blobHolders = &holders{
+				repositories: make(map[uuid.UUID]bool),
+				projects:     make(map[uuid.UUID]bool),
+				tenants:      make(map[uuid.UUID]bool),
+			}
+			holdersByBlob[blob.GetId()] = blobHolders
+		}
+		blobHolders.repositories[repositoryId] = true
+		blobHolders.projects[projectId] = true
+		blobHolders.tenants[tenantOfProject[projectId]] = true
+
+		if linkedSizes[repositoryId] == nil {
+			linkedSizes[repositoryId] = make(map[string]int64)
+		}
+		linkedSizes[repositoryId][blob.GetDigest()] = blob.GetSize()
+	}
+
+	addStored := func(sizes map[uuid.UUID]Sizes, owners map[uuid.UUID]bool, size int64) {
+		for owner := range owners {
+			ownerSizes := sizes[owner]
+			ownerSizes.StoredSize += size
+			if len(owners) == 1 {
+				ownerSizes.UniqueSize += size
+			}
+			sizes[owner] = ownerSizes
+		}
+	}
+
+	for blobId, blobHolders := range holdersByBlob {
+		size := blobsById[blobId].GetSize()
+		addStored(snapshot.Repositories, blobHolders.repositories, size)
+		addStored(snapshot.Projects, blobHolders.projects, size)
+		addStored(snapshot.Tenants, blobHolders.tenants, size)
+	}
+
+	referencesByManifest := groupReferences(manifestReferences)
+	for _, manifest := range manifests {
+		repositoryId := manifest.GetRepositoryId()
+		logicalSize := manifestLogicalSize(manifest, referencesByManifest, linkedSizes[repositoryId])
+
+		projectId := projectOfRepository[repositoryId]
+		tenantId := tenantOfProject[projectId]
+
+		addLogical(snapshot.Repositories, repositoryId, logicalSize)
+		addLogical(snapshot.Projects, projectId, logicalSize)
+		addLogical(snapshot.Tenants, tenantId, logicalSize)
+	}
+
+	return snapshot, nil
+}
+
+func addLogical(sizes map[uuid.UUID]Sizes, owner uuid.UUID, size int64) {
+	ownerSizes, ok := sizes[owner]
+	if !ok {
+		return
+	}
+
+	ownerSizes.LogicalSize += size
+	sizes[owner] = ownerSizes
+}
+
+// manifestLogicalSize returns the size of the manifest and the blobs it directly refers to. Children of an index are
+// manifests of their own, their layers are counted with them.
+func manifestLogicalSize(manifest *repositories.Manifest, referencesByManifest map[uuid.UUID][]*repositories.ManifestReference, sizesByDigest map[string]int64) int64 {
+	size := sizesByDigest[manifest.GetDigest()]
+	for _, reference := range referencesByManifest[manifest.GetId()] {
+		size += sizesByDigest[reference.GetDigest()]
+	}
+
+	return size
+}
+
+func groupReferences(manifestReferences []*repositories.ManifestReference) map[uuid.UUID][]*repositories.ManifestReference {
+	referencesByManifest := make(map[uuid.UUID][]*repositories.ManifestReference)
+	for _, manifestReference := range manifestReferences {
+		referencesByManifest[manifestReference.GetManifestId()] = append(referencesByManifest[manifestReference.GetManifestId()], manifestReference)
+	}
+
+	return referencesByManifest
+}
+
+// RepositoryUsage is the storage of a repository broken down by tag.
+type RepositoryUsage struct {
+	Sizes
+
+	Tags []TagUsage
+	// SharedBlobs are the configs and layers of the repository that other repositories are linked to as well.
+	SharedBlobs []SharedBlob
+}
+
+type TagUsage struct {
+	Name   string
+	Digest string
+
+	// LogicalSize counts every blob of the tagged manifest once, including the manifests of an index.
+	LogicalSize int64
+	// UniqueSize counts the blobs of the tagged manifest that neither other tags, untagged manifests nor other
+	// repositories hold on to, it is what deleting the tag and its manifest would free.
+	UniqueSize int64
+}
+
+type SharedBlob struct {
+	Digest    string
+	MediaType string
+	Size      int64
+	// SharedWith is the number of other repositories linked to the blob.
+	SharedWith int
+	// Tags are the tags of the repository whose manifests refer to the blob.
+	Tags []string
+}
+
+// GetRepositoryUsage computes the storage of a single repository. Unlike ComputeSnapshot it only reads the content of
+// the repository, so it is cheap enough to run on request.
+func GetRepositoryUsage(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) (*RepositoryUsage, error) {
+	graph, err := loadManifestGraph(ctx, dbContext, repositoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, _, err := dbContext.Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId).WithManifestInfo())
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	repositoryBlobs, _, err := dbContext.RepositoryBlobs().List(ctx, repositories.NewRepositoryBlobFilter().BySharedWithRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing repository blobs: %w", err)
+	}
+
+	otherHolders := make(map[uuid.UUID]int)
+	for _, repositoryBlob := range repositoryBlobs {
+		if repositoryBlob.GetRepositoryId() != repositoryId {
+			otherHolders[repositoryBlob.GetBlobId()]++
+		}
+	}
+
+	usage := &RepositoryUsage{}
+	for _, blob := range graph.blobs {
+		usage.StoredSize += blob.GetSize()
+		if otherHolders[blob.GetId()] == 0 {
+			usage.UniqueSize += blob.GetSize()
+		}
+	}
+
+	sizesByDigest := graph.sizesByDigest()
+	for _, manifest := range graph.manifests {
+		usage.LogicalSize += manifestLogicalSize(manifest, graph.references, sizesByDigest)
+	}
+
+	// a manifest is kept by its tags and, if it is untagged, by the index referring to it or otherwise by itself
+	tagsByManifest := make(map[uuid.UUID][]*repositories.Tag)
+	for _, tag := range tags {
+		tagsByManifest[tag.GetRepositoryManifestId()] = append(tagsByManifest[tag.GetRepositoryManifestId()], tag)
+	}
+
+	referenced := make(map[string]bool)
+	for _, references := range graph.references {
+		for _, reference := range references {
+			referenced[reference.GetDigest()] = true
+		}
+	}
+
+	var untaggedRoots []string
+	for _, manifest := range graph.manifests {
+		if len(tagsByManifest[manifest.GetId()]) == 0 && !referenced[manifest.GetDigest()] {
+			untaggedRoots = append(untaggedRoots, manifest.GetDigest())
+		}
+	}
+
+	// roots counts the tags and untagged manifests holding on to every digest, a digest held by a single tag is freed
+	// if the tag and its manifest are deleted
+	trees := make([]map[string]bool, len(tags))
+	roots := make(map[string]int)
+	for i, tag := range tags {
+		trees[i] = graph.tree(tag.GetManifestInfo().Digest)
+		for digest := range trees[i] {
+			roots[digest]++
+		}
+	}
+	for _, root := range untaggedRoots {
+		for digest := range graph.tree(root) {
+			roots[digest]++
+		}
+	}
+
+	tagsByDigest := make(map[string][]string)
+	for i, tag := range tags {
+		digest := tag.GetManifestInfo().Digest
+		tree := trees[i]
+
+		tagUsage := TagUsage{
+			Name:   tag.GetName(),
+			Digest: digest,
+		}
+		for treeDigest := range tree {
+			blob, ok := graph.blobs[treeDigest]
+			if !ok {
+				continue
+			}
+
+			tagUsage.LogicalSize += blob.GetSize()
+			if roots[treeDigest] == 1 && otherHolders[blob.GetId()] == 0 {
+				tagUsage.UniqueSize += blob.GetSize()
+			}
+
+			tagsByDigest[treeDigest] = append(tagsByDigest[treeDigest], tag.GetName())
+		}
+
+		usage.Tags = append(usage.Tags, tagUsage)
+	}
+
+	slices.SortFunc(usage.Tags, func(a, b TagUsage) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	for digest, blob := range graph.blobs {
+		if otherHolders[blob.GetId()] == 0 {
+			continue
+		}
+		if _, ok := graph.manifests[digest]; ok {
+			continue
+		}
+
+		tagNames := tagsByDigest[digest]
+		slices.Sort(tagNames)
+
+		usage.SharedBlobs = append(usage.SharedBlobs, SharedBlob{
+			Digest:     digest,
+			MediaType:  graph.mediaTypes[digest],
+			Size:       blob.GetSize(),
+			SharedWith: otherHolders[blob.GetId()],
+			Tags:       tagNames,
+		})
+	}
+
+	slices.SortFunc(usage.SharedBlobs, func(a, b SharedBlob) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Digest, b.Digest))
+	})
+
+	return usage, nil
+}
+
+// GetManifestSizes returns the logical size of every manifest of the repository by its digest, counting every blob
+// of the manifest once, including the manifests of an index.
+func GetManifestSizes(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) (map[string]int64, error) {
+	graph, err := loadManifestGraph(ctx, dbContext, repositoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(graph.manifests))
+	for digest := range graph.manifests {
+		var size int64
+		for treeDigest := range graph.tree(digest) {
+			if blob, ok := graph.blobs[treeDigest]; ok {
+				size += blob.GetSize()
+			}
+		}
+		sizes[digest] = size
+	}
+
+	return sizes, nil
+}
+
+// manifestGraph holds the manifests of a repository together with the blobs they refer to.
+type manifestGraph struct {
+	// blobs are the blobs linked to the repository by their digest.
+	blobs      map[string]*repositories.Blob
+	manifests  map[string]*repositories.Manifest
+	references map[uuid.UUID][]*repositories.ManifestReference
+	mediaTypes map[string]string
+}
+
+func loadManifestGraph(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) (*manifestGraph, error) {
+	blobs, _, err := dbContext.Blobs().List(ctx, repositories.NewBlobFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing blobs: %w", err)
+	}
+
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	manifestReferences, _, err := dbContext.ManifestReferences().List(ctx, repositories.NewManifestReferenceFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing manifest references: %w", err)
+	}
+
+	graph := &manifestGraph{
+		blobs:      make(map[string]*repositories.Blob, len(blobs)),
+		manifests:  make(map[string]*repositories.Manifest, len(manifests)),
+		references: groupReferences(manifestReferences),
+		mediaTypes: make(map[string]string),
+	}
+
+	for _, blob := range blobs {
+		graph.blobs[blob.GetDigest()] = blob
+	}
+
+	for _, manifest := range manifests {
+		graph.manifests[manifest.GetDigest()] = manifest
+		graph.mediaTypes[manifest.GetDigest()] = manifest.GetMediaType()
+	}
+
+	for _, manifestReference := range manifestReferences {
+		graph.mediaTypes[manifestReference.GetDigest()] = manifestReference.GetMediaType()
+	}
+
+	return graph, nil
+}
+
+func (g *manifestGraph) sizesByDigest() map[string]int64 {
+	sizes := make(map[string]int64, len(g.blobs))
+	for digest, blob := range g.blobs {
+		sizes[digest] = blob.GetSize()
+	}
+
+	return sizes
+}
+
+// tree returns the digest of the manifest and of everything it refers to, directly or through the manifests of an
+// index.
+func (g *manifestGraph) tree(digest string) map[string]bool {
+	tree := make(map[string]bool)
+	g.addTree(digest, tree)
+	return tree
+}
+
+func (g *manifestGraph) addTree(digest string, tree map[string]bool) {
+	if tree[digest] {
+		return
+	}
+	tree[digest] = true
+
+	manifest, ok := g.manifests[digest]
+	if !ok {
+		return
+	}
+
+	for _, reference := range g.references[manifest.GetId()] {
+		g.addTree(reference.GetDigest(), tree)
+	}
+}
+
+// CounterSizes returns the sizes stored in the counter, the sizes are zero if the owner has not been counted yet.
+func CounterSizes(counter *repositories.StorageUsage) Sizes {
+	if counter == nil {
+		return Sizes{}
+	}
+
+	return Sizes{
+		LogicalSize: counter.GetLogicalSize(),
+		StoredSize:  counter.GetStoredSize(),
+		UniqueSize:  counter.GetUniqueSize(),
+	}
+}
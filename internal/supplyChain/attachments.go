@@ -0,0 +1,273 @@
+package supplyChain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/images"
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// Annotations buildkit puts on the attestation manifests it adds to image indexes.
+const (
+	referenceTypeAnnotation   = "vnd.docker.reference.type"
+	referenceDigestAnnotation = "vnd.docker.reference.digest"
+	attestationManifestType   = "attestation-manifest"
+)
+
+// Attachment is a document attached to an image, either as OCI artifact referring to the image or as attestation
+// manifest in the index of the image.
+type Attachment struct {
+	// SubjectDigest is the manifest the document describes.
+	SubjectDigest string
+	// Platform is set if the subject is a platform of the index the attachments were looked up for.
+	Platform *images.Platform
+	// ArtifactDigest is the manifest that holds the document.
+	ArtifactDigest string
+	DocumentDigest string
+	// MediaType is the media type the document is parsed as.
+	MediaType string
+}
+
+// AttachedDocument is a parsed document together with the image it is attached to.
+type AttachedDocument struct {
+	Attachment
+	Document
+}
+
+// FindAttachments lists the documents attached to a manifest, for an index also the documents of its platforms.
+func FindAttachments(ctx context.Context, dbContext database.Context, blobService blobStorage.Service, kvStore kv.Store, manifest *repositories.Manifest) ([]Attachment, error) {
+	subjects := []string{manifest.GetDigest()}
+	platforms := make(map[string]*images.Platform)
+
+	var attachments []Attachment
+	if images.IsIndex(manifest.GetMediaType()) {
+		index, err := readManifest(ctx, blobService, kvStore, manifest.GetDigest())
+		if err != nil {
+			return nil, err
+		}
+
+		attestations, err := findAttestationManifests(ctx, dbContext, blobService, kvStore, manifest.GetRepositoryId(), index)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attestations...)
+
+		for _, descriptor := range index.Manifests {
+			if descriptor.Annotations[referenceTypeAnnotation] != attestationManifestType {
+				subjects = append(subjects, descriptor.Digest)
+				platforms[descriptor.Digest] = descriptor.Platform
+			}
+		}
+	}
+
+	for _, subject := range subjects {
+		referrers, _, err := dbContext.Referrers().List(ctx, repositories.NewReferrerFilter().ByRepositoryId(manifest.GetRepositoryId()).BySubjectDigest(subject))
+		if err != nil {
+			return nil, fmt.Errorf("listing referrers: %w", err)
+		}
+
+		found, err := findReferrerDocuments(ctx, dbContext, blobService, kvStore, referrers)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, found...)
+	}
+
+	for i := range attachments {
+		attachments[i].Platform = platforms[attachments[i].SubjectDigest]
+	}
+
+	return attachments, nil
+}
+
+// ListRepositoryAttachments lists the documents attached to any manifest of a repository. It also returns the indexes
+// of the repository by the digests of the manifests they list, so that an attachment can be traced to the tags of
+// the index its subject belongs to. Manifests are cached in the kv store, so searching a repository again does not read
+// their blobs.
+func ListRepositoryAttachments(ctx context.Context, dbContext database.Context, blobService blobStorage.Service, kvStore kv.Store, repositoryId uuid.UUID) ([]Attachment, map[string][]string, error) {
+	referrers, _, err := dbContext.Referrers().List(ctx, repositories.NewReferrerFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	attachments, err := findReferrerDocuments(ctx, dbContext, blobService, kvStore, referrers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	parents := make(map[string][]string)
+	for _, manifest := range manifests {
+		if !images.IsIndex(manifest.GetMediaType()) {
+			continue
+		}
+
+		index, err := readManifest(ctx, blobService, kvStore, manifest.GetDigest())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, descriptor := range index.Manifests {
+			parents[descriptor.Digest] = append(parents[descriptor.Digest], manifest.GetDigest())
+		}
+
+		attestations, err := findAttestationManifests(ctx, dbContext, blobService, kvStore, repositoryId, index)
+		if err != nil {
+			return nil, nil, err
+		}
+		attachments = append(attachments, attestations...)
+	}
+
+	return attachments, parents, nil
+}
+
+// findAttestationManifests lists the documents of the attestation manifests buildkit adds to an index, they refer to
+// their image by annotation instead of by subject. Attestation manifests that are not stored in the repository, e.g.
+// because an index was only partially proxied, are skipped.
+func findAttestationManifests(ctx context.Context, dbContext database.Context, blobService blobStorage.Service, kvStore kv.Store, repositoryId uuid.UUID, index *images.Manifest) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, descriptor := range index.Manifests {
+		if descriptor.Annotations[referenceTypeAnnotation] != attestationManifestType {
+			continue
+		}
+
+		stored, err := dbContext.Manifests().First(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId).ByDigest(descriptor.Digest))
+		if err != nil {
+			return nil, fmt.Errorf("getting manifest: %w", err)
+		}
+		if stored == nil {
+			continue
+		}
+
+		attestation, err := readManifest(ctx, blobService, kvStore, descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, findDocuments(attestation, descriptor.Annotations[referenceDigestAnnotation], descriptor.Digest, descriptor.ArtifactType)...)
+	}
+
+	return attachments, nil
+}
+
+func findReferrerDocuments(ctx context.Context, dbContext database.Context, blobService blobStorage.Service, kvStore kv.Store, referrers []*repositories.Referrer) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, referrer := range referrers {
+		manifest, err := dbContext.Manifests().Single(ctx, repositories.NewManifestFilter().ById(referrer.GetManifestId()))
+		if err != nil {
+			return nil, fmt.Errorf("getting manifest: %w", err)
+		}
+
+		artifact, err := readManifest(ctx, blobService, kvStore, manifest.GetDigest())
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, findDocuments(artifact, referrer.GetSubjectDigest(), manifest.GetDigest(), referrer.GetArtifactType())...)
+	}
+
+	return attachments, nil
+}
+
+// readManifest reads and parses a manifest. Manifests never change, so they are cached in the kv store by their digest
+// like documents are.
+func readManifest(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, manifestDigest string) (*images.Manifest, error) {
+	cacheKey := buildManifestCacheKey(manifestDigest)
+
+	cached, ok, err := kvStore.Get(ctx, cacheKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting cached manifest: %w", err)
+	}
+	if ok {
+		var manifest images.Manifest
+		err = json.Unmarshal([]byte(cached), &manifest)
+		if err == nil {
+			return &manifest, nil
+		}
+
+		logging.Logger.Warnf("ignoring invalid cached manifest %s: %v", manifestDigest, err)
+	}
+
+	manifest, _, err := images.ReadManifest(ctx, blobService, manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	err = kvStore.Set(ctx, cacheKey, string(encoded), kv.WithExpiration(documentCacheExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("caching manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+func buildManifestCacheKey(manifestDigest string) string {
+	return fmt.Sprintf("supply_chain_manifest:%s", manifestDigest)
+}
+
+// findDocuments returns the layers of an artifact manifest that hold a supported document.
+func findDocuments(artifact *images.Manifest, subjectDigest string, artifactDigest string, artifactType string) []Attachment {
+	if artifact.ArtifactType != "" {
+		artifactType = artifact.ArtifactType
+	}
+
+	var attachments []Attachment
+	for _, layer := range artifact.Layers {
+		mediaType, ok := documentMediaType(layer.MediaType, artifactType)
+		if !ok {
+			continue
+		}
+
+		attachments = append(attachments, Attachment{
+			SubjectDigest:  subjectDigest,
+			ArtifactDigest: artifactDigest,
+			DocumentDigest: layer.Digest,
+			MediaType:      mediaType,
+		})
+	}
+
+	return attachments
+}
+
+// LoadDocuments loads the documents of the attachments. Unsupported attestations are skipped, as are documents that
+// cannot be parsed, so that a single broken upload does not hide the other documents of an image.
+func LoadDocuments(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, attachments []Attachment) ([]AttachedDocument, error) {
+	var documents []AttachedDocument
+	for _, attachment := range attachments {
+		document, err := LoadDocument(ctx, blobService, kvStore, attachment)
+		if errors.Is(err, ErrInvalidDocument) {
+			logging.Logger.Warnf("skipping document %s attached to %s: %v", attachment.DocumentDigest, attachment.SubjectDigest, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+
+		documents = append(documents, AttachedDocument{
+			Attachment: attachment,
+			Document:   *document,
+		})
+	}
+
+	return documents, nil
+}
@@ -0,0 +1,248 @@
+package supplyChain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/the127/dockyard/internal/logging"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// maxDocumentSize limits how much of a document is read into memory, SBOMs of large images can reach tens of MB.
+const maxDocumentSize = 64 * 1024 * 1024
+
+// documentCacheExpiration is how long a parsed document is kept in the kv store, documents are cached by digest so
+// they never become stale.
+const documentCacheExpiration = 24 * time.Hour
+
+const (
+	MediaTypeSpdx           = "application/spdx+json"
+	MediaTypeCycloneDx      = "application/vnd.cyclonedx+json"
+	MediaTypeInToto         = "application/vnd.in-toto+json"
+	MediaTypeDsse           = "application/vnd.dsse.envelope.v1+json"
+	MediaTypeSigstoreBundle = "application/vnd.dev.sigstore.bundle.v0.3+json"
+)
+
+// documentMediaTypes are the media types of the documents that are parsed, other blobs of attached artifacts such as
+// signatures are ignored.
+var documentMediaTypes = map[string]bool{
+	MediaTypeSpdx:           true,
+	MediaTypeCycloneDx:      true,
+	MediaTypeInToto:         true,
+	MediaTypeDsse:           true,
+	MediaTypeSigstoreBundle: true,
+}
+
+const (
+	predicateTypeSpdx      = "https://spdx.dev/Document"
+	predicateTypeCycloneDx = "https://cyclonedx.org/bom"
+	predicateTypeSlsa      = "https://slsa.dev/provenance/"
+)
+
+// ErrInvalidDocument is returned for documents whose media type is supported but whose content cannot be parsed.
+var ErrInvalidDocument = errors.New("invalid document")
+
+type DocumentKind string
+
+const (
+	DocumentKindSbom       DocumentKind = "sbom"
+	DocumentKindProvenance DocumentKind = "provenance"
+)
+
+type DocumentFormat string
+
+const (
+	DocumentFormatSpdx      DocumentFormat = "spdx"
+	DocumentFormatCycloneDx DocumentFormat = "cyclonedx"
+	DocumentFormatSlsa      DocumentFormat = "slsa"
+)
+
+// Document is a parsed SBOM or provenance attestation. Packages is only set for SBOMs, Provenance only for provenance
+// attestations.
+type Document struct {
+	Kind   DocumentKind   `json:"kind"`
+	Format DocumentFormat `json:"format"`
+
+	Packages   []Package   `json:"packages,omitempty"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// documentMediaType returns the media type the document of a blob is parsed as. Artifacts often store their document
+// in a blob with a generic media type and name its format in the artifact type instead.
+func documentMediaType(blobMediaType string, artifactType string) (string, bool) {
+	if documentMediaTypes[blobMediaType] {
+		return blobMediaType, true
+	}
+
+	if documentMediaTypes[artifactType] {
+		return artifactType, true
+	}
+
+	return "", false
+}
+
+// LoadDocument reads and parses the document of an attachment. It returns nil if the document is an attestation of a
+// kind that is not supported, e.g. a vulnerability scan. Documents are cached in the kv store by their digest.
+func LoadDocument(ctx context.Context, blobService blobStorage.Service, kvStore kv.Store, attachment Attachment) (*Document, error) {
+	cacheKey := buildDocumentCacheKey(attachment.DocumentDigest)
+
+	cached, ok, err := kvStore.Get(ctx, cacheKey)
+	if err != nil {
+		return nil, fmt.Errorf("getting cached document: %w", err)
+	}
+	if ok {
+		var document *Document
+		err = json.Unmarshal([]byte(cached), &document)
+		if err == nil {
+			return document, nil
+		}
+
+		logging.Logger.Warnf("ignoring invalid cached document %s: %v", attachment.DocumentDigest, err)
+	}
+
+	body, err := readDocument(ctx, blobService, attachment.DocumentDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := ParseDocument(attachment.MediaType, body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing document %s: %w", attachment.DocumentDigest, err)
+	}
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("encoding document: %w", err)
+	}
+
+	err = kvStore.Set(ctx, cacheKey, string(encoded), kv.WithExpiration(documentCacheExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("caching document: %w", err)
+	}
+
+	return document, nil
+}
+
+func buildDocumentCacheKey(documentDigest string) string {
+	return fmt.Sprintf("supply_chain_document:%s", documentDigest)
+}
+
+func readDocument(ctx context.Context, blobService blobStorage.Service, documentDigest string) ([]byte, error) {
+	reader, err := blobService.OpenBlob(ctx, documentDigest)
+	if err != nil {
+		return nil, fmt.Errorf("opening blob %s: %w", documentDigest, err)
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", documentDigest, err)
+	}
+	if len(body) > maxDocumentSize {
+		return nil, fmt.Errorf("blob %s is larger than %d bytes: %w", documentDigest, maxDocumentSize, ErrInvalidDocument)
+	}
+
+	return body, nil
+}
+
+// ParseDocument parses a document of one of the supported media types. In-toto statements are unwrapped from DSSE
+// envelopes and sigstore bundles, statements whose predicate is not supported yield nil.
+func ParseDocument(mediaType string, body []byte) (*Document, error) {
+	switch mediaType {
+	case MediaTypeSpdx:
+		return parseSpdx(body)
+
+	case MediaTypeCycloneDx:
+		return parseCycloneDx(body)
+
+	case MediaTypeInToto:
+		return parseStatement(body)
+
+	case MediaTypeDsse:
+		payload, err := parseEnvelope(body)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseStatement(payload)
+
+	case MediaTypeSigstoreBundle:
+		var bundle struct {
+			DsseEnvelope json.RawMessage `json:"dsseEnvelope"`
+		}
+		err := json.Unmarshal(body, &bundle)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		if bundle.DsseEnvelope == nil {
+			// bundles of plain signatures do not carry a statement
+			return nil, nil
+		}
+
+		payload, err := parseEnvelope(bundle.DsseEnvelope)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseStatement(payload)
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported media type %s", ErrInvalidDocument, mediaType)
+	}
+}
+
+func parseEnvelope(body []byte) ([]byte, error) {
+	var envelope struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+	}
+	err := json.Unmarshal(body, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	if envelope.PayloadType != MediaTypeInToto {
+		return nil, fmt.Errorf("%w: unsupported envelope payload type %s", ErrInvalidDocument, envelope.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding envelope payload: %v", ErrInvalidDocument, err)
+	}
+
+	return payload, nil
+}
+
+// parseStatement parses an in-toto statement by the type of its predicate, predicate types may carry a version
+// suffix, e.g. https://spdx.dev/Document/v2.3.
+func parseStatement(body []byte) (*Document, error) {
+	var statement struct {
+		PredicateType string          `json:"predicateType"`
+		Predicate     json.RawMessage `json:"predicate"`
+	}
+	err := json.Unmarshal(body, &statement)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	switch {
+	case strings.HasPrefix(statement.PredicateType, predicateTypeSpdx):
+		return parseSpdx(statement.Predicate)
+
+	case strings.HasPrefix(statement.PredicateType, predicateTypeCycloneDx):
+		return parseCycloneDx(statement.Predicate)
+
+	case strings.HasPrefix(statement.PredicateType, predicateTypeSlsa):
+		return parseSlsaProvenance(statement.PredicateType, statement.Predicate)
+
+	default:
+		return nil, nil
+	}
+}
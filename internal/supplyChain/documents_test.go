@@ -0,0 +1,155 @@
+package supplyChain
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DocumentsTestSuite struct {
+	suite.Suite
+}
+
+func TestDocumentsTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(DocumentsTestSuite))
+}
+
+const spdxStatement = `{
+	"_type": "https://in-toto.io/Statement/v0.1",
+	"predicateType": "https://spdx.dev/Document",
+	"predicate": {"spdxVersion": "SPDX-2.3", "packages": [{"name": "openssl", "versionInfo": "3.0.11"}]}
+}`
+
+func envelope(payloadType string, payload string) string {
+	return `{"payloadType": "` + payloadType + `", "payload": "` + payload + `", "signatures": []}`
+}
+
+func (s *DocumentsTestSuite) TestParseEnvelope() {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+		invalid  bool
+	}{
+		{
+			name:     "in-toto statement",
+			body:     envelope(MediaTypeInToto, base64.StdEncoding.EncodeToString([]byte(spdxStatement))),
+			expected: spdxStatement,
+		},
+		{
+			name:    "other payload type",
+			body:    envelope("application/vnd.example+json", base64.StdEncoding.EncodeToString([]byte(spdxStatement))),
+			invalid: true,
+		},
+		{
+			name:    "payload is not base64",
+			body:    envelope(MediaTypeInToto, "not base64!"),
+			invalid: true,
+		},
+		{
+			name:    "invalid json",
+			body:    `{"payloadType": 1}`,
+			invalid: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			payload, err := parseEnvelope([]byte(testCase.body))
+
+			// assert
+			if testCase.invalid {
+				s.ErrorIs(err, ErrInvalidDocument)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(testCase.expected, string(payload))
+		})
+	}
+}
+
+func (s *DocumentsTestSuite) TestParseDocument() {
+	encodedStatement := base64.StdEncoding.EncodeToString([]byte(spdxStatement))
+
+	testCases := []struct {
+		name      string
+		mediaType string
+		body      string
+		// format is empty if the document is expected to be skipped.
+		format  DocumentFormat
+		invalid bool
+	}{
+		{
+			name:      "plain SPDX document",
+			mediaType: MediaTypeSpdx,
+			body:      `{"spdxVersion": "SPDX-2.3", "packages": [{"name": "openssl"}]}`,
+			format:    DocumentFormatSpdx,
+		},
+		{
+			name:      "in-toto statement",
+			mediaType: MediaTypeInToto,
+			body:      spdxStatement,
+			format:    DocumentFormatSpdx,
+		},
+		{
+			name:      "statement with versioned predicate type",
+			mediaType: MediaTypeInToto,
+			body:      `{"predicateType": "https://cyclonedx.org/bom/v1.5", "predicate": {"bomFormat": "CycloneDX"}}`,
+			format:    DocumentFormatCycloneDx,
+		},
+		{
+			name:      "DSSE envelope",
+			mediaType: MediaTypeDsse,
+			body:      envelope(MediaTypeInToto, encodedStatement),
+			format:    DocumentFormatSpdx,
+		},
+		{
+			name:      "sigstore bundle",
+			mediaType: MediaTypeSigstoreBundle,
+			body:      `{"dsseEnvelope": ` + envelope(MediaTypeInToto, encodedStatement) + `}`,
+			format:    DocumentFormatSpdx,
+		},
+		{
+			name:      "sigstore bundle of a signature",
+			mediaType: MediaTypeSigstoreBundle,
+			body:      `{"messageSignature": {}}`,
+		},
+		{
+			name:      "unsupported predicate",
+			mediaType: MediaTypeInToto,
+			body:      `{"predicateType": "https://cosign.sigstore.dev/attestation/vuln/v1", "predicate": {}}`,
+		},
+		{
+			name:      "unsupported media type",
+			mediaType: "application/json",
+			body:      `{}`,
+			invalid:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			document, err := ParseDocument(testCase.mediaType, []byte(testCase.body))
+
+			// assert
+			if testCase.invalid {
+				s.ErrorIs(err, ErrInvalidDocument)
+				return
+			}
+
+			s.Require().NoError(err)
+			if testCase.format == "" {
+				s.Nil(document)
+				return
+			}
+
+			s.Require().NotNil(document)
+			s.Equal(testCase.format, document.Format)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package supplyChain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provenance describes how an image was built, taken from a SLSA provenance attestation.
+type Provenance struct {
+	PredicateType string `json:"predicateType"`
+	BuilderId     string `json:"builderId,omitempty"`
+	BuildType     string `json:"buildType,omitempty"`
+
+	// SourceRepository is the repository the image was built from, e.g. https://github.com/the127/dockyard.
+	SourceRepository string `json:"sourceRepository,omitempty"`
+	SourceRef        string `json:"sourceRef,omitempty"`
+	// SourceRevision is the commit the image was built from.
+	SourceRevision string `json:"sourceRevision,omitempty"`
+	// EntryPoint is the build definition that was run, e.g. the path of a workflow or a Dockerfile.
+	EntryPoint string `json:"entryPoint,omitempty"`
+
+	StartedOn  *time.Time `json:"startedOn,omitempty"`
+	FinishedOn *time.Time `json:"finishedOn,omitempty"`
+
+	// Materials are the inputs of the build, e.g. the source and the base images.
+	Materials []Material `json:"materials,omitempty"`
+}
+
+type Material struct {
+	Uri    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// buildkitMetadata is what buildkit adds to the metadata of its provenance.
+type buildkitMetadata struct {
+	Vcs struct {
+		Source   string `json:"source"`
+		Revision string `json:"revision"`
+	} `json:"vcs"`
+}
+
+// slsaProvenanceV02 covers the v0.1 and v0.2 predicates, which only differ in fields that are not read.
+type slsaProvenanceV02 struct {
+	Builder struct {
+		Id string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource struct {
+			Uri        string            `json:"uri"`
+			Digest     map[string]string `json:"digest"`
+			EntryPoint string            `json:"entryPoint"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildStartedOn  *time.Time        `json:"buildStartedOn"`
+		BuildFinishedOn *time.Time        `json:"buildFinishedOn"`
+		Buildkit        *buildkitMetadata `json:"https://mobyproject.org/buildkit@v1#metadata"`
+	} `json:"metadata"`
+	Materials []Material `json:"materials"`
+}
+
+type slsaProvenanceV1 struct {
+	BuildDefinition struct {
+		BuildType          string `json:"buildType"`
+		ExternalParameters struct {
+			Workflow struct {
+				Repository string `json:"repository"`
+				Ref        string `json:"ref"`
+				Path       string `json:"path"`
+			} `json:"workflow"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []Material `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			Id string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  *time.Time        `json:"startedOn"`
+			FinishedOn *time.Time        `json:"finishedOn"`
+			Buildkit   *buildkitMetadata `json:"https://mobyproject.org/buildkit@v1#metadata"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+func parseSlsaProvenance(predicateType string, body []byte) (*Document, error) {
+	provenance := &Provenance{
+		PredicateType: predicateType,
+	}
+
+	var sourceUri string
+	var sourceDigest map[string]string
+	var buildkit *buildkitMetadata
+
+	if strings.HasPrefix(predicateType, predicateTypeSlsa+"v0.") {
+		var predicate slsaProvenanceV02
+		err := json.Unmarshal(body, &predicate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parsing SLSA provenance: %v", ErrInvalidDocument, err)
+		}
+
+		provenance.BuilderId = predicate.Builder.Id
+		provenance.BuildType = predicate.BuildType
+		provenance.EntryPoint = predicate.Invocation.ConfigSource.EntryPoint
+		provenance.StartedOn = predicate.Metadata.BuildStartedOn
+		provenance.FinishedOn = predicate.Metadata.BuildFinishedOn
+		provenance.Materials = predicate.Materials
+
+		sourceUri = predicate.Invocation.ConfigSource.Uri
+		sourceDigest = predicate.Invocation.ConfigSource.Digest
+		buildkit = predicate.Metadata.Buildkit
+	} else {
+		var predicate slsaProvenanceV1
+		err := json.Unmarshal(body, &predicate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parsing SLSA provenance: %v", ErrInvalidDocument, err)
+		}
+
+		provenance.BuilderId = predicate.RunDetails.Builder.Id
+		provenance.BuildType = predicate.BuildDefinition.BuildType
+		provenance.EntryPoint = predicate.BuildDefinition.ExternalParameters.Workflow.Path
+		provenance.StartedOn = predicate.RunDetails.Metadata.StartedOn
+		provenance.FinishedOn = predicate.RunDetails.Metadata.FinishedOn
+		provenance.Materials = predicate.BuildDefinition.ResolvedDependencies
+
+		buildkit = predicate.RunDetails.Metadata.Buildkit
+	}
+
+	// the source is a git material, unless the builder names it in its own metadata
+	if sourceUri == "" || !strings.HasPrefix(sourceUri, "git+") {
+		for _, material := range provenance.Materials {
+			if strings.HasPrefix(material.Uri, "git+") {
+				sourceUri = material.Uri
+				sourceDigest = material.Digest
+				break
+			}
+		}
+	}
+
+	provenance.SourceRepository, provenance.SourceRef = parseGitUri(sourceUri)
+	provenance.SourceRevision = sourceDigest["gitCommit"]
+	if provenance.SourceRevision == "" {
+		provenance.SourceRevision = sourceDigest["sha1"]
+	}
+
+	if buildkit != nil && buildkit.Vcs.Source != "" {
+		provenance.SourceRepository = buildkit.Vcs.Source
+		provenance.SourceRevision = buildkit.Vcs.Revision
+	}
+
+	return &Document{
+		Kind:       DocumentKindProvenance,
+		Format:     DocumentFormatSlsa,
+		Provenance: provenance,
+	}, nil
+}
+
+// parseGitUri splits a SLSA git URI like git+https://github.com/org/repo@refs/heads/main into the repository and the
+// ref.
+func parseGitUri(uri string) (string, string) {
+	uri = strings.TrimPrefix(uri, "git+")
+
+	// the userinfo of ssh URIs also contains an @, so only the part after the host is searched
+	schemeEnd := strings.Index(uri, "://")
+	if schemeEnd < 0 {
+		return uri, ""
+	}
+
+	hostEnd := strings.Index(uri[schemeEnd+3:], "/")
+	if hostEnd < 0 {
+		return uri, ""
+	}
+
+	pathStart := schemeEnd + 3 + hostEnd
+	repository, ref, _ := strings.Cut(uri[pathStart:], "@")
+	return uri[:pathStart] + repository, ref
+}
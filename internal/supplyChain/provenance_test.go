@@ -0,0 +1,176 @@
+package supplyChain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProvenanceTestSuite struct {
+	suite.Suite
+}
+
+func TestProvenanceTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ProvenanceTestSuite))
+}
+
+func (s *ProvenanceTestSuite) TestParseSlsaProvenance() {
+	startedOn := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	finishedOn := startedOn.Add(5 * time.Minute)
+
+	testCases := []struct {
+		name          string
+		predicateType string
+		body          string
+		expected      Provenance
+		invalid       bool
+	}{
+		{
+			name:          "v0.2 with a git config source",
+			predicateType: "https://slsa.dev/provenance/v0.2",
+			body: `{
+				"builder": {"id": "https://github.com/actions/runner"},
+				"buildType": "https://github.com/Attestations/GitHubActionsWorkflow@v1",
+				"invocation": {
+					"configSource": {
+						"uri": "git+https://github.com/org/app@refs/heads/main",
+						"digest": {"sha1": "abc123"},
+						"entryPoint": ".github/workflows/build.yml"
+					}
+				},
+				"metadata": {"buildStartedOn": "2025-03-01T12:00:00Z", "buildFinishedOn": "2025-03-01T12:05:00Z"},
+				"materials": [{"uri": "pkg:docker/alpine@3.19", "digest": {"sha256": "def456"}}]
+			}`,
+			expected: Provenance{
+				PredicateType:    "https://slsa.dev/provenance/v0.2",
+				BuilderId:        "https://github.com/actions/runner",
+				BuildType:        "https://github.com/Attestations/GitHubActionsWorkflow@v1",
+				SourceRepository: "https://github.com/org/app",
+				SourceRef:        "refs/heads/main",
+				SourceRevision:   "abc123",
+				EntryPoint:       ".github/workflows/build.yml",
+				StartedOn:        &startedOn,
+				FinishedOn:       &finishedOn,
+				Materials:        []Material{{Uri: "pkg:docker/alpine@3.19", Digest: map[string]string{"sha256": "def456"}}},
+			},
+		},
+		{
+			name:          "v0.2 with the source in the materials",
+			predicateType: "https://slsa.dev/provenance/v0.2",
+			body: `{
+				"builder": {"id": "builder"},
+				"invocation": {"configSource": {"entryPoint": "Dockerfile"}},
+				"materials": [
+					{"uri": "pkg:docker/alpine@3.19"},
+					{"uri": "git+ssh://git@github.com/org/app.git@v1.0.0", "digest": {"gitCommit": "abc123"}}
+				]
+			}`,
+			expected: Provenance{
+				PredicateType:    "https://slsa.dev/provenance/v0.2",
+				BuilderId:        "builder",
+				SourceRepository: "ssh://git@github.com/org/app.git",
+				SourceRef:        "v1.0.0",
+				SourceRevision:   "abc123",
+				EntryPoint:       "Dockerfile",
+				Materials: []Material{
+					{Uri: "pkg:docker/alpine@3.19"},
+					{Uri: "git+ssh://git@github.com/org/app.git@v1.0.0", Digest: map[string]string{"gitCommit": "abc123"}},
+				},
+			},
+		},
+		{
+			name:          "v0.2 of buildkit",
+			predicateType: "https://slsa.dev/provenance/v0.2",
+			body: `{
+				"builder": {"id": "https://github.com/org/app/actions/runs/1"},
+				"buildType": "https://mobyproject.org/buildkit@v1",
+				"invocation": {"configSource": {"entryPoint": "Dockerfile"}},
+				"metadata": {
+					"https://mobyproject.org/buildkit@v1#metadata": {
+						"vcs": {"source": "https://github.com/org/app", "revision": "abc123"}
+					}
+				}
+			}`,
+			expected: Provenance{
+				PredicateType:    "https://slsa.dev/provenance/v0.2",
+				BuilderId:        "https://github.com/org/app/actions/runs/1",
+				BuildType:        "https://mobyproject.org/buildkit@v1",
+				SourceRepository: "https://github.com/org/app",
+				SourceRevision:   "abc123",
+				EntryPoint:       "Dockerfile",
+			},
+		},
+		{
+			name:          "v1",
+			predicateType: "https://slsa.dev/provenance/v1",
+			body: `{
+				"buildDefinition": {
+					"buildType": "https://actions.github.io/buildtypes/workflow/v1",
+					"externalParameters": {
+						"workflow": {"repository": "https://github.com/org/app", "ref": "refs/heads/main", "path": ".github/workflows/build.yml"}
+					},
+					"resolvedDependencies": [
+						{"uri": "git+https://github.com/org/app@refs/heads/main", "digest": {"gitCommit": "abc123"}}
+					]
+				},
+				"runDetails": {
+					"builder": {"id": "https://github.com/actions/runner/github-hosted"},
+					"metadata": {"startedOn": "2025-03-01T12:00:00Z", "finishedOn": "2025-03-01T12:05:00Z"}
+				}
+			}`,
+			expected: Provenance{
+				PredicateType:    "https://slsa.dev/provenance/v1",
+				BuilderId:        "https://github.com/actions/runner/github-hosted",
+				BuildType:        "https://actions.github.io/buildtypes/workflow/v1",
+				SourceRepository: "https://github.com/org/app",
+				SourceRef:        "refs/heads/main",
+				SourceRevision:   "abc123",
+				EntryPoint:       ".github/workflows/build.yml",
+				StartedOn:        &startedOn,
+				FinishedOn:       &finishedOn,
+				Materials:        []Material{{Uri: "git+https://github.com/org/app@refs/heads/main", Digest: map[string]string{"gitCommit": "abc123"}}},
+			},
+		},
+		{
+			name:          "v1 without a source",
+			predicateType: "https://slsa.dev/provenance/v1",
+			body:          `{"runDetails": {"builder": {"id": "builder"}}}`,
+			expected: Provenance{
+				PredicateType: "https://slsa.dev/provenance/v1",
+				BuilderId:     "builder",
+			},
+		},
+		{
+			name:          "invalid v0.2",
+			predicateType: "https://slsa.dev/provenance/v0.2",
+			body:          `{"builder": "builder"}`,
+			invalid:       true,
+		},
+		{
+			name:          "invalid v1",
+			predicateType: "https://slsa.dev/provenance/v1",
+			body:          `{"runDetails": {"metadata": {"startedOn": "yesterday"}}}`,
+			invalid:       true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			document, err := parseSlsaProvenance(testCase.predicateType, []byte(testCase.body))
+
+			// assert
+			if testCase.invalid {
+				s.ErrorIs(err, ErrInvalidDocument)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(DocumentKindProvenance, document.Kind)
+			s.Equal(DocumentFormatSlsa, document.Format)
+			s.Equal(testCase.expected, *document.Provenance)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package supplyChain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Package is a package listed in an SBOM.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Purl is the package URL, e.g. pkg:deb/debian/openssl@3.0.11, it is empty if the SBOM does not list one.
+	Purl string `json:"purl,omitempty"`
+	// Type is the package ecosystem taken from the package URL, e.g. deb, npm or golang.
+	Type     string   `json:"type,omitempty"`
+	Licenses []string `json:"licenses,omitempty"`
+}
+
+// Matches reports whether the package has the name, or the package URL if the name is one, and the version. An empty
+// version matches every version.
+func (p Package) Matches(name string, version string) bool {
+	if version != "" && p.Version != version {
+		return false
+	}
+
+	if strings.HasPrefix(name, "pkg:") {
+		purl, _, _ := strings.Cut(p.Purl, "?")
+		purl, _, _ = strings.Cut(purl, "@")
+		return strings.EqualFold(purl, name)
+	}
+
+	return strings.EqualFold(p.Name, name)
+}
+
+// Contains reports whether the name or the package URL contains the text, ignoring case.
+func (p Package) Contains(text string) bool {
+	text = strings.ToLower(text)
+	return strings.Contains(strings.ToLower(p.Name), text) || strings.Contains(strings.ToLower(p.Purl), text)
+}
+
+func newPackage(name string, version string, purl string, licenses []string) Package {
+	return Package{
+		Name:     name,
+		Version:  version,
+		Purl:     purl,
+		Type:     purlType(purl),
+		Licenses: licenses,
+	}
+}
+
+func purlType(purl string) string {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return ""
+	}
+
+	packageType, _, _ := strings.Cut(rest, "/")
+	packageType, err := url.PathUnescape(packageType)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(packageType)
+}
+
+type spdxDocument struct {
+	SpdxVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+		ExternalRefs     []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+func parseSpdx(body []byte) (*Document, error) {
+	var spdx spdxDocument
+	err := json.Unmarshal(body, &spdx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parsing SPDX document: %v", ErrInvalidDocument, err)
+	}
+	if !strings.HasPrefix(spdx.SpdxVersion, "SPDX-") {
+		return nil, fmt.Errorf("%w: not an SPDX document", ErrInvalidDocument)
+	}
+
+	document := &Document{
+		Kind:     DocumentKindSbom,
+		Format:   DocumentFormatSpdx,
+		Packages: make([]Package, 0, len(spdx.Packages)),
+	}
+
+	for _, spdxPackage := range spdx.Packages {
+		purl := ""
+		for _, ref := range spdxPackage.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				purl = ref.ReferenceLocator
+				break
+			}
+		}
+
+		var licenses []string
+		for _, license := range []string{spdxPackage.LicenseDeclared, spdxPackage.LicenseConcluded} {
+			if license != "" && license != "NOASSERTION" && license != "NONE" {
+				licenses = append(licenses, license)
+				break
+			}
+		}
+
+		document.Packages = append(document.Packages, newPackage(spdxPackage.Name, spdxPackage.VersionInfo, purl, licenses))
+	}
+
+	return document, nil
+}
+
+type cycloneDxDocument struct {
+	BomFormat  string               `json:"bomFormat"`
+	Components []cycloneDxComponent `json:"components"`
+}
+
+type cycloneDxComponent struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Purl     string `json:"purl"`
+	Licenses []struct {
+		License struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Components []cycloneDxComponent `json:"components"`
+}
+
+func parseCycloneDx(body []byte) (*Document, error) {
+	var bom cycloneDxDocument
+	err := json.Unmarshal(body, &bom)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parsing CycloneDX document: %v", ErrInvalidDocument, err)
+	}
+	if bom.BomFormat != "CycloneDX" {
+		return nil, fmt.Errorf("%w: not a CycloneDX document", ErrInvalidDocument)
+	}
+
+	document := &Document{
+		Kind:     DocumentKindSbom,
+		Format:   DocumentFormatCycloneDx,
+		Packages: []Package{},
+	}
+
+	// components may be nested, e.g. the modules bundled into a jar
+	var addComponents func(components []cycloneDxComponent)
+	addComponents = func(components []cycloneDxComponent) {
+		for _, component := range components {
+			var licenses []string
+			for _, license := range component.Licenses {
+				switch {
+				case license.Expression != "":
+					licenses = append(licenses, license.Expression)
+				case license.License.Id != "":
+					licenses = append(licenses, license.License.Id)
+				case license.License.Name != "":
+					licenses = append(licenses, license.License.Name)
+				}
+			}
+
+			document.Packages = append(document.Packages, newPackage(component.Name, component.Version, component.Purl, licenses))
+			addComponents(component.Components)
+		}
+	}
+	addComponents(bom.Components)
+
+	return document, nil
+}
@@ -0,0 +1,215 @@
+package supplyChain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SbomTestSuite struct {
+	suite.Suite
+}
+
+func TestSbomTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(SbomTestSuite))
+}
+
+func (s *SbomTestSuite) TestParseSpdx() {
+	testCases := []struct {
+		name     string
+		body     string
+		packages []Package
+		invalid  bool
+	}{
+		{
+			name: "packages",
+			body: `{
+				"spdxVersion": "SPDX-2.3",
+				"packages": [
+					{
+						"name": "openssl",
+						"versionInfo": "3.0.11-1",
+						"licenseDeclared": "Apache-2.0",
+						"externalRefs": [
+							{"referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:openssl:openssl:3.0.11:*:*:*:*:*:*:*"},
+							{"referenceType": "purl", "referenceLocator": "pkg:deb/debian/openssl@3.0.11-1?arch=amd64"}
+						]
+					},
+					{"name": "musl", "versionInfo": "1.2.4"}
+				]
+			}`,
+			packages: []Package{
+				{Name: "openssl", Version: "3.0.11-1", Purl: "pkg:deb/debian/openssl@3.0.11-1?arch=amd64", Type: "deb", Licenses: []string{"Apache-2.0"}},
+				{Name: "musl", Version: "1.2.4"},
+			},
+		},
+		{
+			name: "concluded license if none is declared",
+			body: `{
+				"spdxVersion": "SPDX-2.3",
+				"packages": [
+					{"name": "a", "licenseDeclared": "NOASSERTION", "licenseConcluded": "MIT"},
+					{"name": "b", "licenseDeclared": "NONE", "licenseConcluded": "NOASSERTION"}
+				]
+			}`,
+			packages: []Package{
+				{Name: "a", Licenses: []string{"MIT"}},
+				{Name: "b"},
+			},
+		},
+		{
+			name:     "no packages",
+			body:     `{"spdxVersion": "SPDX-2.2"}`,
+			packages: []Package{},
+		},
+		{
+			name:    "missing version",
+			body:    `{"packages": [{"name": "a"}]}`,
+			invalid: true,
+		},
+		{
+			name:    "invalid json",
+			body:    `{"spdxVersion": `,
+			invalid: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			document, err := parseSpdx([]byte(testCase.body))
+
+			// assert
+			if testCase.invalid {
+				s.ErrorIs(err, ErrInvalidDocument)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(DocumentKindSbom, document.Kind)
+			s.Equal(DocumentFormatSpdx, document.Format)
+			s.Equal(testCase.packages, document.Packages)
+		})
+	}
+}
+
+func (s *SbomTestSuite) TestParseCycloneDx() {
+	testCases := []struct {
+		name     string
+		body     string
+		packages []Package
+		invalid  bool
+	}{
+		{
+			name: "components",
+			body: `{
+				"bomFormat": "CycloneDX",
+				"components": [
+					{
+						"name": "lodash",
+						"version": "4.17.21",
+						"purl": "pkg:npm/lodash@4.17.21",
+						"licenses": [{"license": {"id": "MIT"}}]
+					},
+					{
+						"name": "golang.org/x/net",
+						"version": "v0.17.0",
+						"purl": "pkg:golang/golang.org/x/net@v0.17.0",
+						"licenses": [{"expression": "BSD-3-Clause OR MIT"}, {"license": {"name": "Custom"}}]
+					}
+				]
+			}`,
+			packages: []Package{
+				{Name: "lodash", Version: "4.17.21", Purl: "pkg:npm/lodash@4.17.21", Type: "npm", Licenses: []string{"MIT"}},
+				{Name: "golang.org/x/net", Version: "v0.17.0", Purl: "pkg:golang/golang.org/x/net@v0.17.0", Type: "golang", Licenses: []string{"BSD-3-Clause OR MIT", "Custom"}},
+			},
+		},
+		{
+			name: "nested components",
+			body: `{
+				"bomFormat": "CycloneDX",
+				"components": [
+					{
+						"name": "app",
+						"purl": "pkg:maven/org.example/app@1.0",
+						"components": [{"name": "guava", "version": "32.1.2", "purl": "pkg:maven/com.google.guava/guava@32.1.2"}]
+					},
+					{"name": "other"}
+				]
+			}`,
+			packages: []Package{
+				{Name: "app", Purl: "pkg:maven/org.example/app@1.0", Type: "maven"},
+				{Name: "guava", Version: "32.1.2", Purl: "pkg:maven/com.google.guava/guava@32.1.2", Type: "maven"},
+				{Name: "other"},
+			},
+		},
+		{
+			name:     "no components",
+			body:     `{"bomFormat": "CycloneDX"}`,
+			packages: []Package{},
+		},
+		{
+			name:    "other format",
+			body:    `{"bomFormat": "SPDX", "components": [{"name": "a"}]}`,
+			invalid: true,
+		},
+		{
+			name:    "invalid json",
+			body:    `[]`,
+			invalid: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			document, err := parseCycloneDx([]byte(testCase.body))
+
+			// assert
+			if testCase.invalid {
+				s.ErrorIs(err, ErrInvalidDocument)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(DocumentKindSbom, document.Kind)
+			s.Equal(DocumentFormatCycloneDx, document.Format)
+			s.Equal(testCase.packages, document.Packages)
+		})
+	}
+}
+
+func (s *SbomTestSuite) TestPackageMatches() {
+	openssl := newPackage("openssl", "3.0.11-1", "pkg:deb/debian/openssl@3.0.11-1?arch=amd64", nil)
+
+	testCases := []struct {
+		name     string
+		pkg      Package
+		search   string
+		version  string
+		expected bool
+	}{
+		{name: "name", pkg: openssl, search: "openssl", expected: true},
+		{name: "name ignores case", pkg: openssl, search: "OpenSSL", expected: true},
+		{name: "name and version", pkg: openssl, search: "openssl", version: "3.0.11-1", expected: true},
+		{name: "other version", pkg: openssl, search: "openssl", version: "3.0.11"},
+		{name: "part of the name", pkg: openssl, search: "ssl"},
+		{name: "package url", pkg: openssl, search: "pkg:deb/debian/openssl", expected: true},
+		{name: "package url ignores case", pkg: openssl, search: "pkg:deb/Debian/OpenSSL", expected: true},
+		{name: "package url and version", pkg: openssl, search: "pkg:deb/debian/openssl", version: "3.0.11-1", expected: true},
+		{name: "package url of another ecosystem", pkg: openssl, search: "pkg:apk/alpine/openssl"},
+		{name: "package url with version is not stripped", pkg: openssl, search: "pkg:deb/debian/openssl@3.0.11-1"},
+		{name: "package url of a package without one", pkg: newPackage("openssl", "3.0.11", "", nil), search: "pkg:deb/debian/openssl"},
+	}
+
+	for _, testCase := range testCases {
+		s.Run(testCase.name, func() {
+			// act
+			matches := testCase.pkg.Matches(testCase.search, testCase.version)
+
+			// assert
+			s.Equal(testCase.expected, matches)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package supplyChain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/the127/dockyard/internal/database"
+	"github.com/the127/dockyard/internal/repositories"
+	"github.com/the127/dockyard/internal/services/blobStorage"
+	"github.com/the127/dockyard/internal/services/kv"
+)
+
+// PackageMatch is an image whose SBOM lists a package that was searched for.
+type PackageMatch struct {
+	ManifestDigest string
+	// Tags are the tags of the image and of the indexes listing it.
+	Tags    []string
+	Package Package
+}
+
+// FindPackage searches the SBOMs attached to the images of a repository for a package, see Package.Matches. Every
+// package found is reported, so an image with several versions of a package is listed once per version.
+func FindPackage(ctx context.Context, dbContext database.Context, blobService blobStorage.Service, kvStore kv.Store, repositoryId uuid.UUID, name string, version string) ([]PackageMatch, error) {
+	attachments, parents, err := ListRepositoryAttachments(ctx, dbContext, blobService, kvStore, repositoryId)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	documents, err := LoadDocuments(ctx, blobService, kvStore, attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []PackageMatch
+	for _, document := range documents {
+		for _, documentPackage := range document.Packages {
+			if !documentPackage.Matches(name, version) {
+				continue
+			}
+
+			duplicate := slices.ContainsFunc(matches, func(match PackageMatch) bool {
+				return match.ManifestDigest == document.SubjectDigest && match.Package.Name == documentPackage.Name && match.Package.Version == documentPackage.Version
+			})
+			if !duplicate {
+				matches = append(matches, PackageMatch{
+					ManifestDigest: document.SubjectDigest,
+					Package:        documentPackage,
+				})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	tags, err := getTagsByDigest(ctx, dbContext, repositoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, match := range matches {
+		matchTags := slices.Clone(tags[match.ManifestDigest])
+		for _, parent := range parents[match.ManifestDigest] {
+			matchTags = append(matchTags, tags[parent]...)
+		}
+
+		slices.Sort(matchTags)
+		matches[i].Tags = slices.Compact(matchTags)
+	}
+
+	return matches, nil
+}
+
+func getTagsByDigest(ctx context.Context, dbContext database.Context, repositoryId uuid.UUID) (map[string][]string, error) {
+	manifests, _, err := dbContext.Manifests().List(ctx, repositories.NewManifestFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing manifests: %w", err)
+	}
+
+	digests := make(map[uuid.UUID]string, len(manifests))
+	for _, manifest := range manifests {
+		digests[manifest.GetId()] = manifest.GetDigest()
+	}
+
+	tags, _, err := dbContext.Tags().List(ctx, repositories.NewTagFilter().ByRepositoryId(repositoryId))
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	tagsByDigest := make(map[string][]string)
+	for _, tag := range tags {
+		manifestDigest := digests[tag.GetRepositoryManifestId()]
+		tagsByDigest[manifestDigest] = append(tagsByDigest[manifestDigest], tag.GetName())
+	}
+
+	return tagsByDigest, nil
+}
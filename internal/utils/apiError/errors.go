@@ -22,13 +22,24 @@ var ErrApiTagNotFound = fmt.Errorf("tag not found: %w", ErrApiNotFound)
 var ErrApiBlobNotFound = fmt.Errorf("blob not found: %w", ErrApiNotFound)
 var ErrApiRepositoryBlobNotFound = fmt.Errorf("repository blob not found: %w", ErrApiNotFound)
 var ErrApiFileNotFound = fmt.Errorf("file not found: %w", ErrApiNotFound)
+var ErrApiReferrerNotFound = fmt.Errorf("referrer not found: %w", ErrApiNotFound)
+var ErrApiManifestReferenceNotFound = fmt.Errorf("manifest reference not found: %w", ErrApiNotFound)
 var ErrApiPatNotFound = fmt.Errorf("pat not found: %w", ErrApiNotFound)
+var ErrApiUpstreamNotFound = fmt.Errorf("upstream not found: %w", ErrApiNotFound)
+var ErrApiReplicationRuleNotFound = fmt.Errorf("replication rule not found: %w", ErrApiNotFound)
+var ErrApiReplicationTaskNotFound = fmt.Errorf("replication task not found: %w", ErrApiNotFound)
+var ErrApiTagRuleNotFound = fmt.Errorf("tag rule not found: %w", ErrApiNotFound)
+var ErrApiRetentionPolicyNotFound = fmt.Errorf("retention policy not found: %w", ErrApiNotFound)
+var ErrApiUploadSessionNotFound = fmt.Errorf("upload session not found: %w", ErrApiNotFound)
+var ErrApiStorageUsageNotFound = fmt.Errorf("storage usage not found: %w", ErrApiNotFound)
 
 var ErrApiConflict = errors.New("conflict")
 var ErrApiConcurrentUpdate = fmt.Errorf("concurrent update: %w", ErrApiConflict)
 
 var ErrApiUnauthorized = errors.New("unauthorized")
 
+var ErrApiForbidden = errors.New("forbidden")
+
 func HandleHttpError(w http.ResponseWriter, err error) {
 	var code int
 	var message string
@@ -50,6 +61,10 @@ func HandleHttpError(w http.ResponseWriter, err error) {
 		code = http.StatusUnauthorized
 		message = err.Error()
 
+	case errors.Is(err, ErrApiForbidden):
+		code = http.StatusForbidden
+		message = err.Error()
+
 	case errors.Is(err, ErrApiConflict):
 		code = http.StatusConflict
 		message = err.Error()
@@ -0,0 +1,109 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+// Algorithm identifies a hash function that can be used in digests of the form <algorithm>:<encoded>.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+
+	// Canonical is used whenever the client does not choose an algorithm, e.g. for manifests pushed by tag.
+	Canonical = SHA256
+)
+
+type algorithmInfo struct {
+	newHash   func() hash.Hash
+	hexLength int
+}
+
+var algorithms = map[Algorithm]algorithmInfo{
+	SHA256: {newHash: sha256.New, hexLength: sha256.Size * 2},
+	SHA512: {newHash: sha512.New, hexLength: sha512.Size * 2},
+}
+
+// Algorithms returns all supported algorithms.
+func Algorithms() []Algorithm {
+	return []Algorithm{SHA256, SHA512}
+}
+
+// Available reports whether the algorithm is supported.
+func (a Algorithm) Available() bool {
+	_, ok := algorithms[a]
+	return ok
+}
+
+// New returns a new hash of the algorithm, it panics if the algorithm is not available. The returned hash supports
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler so it can be resumed across requests.
+func (a Algorithm) New() hash.Hash {
+	info, ok := algorithms[a]
+	if !ok {
+		panic(fmt.Errorf("digest algorithm '%s' is not available", a))
+	}
+
+	return info.newHash()
+}
+
+// FromHash formats the current sum of a hash created by New as a digest.
+func (a Algorithm) FromHash(h hash.Hash) string {
+	return fmt.Sprintf("%s:%x", a, h.Sum(nil))
+}
+
+// FromBytes computes the digest of data.
+func (a Algorithm) FromBytes(data []byte) string {
+	h := a.New()
+	h.Write(data)
+	return a.FromHash(h)
+}
+
+// Parse validates the digest and splits it into its algorithm and encoded part. Digests with unknown algorithms or a
+// malformed encoded part are rejected with DIGEST_INVALID.
+func Parse(digest string) (Algorithm, string, error) {
+	algorithmString, encoded, ok := strings.Cut(digest, ":")
+	if !ok {
+		return "", "", newDigestInvalidError(digest, "is not of the form <algorithm>:<encoded>")
+	}
+
+	algorithm := Algorithm(algorithmString)
+	info, ok := algorithms[algorithm]
+	if !ok {
+		return "", "", newDigestInvalidError(digest, fmt.Sprintf("uses the unsupported algorithm '%s'", algorithmString))
+	}
+
+	if len(encoded) != info.hexLength || strings.ToLower(encoded) != encoded {
+		return "", "", newDigestInvalidError(digest, "is not a lowercase hex encoded hash")
+	}
+
+	if _, err := hex.DecodeString(encoded); err != nil {
+		return "", "", newDigestInvalidError(digest, "is not a lowercase hex encoded hash")
+	}
+
+	return algorithm, encoded, nil
+}
+
+// Validate is like Parse, but only reports whether the digest is valid.
+func Validate(digest string) error {
+	_, _, err := Parse(digest)
+	return err
+}
+
+// IsDigest reports whether a manifest reference is a digest rather than a tag. Tags cannot contain colons, so any
+// reference with a colon is treated as digest and has to pass Validate.
+func IsDigest(reference string) bool {
+	return strings.Contains(reference, ":")
+}
+
+func newDigestInvalidError(digest string, reason string) error {
+	return ociError.NewOciError(ociError.DigestInvalid).
+		WithMessage(fmt.Sprintf("digest '%s' %s", digest, reason))
+}
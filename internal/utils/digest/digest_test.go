@@ -0,0 +1,107 @@
+package digest
+
+import (
+	"encoding"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/the127/dockyard/internal/utils/ociError"
+)
+
+type DigestTestSuite struct {
+	suite.Suite
+}
+
+func TestDigestTestSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(DigestTestSuite))
+}
+
+func (s *DigestTestSuite) TestFromBytes_Sha256() {
+	// act
+	actual := SHA256.FromBytes([]byte("hello"))
+
+	// assert
+	s.Equal("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", actual)
+}
+
+func (s *DigestTestSuite) TestFromBytes_Sha512() {
+	// act
+	actual := SHA512.FromBytes([]byte("hello"))
+
+	// assert
+	s.Equal("sha512:9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043", actual)
+}
+
+func (s *DigestTestSuite) TestParse_Valid() {
+	// arrange
+	digest := SHA512.FromBytes([]byte("hello"))
+
+	// act
+	algorithm, encoded, err := Parse(digest)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(SHA512, algorithm)
+	s.Equal(strings.TrimPrefix(digest, "sha512:"), encoded)
+}
+
+func (s *DigestTestSuite) TestParse_UnknownAlgorithm() {
+	// act
+	_, _, err := Parse("md5:5d41402abc4b2a76b9719d911017c592")
+
+	// assert
+	var ociErr *ociError.OciError
+	s.Require().True(errors.As(err, &ociErr))
+	s.Equal(ociError.DigestInvalid, ociErr.Code)
+}
+
+func (s *DigestTestSuite) TestParse_MissingAlgorithm() {
+	// act
+	err := Validate("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+
+	// assert
+	s.Error(err)
+}
+
+func (s *DigestTestSuite) TestParse_WrongLength() {
+	// act
+	err := Validate("sha256:2cf24dba")
+
+	// assert
+	s.Error(err)
+}
+
+func (s *DigestTestSuite) TestParse_UppercaseHex() {
+	// act
+	err := Validate("sha256:2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824")
+
+	// assert
+	s.Error(err)
+}
+
+func (s *DigestTestSuite) TestNew_IsResumable() {
+	for _, algorithm := range Algorithms() {
+		// arrange
+		h := algorithm.New()
+		h.Write([]byte("hel"))
+		state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+		s.Require().NoError(err)
+
+		// act
+		resumed := algorithm.New()
+		err = resumed.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+		s.Require().NoError(err)
+		resumed.Write([]byte("lo"))
+
+		// assert
+		s.Equal(algorithm.FromBytes([]byte("hello")), algorithm.FromHash(resumed))
+	}
+}
+
+func (s *DigestTestSuite) TestIsDigest() {
+	s.True(IsDigest("sha256:abc"))
+	s.False(IsDigest("latest"))
+}
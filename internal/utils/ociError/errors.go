@@ -54,13 +54,17 @@ const (
 
 	// TooManyRequests code-14: too many requests
 	TooManyRequests ErrorCode = "TOO_MANY_REQUESTS"
+
+	// QuotaExceeded is not part of the distribution spec: the push would exceed the storage quota of the project or
+	// tenant
+	QuotaExceeded ErrorCode = "QUOTA_EXCEEDED"
 )
 
 type OciError struct {
-	HttpCode int       `json:"-"`
-	Code     ErrorCode `json:"code"`
-	Message  string    `json:"message,omitempty"`
-	Headers  map[string]string
+	HttpCode int               `json:"-"`
+	Code     ErrorCode         `json:"code"`
+	Message  string            `json:"message,omitempty"`
+	Headers  map[string]string `json:"-"`
 }
 
 func NewOciError(code ErrorCode) *OciError {